@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/news"
+	"invest-manager/internal/scheduler"
+	"invest-manager/internal/screener"
+	"invest-manager/internal/telegram"
+	"invest-manager/internal/telegram/render"
+	"invest-manager/internal/usage"
+	"invest-manager/internal/watchlist"
+	"io"
+	"os"
+	"time"
+)
+
+// analysisTimeout limits a one-shot analysis, including the LLM call
+const analysisTimeout = 2 * time.Minute
+
+// Sources of the LLM response in replay mode
+const (
+	llmRecorded = "recorded"
+	llmLive     = "live"
+)
+
+// runAnalyze runs the analysis once and prints the report or sends it to Telegram.
+// With -replay the portfolio and news come from a recorded report instead of the APIs.
+func runAnalyze(args []string) int {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	opts := addCLIFlags(flags, formatText, formatJSON, formatCSV)
+	monthly := flags.Bool("monthly", false, "Include the monthly reminder")
+	send := flags.Bool("send", false, "Send the report to Telegram instead of printing it")
+	record := flags.String("record", "", "Also save the report as JSON to this file, for replay and backtests")
+	replay := flags.String("replay", "", "Replay the portfolio and news of a recorded report instead of fetching them")
+	llm := flags.String("llm", llmRecorded, "LLM response in replay mode: recorded or live")
+	fixture := flags.String("llm-fixture", "", "Use the LLM response from this text file in replay mode")
+	flags.Parse(args)
+
+	if *replay != "" {
+		if *send {
+			return fail(errors.New("-replay cannot be combined with -send"))
+		}
+		return replayAnalysis(opts, *replay, *llm, *fixture, *monthly, *record)
+	}
+
+	components := []config.Component{config.ComponentBroker, config.ComponentNews, config.ComponentLLM}
+	if *send {
+		components = append(components, config.ComponentTelegram)
+	}
+	env, err := opts.open(components...)
+	if err != nil {
+		return fail(err)
+	}
+	defer env.close()
+
+	investClient, err := invest.NewClient(env.cfg, env.logger)
+	if err != nil {
+		return fail(err)
+	}
+	defer investClient.Close()
+	newsFetcher := news.NewFetcher(env.cfg)
+	watched, err := watchlist.Open(env.cfg.Watchlist.File, env.cfg.Watchlist, investClient, env.logger)
+	if err != nil {
+		return fail(err)
+	}
+	screen := screener.New(env.cfg.Screener, investClient, env.logger)
+	analyzer, err := newAnalyzer(env)
+	if err != nil {
+		return fail(err)
+	}
+
+	if *send {
+		if *record != "" {
+			return fail(errors.New("-record cannot be combined with -send, set schedule.record_dir instead"))
+		}
+		// The bot is only used to send messages here, it does not receive updates
+		telegramBot, err := telegram.NewBot(env.cfg, env.logger, investClient, investClient, investClient, analyzer, newsFetcher,
+			telegram.Options{})
+		if err != nil {
+			return fail(err)
+		}
+		sched := scheduler.NewScheduler(env.cfg, env.logger, investClient, newsFetcher, analyzer, telegramBot,
+			scheduler.Options{Watchlist: watched, Screener: screen})
+		if err := sched.RunNow(*monthly); err != nil {
+			return fail(err)
+		}
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(usage.WithTags(context.Background(), "manual", ""), analysisTimeout)
+	defer cancel()
+	report, err := scheduler.Analyze(ctx, env.logger, investClient, newsFetcher, watched, screen, analyzer, *monthly)
+	if err != nil {
+		return fail(err)
+	}
+	return writeReport(env, report, *record)
+}
+
+// replayAnalysis runs the analysis on a recorded report, with the LLM response
+// from the recording, a fixture file or the live LLM
+func replayAnalysis(opts *cliOptions, path, llm, fixture string, monthly bool, record string) int {
+	recorded, err := scheduler.LoadReport(path)
+	if err != nil {
+		return fail(err)
+	}
+
+	// A recorded or fixture response needs no integration at all
+	components := []config.Component{config.ComponentCore}
+	var response string
+	switch {
+	case fixture != "":
+		data, err := os.ReadFile(fixture)
+		if err != nil {
+			return fail(fmt.Errorf("failed to read LLM fixture: %w", err))
+		}
+		response = string(data)
+	case llm == llmLive:
+		components = append(components, config.ComponentLLM)
+	case llm == llmRecorded:
+		if recorded.Analysis == nil || recorded.Analysis.RawText == "" {
+			return fail(fmt.Errorf("%s has no recorded LLM response, use -llm live or -llm-fixture", path))
+		}
+		response = recorded.Analysis.RawText
+	default:
+		return fail(fmt.Errorf("unknown -llm %q, use %s or %s", llm, llmRecorded, llmLive))
+	}
+
+	env, err := opts.open(components...)
+	if err != nil {
+		return fail(err)
+	}
+	defer env.close()
+
+	// The monthly reminder follows the recording unless asked for explicitly
+	if recorded.Analysis != nil && recorded.Analysis.IsMonthlyReminder {
+		monthly = true
+	}
+
+	analyzer, err := newAnalyzer(env)
+	if err != nil {
+		return fail(err)
+	}
+	ctx, cancel := context.WithTimeout(usage.WithTags(context.Background(), "replay", ""), analysisTimeout)
+	defer cancel()
+	report, err := scheduler.Replay(ctx, env.logger, recorded, analyzer, response, monthly)
+	if err != nil {
+		return fail(err)
+	}
+	return writeReport(env, report, record)
+}
+
+// newAnalyzer creates an analyzer that counts its LLM usage in the usage file,
+// so one-shot runs are part of the budget too
+func newAnalyzer(env *cliEnv) (*analysis.Analyzer, error) {
+	ledger, err := usage.Open(env.cfg.OpenAI.UsageFile, env.logger)
+	if err != nil {
+		return nil, err
+	}
+	analyzer := analysis.NewAnalyzer(env.cfg)
+	analyzer.SetLedger(ledger)
+	return analyzer, nil
+}
+
+// writeReport prints the report in the selected format and records it if asked to
+func writeReport(env *cliEnv, report *scheduler.Report, record string) int {
+	if record != "" {
+		if err := scheduler.SaveReport(record, report); err != nil {
+			return fail(err)
+		}
+	}
+
+	t := &table{header: []string{"section", "ticker", "name", "action", "reason"}}
+	for _, r := range report.Analysis.Recommendations {
+		t.add("recommendation", r.Ticker, r.Name, r.Action, r.Reason)
+	}
+	for _, r := range report.Analysis.Opportunities {
+		t.add("opportunity", r.Ticker, r.Name, r.Action, r.Reason)
+	}
+
+	err := env.write(report, t, func(w io.Writer) error {
+		message := telegram.BuildReport(report.Portfolio, report.Analysis, report.Articles, nil, nil)
+		_, err := fmt.Fprintln(w, message.Render(render.Plain))
+		return err
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// runPromptCommand dispatches "invest-manager prompt <subcommand>"
+func runPromptCommand(args []string) int {
+	if len(args) == 0 || args[0] != "render" {
+		fmt.Fprintln(os.Stderr, "Usage: invest-manager prompt render [-monthly] [-format text|json]")
+		return 2
+	}
+	return runPromptRender(args[1:])
+}
+
+// runPromptRender prints the prompt the analyzer would send for the current portfolio and news
+func runPromptRender(args []string) int {
+	flags := flag.NewFlagSet("prompt render", flag.ExitOnError)
+	opts := addCLIFlags(flags, formatText, formatJSON)
+	monthly := flags.Bool("monthly", false, "Include the monthly reminder")
+	flags.Parse(args)
+
+	// The LLM is not called, so its settings are not required
+	env, err := opts.open(config.ComponentBroker, config.ComponentNews)
+	if err != nil {
+		return fail(err)
+	}
+	defer env.close()
+
+	investClient, err := invest.NewClient(env.cfg, env.logger)
+	if err != nil {
+		return fail(err)
+	}
+	defer investClient.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	portfolio, err := investClient.GetPortfolio(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	articles, err := news.NewFetcher(env.cfg).FetchMarketNews()
+	if err != nil {
+		env.logger.Warn("Failed to fetch news, rendering without news", "error", err)
+		articles = []news.Article{}
+	}
+
+	prompt := analysis.NewAnalyzer(env.cfg).BuildPrompt(portfolio, articles, *monthly)
+	err = env.write(prompt, nil, func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "### SYSTEM (model %s)\n\n%s\n\n### USER\n\n%s\n", prompt.Model, prompt.System, prompt.User)
+		return err
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
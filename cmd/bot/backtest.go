@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/scheduler"
+	"os"
+	"sort"
+	"time"
+)
+
+// backtestResult is the outcome of one recommendation after the horizon
+type backtestResult struct {
+	Date       time.Time `json:"date"`
+	Ticker     string    `json:"ticker"`
+	Action     string    `json:"action"`
+	StartPrice float64   `json:"start_price"`
+	EndPrice   float64   `json:"end_price"`
+	EndDate    time.Time `json:"end_date"`
+	ReturnPct  float64   `json:"return_pct"`
+	Hit        *bool     `json:"hit,omitempty"` // whether the price moved as advised; unset for HOLD
+}
+
+// backtestSummary aggregates the results of one action
+type backtestSummary struct {
+	Action       string  `json:"action"`
+	Count        int     `json:"count"`
+	AvgReturnPct float64 `json:"avg_return_pct"`
+	HitRatePct   float64 `json:"hit_rate_pct,omitempty"`
+}
+
+// runBacktest checks recommendations from saved reports against the prices that followed.
+// Reports are the JSON output of "invest-manager analyze -format json".
+func runBacktest(args []string) int {
+	flags := flag.NewFlagSet("backtest", flag.ExitOnError)
+	opts := addCLIFlags(flags, formatText, formatJSON, formatCSV)
+	horizon := flags.Int("horizon", 30, "Days after the report to evaluate the price at, 0 for today")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: invest-manager backtest [flags] report.json...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var reports []*scheduler.Report
+	for _, path := range flags.Args() {
+		report, err := scheduler.LoadReport(path)
+		if err != nil {
+			return fail(err)
+		}
+		if report.Analysis == nil {
+			return fail(fmt.Errorf("%s has no analysis", path))
+		}
+		reports = append(reports, report)
+	}
+
+	env, err := opts.open(config.ComponentBroker)
+	if err != nil {
+		return fail(err)
+	}
+	defer env.close()
+
+	client, err := invest.NewClient(env.cfg, env.logger)
+	if err != nil {
+		return fail(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+
+	var results []backtestResult
+	for _, report := range reports {
+		end := time.Now()
+		if *horizon > 0 && report.Time.AddDate(0, 0, *horizon).Before(end) {
+			end = report.Time.AddDate(0, 0, *horizon)
+		}
+
+		for _, rec := range report.Analysis.Recommendations {
+			// Only held positions have a known price at the time of the report
+			pos := report.Portfolio.FindPosition(rec.Ticker)
+			if pos == nil || pos.CurrentPrice == 0 {
+				continue
+			}
+			endPrice, endDate, err := closeBefore(ctx, client, pos.FIGI, end)
+			if err != nil {
+				env.logger.Warn("Skipping recommendation", "ticker", rec.Ticker, "date", report.Time.Format("2006-01-02"), "error", err)
+				continue
+			}
+			results = append(results, evaluateRecommendation(report.Time, rec.Ticker, rec.Action, pos.CurrentPrice, endPrice, endDate))
+		}
+	}
+
+	summary := summarizeBacktest(results)
+
+	t := &table{header: []string{"date", "ticker", "action", "start_price", "end_date", "end_price", "return_pct", "hit"}}
+	for _, r := range results {
+		hit := "-"
+		if r.Hit != nil {
+			hit = fmt.Sprint(*r.Hit)
+		}
+		t.add(r.Date.Format("2006-01-02"), r.Ticker, r.Action, formatFloat(r.StartPrice, 2),
+			r.EndDate.Format("2006-01-02"), formatFloat(r.EndPrice, 2), formatFloat(r.ReturnPct, 2), hit)
+	}
+	t.footer = append(t.footer, "")
+	for _, s := range summary {
+		line := fmt.Sprintf("%-5s %d recommendations, average return %+.2f%%", s.Action, s.Count, s.AvgReturnPct)
+		if s.Action == "BUY" || s.Action == "SELL" {
+			line += fmt.Sprintf(", hit rate %.0f%%", s.HitRatePct)
+		}
+		t.footer = append(t.footer, line)
+	}
+
+	data := struct {
+		Results []backtestResult  `json:"results"`
+		Summary []backtestSummary `json:"summary"`
+	}{results, summary}
+	if err := env.write(data, t, nil); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// closeBefore returns the last daily close at or before the given time
+func closeBefore(ctx context.Context, client *invest.Client, figi string, end time.Time) (float64, time.Time, error) {
+	// A week back covers weekends and holidays
+	candles, err := client.GetDailyCandles(ctx, figi, end.AddDate(0, 0, -7), end)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if len(candles) == 0 {
+		return 0, time.Time{}, fmt.Errorf("no prices before %s", end.Format("2006-01-02"))
+	}
+	last := candles[len(candles)-1]
+	return last.Close, last.Time, nil
+}
+
+// evaluateRecommendation computes the return since the report and whether the advice was right
+func evaluateRecommendation(date time.Time, ticker, action string, start, end float64, endDate time.Time) backtestResult {
+	r := backtestResult{
+		Date:       date,
+		Ticker:     ticker,
+		Action:     action,
+		StartPrice: start,
+		EndPrice:   end,
+		EndDate:    endDate,
+		ReturnPct:  (end - start) / start * 100,
+	}
+	switch action {
+	case "BUY":
+		hit := end > start
+		r.Hit = &hit
+	case "SELL":
+		hit := end < start
+		r.Hit = &hit
+	}
+	return r
+}
+
+// summarizeBacktest aggregates results by action
+func summarizeBacktest(results []backtestResult) []backtestSummary {
+	byAction := make(map[string]*backtestSummary)
+	hits := make(map[string]int)
+	for _, r := range results {
+		s, ok := byAction[r.Action]
+		if !ok {
+			s = &backtestSummary{Action: r.Action}
+			byAction[r.Action] = s
+		}
+		s.Count++
+		s.AvgReturnPct += r.ReturnPct
+		if r.Hit != nil && *r.Hit {
+			hits[r.Action]++
+		}
+	}
+
+	summary := make([]backtestSummary, 0, len(byAction))
+	for action, s := range byAction {
+		s.AvgReturnPct /= float64(s.Count)
+		if action == "BUY" || action == "SELL" {
+			s.HitRatePct = float64(hits[action]) / float64(s.Count) * 100
+		}
+		summary = append(summary, *s)
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Action < summary[j].Action })
+	return summary
+}
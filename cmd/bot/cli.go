@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/secrets"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats of one-shot commands
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// cliOptions are the flags shared by one-shot commands
+type cliOptions struct {
+	configPath *string
+	format     *string
+	output     *string
+	formats    []string
+}
+
+// addCLIFlags registers the shared flags; formats lists the output formats the command supports
+func addCLIFlags(flags *flag.FlagSet, formats ...string) *cliOptions {
+	return &cliOptions{
+		configPath: flags.String("config", "", "Path to the YAML config file (default: $"+config.ConfigFileEnv+")"),
+		format:     flags.String("format", formatText, "Output format: "+strings.Join(formats, ", ")),
+		output:     flags.String("o", "", "Write the output to a file instead of stdout"),
+		formats:    formats,
+	}
+}
+
+// cliEnv is what a one-shot command works with: the configuration, a logger on stderr,
+// so stdout only carries the result, and the output destination
+type cliEnv struct {
+	cfg    *config.Config
+	logger *slog.Logger
+	format string
+	out    io.Writer
+	file   *os.File
+}
+
+// maskAccountIDs makes the redactor shorten account IDs to their last characters
+// wherever they appear in the output, e.g. in error messages of the broker API
+func maskAccountIDs(redactor *secrets.Redactor, ids ...string) {
+	for _, id := range ids {
+		if id != "" {
+			redactor.AddMasked(id, logging.MaskAccountID(id))
+		}
+	}
+}
+
+// open loads the configuration, requiring only the settings of the given components,
+// and opens the output
+func (o *cliOptions) open(components ...config.Component) (*cliEnv, error) {
+	if !slices.Contains(o.formats, *o.format) {
+		return nil, fmt.Errorf("unsupported format %q, use one of: %s", *o.format, strings.Join(o.formats, ", "))
+	}
+
+	// Secrets are masked once the configuration is loaded
+	redactor := secrets.NewRedactor(os.Stderr)
+
+	cfg, err := config.Load(*o.configPath, components...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	redactor.Add(cfg.SecretValues()...)
+	maskAccountIDs(redactor, cfg.Tinkoff.AccountID)
+	logger := logging.New(redactor, logging.LevelVar(cfg.LogLevel), cfg.LogFormat)
+
+	env := &cliEnv{cfg: cfg, logger: logger, format: *o.format, out: os.Stdout}
+	if *o.output != "" {
+		file, err := os.Create(*o.output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+		env.file = file
+		env.out = file
+	}
+	return env, nil
+}
+
+// close flushes the output file, if any
+func (e *cliEnv) close() error {
+	if e.file == nil {
+		return nil
+	}
+	return e.file.Close()
+}
+
+// table is tabular output, printed as aligned columns or CSV
+type table struct {
+	header []string
+	rows   [][]string
+	footer []string // lines printed after the table in text format only
+}
+
+// add appends a row
+func (t *table) add(cols ...string) {
+	t.rows = append(t.rows, cols)
+}
+
+// write prints the result in the selected format: data as JSON, the table as text or CSV.
+// If text is set, it replaces the table in text format.
+func (e *cliEnv) write(data any, t *table, text func(w io.Writer) error) error {
+	switch e.format {
+	case formatJSON:
+		encoder := json.NewEncoder(e.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	case formatCSV:
+		if t == nil {
+			return errors.New("CSV output is not supported by this command")
+		}
+		w := csv.NewWriter(e.out)
+		w.Write(t.header)
+		w.WriteAll(t.rows)
+		return w.Error()
+	default:
+		if text != nil {
+			return text(e.out)
+		}
+		return writeTableText(e.out, t)
+	}
+}
+
+// writeTableText prints a table as aligned columns
+func writeTableText(out io.Writer, t *table) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.header, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, line := range t.footer {
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
+
+// fail prints an error of a one-shot command and returns the exit code
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	return 1
+}
+
+// formatFloat prints a number with fixed precision for tables
+func formatFloat(v float64, precision int) string {
+	return fmt.Sprintf("%.*f", precision, v)
+}
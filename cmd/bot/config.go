@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"invest-manager/internal/config"
+	"os"
+)
+
+// runConfigCommand dispatches "invest-manager config <subcommand>"
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "Usage: invest-manager config check [-config path]")
+		return 2
+	}
+	return runConfigCheck(args[1:])
+}
+
+// runConfigCheck loads the configuration and reports every problem found in it
+func runConfigCheck(args []string) int {
+	flags := flag.NewFlagSet("config check", flag.ExitOnError)
+	path := flags.String("config", "", "Path to the YAML config file (default: $"+config.ConfigFileEnv+")")
+	flags.Parse(args)
+
+	cfg, err := config.Parse(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	if err := cfg.Validate(); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			fmt.Fprintf(os.Stderr, "Configuration has %d problem(s):\n", len(validationErr.Problems))
+			for _, p := range validationErr.Problems {
+				fmt.Fprintf(os.Stderr, "  %s: %s\n", p.Path, p.Message)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+
+	fmt.Println("Configuration is valid")
+	fmt.Printf("  telegram mode: %s\n", cfg.Telegram.Mode)
+	fmt.Printf("  LLM analysis:  %s\n", enabledLabel(cfg.OpenAI.Enabled, cfg.OpenAI.Model))
+	fmt.Printf("  news:          %s\n", enabledLabel(cfg.News.Enabled, "NewsAPI"))
+	fmt.Printf("  schedule:      %s\n", cfg.Schedule.Daily)
+	if cfg.Vault.File != "" {
+		fmt.Printf("  vault:         %s\n", cfg.Vault.File)
+	}
+	fmt.Printf("  timezone:      %s\n", cfg.Timezone)
+	fmt.Printf("  log level:     %s\n", cfg.LogLevel)
+	return 0
+}
+
+// enabledLabel describes whether an optional integration is on
+func enabledLabel(enabled bool, detail string) string {
+	if !enabled {
+		return "disabled"
+	}
+	return "enabled (" + detail + ")"
+}
@@ -1,109 +1,97 @@
 package main
 
 import (
-	"context"
-	"flag"
-	"invest-manager/internal/analysis"
-	"invest-manager/internal/config"
-	"invest-manager/internal/invest"
-	"invest-manager/internal/news"
-	"invest-manager/internal/scheduler"
-	"invest-manager/internal/telegram"
-	"log"
+	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"strings"
 )
 
-func main() {
-	// Parse command line flags
-	runOnce := flag.Bool("run-once", false, "Run analysis once and exit")
-	monthlyReminder := flag.Bool("monthly", false, "Include monthly reminder (only with -run-once)")
-	flag.Parse()
-
-	// Initialize logger
-	logger := log.New(os.Stdout, "[INVEST-BOT] ", log.LstdFlags)
-	logger.Println("Starting Invest Manager Bot")
+// command is a subcommand of the invest-manager binary
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists the subcommands in the order they are shown in the usage
+var commands = []command{
+	{"serve", "run the Telegram bot and the daily schedule (default)", runServe},
+	{"analyze", "run the analysis once and print it or send it to Telegram", runAnalyze},
+	{"portfolio", "show positions with weight and P&L", runPortfolio},
+	{"news", "show fresh market news", runNews},
+	{"history", "show the daily portfolio value over a period", runHistory},
+	{"backtest", "check saved recommendations against later prices", runBacktest},
+	{"prompt", "render the LLM prompt without calling the LLM", runPromptCommand},
+	{"config", "validate the configuration", runConfigCommand},
+	{"secrets", "manage the encrypted secret vault", runSecretsCommand},
+	{"sandbox", "serve a scenario as a local stand-in for the broker API", runSandbox},
+}
 
-	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		logger.Fatalf("Failed to load configuration: %v", err)
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches to a subcommand and returns the exit code
+func run(args []string) int {
+	// Without a subcommand the bot is started, as before subcommands existed
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runLegacy(args)
 	}
 
-	// Set up context with cancellation for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Initialize components
-	investClient, err := invest.NewClient(cfg, logger)
-	if err != nil {
-		logger.Fatalf("Failed to initialize Tinkoff Invest client: %v", err)
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
 	}
-	defer investClient.Close()
-
-	newsFetcher := news.NewFetcher(cfg)
-	analyzer := analysis.NewAnalyzer(cfg)
 
-	telegramBot, err := telegram.NewBot(cfg, logger, investClient, analyzer, newsFetcher)
-	if err != nil {
-		logger.Fatalf("Failed to initialize Telegram bot: %v", err)
+	if args[0] != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
 	}
-
-	// Run analysis once if requested
-	if *runOnce {
-		logger.Println("Running one-time analysis")
-		
-		// Set up scheduler for one-time run
-		sched := scheduler.NewScheduler(cfg, logger, investClient, newsFetcher, analyzer, telegramBot)
-		
-		// Run portfolio analysis
-		if err := sched.RunNow(*monthlyReminder); err != nil {
-			logger.Fatalf("Error running portfolio analysis: %v", err)
+	printUsage()
+	if args[0] == "help" {
+		return 0
+	}
+	return 2
+}
+
+// runLegacy maps the old -run-once and -monthly flags to the analyze command
+func runLegacy(args []string) int {
+	var rest []string
+	runOnce, monthly := false, false
+	for _, arg := range args {
+		switch strings.TrimLeft(arg, "-") {
+		case "run-once":
+			runOnce = true
+		case "monthly":
+			monthly = true
+		case "h", "help":
+			printUsage()
+			return 0
+		default:
+			rest = append(rest, arg)
 		}
-		
-		logger.Println("One-time analysis completed, exiting")
-		return
 	}
 
-	// Start the Telegram bot
-	telegramBot.Start()
-	defer telegramBot.Stop()
-
-	// Initialize scheduler
-	sched := scheduler.NewScheduler(cfg, logger, investClient, newsFetcher, analyzer, telegramBot)
-	if err := sched.Start(); err != nil {
-		logger.Fatalf("Failed to start scheduler: %v", err)
+	if !runOnce {
+		return runServe(rest)
 	}
-	defer sched.Stop()
 
-	// Send startup notification
-	if err := telegramBot.SendMessage("🤖 Invest Manager Bot запущен и готов к работе.\nОтправьте /help для списка доступных команд."); err != nil {
-		logger.Printf("Failed to send startup notification: %v", err)
+	fmt.Fprintln(os.Stderr, "Warning: -run-once is deprecated, use \"invest-manager analyze -send\" instead")
+	rest = append(rest, "-send")
+	if monthly {
+		rest = append(rest, "-monthly")
 	}
-
-	// Wait for shutdown signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// Block until signal received
-	sig := <-sigChan
-	logger.Printf("Received signal %v, shutting down...", sig)
-
-	// Give services time to clean up
-	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 10*time.Second)
-	defer shutdownCancel()
-
-	// Wait for shutdown to complete or timeout
-	select {
-	case <-shutdownCtx.Done():
-		if shutdownCtx.Err() == context.DeadlineExceeded {
-			logger.Println("Shutdown timed out, forcing exit")
-		}
-	case <-time.After(time.Second):
-		// Add a brief delay to allow logging to finish
+	return runAnalyze(rest)
+}
+
+// printUsage lists the subcommands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: invest-manager <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
 	}
-
-	logger.Println("Invest Manager Bot stopped")
-} 
\ No newline at end of file
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run \"invest-manager <command> -h\" for the flags of a command.")
+}
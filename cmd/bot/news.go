@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"invest-manager/internal/config"
+	"invest-manager/internal/news"
+)
+
+// runNews prints fresh news for the configured or given query
+func runNews(args []string) int {
+	flags := flag.NewFlagSet("news", flag.ExitOnError)
+	opts := addCLIFlags(flags, formatText, formatJSON, formatCSV)
+	query := flags.String("query", "", "Search query (default: news.query)")
+	limit := flags.Int("limit", 0, "Number of articles (default: news.limit)")
+	flags.Parse(args)
+
+	env, err := opts.open(config.ComponentNews)
+	if err != nil {
+		return fail(err)
+	}
+	defer env.close()
+
+	fetcher := news.NewFetcher(env.cfg)
+	if !fetcher.Enabled() {
+		return fail(errors.New("news is disabled in the configuration"))
+	}
+
+	if *query == "" {
+		*query = env.cfg.News.Query
+	}
+	if *limit == 0 {
+		*limit = env.cfg.News.Limit
+	}
+	articles, err := fetcher.FetchNews(*query, *limit)
+	if err != nil {
+		return fail(err)
+	}
+
+	t := &table{header: []string{"published", "source", "title", "url"}}
+	for _, a := range articles {
+		t.add(a.PublishedAt.Format("2006-01-02 15:04"), a.Source.Name, a.Title, a.URL)
+	}
+
+	if err := env.write(articles, t, nil); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"time"
+)
+
+// brokerTimeout limits broker requests of one-shot commands
+const brokerTimeout = 2 * time.Minute
+
+// runPortfolio prints the positions of the selected account
+func runPortfolio(args []string) int {
+	flags := flag.NewFlagSet("portfolio", flag.ExitOnError)
+	opts := addCLIFlags(flags, formatText, formatJSON, formatCSV)
+	account := flags.String("account", "", "Account ID (default: tinkoff.account_id or the first open account)")
+	flags.Parse(args)
+
+	env, err := opts.open(config.ComponentBroker)
+	if err != nil {
+		return fail(err)
+	}
+	defer env.close()
+
+	client, err := invest.NewClient(env.cfg, env.logger)
+	if err != nil {
+		return fail(err)
+	}
+	defer client.Close()
+	if *account != "" {
+		client.SetAccount(*account)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	portfolio, err := client.GetPortfolio(ctx)
+	if err != nil {
+		return fail(err)
+	}
+
+	t := &table{header: []string{"ticker", "name", "type", "quantity", "average_price", "current_price", "value", "weight_pct", "pnl", "pnl_pct", "currency", "asset_class", "sector", "country"}}
+	for _, pos := range portfolio.Positions {
+		t.add(pos.Ticker, pos.Name, pos.InstrumentType,
+			formatFloat(pos.Quantity, 2), formatFloat(pos.AveragePrice, 2), formatFloat(pos.CurrentPrice, 2),
+			formatFloat(pos.Value(), 2), formatFloat(portfolio.Weight(pos), 2),
+			formatFloat(pos.ExpectedYield, 2), formatFloat(pos.YieldPercent(), 2), pos.Currency,
+			pos.AssetClass, pos.Sector, pos.Country)
+	}
+	t.footer = []string{
+		"",
+		fmt.Sprintf("Total: %.2f %s, expected yield: %.2f %s", portfolio.TotalAmount, portfolio.Currency, portfolio.ExpectedYield, portfolio.Currency),
+	}
+
+	if err := env.write(portfolio, t, nil); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// runHistory prints the daily value of the current positions over a period
+func runHistory(args []string) int {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	opts := addCLIFlags(flags, formatText, formatJSON, formatCSV)
+	days := flags.Int("days", 90, "Number of days to show")
+	account := flags.String("account", "", "Account ID (default: tinkoff.account_id or the first open account)")
+	flags.Parse(args)
+
+	if *days <= 0 {
+		return fail(fmt.Errorf("-days must be positive"))
+	}
+
+	env, err := opts.open(config.ComponentBroker)
+	if err != nil {
+		return fail(err)
+	}
+	defer env.close()
+
+	client, err := invest.NewClient(env.cfg, env.logger)
+	if err != nil {
+		return fail(err)
+	}
+	defer client.Close()
+	if *account != "" {
+		client.SetAccount(*account)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	portfolio, err := client.GetPortfolio(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	points, err := client.GetPortfolioHistory(ctx, portfolio, time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		return fail(err)
+	}
+
+	t := &table{header: []string{"date", "value"}}
+	for _, p := range points {
+		t.add(p.Time.Format("2006-01-02"), formatFloat(p.Value, 2))
+	}
+	if len(points) > 1 {
+		first, last := points[0].Value, points[len(points)-1].Value
+		change := last - first
+		t.footer = []string{"", fmt.Sprintf("Change: %+.2f %s (%+.2f%%)", change, portfolio.Currency, change/first*100)}
+	}
+	// Quantities are taken as they are now, see GetPortfolioHistory
+	t.footer = append(t.footer, "Values assume the current quantities over the whole period.")
+
+	if err := env.write(points, t, nil); err != nil {
+		return fail(err)
+	}
+	return 0
+}
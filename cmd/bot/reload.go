@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/contributions"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/models"
+	"invest-manager/internal/news"
+	"invest-manager/internal/paper"
+	"invest-manager/internal/performance"
+	"invest-manager/internal/scheduler"
+	"invest-manager/internal/screener"
+	"invest-manager/internal/secrets"
+	"invest-manager/internal/stops"
+	"invest-manager/internal/tax"
+	"invest-manager/internal/telegram"
+	"invest-manager/internal/watchlist"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// configPollInterval is how often config files are checked for changes
+const configPollInterval = 2 * time.Second
+
+// reloader applies a changed configuration to the running components
+type reloader struct {
+	path          string
+	store         *config.Store
+	logger        *slog.Logger
+	level         *slog.LevelVar
+	redactor      *secrets.Redactor
+	investor      *invest.Client
+	analyzer      *analysis.Analyzer
+	newsFetcher   *news.Fetcher
+	bot           *telegram.Bot
+	scheduler     *scheduler.Scheduler
+	paper         *paper.Account
+	watchlist     *watchlist.List
+	screener      *screener.Screener
+	stops         *stops.Monitor
+	performance   *performance.Tracker
+	tax           *tax.Estimator
+	contributions *contributions.Plan
+	models        *models.Comparer
+}
+
+// reload loads and validates the configuration again and swaps it in.
+// An invalid configuration is rejected and the current one stays in effect.
+func (r *reloader) reload(trigger string) {
+	r.logger.Info("Reloading configuration", "trigger", trigger)
+
+	cfg, err := config.Load(r.path)
+	if err != nil {
+		r.reject(err)
+		return
+	}
+
+	changes := config.Diff(r.store.Current(), cfg)
+	if len(changes) == 0 {
+		r.logger.Info("Configuration unchanged")
+		return
+	}
+
+	// New secrets must be masked before anything can log them
+	r.redactor.Add(cfg.SecretValues()...)
+	maskAccountIDs(r.redactor, cfg.Tinkoff.AccountID)
+
+	// Re-register cron jobs first: it is the only step that can fail
+	if err := r.scheduler.Reload(cfg); err != nil {
+		r.reject(err)
+		return
+	}
+	r.store.Swap(cfg)
+	if level, err := logging.ParseLevel(cfg.LogLevel); err == nil {
+		r.level.Set(level)
+	}
+	r.investor.Reload(cfg)
+	r.analyzer.Reload(cfg)
+	r.newsFetcher.Reload(cfg)
+	r.bot.Reload(cfg)
+	r.paper.Reload(cfg.Paper)
+	r.watchlist.Reload(cfg.Watchlist)
+	r.screener.Reload(cfg.Screener)
+	r.stops.Reload(cfg.Stops)
+	r.performance.Reload(cfg.Performance)
+	r.tax.Reload(cfg.Tax)
+	r.contributions.Reload(cfg.Contributions)
+	r.models.Reload(cfg.Models)
+
+	// Report what changed
+	var sb strings.Builder
+	sb.WriteString("🔄 Конфигурация обновлена:\n")
+	for _, change := range changes {
+		r.logger.Info("Config changed", "change", change.String())
+		sb.WriteString("• " + change.String() + "\n")
+	}
+	if err := r.bot.SendMessage(sb.String()); err != nil {
+		r.logger.Warn("Failed to send reload notification", "error", err)
+	}
+}
+
+// reject logs and reports a configuration that could not be applied
+func (r *reloader) reject(err error) {
+	r.logger.Error("New configuration rejected, keeping the current one", "error", err)
+	msg := fmt.Sprintf("⚠️ Новая конфигурация отклонена, продолжаю работать с текущей.\n\n%s", r.redactor.Redact(err.Error()))
+	if sendErr := r.bot.SendMessage(msg); sendErr != nil {
+		r.logger.Warn("Failed to send reload notification", "error", sendErr)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/sandbox"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runSandbox serves a scenario as a local stand-in for the Tinkoff Invest API until interrupted
+func runSandbox(args []string) int {
+	flags := flag.NewFlagSet("sandbox", flag.ExitOnError)
+	scenarioPath := flags.String("scenario", "", "Path to the scenario file (required)")
+	listen := flags.String("listen", "localhost:8443", "Address to listen on")
+	certPath := flags.String("cert", "sandbox-cert.pem", "Where to write the certificate clients have to trust")
+	flags.Parse(args)
+
+	if *scenarioPath == "" {
+		return fail(errors.New("-scenario is required"))
+	}
+
+	logger := logging.New(os.Stderr, logging.LevelVar("info"), logging.FormatText)
+	scenario, err := sandbox.LoadScenario(*scenarioPath)
+	if err != nil {
+		return fail(err)
+	}
+
+	server, err := sandbox.NewServer(scenario, logger)
+	if err != nil {
+		return fail(err)
+	}
+	if err := server.WriteCert(*certPath); err != nil {
+		return fail(err)
+	}
+	if err := server.Start(*listen); err != nil {
+		return fail(err)
+	}
+	defer server.Stop()
+
+	token := scenario.Token
+	if token == "" {
+		token = "any"
+	}
+	logger.Info("Serving scenario", "path", *scenarioPath, "accounts", len(scenario.Accounts), "instruments", len(scenario.Instruments))
+	fmt.Fprintf(os.Stderr, "\nPoint the bot at the sandbox with:\n\n")
+	fmt.Fprintf(os.Stderr, "  export TINKOFF_ENDPOINT=%s TINKOFF_TOKEN=%s SSL_CERT_FILE=%s\n\n", server.Addr(), token, *certPath)
+
+	// Serve until interrupted
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+	logger.Info("Shutting down")
+	return 0
+}
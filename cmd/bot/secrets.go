@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/secrets"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const secretsUsage = `Usage: invest-manager secrets <command> [flags] [name]
+
+Commands:
+  set <name>      add or replace a secret, reading the value from stdin
+  rotate <name>   replace an existing secret, reading the new value from stdin
+  remove <name>   delete a secret
+  list            show stored secrets and when they were updated, without values
+  rekey           re-encrypt the vault with a new passphrase or key file
+
+Names are config paths: %s
+
+The vault is located by vault.file / VAULT_FILE and unlocked with
+VAULT_PASSPHRASE or vault.key_file / VAULT_KEY_FILE.
+`
+
+// runSecretsCommand dispatches "invest-manager secrets <subcommand>"
+func runSecretsCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, secretsUsage, strings.Join(config.SecretPaths(), ", "))
+		return 2
+	}
+
+	command := args[0]
+	flags := flag.NewFlagSet("secrets "+command, flag.ExitOnError)
+	configPath := flags.String("config", "", "Path to the YAML config file (default: $"+config.ConfigFileEnv+")")
+	vaultPath := flags.String("vault", "", "Path to the vault file (default: vault.file from the config)")
+	newKeyFile := flags.String("new-key-file", "", "Key file for the re-encrypted vault (rekey only; default: prompt for a passphrase)")
+	flags.Parse(args[1:])
+
+	vaultCfg, err := config.ParseVault(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	if *vaultPath != "" {
+		vaultCfg.File = *vaultPath
+	}
+	if vaultCfg.File == "" {
+		fmt.Fprintln(os.Stderr, "Vault file is not set: use -vault, vault.file or VAULT_FILE")
+		return 1
+	}
+
+	cmd := &secretsCmd{cfg: vaultCfg, stdin: os.Stdin}
+	switch command {
+	case "set", "rotate", "remove":
+		if flags.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "Usage: invest-manager secrets %s <name>\n", command)
+			return 2
+		}
+		name := flags.Arg(0)
+		if !config.IsSecretPath(name) {
+			fmt.Fprintf(os.Stderr, "Unknown secret %q, expected one of: %s\n", name, strings.Join(config.SecretPaths(), ", "))
+			return 2
+		}
+		switch command {
+		case "set":
+			err = cmd.set(name, false)
+		case "rotate":
+			err = cmd.set(name, true)
+		default:
+			err = cmd.remove(name)
+		}
+	case "list":
+		err = cmd.list()
+	case "rekey":
+		err = cmd.rekey(*newKeyFile)
+	default:
+		fmt.Fprintf(os.Stderr, secretsUsage, strings.Join(config.SecretPaths(), ", "))
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// secretsCmd runs vault operations for the CLI
+type secretsCmd struct {
+	cfg   config.VaultConfig
+	stdin *os.File
+}
+
+// key returns the vault key, prompting for a passphrase on a terminal if none is configured
+func (c *secretsCmd) key(prompt string) ([]byte, error) {
+	key, err := c.cfg.Key()
+	if err == nil {
+		return key, nil
+	}
+	if !term.IsTerminal(int(c.stdin.Fd())) {
+		return nil, err
+	}
+	return c.readHidden(prompt)
+}
+
+// open opens the vault, or returns an empty one if create is set and the file does not exist yet
+func (c *secretsCmd) open(create bool) (*secrets.Vault, []byte, error) {
+	key, err := c.key("Vault passphrase: ")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vault, err := secrets.Open(c.cfg.File, key)
+	if create && errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Creating new vault %s\n", c.cfg.File)
+		return secrets.New(), key, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return vault, key, nil
+}
+
+// set stores a secret; with rotate the secret must already exist
+func (c *secretsCmd) set(name string, rotate bool) error {
+	vault, key, err := c.open(!rotate)
+	if err != nil {
+		return err
+	}
+
+	previous, exists := vault.Entry(name)
+	if rotate && !exists {
+		return fmt.Errorf("secret %s is not in the vault, use \"set\" to add it", name)
+	}
+
+	value, err := c.readValue(fmt.Sprintf("Value for %s: ", name))
+	if err != nil {
+		return err
+	}
+	if rotate && value == previous.Value {
+		return errors.New("new value is the same as the current one")
+	}
+
+	vault.Set(name, value)
+	if err := vault.Save(c.cfg.File, key); err != nil {
+		return err
+	}
+
+	if rotate {
+		fmt.Printf("Rotated %s (previous value set %s)\n", name, previous.Updated.Format("2006-01-02 15:04"))
+	} else {
+		fmt.Printf("Stored %s\n", name)
+	}
+	return nil
+}
+
+// remove deletes a secret from the vault
+func (c *secretsCmd) remove(name string) error {
+	vault, key, err := c.open(false)
+	if err != nil {
+		return err
+	}
+	if !vault.Delete(name) {
+		return fmt.Errorf("secret %s is not in the vault", name)
+	}
+	if err := vault.Save(c.cfg.File, key); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s\n", name)
+	return nil
+}
+
+// list prints stored secret names with their timestamps
+func (c *secretsCmd) list() error {
+	vault, _, err := c.open(false)
+	if err != nil {
+		return err
+	}
+
+	names := vault.Names()
+	if len(names) == 0 {
+		fmt.Println("Vault is empty")
+		return nil
+	}
+	for _, name := range names {
+		entry, _ := vault.Entry(name)
+		fmt.Printf("%-26s updated %s\n", name, entry.Updated.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// rekey re-encrypts the vault with a new key file or passphrase
+func (c *secretsCmd) rekey(newKeyFile string) error {
+	vault, _, err := c.open(false)
+	if err != nil {
+		return err
+	}
+
+	var newKey []byte
+	if newKeyFile != "" {
+		newKey, err = secrets.ReadKeyFile(newKeyFile)
+	} else {
+		newKey, err = c.readNewPassphrase()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := vault.Save(c.cfg.File, newKey); err != nil {
+		return err
+	}
+	fmt.Println("Vault re-encrypted, update VAULT_PASSPHRASE or VAULT_KEY_FILE accordingly")
+	return nil
+}
+
+// readNewPassphrase asks for a new passphrase twice
+func (c *secretsCmd) readNewPassphrase() ([]byte, error) {
+	if !term.IsTerminal(int(c.stdin.Fd())) {
+		return nil, errors.New("a new passphrase can only be entered on a terminal, use -new-key-file instead")
+	}
+	first, err := c.readHidden("New vault passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	second, err := c.readHidden("Repeat new passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if string(first) != string(second) {
+		return nil, errors.New("passphrases do not match")
+	}
+	if len(first) == 0 {
+		return nil, errors.New("passphrase is empty")
+	}
+	return first, nil
+}
+
+// readValue reads a secret value without echo on a terminal, or the whole of stdin otherwise
+func (c *secretsCmd) readValue(prompt string) (string, error) {
+	var value string
+	if term.IsTerminal(int(c.stdin.Fd())) {
+		data, err := c.readHidden(prompt)
+		if err != nil {
+			return "", err
+		}
+		value = string(data)
+	} else {
+		data, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value: %w", err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if value == "" {
+		return "", errors.New("value is empty")
+	}
+	return value, nil
+}
+
+// readHidden prompts on stderr and reads a line from the terminal without echo
+func (c *secretsCmd) readHidden(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(int(c.stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	return data, nil
+}
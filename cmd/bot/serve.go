@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/contributions"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/models"
+	"invest-manager/internal/monitoring"
+	"invest-manager/internal/news"
+	"invest-manager/internal/paper"
+	"invest-manager/internal/performance"
+	"invest-manager/internal/scheduler"
+	"invest-manager/internal/screener"
+	"invest-manager/internal/secrets"
+	"invest-manager/internal/stops"
+	"invest-manager/internal/tax"
+	"invest-manager/internal/telegram"
+	"invest-manager/internal/usage"
+	"invest-manager/internal/watchlist"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// healthCheckInterval is how often the broker, Telegram and the LLM are checked for /healthz
+const healthCheckInterval = time.Minute
+
+// runServe runs the Telegram bot with the scheduler until a shutdown signal arrives
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := flags.String("config", "", "Path to the YAML config file (default: $"+config.ConfigFileEnv+")")
+	flags.Parse(args)
+
+	// Initialize logger; secrets are masked once the configuration is loaded
+	redactor := secrets.NewRedactor(os.Stdout)
+	logger := logging.New(redactor, logging.LevelVar("info"), logging.FormatText)
+
+	// Load configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logger.Error("Failed to load configuration", "error", err)
+		return 1
+	}
+	redactor.Add(cfg.SecretValues()...)
+	maskAccountIDs(redactor, cfg.Tinkoff.AccountID)
+
+	// Switch to the configured level and format; the level follows config reloads
+	level := logging.LevelVar(cfg.LogLevel)
+	logger = logging.New(redactor, level, cfg.LogFormat)
+	logger.Info("Starting Invest Manager Bot")
+
+	// Set up context with cancellation for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Serve health and metrics first, so supervisors see the startup
+	health := monitoring.NewHealth()
+	if cfg.Monitoring.Listen != "" {
+		monitoringServer := monitoring.NewServer(cfg.Monitoring.Listen, health, logger)
+		if err := monitoringServer.Start(); err != nil {
+			logger.Error("Failed to start monitoring listener", "error", err)
+			return 1
+		}
+		defer monitoringServer.Stop()
+	}
+
+	// Initialize components
+	investClient, err := invest.NewClient(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to initialize Tinkoff Invest client", "error", err)
+		return 1
+	}
+	defer investClient.Close()
+
+	// Mask every account the token can see, since /account can switch to any of them
+	if accounts, err := investClient.GetAccounts(ctx); err != nil {
+		logger.Warn("Failed to list accounts", "error", err)
+	} else {
+		for _, account := range accounts {
+			maskAccountIDs(redactor, account.ID)
+		}
+	}
+
+	newsFetcher := news.NewFetcher(cfg)
+	analyzer := analysis.NewAnalyzer(cfg)
+	ledger, err := usage.Open(cfg.OpenAI.UsageFile, logger)
+	if err != nil {
+		logger.Error("Failed to load LLM usage", "error", err)
+		return 1
+	}
+	analyzer.SetLedger(ledger)
+	paperAccount, err := paper.Open(cfg.Paper.File, cfg.Paper, investClient, logger)
+	if err != nil {
+		logger.Error("Failed to load paper portfolio", "error", err)
+		return 1
+	}
+	watched, err := watchlist.Open(cfg.Watchlist.File, cfg.Watchlist, investClient, logger)
+	if err != nil {
+		logger.Error("Failed to load watchlist", "error", err)
+		return 1
+	}
+	screen := screener.New(cfg.Screener, investClient, logger)
+	stopBook, err := stops.Open(cfg.Stops.File, logger)
+	if err != nil {
+		logger.Error("Failed to load stop levels", "error", err)
+		return 1
+	}
+	tracker, err := performance.Open(cfg.Performance.File, cfg.Performance, investClient, logger)
+	if err != nil {
+		logger.Error("Failed to load portfolio values", "error", err)
+		return 1
+	}
+	taxEstimator := tax.New(cfg.Tax, investClient, logger)
+	contributionPlan := contributions.New(cfg.Contributions, investClient, logger)
+	comparer := models.New(cfg.Models, investClient, logger)
+
+	// The scheduled runs and /analyze run the same steps with the same parts
+	runOptions := scheduler.Options{
+		Paper:         paperAccount,
+		Watchlist:     watched,
+		Screener:      screen,
+		Performance:   tracker,
+		Tax:           taxEstimator,
+		Contributions: contributionPlan,
+		Models:        comparer,
+	}
+
+	telegramBot, err := telegram.NewBot(cfg, logger, investClient, investClient, investClient, analyzer, newsFetcher,
+		telegram.Options{
+			Pipeline:      scheduler.NewPipeline(logger, investClient, newsFetcher, analyzer, runOptions),
+			Paper:         paperAccount,
+			Watchlist:     watched,
+			Screener:      screen,
+			Stops:         stopBook,
+			Performance:   tracker,
+			Tax:           taxEstimator,
+			Contributions: contributionPlan,
+			Models:        comparer,
+		})
+	if err != nil {
+		logger.Error("Failed to initialize Telegram bot", "error", err)
+		return 1
+	}
+
+	// Start the Telegram bot
+	if err := telegramBot.Start(); err != nil {
+		logger.Error("Failed to start Telegram bot", "error", err)
+		return 1
+	}
+	defer telegramBot.Stop()
+
+	// Watch the stop-loss and take-profit levels
+	stopMonitor := stops.NewMonitor(stopBook, investClient, telegramBot, cfg.Stops, logger)
+	go stopMonitor.Run(ctx)
+
+	// Initialize scheduler
+	sched := scheduler.NewScheduler(cfg, logger, investClient, newsFetcher, analyzer, telegramBot, runOptions)
+	if err := sched.Start(); err != nil {
+		logger.Error("Failed to start scheduler", "error", err)
+		return 1
+	}
+	defer sched.Stop()
+
+	// Check the dependencies in the background and report readiness
+	health.Register("broker", investClient.Ping)
+	health.Register("telegram", telegramBot.Ping)
+	health.Register("llm", analyzer.Ping)
+	go health.Run(ctx, healthCheckInterval)
+	health.SetReady(true)
+	if err := monitoring.Notify("READY=1"); err != nil {
+		logger.Warn("Failed to notify systemd", "error", err)
+	}
+
+	// Send startup notification
+	if err := telegramBot.SendMessage("🤖 Invest Manager Bot запущен и готов к работе.\nОтправьте /help для списка доступных команд."); err != nil {
+		logger.Warn("Failed to send startup notification", "error", err)
+	}
+
+	// Reload the configuration on SIGHUP and when its files change
+	store := config.NewStore(cfg)
+	reload := &reloader{
+		path:          *configPath,
+		store:         store,
+		logger:        logger,
+		level:         level,
+		redactor:      redactor,
+		investor:      investClient,
+		analyzer:      analyzer,
+		newsFetcher:   newsFetcher,
+		bot:           telegramBot,
+		scheduler:     sched,
+		paper:         paperAccount,
+		watchlist:     watched,
+		screener:      screen,
+		stops:         stopMonitor,
+		performance:   tracker,
+		tax:           taxEstimator,
+		contributions: contributionPlan,
+		models:        comparer,
+	}
+	configChanged := config.Watch(ctx, func() []string {
+		return store.Current().WatchedFiles(*configPath)
+	}, configPollInterval)
+
+	// Wait for shutdown signal
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	// Block until a shutdown signal is received, reloading in the meantime
+	var sig os.Signal
+	for sig == nil {
+		select {
+		case s := <-sigChan:
+			if s == syscall.SIGHUP {
+				reload.reload("SIGHUP")
+				continue
+			}
+			sig = s
+		case <-configChanged:
+			reload.reload("file changed")
+		}
+	}
+	logger.Info("Shutting down", "signal", sig.String())
+	health.SetReady(false)
+	monitoring.Notify("STOPPING=1")
+
+	// Give services time to clean up
+	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer shutdownCancel()
+
+	// Wait for shutdown to complete or timeout
+	select {
+	case <-shutdownCtx.Done():
+		if shutdownCtx.Err() == context.DeadlineExceeded {
+			logger.Warn("Shutdown timed out, forcing exit")
+		}
+	case <-time.After(time.Second):
+		// Add a brief delay to allow logging to finish
+	}
+
+	logger.Info("Invest Manager Bot stopped")
+	return 0
+}
@@ -5,111 +5,287 @@ import (
 	"fmt"
 	"invest-manager/internal/config"
 	"invest-manager/internal/invest"
+	"invest-manager/internal/monitoring"
 	"invest-manager/internal/news"
+	"invest-manager/internal/usage"
+	"sort"
 	"strings"
-
-	"github.com/sashabaranov/go-openai"
+	"sync"
+	"time"
 )
 
+// defaultSystemPrompt is used unless prompts.system_file is configured
+const defaultSystemPrompt = `You are an investment advisor specializing in Russian stocks. 
+You will analyze a portfolio and relevant news to provide actionable advice for each position.
+For each position, provide a recommendation (BUY/SELL/HOLD) and a brief, easy-to-understand explanation.
+Additionally, suggest a few trading opportunities: stocks not currently in the portfolio that present attractive long or short positions (LONG/SHORT), with a brief explanation.
+Use clear language suitable for non-financial experts ("for beginners").
+Format your response as:
+
+SUMMARY:
+[Overall portfolio assessment and 1-2 key insights]
+
+RECOMMENDATIONS:
+[ticker]: [NAME] - [BUY/SELL/HOLD]
+Explanation: [1-2 sentences explaining the recommendation]
+
+OPPORTUNITIES:
+[ticker]: [NAME] - [LONG/SHORT]
+Explanation: [1-2 sentences explaining the opportunity]
+
+Отвечай на русском языке.
+Пожалуйста, используйте заголовки строго на английском языке как "SUMMARY:", "RECOMMENDATIONS:", and "OPPORTUNITIES:".`
+
 // Recommendation represents an investment recommendation
 type Recommendation struct {
-	Ticker  string
-	Name    string
-	Action  string // BUY, SELL, HOLD
-	Reason  string
+	Ticker     string `json:"ticker"`
+	Name       string `json:"name"`
+	Action     string `json:"action"` // BUY, SELL, HOLD
+	Reason     string `json:"reason"`
+	Watched    bool   `json:"watched,omitempty"`    // a watchlist instrument that is not held
+	Unverified bool   `json:"unverified,omitempty"` // an opportunity the broker could not be asked about
 }
 
 // PortfolioAnalysis contains the complete analysis results
 type PortfolioAnalysis struct {
-	Recommendations []Recommendation
-	Opportunities   []Recommendation // Added opportunities field
-	Summary         string
-	IsMonthlyReminder bool
-	RawText         string // store original AI response
+	Recommendations []Recommendation `json:"recommendations"`
+	Opportunities   []Recommendation `json:"opportunities"` // Added opportunities field
+	Summary         string           `json:"summary"`
+	IsMonthlyReminder bool           `json:"is_monthly_reminder"`
+	RawText         string           `json:"raw_text"` // store original AI response
+	OverBudget      string           `json:"over_budget,omitempty"` // why a cheaper, shorter analysis was made
 }
 
 // Analyzer handles OpenAI interactions
 type Analyzer struct {
-	client *openai.Client
-	model  string
+	newLLM       func(cfg *config.Config) LLM
+
+	mu           sync.RWMutex
+	llm          LLM
+	model        string
+	enabled      bool
+	systemPrompt string
+	instructions string
+	prices       map[string]config.ModelPrice
+	budget       config.BudgetConfig
+	timezone     *time.Location
+	ledger       *usage.Ledger
 }
 
 // NewAnalyzer creates a new OpenAI analyzer
 func NewAnalyzer(cfg *config.Config) *Analyzer {
-	openaiConfig := openai.DefaultConfig(cfg.OpenAIApiKey)
-	openaiConfig.BaseURL = cfg.OpenAIBaseURL
-	client := openai.NewClientWithConfig(openaiConfig)
+	a := &Analyzer{newLLM: NewOpenAI}
+	a.Reload(cfg)
+	return a
+}
+
+// NewAnalyzerWithLLM creates an analyzer that sends prompts to the given LLM,
+// which is kept across config reloads
+func NewAnalyzerWithLLM(cfg *config.Config, llm LLM) *Analyzer {
+	a := &Analyzer{newLLM: func(*config.Config) LLM { return llm }}
+	a.Reload(cfg)
+	return a
+}
+
+// Reload applies the LLM and prompt settings of a new configuration
+func (a *Analyzer) Reload(cfg *config.Config) {
+	llm := a.newLLM(cfg)
+	
+	systemPrompt := defaultSystemPrompt
+	if cfg.Prompts.System != "" {
+		systemPrompt = cfg.Prompts.System
+	}
 	
-	return &Analyzer{
-		client: client,
-		model:  "gpt-4o", // Using GPT-4 as specified
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.llm = llm
+	a.model = cfg.OpenAI.Model
+	a.enabled = cfg.OpenAI.Enabled
+	a.systemPrompt = systemPrompt
+	a.instructions = cfg.Prompts.Instructions
+	a.prices = cfg.OpenAI.Prices
+	a.budget = cfg.OpenAI.Budget
+	a.timezone = cfg.Timezone
+	if a.timezone == nil {
+		a.timezone = time.Local
 	}
 }
 
-// AnalyzePortfolio analyzes portfolio data with news context
-func (a *Analyzer) AnalyzePortfolio(ctx context.Context, portfolio *invest.Portfolio, newsArticles []news.Article, isMonthlyReminder bool) (*PortfolioAnalysis, error) {
+// SetLedger records the token usage of every analysis in the ledger and limits
+// the spending to the configured budget. Without a ledger usage is not tracked.
+func (a *Analyzer) SetLedger(ledger *usage.Ledger) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ledger = ledger
+}
+
+// UsageReport returns the LLM usage of the current day and month, or nil if usage is not tracked
+func (a *Analyzer) UsageReport() *usage.Report {
+	a.mu.RLock()
+	ledger, budget, timezone := a.ledger, a.budget, a.timezone
+	a.mu.RUnlock()
+
+	if ledger == nil {
+		return nil
+	}
+	report := ledger.Report(budget, time.Now().In(timezone))
+	return &report
+}
+
+// Enabled reports whether the LLM integration is switched on
+func (a *Analyzer) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.enabled
+}
+
+// Ping checks that the LLM API is reachable, if the LLM supports checks
+func (a *Analyzer) Ping(ctx context.Context) error {
+	a.mu.RLock()
+	llm, enabled := a.llm, a.enabled
+	a.mu.RUnlock()
+
+	if !enabled {
+		return monitoring.ErrDisabled
+	}
+	if p, ok := llm.(pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// Prompt is the pair of messages sent to the LLM
+type Prompt struct {
+	Model  string `json:"model"`
+	System string `json:"system"`
+	User   string `json:"user"`
+}
+
+// BuildPrompt creates the prompt for a portfolio analysis without calling the LLM
+func (a *Analyzer) BuildPrompt(portfolio *invest.Portfolio, newsArticles []news.Article, isMonthlyReminder bool) Prompt {
+	return a.buildPrompt(portfolio, newsArticles, isMonthlyReminder, false)
+}
+
+// buildPrompt creates the prompt; a short prompt leaves out the news and the opportunities,
+// which are the optional parts of the analysis
+func (a *Analyzer) buildPrompt(portfolio *invest.Portfolio, newsArticles []news.Article, isMonthlyReminder, short bool) Prompt {
+	a.mu.RLock()
+	model, systemPrompt, instructions := a.model, a.systemPrompt, a.instructions
+	a.mu.RUnlock()
+	
+	if short {
+		newsArticles = nil
+	}
+	
 	// Format the portfolio information
 	portfolioInfo := formatPortfolioInfo(portfolio)
 	
 	// Format the news information
 	newsInfo := formatNewsInfo(newsArticles)
 	
-	// Create the system and user prompts
-	systemPrompt := `You are an investment advisor specializing in Russian stocks. 
-You will analyze a portfolio and relevant news to provide actionable advice for each position.
-For each position, provide a recommendation (BUY/SELL/HOLD) and a brief, easy-to-understand explanation.
-Additionally, suggest a few trading opportunities: stocks not currently in the portfolio that present attractive long or short positions (LONG/SHORT), with a brief explanation.
-Use clear language suitable for non-financial experts ("for beginners").
-Format your response as:
-
-SUMMARY:
-[Overall portfolio assessment and 1-2 key insights]
-
-RECOMMENDATIONS:
-[ticker]: [NAME] - [BUY/SELL/HOLD]
-Explanation: [1-2 sentences explaining the recommendation]
-
-OPPORTUNITIES:
-[ticker]: [NAME] - [LONG/SHORT]
-Explanation: [1-2 sentences explaining the opportunity]
-
-Отвечай на русском языке.
-Пожалуйста, используйте заголовки строго на английском языке как "SUMMARY:", "RECOMMENDATIONS:", and "OPPORTUNITIES:".`
-
+	// Create the user prompt
 	userPrompt := fmt.Sprintf("Here is the current portfolio information:\n\n%s\n\nRecent news about Russia:\n\n%s\n\nPlease provide investment recommendations for each position in the portfolio, and suggest trading opportunities (LONG/SHORT) for other relevant stocks.\n\nОтвечай на русском языке.", portfolioInfo, newsInfo)
 	
+	if len(portfolio.Watched) > 0 {
+		userPrompt += "\n\nAlso recommend BUY or HOLD for each watchlist instrument in the RECOMMENDATIONS section, in the same format: BUY if a position is worth opening now."
+	}
+	
+	if len(portfolio.Exposures) > 0 {
+		userPrompt += "\n\nTake the concentration by sector, country of risk and issuer listed in the exposures into account."
+	}
+	
+	if short {
+		userPrompt += "\n\nKeep the answer brief and skip the OPPORTUNITIES section."
+	} else if portfolio.Screen != "" {
+		if len(portfolio.Candidates) > 0 {
+			userPrompt += "\n\nSuggest OPPORTUNITIES only from the screened candidates listed above, no other instruments."
+		} else {
+			userPrompt += "\n\nNo instruments passed the opportunity screen, leave the OPPORTUNITIES section empty."
+		}
+	}
+	
 	// Add monthly reminder if needed
 	if isMonthlyReminder {
-		userPrompt += "\n\nThis is a monthly review. Please also include a reminder to add funds and redistribute the portfolio."
-	}
-
-	// Create the OpenAI API request
-	request := openai.ChatCompletionRequest{
-		Model: a.model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: systemPrompt,
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: userPrompt,
-			},
-		},
-		Temperature: 0.3, // Lower temperature for more focused responses
+		// Deposits are checked against the contribution plan by the report itself
+		userPrompt += "\n\nThis is a monthly review. Please also suggest how to redistribute the portfolio."
 	}
-	// Make the API call
-	response, err := a.client.CreateChatCompletion(ctx, request)
-	if err != nil {
-		return nil, fmt.Errorf("error calling OpenAI API: %w", err)
+	
+	// Add custom instructions from the configuration
+	if instructions != "" {
+		userPrompt += "\n\n" + instructions
 	}
 	
-	if len(response.Choices) == 0 {
-		return nil, fmt.Errorf("no response from OpenAI API")
+	return Prompt{Model: model, System: systemPrompt, User: userPrompt}
+}
+
+// AnalyzePortfolio analyzes portfolio data with news context
+func (a *Analyzer) AnalyzePortfolio(ctx context.Context, portfolio *invest.Portfolio, newsArticles []news.Article, isMonthlyReminder bool) (*PortfolioAnalysis, error) {
+	// Take the current settings, so a reload does not affect a running analysis
+	a.mu.RLock()
+	llm, enabled, ledger, budget, timezone := a.llm, a.enabled, a.ledger, a.budget, a.timezone
+	a.mu.RUnlock()
+	
+	// Without the LLM the report contains only portfolio data and news
+	if !enabled {
+		return &PortfolioAnalysis{
+			Recommendations:   []Recommendation{},
+			Opportunities:     []Recommendation{},
+			Summary:           "AI analysis is disabled in the configuration.",
+			IsMonthlyReminder: isMonthlyReminder,
+		}, nil
 	}
 	
+	// Over budget, switch to the cheaper model and skip the optional parts
+	overBudget := ""
+	if ledger != nil {
+		overBudget = ledger.OverBudget(budget, time.Now().In(timezone))
+	}
+	prompt := a.buildPrompt(portfolio, newsArticles, isMonthlyReminder, overBudget != "")
+	if overBudget != "" && budget.FallbackModel != "" {
+		prompt.Model = budget.FallbackModel
+	}
+
+	// Make the API call
+	completion, err := llm.Complete(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	a.recordUsage(ctx, prompt.Model, completion)
+	
 	// Parse the response
-	analysisText := response.Choices[0].Message.Content
+	result, err := ParseResponse(completion.Text, portfolio, isMonthlyReminder)
+	if err != nil {
+		return nil, err
+	}
+	result.OverBudget = overBudget
+	return result, nil
+}
+
+// recordUsage adds the tokens of a completion to the ledger, tagged with the job and user of the context
+func (a *Analyzer) recordUsage(ctx context.Context, model string, completion Completion) {
+	a.mu.RLock()
+	ledger, prices := a.ledger, a.prices
+	a.mu.RUnlock()
+
+	cost, _ := usage.Cost(prices, model, completion.PromptTokens, completion.CompletionTokens)
+	tags := usage.TagsFrom(ctx)
+	monitoring.AddLLMCost(model, tags.Job, cost)
+	if ledger == nil {
+		return
+	}
+	ledger.Add(usage.Record{
+		Time:             time.Now(),
+		Job:              tags.Job,
+		User:             tags.User,
+		Model:            model,
+		PromptTokens:     completion.PromptTokens,
+		CompletionTokens: completion.CompletionTokens,
+		Cost:             cost,
+	})
+}
+
+// ParseResponse turns an LLM response, live or recorded, into an analysis of the portfolio
+func ParseResponse(analysisText string, portfolio *invest.Portfolio, isMonthlyReminder bool) (*PortfolioAnalysis, error) {
 	analysis, err := parseAnalysisResponse(analysisText, portfolio)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing analysis response: %w", err)
@@ -139,9 +315,64 @@ func formatPortfolioInfo(portfolio *invest.Portfolio) string {
 		sb.WriteString("\n")
 	}
 	
+	if len(portfolio.Exposures) > 0 {
+		sb.WriteString("Exposures (share of the positions value):\n")
+		for _, group := range invest.ExposureGroups {
+			if exposures := portfolio.ExposuresBy(group); len(exposures) > 0 {
+				sb.WriteString(fmt.Sprintf("- By %s: %s\n", strings.ReplaceAll(group, "_", " "), formatExposures(exposures)))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	
+	if len(portfolio.Watched) > 0 {
+		sb.WriteString("Watchlist (not held):\n")
+		for _, q := range portfolio.Watched {
+			sb.WriteString(fmt.Sprintf("- %s (%s): %s\n", q.Ticker, q.Name, q.Type))
+			if q.Price > 0 {
+				sb.WriteString(fmt.Sprintf("  Current Price: %.2f %s\n", q.Price, q.Currency))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	
+	if portfolio.Screen != "" && len(portfolio.Candidates) > 0 {
+		sb.WriteString(fmt.Sprintf("Screened candidates for opportunities (screen %q):\n", portfolio.Screen))
+		for _, q := range portfolio.Candidates {
+			sb.WriteString(fmt.Sprintf("- %s (%s): %s\n", q.Ticker, q.Name, q.Type))
+			sb.WriteString(fmt.Sprintf("  Current Price: %.2f %s\n", q.Price, q.Currency))
+			sb.WriteString(fmt.Sprintf("  Metrics: %s\n", formatMetrics(q.Metrics)))
+			sb.WriteString("\n")
+		}
+	}
+	
 	return sb.String()
 }
 
+// formatExposures lists the exposures of a group with their weights, the largest first
+func formatExposures(exposures []invest.Exposure) string {
+	parts := make([]string, 0, len(exposures))
+	for _, e := range exposures {
+		parts = append(parts, fmt.Sprintf("%s %.1f%%", e.Name, e.Weight))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatMetrics lists screened metrics in alphabetical order
+func formatMetrics(metrics map[string]float64) string {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %.2f", name, metrics[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // formatNewsInfo formats news articles into a readable string
 func formatNewsInfo(articles []news.Article) string {
 	var sb strings.Builder
@@ -159,6 +390,25 @@ func formatNewsInfo(articles []news.Article) string {
 	return sb.String()
 }
 
+// knownInstrument is an instrument a recommendation may refer to
+type knownInstrument struct {
+	Ticker  string
+	Name    string
+	Watched bool
+}
+
+// knownInstruments lists the held positions followed by the watched instruments
+func knownInstruments(portfolio *invest.Portfolio) []knownInstrument {
+	known := make([]knownInstrument, 0, len(portfolio.Positions)+len(portfolio.Watched))
+	for _, pos := range portfolio.Positions {
+		known = append(known, knownInstrument{Ticker: pos.Ticker, Name: pos.Name})
+	}
+	for _, q := range portfolio.Watched {
+		known = append(known, knownInstrument{Ticker: q.Ticker, Name: q.Name, Watched: true})
+	}
+	return known
+}
+
 // parseAnalysisResponse parses the OpenAI response into structured data
 func parseAnalysisResponse(analysisText string, portfolio *invest.Portfolio) (*PortfolioAnalysis, error) {
 	// Clean markdown formatting to detect headings reliably
@@ -201,9 +451,13 @@ func parseAnalysisResponse(analysisText string, portfolio *invest.Portfolio) (*P
 			}
 		}
 		
-		// Parse the recommendations: lines starting with a ticker
+		// Parse the recommendations: lines starting with a ticker, up to the opportunities
 		recsText := summaryParts[1]
+		if end := strings.Index(recsText, "OPPORTUNITIES:"); end >= 0 {
+			recsText = recsText[:end]
+		}
 		recsLines := strings.Split(recsText, "\n")
+		known := knownInstruments(portfolio)
 		var currentRec *Recommendation
 		for i := 0; i < len(recsLines); i++ {
 			line := strings.TrimSpace(recsLines[i])
@@ -211,7 +465,7 @@ func parseAnalysisResponse(analysisText string, portfolio *invest.Portfolio) (*P
 				continue
 			}
 			// Detect new recommendation by ticker prefix
-			for _, pos := range portfolio.Positions {
+			for _, pos := range known {
 				if strings.HasPrefix(line, pos.Ticker) {
 					// Append previous rec
 					if currentRec != nil {
@@ -232,9 +486,10 @@ func parseAnalysisResponse(analysisText string, portfolio *invest.Portfolio) (*P
 					}
 					// Start new recommendation
 					currentRec = &Recommendation{
-						Ticker: pos.Ticker,
-						Name:   pos.Name,
-						Action: action,
+						Ticker:  pos.Ticker,
+						Name:    pos.Name,
+						Action:  action,
+						Watched: pos.Watched,
 					}
 					// Next non-empty line is the explanation
 					if i+1 < len(recsLines) {
@@ -315,5 +570,23 @@ func parseAnalysisResponse(analysisText string, portfolio *invest.Portfolio) (*P
 		}
 	}
 	
+	if portfolio.Screen != "" {
+		analysis.Opportunities = screenedOpportunities(analysis.Opportunities, portfolio.Candidates)
+	}
+	
 	return analysis, nil
+}
+
+// screenedOpportunities keeps the opportunities among the screened candidates
+func screenedOpportunities(opportunities []Recommendation, candidates []invest.Quote) []Recommendation {
+	var kept []Recommendation
+	for _, opp := range opportunities {
+		for _, q := range candidates {
+			if strings.EqualFold(opp.Ticker, q.Ticker) {
+				kept = append(kept, opp)
+				break
+			}
+		}
+	}
+	return kept
 } 
\ No newline at end of file
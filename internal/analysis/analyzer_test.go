@@ -0,0 +1,271 @@
+package analysis
+
+import (
+	"context"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/news"
+	"invest-manager/internal/usage"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPortfolio holds the positions the recorded responses refer to
+var testPortfolio = &invest.Portfolio{
+	Positions: []invest.Position{
+		{Ticker: "SBER", Name: "Сбербанк", ExpectedYield: 500},
+		{Ticker: "GAZP", Name: "Газпром", ExpectedYield: -200},
+	},
+	Currency: "rub",
+}
+
+func TestParseAnalysisResponse(t *testing.T) {
+	tests := []struct {
+		fixture             string
+		wantSummary         string
+		wantRecommendations []Recommendation
+		wantOpportunities   []Recommendation
+	}{
+		{
+			fixture:     "standard.txt",
+			wantSummary: "Портфель хорошо диверсифицирован, но доля энергетики высока.",
+			wantRecommendations: []Recommendation{
+				{Ticker: "SBER", Name: "Сбербанк", Action: "BUY", Reason: "Explanation: Сильная отчётность и высокие дивиденды."},
+				{Ticker: "GAZP", Name: "Газпром", Action: "SELL", Reason: "Explanation: Снижение экспорта давит на выручку."},
+			},
+			wantOpportunities: []Recommendation{
+				{Ticker: "LKOH", Name: "Лукойл", Action: "LONG", Reason: "Стабильный денежный поток."},
+				{Ticker: "YNDX", Name: "Яндекс", Action: "SHORT", Reason: "Высокая оценка."},
+			},
+		},
+		{
+			fixture:     "markdown.txt",
+			wantSummary: "Рынок в боковике.",
+			wantRecommendations: []Recommendation{
+				{Ticker: "SBER", Name: "Сбербанк", Action: "HOLD", Reason: "Держать до отчётности."},
+				{Ticker: "GAZP", Name: "Газпром", Action: "BUY", Reason: "Дивидендная история."},
+			},
+			wantOpportunities: []Recommendation{},
+		},
+		{
+			fixture:     "russian.txt",
+			wantSummary: "Краткий обзор.",
+			wantRecommendations: []Recommendation{
+				{Ticker: "SBER", Name: "Сбербанк", Action: "BUY", Reason: "Банк растёт."},
+				{Ticker: "GAZP", Name: "Газпром", Action: "HOLD"},
+			},
+			wantOpportunities: []Recommendation{},
+		},
+		{
+			// Without sections the recommendations fall back to the sign of the yield
+			fixture:     "unstructured.txt",
+			wantSummary: "Analysis completed, but could not parse specific recommendations.",
+			wantRecommendations: []Recommendation{
+				{Ticker: "SBER", Name: "Сбербанк", Action: "BUY", Reason: "Based on current position yield."},
+				{Ticker: "GAZP", Name: "Газпром", Action: "SELL", Reason: "Based on current position yield."},
+			},
+			wantOpportunities: []Recommendation{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSuffix(tt.fixture, ".txt"), func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parseAnalysisResponse(string(data), testPortfolio)
+			if err != nil {
+				t.Fatalf("parseAnalysisResponse: %v", err)
+			}
+			if got.Summary != tt.wantSummary {
+				t.Errorf("Summary = %q, want %q", got.Summary, tt.wantSummary)
+			}
+			if !reflect.DeepEqual(got.Recommendations, tt.wantRecommendations) {
+				t.Errorf("Recommendations = %+v, want %+v", got.Recommendations, tt.wantRecommendations)
+			}
+			if !reflect.DeepEqual(got.Opportunities, tt.wantOpportunities) {
+				t.Errorf("Opportunities = %+v, want %+v", got.Opportunities, tt.wantOpportunities)
+			}
+		})
+	}
+}
+
+func TestParseWatchedRecommendations(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "watchlist.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	portfolio := *testPortfolio
+	portfolio.Watched = []invest.Quote{{Ticker: "LKOH", Name: "Лукойл", Price: 7000}}
+
+	got, err := parseAnalysisResponse(string(data), &portfolio)
+	if err != nil {
+		t.Fatalf("parseAnalysisResponse: %v", err)
+	}
+	want := []Recommendation{
+		{Ticker: "SBER", Name: "Сбербанк", Action: "HOLD", Reason: "Explanation: Ждём отчётность."},
+		{Ticker: "GAZP", Name: "Газпром", Action: "SELL", Reason: "Explanation: Слабые перспективы экспорта."},
+		{Ticker: "LKOH", Name: "Лукойл", Action: "BUY", Reason: "Explanation: Высокая дивидендная доходность.", Watched: true},
+	}
+	// The opportunity for the same ticker must not be read as a second recommendation
+	if !reflect.DeepEqual(got.Recommendations, want) {
+		t.Errorf("Recommendations = %+v, want %+v", got.Recommendations, want)
+	}
+	if len(got.Opportunities) != 1 {
+		t.Errorf("Opportunities = %+v, want LKOH", got.Opportunities)
+	}
+
+	a := NewAnalyzerWithLLM(&config.Config{}, nil)
+	prompt := a.BuildPrompt(&portfolio, nil, false)
+	if !strings.Contains(prompt.User, "Watchlist (not held):\n- LKOH (Лукойл)") || !strings.Contains(prompt.User, "watchlist instrument") {
+		t.Errorf("prompt does not list the watchlist:\n%s", prompt.User)
+	}
+}
+
+func TestScreenedOpportunities(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "watchlist.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	portfolio := *testPortfolio
+	portfolio.Screen = "dividends"
+	portfolio.Candidates = []invest.Quote{{Ticker: "MRKC", Name: "Россети Центр", Type: "share", Currency: "RUB", Price: 0.96,
+		Metrics: map[string]float64{"turnover": 97000, "dividend_yield": 10.4}}}
+
+	// LKOH is not a candidate of the screen
+	got, err := parseAnalysisResponse(string(data), &portfolio)
+	if err != nil {
+		t.Fatalf("parseAnalysisResponse: %v", err)
+	}
+	if len(got.Opportunities) != 0 {
+		t.Errorf("Opportunities = %+v, want none outside the candidates", got.Opportunities)
+	}
+
+	portfolio.Candidates = append(portfolio.Candidates, invest.Quote{Ticker: "lkoh"})
+	if got, _ := parseAnalysisResponse(string(data), &portfolio); len(got.Opportunities) != 1 {
+		t.Errorf("Opportunities = %+v, want the LKOH candidate", got.Opportunities)
+	}
+
+	a := NewAnalyzerWithLLM(&config.Config{}, nil)
+	prompt := a.BuildPrompt(&portfolio, nil, false)
+	if !strings.Contains(prompt.User, "- MRKC (Россети Центр): share\n  Current Price: 0.96 RUB\n  Metrics: dividend_yield 10.40, turnover 97000.00") ||
+		!strings.Contains(prompt.User, "only from the screened candidates") {
+		t.Errorf("prompt does not list the candidates:\n%s", prompt.User)
+	}
+
+	portfolio.Candidates = nil
+	if prompt := a.BuildPrompt(&portfolio, nil, false); !strings.Contains(prompt.User, "leave the OPPORTUNITIES section empty") {
+		t.Errorf("prompt without candidates does not rule out opportunities:\n%s", prompt.User)
+	}
+}
+
+func TestExposuresInPrompt(t *testing.T) {
+	portfolio := *testPortfolio
+	portfolio.Exposures = []invest.Exposure{
+		{Group: invest.GroupAssetClass, Name: invest.AssetEquity, Weight: 100},
+		{Group: invest.GroupSector, Name: "financial", Weight: 62.5},
+		{Group: invest.GroupSector, Name: "energy", Weight: 37.5},
+	}
+
+	a := NewAnalyzerWithLLM(&config.Config{}, nil)
+	prompt := a.BuildPrompt(&portfolio, nil, false)
+	if !strings.Contains(prompt.User, "- By asset class: equity 100.0%\n- By sector: financial 62.5%, energy 37.5%\n") ||
+		!strings.Contains(prompt.User, "concentration by sector") {
+		t.Errorf("prompt does not list the exposures:\n%s", prompt.User)
+	}
+	if prompt := a.BuildPrompt(testPortfolio, nil, false); strings.Contains(prompt.User, "Exposures") {
+		t.Errorf("prompt lists exposures of an unclassified portfolio:\n%s", prompt.User)
+	}
+}
+
+func TestParseResponseKeepsRawText(t *testing.T) {
+	raw := "SUMMARY:\nok\n\nRECOMMENDATIONS:\nSBER - HOLD\n"
+	got, err := ParseResponse(raw, testPortfolio, true)
+	if err != nil {
+		t.Fatalf("ParseResponse: %v", err)
+	}
+	if got.RawText != raw || !got.IsMonthlyReminder {
+		t.Errorf("RawText = %q, IsMonthlyReminder = %v", got.RawText, got.IsMonthlyReminder)
+	}
+}
+
+// recordingLLM answers with a fixed response and usage and keeps the last prompt
+type recordingLLM struct {
+	completion Completion
+	prompt     Prompt
+}
+
+func (l *recordingLLM) Complete(ctx context.Context, prompt Prompt) (Completion, error) {
+	l.prompt = prompt
+	return l.completion, nil
+}
+
+func TestAnalyzePortfolioBudget(t *testing.T) {
+	articles := []news.Article{{Title: "Ставка ЦБ снижена"}}
+
+	tests := []struct {
+		name           string
+		spent          float64
+		wantModel      string
+		wantOverBudget bool
+	}{
+		{name: "within budget", spent: 0.5, wantModel: "gpt-4o"},
+		{name: "over daily budget", spent: 1, wantModel: "gpt-4o-mini", wantOverBudget: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Timezone = time.UTC
+			cfg.OpenAI.Budget = config.BudgetConfig{Daily: 1, FallbackModel: "gpt-4o-mini"}
+
+			ledger, err := usage.Open("", logging.Discard())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ledger.Add(usage.Record{Time: time.Now(), Job: "scheduled", Model: "gpt-4o", Cost: tt.spent})
+
+			llm := &recordingLLM{completion: Completion{
+				Text:             "SUMMARY:\nok\n\nRECOMMENDATIONS:\nSBER - HOLD\n",
+				PromptTokens:     1000,
+				CompletionTokens: 100,
+			}}
+			analyzer := NewAnalyzerWithLLM(cfg, llm)
+			analyzer.SetLedger(ledger)
+
+			ctx := usage.WithTags(context.Background(), "command", "alice")
+			result, err := analyzer.AnalyzePortfolio(ctx, testPortfolio, articles, false)
+			if err != nil {
+				t.Fatalf("AnalyzePortfolio: %v", err)
+			}
+
+			if llm.prompt.Model != tt.wantModel {
+				t.Errorf("model = %q, want %q", llm.prompt.Model, tt.wantModel)
+			}
+			if (result.OverBudget != "") != tt.wantOverBudget {
+				t.Errorf("OverBudget = %q", result.OverBudget)
+			}
+			if withNews := strings.Contains(llm.prompt.User, "Ставка ЦБ"); withNews == tt.wantOverBudget {
+				t.Errorf("news in the prompt = %v over budget = %v", withNews, tt.wantOverBudget)
+			}
+
+			// The request is recorded with its cost and the tags of the context
+			month := analyzer.UsageReport().Month
+			got := month.ByUser["alice"]
+			wantCost, _ := usage.Cost(cfg.OpenAI.Prices, tt.wantModel, 1000, 100)
+			if got.Calls != 1 || got.Tokens() != 1100 || got.Cost != wantCost {
+				t.Errorf("usage of alice = %+v, want 1 call of 1100 tokens costing %g", got, wantCost)
+			}
+			if month.ByJob["command"].Calls != 1 {
+				t.Errorf("usage by job = %+v", month.ByJob)
+			}
+		})
+	}
+}
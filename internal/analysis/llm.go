@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/monitoring"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// LLM completes a prompt and returns the answer with the tokens it took
+type LLM interface {
+	Complete(ctx context.Context, prompt Prompt) (Completion, error)
+}
+
+// Completion is the answer of the LLM
+type Completion struct {
+	Text             string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// pinger is implemented by LLMs that can check their API is reachable without a completion
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// openAILLM sends prompts to an OpenAI-compatible chat completion API
+type openAILLM struct {
+	client *openai.Client
+}
+
+// NewOpenAI creates an LLM backed by the OpenAI API of the configuration
+func NewOpenAI(cfg *config.Config) LLM {
+	openaiConfig := openai.DefaultConfig(cfg.OpenAI.APIKey.Value())
+	openaiConfig.BaseURL = cfg.OpenAI.BaseURL
+	return &openAILLM{client: openai.NewClientWithConfig(openaiConfig)}
+}
+
+// Complete sends the prompt as a system and a user message
+func (o *openAILLM) Complete(ctx context.Context, prompt Prompt) (Completion, error) {
+	// Create the OpenAI API request
+	request := openai.ChatCompletionRequest{
+		Model: prompt.Model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: prompt.System,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt.User,
+			},
+		},
+		Temperature: 0.3, // Lower temperature for more focused responses
+	}
+
+	start := time.Now()
+	response, err := o.client.CreateChatCompletion(ctx, request)
+	monitoring.ObserveLLMRequest(prompt.Model, start, err)
+	if err != nil {
+		return Completion{}, fmt.Errorf("error calling OpenAI API: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return Completion{}, errors.New("no response from OpenAI API")
+	}
+	monitoring.AddLLMTokens(prompt.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens)
+	return Completion{
+		Text:             response.Choices[0].Message.Content,
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+	}, nil
+}
+
+// Ping lists the models, which checks the API key without spending tokens
+func (o *openAILLM) Ping(ctx context.Context) error {
+	if _, err := o.client.ListModels(ctx); err != nil {
+		return fmt.Errorf("error calling OpenAI API: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package charts
+
+import (
+	"errors"
+	"math"
+)
+
+// Bar is a labeled value of a bar chart
+type Bar struct {
+	Label string
+	Value float64
+}
+
+// Bars renders a horizontal bar chart; positive values are green, negative are red
+func Bars(title string, bars []Bar) ([]byte, error) {
+	if len(bars) == 0 {
+		return nil, errors.New("no data to plot")
+	}
+
+	c, err := newCanvas(title)
+	if err != nil {
+		return nil, err
+	}
+
+	minV, maxV := 0.0, 0.0
+	labelWidth := 0
+	for _, b := range bars {
+		minV = math.Min(minV, b.Value)
+		maxV = math.Max(maxV, b.Value)
+		if w := textWidth(b.Label, labelFace); w > labelWidth {
+			labelWidth = w
+		}
+	}
+
+	ticks := niceTicks(minV, maxV, 6)
+	xMin, xMax := ticks[0], ticks[len(ticks)-1]
+	left := float64(labelWidth + 30)
+	if left < marginLeft {
+		left = marginLeft
+	}
+	right := float64(Width - marginRight)
+	top, bottom := float64(marginTop), float64(Height-marginBottom)
+
+	xOf := func(v float64) float64 {
+		return left + (right-left)*(v-xMin)/(xMax-xMin)
+	}
+
+	// Vertical grid with value labels
+	for _, tick := range ticks {
+		x := xOf(tick)
+		c.line(x, top, x, bottom, 1, colorGrid)
+		c.textCentered(formatValue(tick), int(x), Height-marginBottom+22, labelFace, colorText)
+	}
+
+	rowHeight := (bottom - top) / float64(len(bars))
+	barHeight := math.Min(math.Max(rowHeight*0.7, 1), 60)
+	zero := xOf(0)
+
+	for i, b := range bars {
+		y := top + rowHeight*float64(i) + (rowHeight-barHeight)/2
+		col := colorPositive
+		if b.Value < 0 {
+			col = colorNegative
+		}
+		c.fillRect(int(zero), int(y), int(xOf(b.Value)), int(y+barHeight), col)
+		c.textRight(b.Label, int(left)-10, int(y+barHeight/2)+5, labelFace, colorText)
+	}
+	c.line(zero, top, zero, bottom, 1, colorAxis)
+
+	return c.encode()
+}
@@ -0,0 +1,270 @@
+package charts
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Size of rendered charts in pixels
+const (
+	Width  = 1000
+	Height = 600
+)
+
+// Margins around the plot area
+const (
+	marginTop    = 60
+	marginBottom = 50
+	marginLeft   = 90
+	marginRight  = 30
+)
+
+// Common colors
+var (
+	colorBackground = color.RGBA{255, 255, 255, 255}
+	colorText       = color.RGBA{33, 33, 33, 255}
+	colorGrid       = color.RGBA{225, 225, 225, 255}
+	colorAxis       = color.RGBA{120, 120, 120, 255}
+	colorPositive   = color.RGBA{46, 160, 67, 255}
+	colorNegative   = color.RGBA{218, 54, 51, 255}
+)
+
+// palette is used for series and pie slices in order
+var palette = []color.RGBA{
+	{31, 119, 180, 255},
+	{255, 127, 14, 255},
+	{44, 160, 44, 255},
+	{214, 39, 40, 255},
+	{148, 103, 189, 255},
+	{140, 86, 75, 255},
+	{227, 119, 194, 255},
+	{127, 127, 127, 255},
+	{188, 189, 34, 255},
+	{23, 190, 207, 255},
+}
+
+// paletteColor returns the palette color for an index
+func paletteColor(i int) color.RGBA {
+	return palette[i%len(palette)]
+}
+
+// fonts are parsed once and shared by all charts
+var (
+	fontsOnce  sync.Once
+	fontsErr   error
+	titleFace  font.Face
+	labelFace  font.Face
+	legendFace font.Face
+)
+
+// loadFonts parses the embedded Go fonts, which cover Latin and Cyrillic
+func loadFonts() error {
+	fontsOnce.Do(func() {
+		regular, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			fontsErr = fmt.Errorf("failed to parse regular font: %w", err)
+			return
+		}
+		bold, err := opentype.Parse(gobold.TTF)
+		if err != nil {
+			fontsErr = fmt.Errorf("failed to parse bold font: %w", err)
+			return
+		}
+
+		newFace := func(f *opentype.Font, size float64) font.Face {
+			if fontsErr != nil {
+				return nil
+			}
+			face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+			if err != nil {
+				fontsErr = fmt.Errorf("failed to create font face: %w", err)
+			}
+			return face
+		}
+		titleFace = newFace(bold, 22)
+		labelFace = newFace(regular, 14)
+		legendFace = newFace(regular, 16)
+	})
+	return fontsErr
+}
+
+// canvas is an image with drawing helpers
+type canvas struct {
+	img *image.RGBA
+}
+
+// newCanvas creates a chart canvas with a white background and a title
+func newCanvas(title string) (*canvas, error) {
+	if err := loadFonts(); err != nil {
+		return nil, err
+	}
+
+	c := &canvas{img: image.NewRGBA(image.Rect(0, 0, Width, Height))}
+	draw.Draw(c.img, c.img.Bounds(), &image.Uniform{colorBackground}, image.Point{}, draw.Src)
+	c.textCentered(title, Width/2, 36, titleFace, colorText)
+	return c, nil
+}
+
+// encode returns the canvas as PNG
+func (c *canvas) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, fmt.Errorf("failed to encode chart: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fillRect fills a rectangle given by two corners
+func (c *canvas) fillRect(x0, y0, x1, y1 int, col color.Color) {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	draw.Draw(c.img, image.Rect(x0, y0, x1, y1), &image.Uniform{col}, image.Point{}, draw.Src)
+}
+
+// line draws a line of the given width between two points
+func (c *canvas) line(x0, y0, x1, y1 float64, width int, col color.Color) {
+	c.dashedLine(x0, y0, x1, y1, width, 0, col)
+}
+
+// dashedLine draws a line with dashes of the given length; zero means solid
+func (c *canvas) dashedLine(x0, y0, x1, y1 float64, width, dash int, col color.Color) {
+	dx, dy := x1-x0, y1-y0
+	steps := int(math.Max(math.Abs(dx), math.Abs(dy)))
+	if steps == 0 {
+		steps = 1
+	}
+	half := width / 2
+	for i := 0; i <= steps; i++ {
+		if dash > 0 && (i/dash)%2 == 1 {
+			continue
+		}
+		t := float64(i) / float64(steps)
+		x := int(math.Round(x0 + dx*t))
+		y := int(math.Round(y0 + dy*t))
+		for ox := -half; ox <= width-1-half; ox++ {
+			for oy := -half; oy <= width-1-half; oy++ {
+				c.img.Set(x+ox, y+oy, col)
+			}
+		}
+	}
+}
+
+// legendEntry draws a colored marker followed by a label
+func (c *canvas) legendEntry(label string, x, y int, col color.Color) {
+	c.fillRect(x, y-12, x+14, y+2, col)
+	c.text(label, x+22, y, legendFace, colorText)
+}
+
+// text draws a string with its baseline starting at the given point
+func (c *canvas) text(s string, x, y int, face font.Face, col color.Color) {
+	d := &font.Drawer{
+		Dst:  c.img,
+		Src:  &image.Uniform{col},
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// textWidth measures a string in pixels
+func textWidth(s string, face font.Face) int {
+	return font.MeasureString(face, s).Ceil()
+}
+
+// textCentered draws a string centered horizontally around x
+func (c *canvas) textCentered(s string, x, y int, face font.Face, col color.Color) {
+	c.text(s, x-textWidth(s, face)/2, y, face, col)
+}
+
+// textRight draws a string ending at x
+func (c *canvas) textRight(s string, x, y int, face font.Face, col color.Color) {
+	c.text(s, x-textWidth(s, face), y, face, col)
+}
+
+// niceTicks returns evenly spaced round values covering [min, max]
+func niceTicks(min, max float64, count int) []float64 {
+	if min == max {
+		min, max = min-1, max+1
+	}
+	step := niceNum((max-min)/float64(count-1), true)
+	start := math.Floor(min/step) * step
+	end := math.Ceil(max/step) * step
+
+	var ticks []float64
+	for v := start; v <= end+step/2; v += step {
+		ticks = append(ticks, v)
+	}
+	return ticks
+}
+
+// niceNum rounds a range to 1, 2, 5 or 10 times a power of ten
+func niceNum(x float64, round bool) float64 {
+	exp := math.Floor(math.Log10(x))
+	f := x / math.Pow(10, exp)
+
+	var nice float64
+	switch {
+	case round && f < 1.5, !round && f <= 1:
+		nice = 1
+	case round && f < 3, !round && f <= 2:
+		nice = 2
+	case round && f < 7, !round && f <= 5:
+		nice = 5
+	default:
+		nice = 10
+	}
+	return nice * math.Pow(10, exp)
+}
+
+// formatValue formats an axis value compactly
+func formatValue(v float64) string {
+	abs := math.Abs(v)
+	switch {
+	case abs >= 1e9:
+		return trimZeros(fmt.Sprintf("%.2f", v/1e9)) + "B"
+	case abs >= 1e6:
+		return trimZeros(fmt.Sprintf("%.2f", v/1e6)) + "M"
+	case abs >= 1e4:
+		return trimZeros(fmt.Sprintf("%.1f", v/1e3)) + "K"
+	case abs >= 100 || v == math.Trunc(v):
+		return fmt.Sprintf("%.0f", v)
+	}
+	return trimZeros(fmt.Sprintf("%.2f", v))
+}
+
+// trimZeros removes trailing zeros after the decimal point
+func trimZeros(s string) string {
+	for len(s) > 1 && s[len(s)-1] == '0' && containsDot(s) {
+		s = s[:len(s)-1]
+	}
+	if len(s) > 1 && s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// containsDot reports whether s has a decimal point
+func containsDot(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '.' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package charts
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		render func() ([]byte, error)
+	}{
+		{"line", func() ([]byte, error) {
+			points := []Point{{day, 100}, {day.AddDate(0, 0, 1), 104.5}, {day.AddDate(0, 0, 2), 98}}
+			return Line("Стоимость", []Series{{Name: "Портфель", Points: points}}, []Level{{Name: "Средняя", Value: 101}})
+		}},
+		{"single point", func() ([]byte, error) {
+			return Line("Стоимость", []Series{{Name: "Портфель", Points: []Point{{day, 100}}}}, nil)
+		}},
+		{"pie", func() ([]byte, error) {
+			var slices []Slice
+			for _, label := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+				slices = append(slices, Slice{Label: label, Value: 10})
+			}
+			return Pie("Доли", append(slices, Slice{Label: "пусто", Value: -1}))
+		}},
+		{"bars", func() ([]byte, error) {
+			return Bars("P&L", []Bar{{"SBER", 1200}, {"GAZP", -350.5}})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.render()
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("not a PNG: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != Width || b.Dy() != Height {
+				t.Errorf("size = %v, want %dx%d", b, Width, Height)
+			}
+		})
+	}
+}
+
+func TestRenderWithoutData(t *testing.T) {
+	if _, err := Line("Стоимость", []Series{{Name: "Портфель"}}, nil); err == nil {
+		t.Error("Line() without points succeeded")
+	}
+	if _, err := Pie("Доли", []Slice{{Label: "пусто"}}); err == nil {
+		t.Error("Pie() without positive values succeeded")
+	}
+	if _, err := Bars("P&L", nil); err == nil {
+		t.Error("Bars() without bars succeeded")
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	ticks := niceTicks(3, 97, 6)
+	if ticks[0] != 0 || ticks[len(ticks)-1] != 100 || ticks[1]-ticks[0] != 20 {
+		t.Errorf("niceTicks(3, 97) = %v", ticks)
+	}
+	if ticks := niceTicks(5, 5, 6); ticks[0] > 4 || ticks[len(ticks)-1] < 6 {
+		t.Errorf("niceTicks of a flat range = %v", ticks)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := map[float64]string{
+		2500000000: "2.5B",
+		1250000:    "1.25M",
+		15000:      "15K",
+		250:        "250",
+		3:          "3",
+		1.25:       "1.25",
+		-0.5:       "-0.5",
+	}
+	for v, want := range tests {
+		if got := formatValue(v); got != want {
+			t.Errorf("formatValue(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
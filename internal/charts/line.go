@@ -0,0 +1,118 @@
+package charts
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Point is a single value of a time series
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a named line on a line chart
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Level is a horizontal reference line, e.g. the average cost of a position
+type Level struct {
+	Name  string
+	Value float64
+}
+
+// Line renders time series as a line chart with optional horizontal levels
+func Line(title string, series []Series, levels []Level) ([]byte, error) {
+	// Find the data range
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	var minT, maxT time.Time
+	points := 0
+	for _, s := range series {
+		for _, p := range s.Points {
+			minV = math.Min(minV, p.Value)
+			maxV = math.Max(maxV, p.Value)
+			if minT.IsZero() || p.Time.Before(minT) {
+				minT = p.Time
+			}
+			if p.Time.After(maxT) {
+				maxT = p.Time
+			}
+			points++
+		}
+	}
+	if points == 0 {
+		return nil, errors.New("no data to plot")
+	}
+	for _, l := range levels {
+		minV = math.Min(minV, l.Value)
+		maxV = math.Max(maxV, l.Value)
+	}
+	if !maxT.After(minT) {
+		maxT = minT.Add(24 * time.Hour)
+	}
+
+	c, err := newCanvas(title)
+	if err != nil {
+		return nil, err
+	}
+
+	ticks := niceTicks(minV, maxV, 6)
+	yMin, yMax := ticks[0], ticks[len(ticks)-1]
+	left, right := float64(marginLeft), float64(Width-marginRight)
+	top, bottom := float64(marginTop), float64(Height-marginBottom)
+
+	xOf := func(t time.Time) float64 {
+		return left + (right-left)*float64(t.Sub(minT))/float64(maxT.Sub(minT))
+	}
+	yOf := func(v float64) float64 {
+		return bottom - (bottom-top)*(v-yMin)/(yMax-yMin)
+	}
+
+	// Horizontal grid with value labels
+	for _, tick := range ticks {
+		y := yOf(tick)
+		c.line(left, y, right, y, 1, colorGrid)
+		c.textRight(formatValue(tick), marginLeft-10, int(y)+5, labelFace, colorText)
+	}
+
+	// Date labels along the x axis
+	layout := "02.01"
+	if maxT.Sub(minT) > 365*24*time.Hour {
+		layout = "01.2006"
+	}
+	const dateLabels = 6
+	for i := 0; i < dateLabels; i++ {
+		t := minT.Add(time.Duration(float64(maxT.Sub(minT)) * float64(i) / (dateLabels - 1)))
+		x := xOf(t)
+		c.line(x, bottom, x, bottom+5, 1, colorAxis)
+		c.textCentered(t.Format(layout), int(x), Height-marginBottom+22, labelFace, colorText)
+	}
+	c.line(left, bottom, right, bottom, 1, colorAxis)
+	c.line(left, top, left, bottom, 1, colorAxis)
+
+	// Reference levels go under the data lines
+	legendY := marginTop + 20
+	for i, l := range levels {
+		col := paletteColor(len(series) + i)
+		c.dashedLine(left, yOf(l.Value), right, yOf(l.Value), 2, 8, col)
+		c.legendEntry(l.Name, marginLeft+15, legendY, col)
+		legendY += 22
+	}
+
+	for i, s := range series {
+		col := paletteColor(i)
+		for j := 1; j < len(s.Points); j++ {
+			prev, cur := s.Points[j-1], s.Points[j]
+			c.line(xOf(prev.Time), yOf(prev.Value), xOf(cur.Time), yOf(cur.Value), 3, col)
+		}
+		if s.Name != "" {
+			c.legendEntry(s.Name, marginLeft+15, legendY, col)
+			legendY += 22
+		}
+	}
+
+	return c.encode()
+}
@@ -0,0 +1,93 @@
+package charts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// maxSlices limits the number of pie slices; the rest is grouped together
+const maxSlices = 8
+
+// Slice is a share of a pie chart
+type Slice struct {
+	Label string
+	Value float64
+}
+
+// Pie renders a pie chart with a legend showing shares in percent.
+// Non-positive values are ignored.
+func Pie(title string, slices []Slice) ([]byte, error) {
+	var total float64
+	var data []Slice
+	for _, s := range slices {
+		if s.Value > 0 {
+			data = append(data, s)
+			total += s.Value
+		}
+	}
+	if total == 0 {
+		return nil, errors.New("no data to plot")
+	}
+
+	// Largest slices first, small ones grouped into a single slice
+	sort.SliceStable(data, func(i, j int) bool {
+		return data[i].Value > data[j].Value
+	})
+	if len(data) > maxSlices {
+		other := Slice{Label: "Прочее"}
+		for _, s := range data[maxSlices-1:] {
+			other.Value += s.Value
+		}
+		data = append(data[:maxSlices-1], other)
+	}
+
+	c, err := newCanvas(title)
+	if err != nil {
+		return nil, err
+	}
+
+	const radius = 220
+	cx, cy := marginLeft+radius, marginTop+(Height-marginTop)/2
+
+	// Cumulative shares define where each slice ends
+	bounds := make([]float64, len(data))
+	var acc float64
+	for i, s := range data {
+		acc += s.Value / total
+		bounds[i] = acc
+	}
+
+	// Fill every pixel of the circle with the color of its slice,
+	// starting at 12 o'clock and going clockwise
+	for y := cy - radius; y <= cy+radius; y++ {
+		for x := cx - radius; x <= cx+radius; x++ {
+			dx, dy := float64(x-cx), float64(y-cy)
+			if dx*dx+dy*dy > radius*radius {
+				continue
+			}
+			angle := math.Atan2(dx, -dy)
+			if angle < 0 {
+				angle += 2 * math.Pi
+			}
+			share := angle / (2 * math.Pi)
+			idx := sort.SearchFloat64s(bounds, share)
+			if idx >= len(data) {
+				idx = len(data) - 1
+			}
+			c.img.Set(x, y, paletteColor(idx))
+		}
+	}
+
+	// Legend with shares
+	legendX := cx + radius + 60
+	legendY := cy - len(data)*30/2 + 10
+	for i, s := range data {
+		label := fmt.Sprintf("%s — %.1f%%", s.Label, s.Value/total*100)
+		c.legendEntry(label, legendX, legendY, paletteColor(i))
+		legendY += 30
+	}
+
+	return c.encode()
+}
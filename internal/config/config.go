@@ -1,85 +1,403 @@
 package config
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Config stores all configuration for the application
 type Config struct {
-	TinkoffToken    string
-	TinkoffEndpoint string
-	OpenAIApiKey    string
-	OpenAIBaseURL   string
-	TelegramToken   string
-	TelegramChatID  string
-	NewsAPIToken    string
-	Timezone        *time.Location
-	LogLevel        string
-}
-
-// Load loads configuration from environment variables
-func Load() (*Config, error) {
-	cfg := &Config{
-		TinkoffToken:    os.Getenv("TINKOFF_TOKEN"),
-		TinkoffEndpoint: os.Getenv("TINKOFF_ENDPOINT"),
-		OpenAIApiKey:    os.Getenv("OPENAI_API_KEY"),
-		OpenAIBaseURL:   os.Getenv("OPENAI_BASE_URL"),
-		TelegramToken:   os.Getenv("TELEGRAM_TOKEN"),
-		TelegramChatID:  os.Getenv("TELEGRAM_CHAT_ID"),
-		NewsAPIToken:    os.Getenv("NEWSAPI_TOKEN"),
-		LogLevel:        getEnvOrDefault("LOG_LEVEL", "info"),
-	}
+	Tinkoff        TinkoffConfig        `yaml:"tinkoff"`
+	OpenAI         OpenAIConfig         `yaml:"openai"`
+	Telegram       TelegramConfig       `yaml:"telegram"`
+	News           NewsConfig           `yaml:"news"`
+	Schedule       ScheduleConfig       `yaml:"schedule"`
+	Prompts        PromptsConfig        `yaml:"prompts"`
+	Vault          VaultConfig          `yaml:"vault"`
+	Monitoring     MonitoringConfig     `yaml:"monitoring"`
+	Trading        TradingConfig        `yaml:"trading"`
+	Paper          PaperConfig          `yaml:"paper"`
+	Watchlist      WatchlistConfig      `yaml:"watchlist"`
+	Screener       ScreenerConfig       `yaml:"screener"`
+	Stops          StopsConfig          `yaml:"stops"`
+	Performance    PerformanceConfig    `yaml:"performance"`
+	Tax            TaxConfig            `yaml:"tax"`
+	Contributions  ContributionsConfig  `yaml:"contributions"`
+	Classification ClassificationConfig `yaml:"classification"`
+	Models         ModelsConfig         `yaml:"models"`
+	TimezoneName   string               `yaml:"timezone"`
+	LogLevel       string               `yaml:"log_level"`
+	LogFormat      string               `yaml:"log_format"`
+
+	// Timezone is resolved from TimezoneName during validation
+	Timezone *time.Location `yaml:"-"`
+
+	// envProblems are the environment variables that could not be applied, reported by Validate
+	envProblems []Problem
+}
+
+// TinkoffConfig configures access to the Tinkoff Invest API
+type TinkoffConfig struct {
+	Token     Secret `yaml:"token"`
+	Endpoint  string `yaml:"endpoint"`
+	AccountID string `yaml:"account_id"`
+}
+
+// OpenAIConfig configures the LLM used for analysis
+type OpenAIConfig struct {
+	Enabled   bool                  `yaml:"enabled"`
+	APIKey    Secret                `yaml:"api_key"`
+	BaseURL   string                `yaml:"base_url"`
+	Model     string                `yaml:"model"`
+	Prices    map[string]ModelPrice `yaml:"prices"` // by model, to compute the cost of token usage
+	Budget    BudgetConfig          `yaml:"budget"`
+	UsageFile string                `yaml:"usage_file"` // JSON lines file keeping token usage across restarts
+}
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+// BudgetConfig limits the LLM spending in USD. When a limit is reached, analyses
+// switch to the fallback model and skip optional stages; 0 means no limit.
+type BudgetConfig struct {
+	Daily         float64 `yaml:"daily"`
+	Monthly       float64 `yaml:"monthly"`
+	FallbackModel string  `yaml:"fallback_model"` // cheaper model used over budget; empty keeps the model
+}
+
+// TelegramConfig configures the Telegram bot
+type TelegramConfig struct {
+	Token   Secret        `yaml:"token"`
+	ChatID  string        `yaml:"chat_id"`
+	Mode    string        `yaml:"mode"`
+	Webhook WebhookConfig `yaml:"webhook"`
+}
+
+// WebhookConfig describes how the bot receives updates in webhook mode
+type WebhookConfig struct {
+	ListenAddr string `yaml:"listen"`    // address of the built-in listener, e.g. ":8443"
+	PublicURL  string `yaml:"url"`       // URL Telegram sends updates to
+	Secret     Secret `yaml:"secret"`    // secret token Telegram puts into every request
+	CertFile   string `yaml:"cert_file"` // TLS certificate; serve plain HTTP if empty
+	KeyFile    string `yaml:"key_file"`  // TLS private key
+}
+
+// NewsConfig configures the NewsAPI integration
+type NewsConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	APIToken Secret `yaml:"api_token"`
+	Query    string `yaml:"query"` // query for market news in reports
+	Limit    int    `yaml:"limit"` // number of articles in reports
+}
+
+// ScheduleConfig configures when reports are sent
+type ScheduleConfig struct {
+	Daily              string `yaml:"daily"`                // cron spec of the daily report, in the configured time zone
+	MonthlyReminderDay int    `yaml:"monthly_reminder_day"` // day of month with the deposit reminder, 0 disables it
+	Weekly             string `yaml:"weekly"`               // cron spec of the model portfolio comparison, empty disables it
+	RecordDir          string `yaml:"record_dir"`           // directory to save every scheduled report to, for replay
+}
+
+// PromptsConfig customizes the LLM prompts
+type PromptsConfig struct {
+	SystemFile   string `yaml:"system_file"`  // replaces the built-in system prompt
+	Instructions string `yaml:"instructions"` // extra instructions appended to the user prompt
+
+	// System is read from SystemFile during validation
+	System string `yaml:"-"`
+}
+
+// VaultConfig points to the encrypted secret store.
+// The passphrase is only read from the environment, never from the config file.
+type VaultConfig struct {
+	File       string `yaml:"file"`
+	KeyFile    string `yaml:"key_file"`
+	Passphrase Secret `yaml:"-"`
+}
+
+// MonitoringConfig configures the health and metrics endpoints
+type MonitoringConfig struct {
+	Listen string `yaml:"listen"` // address of /healthz, /readyz and /metrics; empty disables them
+}
+
+// TradingConfig enables placing orders from recommendations through /trade.
+// Amounts are in RUB; every order still needs a confirmation in the chat.
+type TradingConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	OrderAmount    float64 `yaml:"order_amount"`     // amount a buy proposal aims for
+	SellShare      float64 `yaml:"sell_share"`       // share of the position a sell proposal offers, 1 sells all
+	MaxOrderAmount float64 `yaml:"max_order_amount"` // hard limit of a single order
+	MaxDailyAmount float64 `yaml:"max_daily_amount"` // hard limit of the amount bought and sold per day
+	CommissionRate float64 `yaml:"commission_rate"`  // broker commission for estimates, e.g. 0.003 for 0.3%
+}
+
+// PaperConfig sets up the paper portfolio that follows every BUY and SELL recommendation
+// in a local simulation, to compare the advice with the real account
+type PaperConfig struct {
+	Enabled          bool    `yaml:"enabled"`
+	File             string  `yaml:"file"`               // JSON file keeping the paper portfolio across restarts
+	InitialCash      float64 `yaml:"initial_cash"`       // RUB to start with; 0 starts as a copy of the real portfolio
+	BuyShare         float64 `yaml:"buy_share"`          // share of the paper equity spent on a buy
+	SellShare        float64 `yaml:"sell_share"`         // share of the holding sold on a sell, 1 sells all
+	MaxPositionShare float64 `yaml:"max_position_share"` // buys stop once a holding reaches this share of the equity
+	CommissionRate   float64 `yaml:"commission_rate"`    // commission charged on every paper trade
+}
+
+// WatchlistConfig sets up the instruments analyzed alongside the portfolio without being held
+type WatchlistConfig struct {
+	File      string `yaml:"file"`       // JSON file keeping the watchlist across restarts
+	MaxItems  int    `yaml:"max_items"`  // limit of watched instruments, each one makes the prompt longer
+	NewsLimit int    `yaml:"news_limit"` // articles fetched per watched instrument, 0 fetches none
+}
+
+// ScreenerConfig declares the screens run over the tradable shares and ETFs
+type ScreenerConfig struct {
+	Default       string              `yaml:"default"`        // screen whose matches are the only allowed opportunities, empty leaves them to the LLM
+	MaxCandidates int                 `yaml:"max_candidates"` // matches passed to the analyzer, the most traded first
+	Screens       map[string][]string `yaml:"screens"`        // rules by screen name, e.g. "dividend_yield > 8%"
+
+	// Rules are parsed from Screens during validation
+	Rules map[string][]ScreenRule `yaml:"-"`
+}
+
+// StopsConfig sets up the stop-loss and take-profit levels of the positions
+type StopsConfig struct {
+	File         string `yaml:"file"`          // JSON file keeping the levels across restarts
+	CheckMinutes int    `yaml:"check_minutes"` // how often the levels are checked against the last prices
+}
+
+// PerformanceConfig sets up the money-weighted and time-weighted return reporting
+type PerformanceConfig struct {
+	File       string   `yaml:"file"`       // JSON file keeping the daily portfolio values
+	Benchmarks []string `yaml:"benchmarks"` // tickers of the indices the returns are compared with
+}
+
+// TaxConfig sets up the income tax estimate of the account
+type TaxConfig struct {
+	ExemptNoticeDays     int     `yaml:"exempt_notice_days"`     // how early lots about to become tax-exempt are flagged
+	HarvestMonth         int     `yaml:"harvest_month"`          // month from which the monthly report suggests selling at a loss
+	IISContributionLimit float64 `yaml:"iis_contribution_limit"` // yearly IIS contribution limit, 0 for none
+	IISDeductionBase     float64 `yaml:"iis_deduction_base"`     // yearly contributions the type A deduction is granted on
+}
+
+// ContributionsConfig sets up the deposit plan the monthly reminder is checked against
+type ContributionsConfig struct {
+	Plans      []ContributionPlan `yaml:"plans"`      // per account; without plans the reminder always fires
+	Allocation map[string]float64 `yaml:"allocation"` // target weights by ticker the new money is split by, summing to 1
+}
+
+// ClassificationConfig fills the gaps of the instrument metadata the exposures are computed from
+type ClassificationConfig struct {
+	File string `yaml:"file"` // YAML file with the classification by ticker or FIGI, e.g. the sector of a fund
+
+	// Overrides are read from File during validation, keyed by upper-case ticker or FIGI
+	Overrides map[string]Classification `yaml:"-"`
+}
 
-	// Load timezone
-	tzName := os.Getenv("TIMEZONE")
-	if tzName == "" {
-		tzName = "Europe/Moscow" // Default to Moscow time
+// Classification replaces the metadata of an instrument; empty fields keep the broker's
+type Classification struct {
+	Sector     string `yaml:"sector"`
+	Country    string `yaml:"country"` // country of risk, e.g. RU
+	Issuer     string `yaml:"issuer"`
+	AssetClass string `yaml:"asset_class"` // equity, bond, fund, cash, derivative or other
+}
+
+// ModelsConfig declares the model portfolios the accounts are benchmarked against
+type ModelsConfig struct {
+	PeriodDays      int                       `yaml:"period_days"`      // history the models are simulated over
+	RebalanceMonths int                       `yaml:"rebalance_months"` // how often a model is brought back to its weights, 0 never
+	Portfolios      map[string]ModelPortfolio `yaml:"portfolios"`       // by name, e.g. dividend
+}
+
+// ModelPortfolio is a strategy given by its target weights
+type ModelPortfolio struct {
+	Account string             `yaml:"account"` // account that follows the model, empty to simulate the model alone
+	Weights map[string]float64 `yaml:"weights"` // target weights by ticker, summing to 1
+}
+
+// ContributionPlan is the money to deposit to an account
+type ContributionPlan struct {
+	Account string  `yaml:"account"` // account ID
+	Monthly float64 `yaml:"monthly"` // RUB every month
+	Yearly  float64 `yaml:"yearly"`  // goal for the year, 12 monthly deposits if 0
+}
+
+// Telegram update modes
+const (
+	TelegramModePolling = "polling"
+	TelegramModeWebhook = "webhook"
+)
+
+// ConfigFileEnv names the environment variable with the path to the config file
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Default returns the configuration used before the file and environment are applied
+func Default() *Config {
+	return &Config{
+		OpenAI: OpenAIConfig{
+			Enabled: true,
+			BaseURL: "https://api.openai.com/v1",
+			Model:   "gpt-4o",
+			Prices: map[string]ModelPrice{
+				"gpt-4o":      {Prompt: 2.5, Completion: 10},
+				"gpt-4o-mini": {Prompt: 0.15, Completion: 0.6},
+			},
+		},
+		Telegram: TelegramConfig{
+			Mode: TelegramModePolling,
+			Webhook: WebhookConfig{
+				ListenAddr: ":8443",
+			},
+		},
+		News: NewsConfig{
+			Enabled: true,
+			Query:   "Russia",
+			Limit:   5,
+		},
+		Schedule: ScheduleConfig{
+			Daily:              "0 7 * * *",
+			MonthlyReminderDay: 5,
+			Weekly:             "0 10 * * 6",
+		},
+		Monitoring: MonitoringConfig{
+			Listen: "localhost:9090",
+		},
+		Trading: TradingConfig{
+			OrderAmount:    10000,
+			SellShare:      1,
+			MaxOrderAmount: 50000,
+			MaxDailyAmount: 100000,
+			CommissionRate: 0.003,
+		},
+		Paper: PaperConfig{
+			BuyShare:         0.05,
+			SellShare:        1,
+			MaxPositionShare: 0.2,
+			CommissionRate:   0.003,
+		},
+		Watchlist: WatchlistConfig{
+			MaxItems:  20,
+			NewsLimit: 2,
+		},
+		Screener: ScreenerConfig{
+			MaxCandidates: 10,
+		},
+		Stops: StopsConfig{
+			CheckMinutes: 5,
+		},
+		Performance: PerformanceConfig{
+			Benchmarks: []string{"IMOEX", "MCFTRR"},
+		},
+		Tax: TaxConfig{
+			ExemptNoticeDays:     90,
+			HarvestMonth:         11,
+			IISContributionLimit: 1000000,
+			IISDeductionBase:     400000,
+		},
+		Models: ModelsConfig{
+			PeriodDays:      365,
+			RebalanceMonths: 3,
+		},
+		TimezoneName: "Europe/Moscow", // Default to Moscow time
+		LogLevel:     "info",
+		LogFormat:    "text",
 	}
+}
 
-	location, err := time.LoadLocation(tzName)
+// Load loads configuration from an optional YAML file, environment variables and the secret vault.
+// Environment variables override values from the file. If path is empty,
+// the CONFIG_FILE environment variable is used; without it only the environment is read.
+// If components are given, only their settings are required (see Validate).
+func Load(path string, components ...Component) (*Config, error) {
+	cfg, err := parse(path)
 	if err != nil {
 		return nil, err
 	}
-	cfg.Timezone = location
 
 	// Validate required fields
-	if err := cfg.validate(); err != nil {
+	if err := cfg.Validate(components...); err != nil {
 		return nil, err
 	}
 
 	return cfg, nil
 }
 
-// getEnvOrDefault returns environment variable value or default if not set
-func getEnvOrDefault(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// Parse reads the file, environment and vault without validating the result.
+// Environment variables that cannot be applied are reported at once in a *ValidationError.
+func Parse(path string) (*Config, error) {
+	cfg, err := parse(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.envProblems) > 0 {
+		return nil, &ValidationError{Problems: cfg.envProblems}
+	}
+	return cfg, nil
+}
+
+// parse reads the file, environment and vault, leaving the problems of the environment to Validate
+func parse(path string) (*Config, error) {
+	cfg, fromEnv, err := parseSources(path)
+	if err != nil {
+		return nil, err
 	}
-	return value
+
+	// Vault entries override the file, but not secrets passed through the environment
+	if err := cfg.applyVault(fromEnv); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
 }
 
-// validate checks if all required fields are provided
-func (c *Config) validate() error {
-	if c.TinkoffToken == "" {
-		return errors.New("TINKOFF_TOKEN is required")
+// ParseVault returns the vault settings without opening the vault, e.g. to create it
+func ParseVault(path string) (VaultConfig, error) {
+	cfg, _, err := parseSources(path)
+	if err != nil {
+		return VaultConfig{}, err
 	}
-	if c.OpenAIApiKey == "" {
-		return errors.New("OPENAI_API_KEY is required")
+	if len(cfg.envProblems) > 0 {
+		return VaultConfig{}, &ValidationError{Problems: cfg.envProblems}
 	}
-	if c.OpenAIBaseURL == "" {
-		return errors.New("OPENAI_BASE_URL is required")
+	return cfg.Vault, nil
+}
+
+// parseSources applies defaults, the file and the environment, returning the paths set by the environment
+func parseSources(path string) (*Config, map[string]bool, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
 	}
-	if c.TelegramToken == "" {
-		return errors.New("TELEGRAM_TOKEN is required")
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, nil, err
+		}
 	}
-	if c.TelegramChatID == "" {
-		return errors.New("TELEGRAM_CHAT_ID is required")
+
+	return cfg, cfg.applyEnv(), nil
+}
+
+// loadFile decodes a YAML file over the current values, rejecting unknown keys
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
-	if c.NewsAPIToken == "" {
-		return errors.New("NEWSAPI_TOKEN is required")
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
 	}
 	return nil
-} 
\ No newline at end of file
+}
@@ -0,0 +1,375 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"invest-manager/internal/secrets"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validConfig returns a configuration that passes validation
+func validConfig() *Config {
+	cfg := Default()
+	cfg.Tinkoff.Token = "tinkoff"
+	cfg.OpenAI.APIKey = "openai"
+	cfg.Telegram.Token = "telegram"
+	cfg.Telegram.ChatID = "12345"
+	cfg.News.APIToken = "news"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *Config) {},
+		},
+		{
+			name: "optional integrations disabled",
+			modify: func(c *Config) {
+				c.OpenAI.Enabled = false
+				c.OpenAI.APIKey = ""
+				c.News.Enabled = false
+				c.News.APIToken = ""
+			},
+		},
+		{
+			name: "all problems reported at once",
+			modify: func(c *Config) {
+				c.Tinkoff.Token = ""
+				c.Telegram.ChatID = "not-a-number"
+				c.News.APIToken = ""
+				c.TimezoneName = "Mars/Olympus"
+				c.LogLevel = "loud"
+				c.LogFormat = "xml"
+			},
+			want: []string{"tinkoff.token", "telegram.chat_id", "news.api_token", "timezone", "log_level", "log_format"},
+		},
+		{
+			name: "webhook mode requires url and secret",
+			modify: func(c *Config) {
+				c.Telegram.Mode = TelegramModeWebhook
+				c.Telegram.Webhook.CertFile = "cert.pem"
+			},
+			want: []string{"telegram.webhook.url", "telegram.webhook.secret", "telegram.webhook"},
+		},
+		{
+			name: "invalid schedule and prompt file",
+			modify: func(c *Config) {
+				c.Schedule.Daily = "every morning"
+				c.Schedule.MonthlyReminderDay = 31
+				c.Prompts.SystemFile = filepath.Join(os.TempDir(), "missing-prompt.txt")
+			},
+			want: []string{"schedule.daily", "schedule.monthly_reminder_day", "prompts.system_file"},
+		},
+		{
+			name:   "unknown mode",
+			modify: func(c *Config) { c.Telegram.Mode = "carrier-pigeon" },
+			want:   []string{"telegram.mode"},
+		},
+		{
+			name:   "monitoring listen without port",
+			modify: func(c *Config) { c.Monitoring.Listen = "localhost" },
+			want:   []string{"monitoring.listen"},
+		},
+		{
+			name:   "monitoring disabled",
+			modify: func(c *Config) { c.Monitoring.Listen = "" },
+		},
+		{
+			name: "budget with priced models",
+			modify: func(c *Config) {
+				c.OpenAI.Budget = BudgetConfig{Daily: 1, Monthly: 20, FallbackModel: "gpt-4o-mini"}
+			},
+		},
+		{
+			name: "budget needs prices",
+			modify: func(c *Config) {
+				c.OpenAI.Model = "o3"
+				c.OpenAI.Budget = BudgetConfig{Daily: -1, Monthly: 20}
+			},
+			want: []string{"openai.budget.daily", "openai.prices"},
+		},
+		{
+			name:   "trading with default limits",
+			modify: func(c *Config) { c.Trading.Enabled = true },
+		},
+		{
+			name: "trading limits are inconsistent",
+			modify: func(c *Config) {
+				c.Trading = TradingConfig{Enabled: true, OrderAmount: 20000, SellShare: 1.5, MaxOrderAmount: 10000, MaxDailyAmount: 5000}
+			},
+			want: []string{"trading.max_daily_amount", "trading.order_amount", "trading.sell_share"},
+		},
+		{
+			name: "paper sizing rules out of range",
+			modify: func(c *Config) {
+				c.Paper.Enabled = true
+				c.Paper.InitialCash = -1
+				c.Paper.BuyShare = 0
+				c.Paper.MaxPositionShare = 2
+			},
+			want: []string{"paper.initial_cash", "paper.buy_share", "paper.max_position_share"},
+		},
+		{
+			name: "watchlist limits out of range",
+			modify: func(c *Config) {
+				c.Watchlist.MaxItems = 0
+				c.Watchlist.NewsLimit = -1
+			},
+			want: []string{"watchlist.max_items", "watchlist.news_limit"},
+		},
+		{
+			name: "screens are parsed",
+			modify: func(c *Config) {
+				c.Screener.Default = "dividends"
+				c.Screener.Screens = map[string][]string{"dividends": {"dividend_yield > 8%", "turnover > 50M", "rsi < 30"}}
+			},
+		},
+		{
+			name: "invalid screens",
+			modify: func(c *Config) {
+				c.Screener.Default = "value"
+				c.Screener.Screens = map[string][]string{"empty": {}, "typo": {"rsi << 30"}}
+			},
+			want: []string{"screener.screens.empty", "screener.screens.typo", "screener.default"},
+		},
+		{
+			name:   "stop check interval out of range",
+			modify: func(c *Config) { c.Stops.CheckMinutes = 0 },
+			want:   []string{"stops.check_minutes"},
+		},
+		{
+			name:   "empty benchmark",
+			modify: func(c *Config) { c.Performance.Benchmarks = []string{"IMOEX", " "} },
+			want:   []string{"performance.benchmarks[1]"},
+		},
+		{
+			name: "tax settings out of range",
+			modify: func(c *Config) {
+				c.Tax.HarvestMonth = 13
+				c.Tax.IISDeductionBase = 2000000
+			},
+			want: []string{"tax.harvest_month", "tax.iis_deduction_base"},
+		},
+		{
+			name: "invalid contribution plan",
+			modify: func(c *Config) {
+				c.Contributions.Plans = []ContributionPlan{{Account: "1", Monthly: 10000}, {Account: "1", Monthly: 0}}
+				c.Contributions.Allocation = map[string]float64{"SBER": 0.5, "TMOS": 0.4}
+			},
+			want: []string{"contributions.plans[1].account", "contributions.plans[1].monthly", "contributions.allocation"},
+		},
+		{
+			name: "invalid model portfolios",
+			modify: func(c *Config) {
+				c.Schedule.Weekly = "every saturday"
+				c.Models.RebalanceMonths = 13
+				c.Models.Portfolios = map[string]ModelPortfolio{
+					"bonds":    {},
+					"dividend": {Weights: map[string]float64{"SBER": 0.5, "MTSS": 0.4}},
+				}
+			},
+			want: []string{"schedule.weekly", "models.rebalance_months", "models.portfolios.bonds.weights", "models.portfolios.dividend.weights"},
+		},
+		{
+			name:   "missing classification file",
+			modify: func(c *Config) { c.Classification.File = filepath.Join(os.TempDir(), "missing-classification.yaml") },
+			want:   []string{"classification.file"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected *ValidationError, got %v", err)
+			}
+			var paths []string
+			for _, p := range validationErr.Problems {
+				paths = append(paths, p.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("problem paths = %v, want %v", paths, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadClassification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "classification.yaml")
+	content := "tmos:\n  sector: diversified\n  country: ru\nSU26238RMFS4:\n  issuer: Минфин России\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := validConfig()
+	cfg.Classification.File = path
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Classification{
+		"TMOS":         {Sector: "diversified", Country: "RU"},
+		"SU26238RMFS4": {Issuer: "Минфин России"},
+	}
+	if !reflect.DeepEqual(cfg.Classification.Overrides, want) {
+		t.Errorf("overrides = %+v, want %+v", cfg.Classification.Overrides, want)
+	}
+
+	if err := os.WriteFile(path, []byte("TMOS:\n  asset_class: stocks\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readClassification(path); err == nil || !strings.Contains(err.Error(), `"stocks"`) {
+		t.Errorf("unknown asset class: err = %v", err)
+	}
+	if err := os.WriteFile(path, []byte("TMOS: {}\ntmos: {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readClassification(path); err == nil {
+		t.Error("a ticker listed twice was accepted")
+	}
+}
+
+func TestParseFileWithEnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := []byte(`
+tinkoff:
+  token: from-file
+telegram:
+  chat_id: "42"
+news:
+  enabled: false
+timezone: UTC
+`)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TINKOFF_TOKEN", "from-env")
+	t.Setenv("OPENAI_MODEL", "")
+
+	cfg, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if cfg.Tinkoff.Token != "from-env" {
+		t.Errorf("environment should override the file, got token %q", cfg.Tinkoff.Token)
+	}
+	if cfg.Telegram.ChatID != "42" || cfg.News.Enabled || cfg.TimezoneName != "UTC" {
+		t.Errorf("file values were not applied: %+v", cfg)
+	}
+	if cfg.OpenAI.Model != "gpt-4o" || cfg.Telegram.Mode != TelegramModePolling {
+		t.Errorf("defaults were not kept: %+v", cfg)
+	}
+}
+
+func TestEnvProblemsReportedWithValidation(t *testing.T) {
+	t.Setenv(ConfigFileEnv, "")
+	t.Setenv("NEWS_LIMIT", "many")
+	t.Setenv("PAPER_ENABLED", "maybe")
+	t.Setenv("LOG_FORMAT", "xml")
+
+	_, err := Load("")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	found := make(map[string]bool)
+	for _, p := range validationErr.Problems {
+		found[p.Path] = true
+	}
+	for _, path := range []string{"news.limit", "paper.enabled", "log_format"} {
+		if !found[path] {
+			t.Errorf("no problem for %s in %v", path, validationErr)
+		}
+	}
+}
+
+func TestParseRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("telegram:\n  tokn: typo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Parse(path); err == nil {
+		t.Fatal("expected an error for an unknown key")
+	}
+}
+
+func TestSecretsFromFilesAndVault(t *testing.T) {
+	dir := t.TempDir()
+
+	// Tinkoff token comes from the vault, Telegram token from a credentials file
+	vault := secrets.New()
+	vault.Set("tinkoff.token", "from-vault")
+	vault.Set("telegram.token", "overridden")
+	vaultPath := filepath.Join(dir, "vault.json")
+	if err := vault.Save(vaultPath, []byte("pass")); err != nil {
+		t.Fatal(err)
+	}
+	tokenPath := filepath.Join(dir, "telegram_token")
+	if err := os.WriteFile(tokenPath, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("VAULT_FILE", vaultPath)
+	t.Setenv("VAULT_PASSPHRASE", "pass")
+	t.Setenv("TELEGRAM_TOKEN_FILE", tokenPath)
+
+	cfg, err := Parse("")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if cfg.Tinkoff.Token.Value() != "from-vault" {
+		t.Errorf("tinkoff.token = %q, want the vault value", cfg.Tinkoff.Token.Value())
+	}
+	if cfg.Telegram.Token.Value() != "from-file" {
+		t.Errorf("telegram.token = %q, want the file value", cfg.Telegram.Token.Value())
+	}
+
+	t.Setenv("TELEGRAM_TOKEN", "inline")
+	if _, err := Parse(""); err == nil {
+		t.Error("expected an error when both TELEGRAM_TOKEN and TELEGRAM_TOKEN_FILE are set")
+	}
+
+	t.Setenv("VAULT_PASSPHRASE", "wrong")
+	t.Setenv("TELEGRAM_TOKEN", "")
+	if _, err := Parse(""); !errors.Is(err, secrets.ErrWrongKey) {
+		t.Errorf("expected ErrWrongKey, got %v", err)
+	}
+}
+
+func TestSecretsAreNotPrinted(t *testing.T) {
+	cfg := validConfig()
+	cfg.Telegram.Token = "123456:telegram-secret"
+
+	dumped, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, out := range []string{fmt.Sprintf("%v", cfg), fmt.Sprintf("%+v", *cfg), fmt.Sprintf("%#v", *cfg), string(dumped)} {
+		if strings.Contains(out, "telegram-secret") {
+			t.Errorf("secret leaked in %q", out)
+		}
+	}
+}
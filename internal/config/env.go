@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// envVar binds an environment variable to a config field
+type envVar struct {
+	name   string
+	path   string
+	secret bool // also read from NAME_FILE
+	apply  func(c *Config, value string) error
+}
+
+// stringVar binds an environment variable to a string field
+func stringVar(name, path string, field func(c *Config) *string) envVar {
+	return envVar{name: name, path: path, apply: func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+// boolVar binds an environment variable to a boolean field
+func boolVar(name, path string, field func(c *Config) *bool) envVar {
+	return envVar{name: name, path: path, apply: func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+// intVar binds an environment variable to an integer field
+func intVar(name, path string, field func(c *Config) *int) envVar {
+	return envVar{name: name, path: path, apply: func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+// floatVar binds an environment variable to a floating point field
+func floatVar(name, path string, field func(c *Config) *float64) envVar {
+	return envVar{name: name, path: path, apply: func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = f
+		return nil
+	}}
+}
+
+// envVars lists every environment variable that overrides the config file
+var envVars = []envVar{
+	secretVar("TINKOFF_TOKEN", "tinkoff.token", func(c *Config) *Secret { return &c.Tinkoff.Token }),
+	stringVar("TINKOFF_ENDPOINT", "tinkoff.endpoint", func(c *Config) *string { return &c.Tinkoff.Endpoint }),
+	stringVar("TINKOFF_ACCOUNT_ID", "tinkoff.account_id", func(c *Config) *string { return &c.Tinkoff.AccountID }),
+	boolVar("OPENAI_ENABLED", "openai.enabled", func(c *Config) *bool { return &c.OpenAI.Enabled }),
+	secretVar("OPENAI_API_KEY", "openai.api_key", func(c *Config) *Secret { return &c.OpenAI.APIKey }),
+	stringVar("OPENAI_BASE_URL", "openai.base_url", func(c *Config) *string { return &c.OpenAI.BaseURL }),
+	stringVar("OPENAI_MODEL", "openai.model", func(c *Config) *string { return &c.OpenAI.Model }),
+	floatVar("OPENAI_BUDGET_DAILY", "openai.budget.daily", func(c *Config) *float64 { return &c.OpenAI.Budget.Daily }),
+	floatVar("OPENAI_BUDGET_MONTHLY", "openai.budget.monthly", func(c *Config) *float64 { return &c.OpenAI.Budget.Monthly }),
+	stringVar("OPENAI_FALLBACK_MODEL", "openai.budget.fallback_model", func(c *Config) *string { return &c.OpenAI.Budget.FallbackModel }),
+	stringVar("OPENAI_USAGE_FILE", "openai.usage_file", func(c *Config) *string { return &c.OpenAI.UsageFile }),
+	secretVar("TELEGRAM_TOKEN", "telegram.token", func(c *Config) *Secret { return &c.Telegram.Token }),
+	stringVar("TELEGRAM_CHAT_ID", "telegram.chat_id", func(c *Config) *string { return &c.Telegram.ChatID }),
+	stringVar("TELEGRAM_MODE", "telegram.mode", func(c *Config) *string { return &c.Telegram.Mode }),
+	stringVar("TELEGRAM_WEBHOOK_LISTEN", "telegram.webhook.listen", func(c *Config) *string { return &c.Telegram.Webhook.ListenAddr }),
+	stringVar("TELEGRAM_WEBHOOK_URL", "telegram.webhook.url", func(c *Config) *string { return &c.Telegram.Webhook.PublicURL }),
+	secretVar("TELEGRAM_WEBHOOK_SECRET", "telegram.webhook.secret", func(c *Config) *Secret { return &c.Telegram.Webhook.Secret }),
+	stringVar("TELEGRAM_WEBHOOK_CERT", "telegram.webhook.cert_file", func(c *Config) *string { return &c.Telegram.Webhook.CertFile }),
+	stringVar("TELEGRAM_WEBHOOK_KEY", "telegram.webhook.key_file", func(c *Config) *string { return &c.Telegram.Webhook.KeyFile }),
+	boolVar("NEWS_ENABLED", "news.enabled", func(c *Config) *bool { return &c.News.Enabled }),
+	secretVar("NEWSAPI_TOKEN", "news.api_token", func(c *Config) *Secret { return &c.News.APIToken }),
+	stringVar("NEWS_QUERY", "news.query", func(c *Config) *string { return &c.News.Query }),
+	intVar("NEWS_LIMIT", "news.limit", func(c *Config) *int { return &c.News.Limit }),
+	stringVar("SCHEDULE_DAILY", "schedule.daily", func(c *Config) *string { return &c.Schedule.Daily }),
+	stringVar("SCHEDULE_WEEKLY", "schedule.weekly", func(c *Config) *string { return &c.Schedule.Weekly }),
+	intVar("SCHEDULE_MONTHLY_REMINDER_DAY", "schedule.monthly_reminder_day", func(c *Config) *int { return &c.Schedule.MonthlyReminderDay }),
+	stringVar("SCHEDULE_RECORD_DIR", "schedule.record_dir", func(c *Config) *string { return &c.Schedule.RecordDir }),
+	stringVar("PROMPTS_SYSTEM_FILE", "prompts.system_file", func(c *Config) *string { return &c.Prompts.SystemFile }),
+	stringVar("PROMPTS_INSTRUCTIONS", "prompts.instructions", func(c *Config) *string { return &c.Prompts.Instructions }),
+	stringVar("VAULT_FILE", "vault.file", func(c *Config) *string { return &c.Vault.File }),
+	stringVar("VAULT_KEY_FILE", "vault.key_file", func(c *Config) *string { return &c.Vault.KeyFile }),
+	secretVar("VAULT_PASSPHRASE", vaultPassphrasePath, func(c *Config) *Secret { return &c.Vault.Passphrase }),
+	stringVar("MONITORING_LISTEN", "monitoring.listen", func(c *Config) *string { return &c.Monitoring.Listen }),
+	boolVar("TRADING_ENABLED", "trading.enabled", func(c *Config) *bool { return &c.Trading.Enabled }),
+	floatVar("TRADING_ORDER_AMOUNT", "trading.order_amount", func(c *Config) *float64 { return &c.Trading.OrderAmount }),
+	floatVar("TRADING_MAX_ORDER_AMOUNT", "trading.max_order_amount", func(c *Config) *float64 { return &c.Trading.MaxOrderAmount }),
+	floatVar("TRADING_MAX_DAILY_AMOUNT", "trading.max_daily_amount", func(c *Config) *float64 { return &c.Trading.MaxDailyAmount }),
+	boolVar("PAPER_ENABLED", "paper.enabled", func(c *Config) *bool { return &c.Paper.Enabled }),
+	stringVar("PAPER_FILE", "paper.file", func(c *Config) *string { return &c.Paper.File }),
+	floatVar("PAPER_INITIAL_CASH", "paper.initial_cash", func(c *Config) *float64 { return &c.Paper.InitialCash }),
+	stringVar("WATCHLIST_FILE", "watchlist.file", func(c *Config) *string { return &c.Watchlist.File }),
+	stringVar("SCREENER_DEFAULT", "screener.default", func(c *Config) *string { return &c.Screener.Default }),
+	stringVar("STOPS_FILE", "stops.file", func(c *Config) *string { return &c.Stops.File }),
+	stringVar("PERFORMANCE_FILE", "performance.file", func(c *Config) *string { return &c.Performance.File }),
+	stringVar("CLASSIFICATION_FILE", "classification.file", func(c *Config) *string { return &c.Classification.File }),
+	stringVar("TIMEZONE", "timezone", func(c *Config) *string { return &c.TimezoneName }),
+	stringVar("LOG_LEVEL", "log_level", func(c *Config) *string { return &c.LogLevel }),
+	stringVar("LOG_FORMAT", "log_format", func(c *Config) *string { return &c.LogFormat }),
+}
+
+// vaultPassphrasePath is the pseudo path of the vault passphrase, which only comes from the environment
+const vaultPassphrasePath = "vault.passphrase"
+
+// applyEnv overrides config values with environment variables that are set and not empty.
+// Secrets can also be given as NAME_FILE with the path to a file holding the value.
+// It returns the paths that were set; variables that cannot be applied are kept for
+// Validate to report with the other problems.
+func (c *Config) applyEnv() map[string]bool {
+	set := make(map[string]bool)
+	c.envProblems = nil
+	for _, v := range envVars {
+		value, err := lookupEnv(v)
+		if err != nil {
+			c.envProblems = append(c.envProblems, Problem{Path: v.path, Message: err.Error()})
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		if err := v.apply(c, value); err != nil {
+			c.envProblems = append(c.envProblems, Problem{Path: v.path, Message: fmt.Sprintf("invalid value of %s: %v", v.name, err)})
+			continue
+		}
+		set[v.path] = true
+	}
+	return set
+}
+
+// lookupEnv returns the value of a variable, reading NAME_FILE for secrets
+func lookupEnv(v envVar) (string, error) {
+	value := os.Getenv(v.name)
+	if !v.secret {
+		return value, nil
+	}
+
+	file := os.Getenv(v.name + "_FILE")
+	if file == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("both %s and %s_FILE are set, use only one", v.name, v.name)
+	}
+
+	value, err := readSecretFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %w", v.name, err)
+	}
+	return value, nil
+}
+
+// envName returns the environment variable that overrides a config path
+func envName(path string) string {
+	for _, v := range envVars {
+		if v.path == path {
+			return v.name
+		}
+	}
+	return ""
+}
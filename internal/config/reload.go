@@ -0,0 +1,207 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Change describes a config field that differs between two configurations
+type Change struct {
+	Path    string
+	Old     string
+	New     string
+	Restart bool // the new value only takes effect after a restart
+}
+
+// String formats the change for logs and notifications, masking secrets
+func (c Change) String() string {
+	s := fmt.Sprintf("%s: %s → %s", c.Path, displayValue(c.Old), displayValue(c.New))
+	if c.Restart {
+		s += " (requires restart)"
+	}
+	return s
+}
+
+// displayValue shows empty values explicitly
+func displayValue(v string) string {
+	if v == "" {
+		return `""`
+	}
+	return v
+}
+
+// restartPaths lists settings that are bound to connections opened at startup
+var restartPaths = []string{
+	"tinkoff.token",
+	"tinkoff.endpoint",
+	"telegram.token",
+	"telegram.mode",
+	"telegram.webhook",
+	"monitoring",
+	"openai.usage_file",
+	"paper.file",
+	"watchlist.file",
+	"stops.file",
+	"performance.file",
+	"log_format",
+}
+
+// requiresRestart reports whether a path is one of restartPaths or nested in one
+func requiresRestart(path string) bool {
+	for _, p := range restartPaths {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff lists the fields that differ between two configurations.
+// Secrets are compared, but their values are masked in the result.
+func Diff(old, new *Config) []Change {
+	var changes []Change
+	diffValue(reflect.ValueOf(*old), reflect.ValueOf(*new), "", &changes)
+
+	// The system prompt is loaded from a file, so its contents can change without the path
+	if old.Prompts.SystemFile == new.Prompts.SystemFile && old.Prompts.System != new.Prompts.System {
+		changes = append(changes, Change{Path: "prompts.system_file", Old: "(previous contents)", New: "(new contents)"})
+	}
+	// So are the classification overrides
+	if old.Classification.File == new.Classification.File && !reflect.DeepEqual(old.Classification.Overrides, new.Classification.Overrides) {
+		changes = append(changes, Change{Path: "classification.file", Old: "(previous contents)", New: "(new contents)"})
+	}
+	return changes
+}
+
+// diffValue walks structs by their YAML keys and records differing leaf values
+func diffValue(old, new reflect.Value, path string, changes *[]Change) {
+	if old.Kind() == reflect.Struct {
+		t := old.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValue(old.Field(i), new.Field(i), name, changes)
+		}
+		return
+	}
+
+	if reflect.DeepEqual(old.Interface(), new.Interface()) {
+		return
+	}
+	*changes = append(*changes, Change{
+		Path:    path,
+		Old:     fmt.Sprint(old.Interface()),
+		New:     fmt.Sprint(new.Interface()),
+		Restart: requiresRestart(path),
+	})
+}
+
+// Store holds the current configuration and lets it be swapped atomically
+type Store struct {
+	current atomic.Pointer[Config]
+}
+
+// NewStore creates a store with the initial configuration
+func NewStore(cfg *Config) *Store {
+	s := &Store{}
+	s.current.Store(cfg)
+	return s
+}
+
+// Current returns the configuration in effect
+func (s *Store) Current() *Config {
+	return s.current.Load()
+}
+
+// Swap replaces the configuration and returns the previous one
+func (s *Store) Swap(cfg *Config) *Config {
+	return s.current.Swap(cfg)
+}
+
+// WatchedFiles returns the files the configuration was read from, which should trigger a reload when changed
+func (c *Config) WatchedFiles(configPath string) []string {
+	if configPath == "" {
+		configPath = os.Getenv(ConfigFileEnv)
+	}
+
+	var files []string
+	for _, f := range []string{configPath, c.Prompts.SystemFile, c.Classification.File, c.Vault.File} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// fileState identifies a version of a file
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// statFile returns the current state of a file
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
+
+// Watch polls files and sends on the returned channel when any of them changes.
+// Polling is used rather than inotify because editors and config management
+// usually replace files by renaming, which breaks watches on the file itself.
+// files is called before every poll, so the set can change after a reload.
+func Watch(ctx context.Context, files func() []string, interval time.Duration) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+
+	go func() {
+		states := make(map[string]fileState)
+		for _, f := range files() {
+			states[f] = statFile(f)
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current := make(map[string]fileState)
+			modified := false
+			for _, f := range files() {
+				state := statFile(f)
+				current[f] = state
+				if previous, ok := states[f]; ok && previous != state {
+					modified = true
+				}
+			}
+			states = current
+
+			if modified {
+				// Coalesce changes while a reload is still pending
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return changed
+}
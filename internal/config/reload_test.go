@@ -0,0 +1,65 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	old := validConfig()
+	new := validConfig()
+	new.Schedule.Daily = "30 8 * * 1-5"
+	new.Telegram.Token = "rotated"
+	new.News.Limit = 10
+
+	var got []string
+	for _, c := range Diff(old, new) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"telegram.token: [REDACTED] → [REDACTED] (requires restart)",
+		"news.limit: 5 → 10",
+		"schedule.daily: 0 7 * * * → 30 8 * * 1-5",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Diff = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if changes := Diff(old, validConfig()); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("log_level: info\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := Watch(ctx, func() []string { return []string{path} }, 10*time.Millisecond)
+
+	select {
+	case <-changed:
+		t.Fatal("unexpected change before the file was modified")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("log_level: debug\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("change was not detected")
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Screen metrics computed by the screener
+const (
+	MetricPrice         = "price"          // last close, RUB
+	MetricTurnover      = "turnover"       // average daily turnover over 20 sessions, RUB
+	MetricRSI           = "rsi"            // 14-day relative strength index
+	MetricChange        = "change"         // price change over 30 days, percent
+	MetricDividendYield = "dividend_yield" // dividends with a record date in the last 12 months to the price, percent
+	MetricType          = "type"           // share or etf
+	MetricSector        = "sector"         // sector of the instruments service, e.g. energy
+)
+
+// numericMetrics are compared as numbers, the other metrics as text
+var numericMetrics = map[string]bool{
+	MetricPrice:         true,
+	MetricTurnover:      true,
+	MetricRSI:           true,
+	MetricChange:        true,
+	MetricDividendYield: true,
+}
+
+// textMetrics are compared as case-insensitive text
+var textMetrics = map[string]bool{
+	MetricType:   true,
+	MetricSector: true,
+}
+
+// ScreenRule is a single condition of a screen, e.g. "dividend_yield > 8%"
+type ScreenRule struct {
+	Metric string
+	Op     string // >, >=, <, <=, = or !=
+	Number float64
+	Text   string // the value of a text metric, lowercase
+}
+
+// Numeric reports whether the rule compares a number
+func (r ScreenRule) Numeric() bool {
+	return numericMetrics[r.Metric]
+}
+
+// String formats the rule the way it is written in the configuration
+func (r ScreenRule) String() string {
+	if r.Numeric() {
+		return fmt.Sprintf("%s %s %g", r.Metric, r.Op, r.Number)
+	}
+	return fmt.Sprintf("%s %s %s", r.Metric, r.Op, r.Text)
+}
+
+// valueSuffixes scale numbers written as 50M or 1.5B
+var valueSuffixes = map[string]float64{"K": 1e3, "M": 1e6, "B": 1e9}
+
+// ParseScreenRule parses a condition of the form "<metric> <op> <value>".
+// Numbers may end with % (ignored) or with K, M or B for thousands, millions and billions.
+func ParseScreenRule(s string) (ScreenRule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return ScreenRule{}, fmt.Errorf("%q is not of the form \"metric op value\"", s)
+	}
+	rule := ScreenRule{Metric: strings.ToLower(fields[0]), Op: fields[1]}
+
+	switch rule.Op {
+	case ">", ">=", "<", "<=", "=", "!=":
+	default:
+		return ScreenRule{}, fmt.Errorf("%q: unknown operator %q", s, rule.Op)
+	}
+
+	switch {
+	case numericMetrics[rule.Metric]:
+		value := strings.TrimSuffix(fields[2], "%")
+		scale := 1.0
+		if len(value) > 0 {
+			if m, ok := valueSuffixes[strings.ToUpper(value[len(value)-1:])]; ok {
+				scale, value = m, value[:len(value)-1]
+			}
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return ScreenRule{}, fmt.Errorf("%q: %q is not a number", s, fields[2])
+		}
+		rule.Number = number * scale
+	case textMetrics[rule.Metric]:
+		if rule.Op != "=" && rule.Op != "!=" {
+			return ScreenRule{}, fmt.Errorf("%q: %s can only be compared with = or !=", s, rule.Metric)
+		}
+		rule.Text = strings.ToLower(fields[2])
+	default:
+		return ScreenRule{}, fmt.Errorf("%q: unknown metric %q, use one of %s", s, rule.Metric, strings.Join(screenMetrics(), ", "))
+	}
+	return rule, nil
+}
+
+// screenMetrics lists the metric names for error messages
+func screenMetrics() []string {
+	var names []string
+	for name := range numericMetrics {
+		names = append(names, name)
+	}
+	for name := range textMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
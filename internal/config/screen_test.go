@@ -0,0 +1,41 @@
+package config
+
+import "testing"
+
+func TestParseScreenRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ScreenRule
+		wantErr bool
+	}{
+		{in: "dividend_yield > 8%", want: ScreenRule{Metric: MetricDividendYield, Op: ">", Number: 8}},
+		{in: "turnover >= 50M", want: ScreenRule{Metric: MetricTurnover, Op: ">=", Number: 50e6}},
+		{in: "Turnover > 1.5b", want: ScreenRule{Metric: MetricTurnover, Op: ">", Number: 1.5e9}},
+		{in: "rsi < 30", want: ScreenRule{Metric: MetricRSI, Op: "<", Number: 30}},
+		{in: "change <= -10%", want: ScreenRule{Metric: MetricChange, Op: "<=", Number: -10}},
+		{in: "sector != Energy", want: ScreenRule{Metric: MetricSector, Op: "!=", Text: "energy"}},
+		{in: "type = etf", want: ScreenRule{Metric: MetricType, Op: "=", Text: "etf"}},
+		{in: "rsi<30", wantErr: true},
+		{in: "pe < 5", wantErr: true},
+		{in: "rsi ~ 30", wantErr: true},
+		{in: "price > cheap", wantErr: true},
+		{in: "sector > energy", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseScreenRule(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseScreenRule: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("rule = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
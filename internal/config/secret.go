@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"invest-manager/internal/secrets"
+	"os"
+	"strings"
+)
+
+// Secret is a sensitive config value that never shows up in printed or serialized form.
+// Use Value to get the actual secret.
+type Secret string
+
+// Value returns the secret itself
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String implements fmt.Stringer, masking the value
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return secrets.Redacted
+}
+
+// GoString masks the value for the %#v verb
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+// MarshalYAML masks the value when the config is dumped as YAML
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// MarshalJSON masks the value when the config is dumped as JSON
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// secretVar binds a secret to an environment variable and to a NAME_FILE variable
+// holding the path to a file with the value, as used by Docker and systemd credentials
+func secretVar(name, path string, field func(c *Config) *Secret) envVar {
+	return envVar{name: name, path: path, secret: true, apply: func(c *Config, value string) error {
+		*field(c) = Secret(value)
+		return nil
+	}}
+}
+
+// readSecretFile reads a secret from a file, dropping the trailing newline editors add
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// applyVault fills secrets from the encrypted vault, skipping paths already set by the environment
+func (c *Config) applyVault(fromEnv map[string]bool) error {
+	if c.Vault.File == "" {
+		return nil
+	}
+
+	key, err := c.Vault.Key()
+	if err != nil {
+		return err
+	}
+	vault, err := secrets.Open(c.Vault.File, key)
+	if err != nil {
+		return fmt.Errorf("failed to open vault %s: %w", c.Vault.File, err)
+	}
+
+	for _, v := range envVars {
+		if !v.secret || v.path == vaultPassphrasePath || fromEnv[v.path] {
+			continue
+		}
+		if value, ok := vault.Get(v.path); ok {
+			if err := v.apply(c, value); err != nil {
+				return fmt.Errorf("invalid vault entry %s: %w", v.path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Key returns the vault key from the key file or the passphrase
+func (v VaultConfig) Key() ([]byte, error) {
+	if v.KeyFile != "" {
+		return secrets.ReadKeyFile(v.KeyFile)
+	}
+	if v.Passphrase != "" {
+		return []byte(v.Passphrase.Value()), nil
+	}
+	return nil, fmt.Errorf("vault %s needs VAULT_PASSPHRASE or vault.key_file to be unlocked", v.File)
+}
+
+// SecretPaths returns the config paths of all secrets, which are also the vault entry names
+func SecretPaths() []string {
+	var paths []string
+	for _, v := range envVars {
+		if v.secret && v.path != vaultPassphrasePath {
+			paths = append(paths, v.path)
+		}
+	}
+	return paths
+}
+
+// IsSecretPath reports whether a config path holds a secret that can be stored in the vault
+func IsSecretPath(path string) bool {
+	for _, p := range SecretPaths() {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// SecretValues returns every non-empty secret, e.g. to register them with a log redactor
+func (c *Config) SecretValues() []string {
+	var values []string
+	for _, s := range []Secret{
+		c.Tinkoff.Token,
+		c.OpenAI.APIKey,
+		c.Telegram.Token,
+		c.Telegram.Webhook.Secret,
+		c.News.APIToken,
+		c.Vault.Passphrase,
+	} {
+		if s != "" {
+			values = append(values, s.Value())
+		}
+	}
+	return values
+}
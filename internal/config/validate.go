@@ -0,0 +1,462 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a single validation error bound to a config field
+type Problem struct {
+	Path    string
+	Message string
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []Problem
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("invalid configuration (%d problems):", len(e.Problems)))
+	for _, p := range e.Problems {
+		sb.WriteString(fmt.Sprintf("\n  - %s: %s", p.Path, p.Message))
+	}
+	return sb.String()
+}
+
+// validator collects problems
+type validator struct {
+	problems []Problem
+}
+
+// add records a problem for a path
+func (v *validator) add(path, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// required records a problem if the value is empty
+func (v *validator) required(path, value, reason string) {
+	if value != "" {
+		return
+	}
+	msg := "is required"
+	if reason != "" {
+		msg += " " + reason
+	}
+	if env := envName(path); env != "" {
+		if IsSecretPath(path) {
+			msg += fmt.Sprintf(" (set it via %s, %s_FILE or the vault)", env, env)
+		} else {
+			msg += fmt.Sprintf(" (set it in the config file or via %s)", env)
+		}
+	}
+	v.add(path, "%s", msg)
+}
+
+// Component is an integration whose settings are only validated when it is used
+type Component string
+
+// Components named after their config sections
+const (
+	ComponentBroker   Component = "tinkoff"
+	ComponentLLM      Component = "openai"
+	ComponentTelegram Component = "telegram"
+	ComponentNews     Component = "news"
+
+	// ComponentCore selects only the general settings, for commands that use no integration
+	ComponentCore Component = "core"
+)
+
+// allComponents lists every component that can be left out of validation
+var allComponents = []Component{ComponentBroker, ComponentLLM, ComponentTelegram, ComponentNews}
+
+// Validate checks the configuration and resolves derived fields.
+// If components are given, only the sections of those integrations are checked
+// together with the general settings; otherwise everything is.
+// All problems are reported at once in a *ValidationError.
+func (c *Config) Validate(components ...Component) error {
+	v := &validator{problems: slices.Clone(c.envProblems)}
+
+	v.required("tinkoff.token", c.Tinkoff.Token.Value(), "")
+
+	v.required("telegram.token", c.Telegram.Token.Value(), "")
+	v.required("telegram.chat_id", c.Telegram.ChatID, "")
+	if c.Telegram.ChatID != "" {
+		if _, err := strconv.ParseInt(c.Telegram.ChatID, 10, 64); err != nil {
+			v.add("telegram.chat_id", "must be a numeric chat ID")
+		}
+	}
+
+	switch c.Telegram.Mode {
+	case TelegramModePolling:
+	case TelegramModeWebhook:
+		webhook := c.Telegram.Webhook
+		v.required("telegram.webhook.url", webhook.PublicURL, "in webhook mode")
+		if webhook.PublicURL != "" {
+			if u, err := url.Parse(webhook.PublicURL); err != nil || u.Scheme != "https" || u.Host == "" {
+				v.add("telegram.webhook.url", "must be an absolute https URL")
+			}
+		}
+		v.required("telegram.webhook.secret", webhook.Secret.Value(), "in webhook mode")
+		v.required("telegram.webhook.listen", webhook.ListenAddr, "in webhook mode")
+		if (webhook.CertFile == "") != (webhook.KeyFile == "") {
+			v.add("telegram.webhook", "cert_file and key_file must be set together")
+		}
+	default:
+		v.add("telegram.mode", "must be %q or %q, got %q", TelegramModePolling, TelegramModeWebhook, c.Telegram.Mode)
+	}
+
+	if c.OpenAI.Enabled {
+		v.required("openai.api_key", c.OpenAI.APIKey.Value(), "while openai.enabled is true")
+		v.required("openai.base_url", c.OpenAI.BaseURL, "while openai.enabled is true")
+		v.required("openai.model", c.OpenAI.Model, "while openai.enabled is true")
+	}
+	for model, price := range c.OpenAI.Prices {
+		if price.Prompt < 0 || price.Completion < 0 {
+			v.add("openai.prices", "prices of %s must not be negative", model)
+		}
+	}
+	budget := c.OpenAI.Budget
+	if budget.Daily < 0 {
+		v.add("openai.budget.daily", "must not be negative, got %g", budget.Daily)
+	}
+	if budget.Monthly < 0 {
+		v.add("openai.budget.monthly", "must not be negative, got %g", budget.Monthly)
+	}
+	// Without a price the cost of a model cannot be counted against the budget
+	if c.OpenAI.Enabled && (budget.Daily > 0 || budget.Monthly > 0) {
+		for _, model := range []string{c.OpenAI.Model, budget.FallbackModel} {
+			if _, ok := c.OpenAI.Prices[model]; model != "" && !ok {
+				v.add("openai.prices", "has no price for %s, which is needed to enforce the budget", model)
+			}
+		}
+	}
+
+	if c.News.Enabled {
+		v.required("news.api_token", c.News.APIToken.Value(), "while news.enabled is true")
+	}
+
+	if c.News.Enabled && (c.News.Limit < 1 || c.News.Limit > 100) {
+		v.add("news.limit", "must be between 1 and 100, got %d", c.News.Limit)
+	}
+
+	v.required("schedule.daily", c.Schedule.Daily, "")
+	if c.Schedule.Daily != "" {
+		if _, err := cron.ParseStandard(c.Schedule.Daily); err != nil {
+			v.add("schedule.daily", "invalid cron spec %q: %v", c.Schedule.Daily, err)
+		}
+	}
+	if c.Schedule.Weekly != "" {
+		if _, err := cron.ParseStandard(c.Schedule.Weekly); err != nil {
+			v.add("schedule.weekly", "invalid cron spec %q: %v", c.Schedule.Weekly, err)
+		}
+	}
+	if c.Schedule.MonthlyReminderDay < 0 || c.Schedule.MonthlyReminderDay > 28 {
+		v.add("schedule.monthly_reminder_day", "must be between 1 and 28, or 0 to disable, got %d", c.Schedule.MonthlyReminderDay)
+	}
+	if c.Schedule.RecordDir != "" {
+		if info, err := os.Stat(c.Schedule.RecordDir); err != nil {
+			v.add("schedule.record_dir", "%v", err)
+		} else if !info.IsDir() {
+			v.add("schedule.record_dir", "%s is not a directory", c.Schedule.RecordDir)
+		}
+	}
+
+	c.Prompts.System = ""
+	if c.Prompts.SystemFile != "" {
+		data, err := os.ReadFile(c.Prompts.SystemFile)
+		switch {
+		case err != nil:
+			v.add("prompts.system_file", "cannot be read: %v", err)
+		case strings.TrimSpace(string(data)) == "":
+			v.add("prompts.system_file", "is empty")
+		default:
+			c.Prompts.System = string(data)
+		}
+	}
+
+	c.Classification.Overrides = nil
+	if c.Classification.File != "" {
+		overrides, err := readClassification(c.Classification.File)
+		if err != nil {
+			v.add("classification.file", "%v", err)
+		} else {
+			c.Classification.Overrides = overrides
+		}
+	}
+
+	if c.Monitoring.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Monitoring.Listen); err != nil {
+			v.add("monitoring.listen", "must be host:port or :port, got %q", c.Monitoring.Listen)
+		}
+	}
+
+	if c.Trading.Enabled {
+		trading := c.Trading
+		if trading.MaxOrderAmount <= 0 {
+			v.add("trading.max_order_amount", "must be positive while trading.enabled is true, got %g", trading.MaxOrderAmount)
+		}
+		if trading.MaxDailyAmount < trading.MaxOrderAmount {
+			v.add("trading.max_daily_amount", "must not be less than max_order_amount, got %g", trading.MaxDailyAmount)
+		}
+		if trading.OrderAmount <= 0 || trading.OrderAmount > trading.MaxOrderAmount {
+			v.add("trading.order_amount", "must be positive and not more than max_order_amount, got %g", trading.OrderAmount)
+		}
+		if trading.SellShare <= 0 || trading.SellShare > 1 {
+			v.add("trading.sell_share", "must be greater than 0 and at most 1, got %g", trading.SellShare)
+		}
+		if trading.CommissionRate < 0 || trading.CommissionRate >= 0.1 {
+			v.add("trading.commission_rate", "must be between 0 and 0.1, got %g", trading.CommissionRate)
+		}
+	}
+
+	if c.Paper.Enabled {
+		paper := c.Paper
+		if paper.InitialCash < 0 {
+			v.add("paper.initial_cash", "must not be negative, got %g", paper.InitialCash)
+		}
+		shares := []struct {
+			path  string
+			value float64
+		}{
+			{"paper.buy_share", paper.BuyShare},
+			{"paper.sell_share", paper.SellShare},
+			{"paper.max_position_share", paper.MaxPositionShare},
+		}
+		for _, share := range shares {
+			if share.value <= 0 || share.value > 1 {
+				v.add(share.path, "must be greater than 0 and at most 1, got %g", share.value)
+			}
+		}
+		if paper.CommissionRate < 0 || paper.CommissionRate >= 0.1 {
+			v.add("paper.commission_rate", "must be between 0 and 0.1, got %g", paper.CommissionRate)
+		}
+	}
+
+	if c.Watchlist.MaxItems < 1 || c.Watchlist.MaxItems > 100 {
+		v.add("watchlist.max_items", "must be between 1 and 100, got %d", c.Watchlist.MaxItems)
+	}
+	if c.Watchlist.NewsLimit < 0 || c.Watchlist.NewsLimit > 10 {
+		v.add("watchlist.news_limit", "must be between 0 and 10, got %d", c.Watchlist.NewsLimit)
+	}
+
+	c.Screener.Rules = make(map[string][]ScreenRule, len(c.Screener.Screens))
+	names := make([]string, 0, len(c.Screener.Screens))
+	for name := range c.Screener.Screens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := "screener.screens." + name
+		if len(c.Screener.Screens[name]) == 0 {
+			v.add(path, "needs at least one rule")
+			continue
+		}
+		for _, s := range c.Screener.Screens[name] {
+			rule, err := ParseScreenRule(s)
+			if err != nil {
+				v.add(path, "%v", err)
+				continue
+			}
+			c.Screener.Rules[name] = append(c.Screener.Rules[name], rule)
+		}
+	}
+	if c.Screener.Default != "" && c.Screener.Screens[c.Screener.Default] == nil {
+		v.add("screener.default", "unknown screen %q", c.Screener.Default)
+	}
+	if c.Screener.MaxCandidates < 1 || c.Screener.MaxCandidates > 50 {
+		v.add("screener.max_candidates", "must be between 1 and 50, got %d", c.Screener.MaxCandidates)
+	}
+
+	if c.Stops.CheckMinutes < 1 || c.Stops.CheckMinutes > 60 {
+		v.add("stops.check_minutes", "must be between 1 and 60, got %d", c.Stops.CheckMinutes)
+	}
+
+	if len(c.Performance.Benchmarks) > 5 {
+		v.add("performance.benchmarks", "at most 5 indices, got %d", len(c.Performance.Benchmarks))
+	}
+	for i, ticker := range c.Performance.Benchmarks {
+		if strings.TrimSpace(ticker) == "" {
+			v.add(fmt.Sprintf("performance.benchmarks[%d]", i), "must not be empty")
+		}
+	}
+
+	if c.Tax.ExemptNoticeDays < 1 || c.Tax.ExemptNoticeDays > 365 {
+		v.add("tax.exempt_notice_days", "must be between 1 and 365, got %d", c.Tax.ExemptNoticeDays)
+	}
+	if c.Tax.HarvestMonth < 1 || c.Tax.HarvestMonth > 12 {
+		v.add("tax.harvest_month", "must be between 1 and 12, got %d", c.Tax.HarvestMonth)
+	}
+	if c.Tax.IISContributionLimit < 0 {
+		v.add("tax.iis_contribution_limit", "must not be negative, got %g", c.Tax.IISContributionLimit)
+	}
+	if c.Tax.IISDeductionBase <= 0 {
+		v.add("tax.iis_deduction_base", "must be positive, got %g", c.Tax.IISDeductionBase)
+	} else if c.Tax.IISContributionLimit > 0 && c.Tax.IISDeductionBase > c.Tax.IISContributionLimit {
+		v.add("tax.iis_deduction_base", "must not exceed tax.iis_contribution_limit, got %g", c.Tax.IISDeductionBase)
+	}
+
+	accounts := make(map[string]bool)
+	for i, plan := range c.Contributions.Plans {
+		path := fmt.Sprintf("contributions.plans[%d]", i)
+		switch {
+		case plan.Account == "":
+			v.add(path+".account", "must not be empty")
+		case accounts[plan.Account]:
+			v.add(path+".account", "duplicate account %s", plan.Account)
+		}
+		accounts[plan.Account] = true
+		if plan.Monthly <= 0 {
+			v.add(path+".monthly", "must be positive, got %g", plan.Monthly)
+		}
+		if plan.Yearly < 0 {
+			v.add(path+".yearly", "must not be negative, got %g", plan.Yearly)
+		}
+	}
+	var weights float64
+	tickers := make([]string, 0, len(c.Contributions.Allocation))
+	for ticker := range c.Contributions.Allocation {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+	for _, ticker := range tickers {
+		weight := c.Contributions.Allocation[ticker]
+		if strings.TrimSpace(ticker) == "" || weight <= 0 {
+			v.add("contributions.allocation", "every ticker needs a positive weight, got %q: %g", ticker, weight)
+		}
+		weights += weight
+	}
+	if len(c.Contributions.Allocation) > 0 && math.Abs(weights-1) > 0.001 {
+		v.add("contributions.allocation", "weights must sum to 1, got %g", weights)
+	}
+
+	if c.Models.PeriodDays < 30 || c.Models.PeriodDays > 1825 {
+		v.add("models.period_days", "must be between 30 and 1825, got %d", c.Models.PeriodDays)
+	}
+	if c.Models.RebalanceMonths < 0 || c.Models.RebalanceMonths > 12 {
+		v.add("models.rebalance_months", "must be between 1 and 12, or 0 to never rebalance, got %d", c.Models.RebalanceMonths)
+	}
+	modelNames := make([]string, 0, len(c.Models.Portfolios))
+	for name := range c.Models.Portfolios {
+		modelNames = append(modelNames, name)
+	}
+	sort.Strings(modelNames)
+	for _, name := range modelNames {
+		path := "models.portfolios." + name
+		model := c.Models.Portfolios[name]
+		if strings.TrimSpace(name) == "" {
+			v.add("models.portfolios", "every model needs a name")
+		}
+		if len(model.Weights) == 0 {
+			v.add(path+".weights", "must list at least one ticker")
+			continue
+		}
+		tickers := make([]string, 0, len(model.Weights))
+		for ticker := range model.Weights {
+			tickers = append(tickers, ticker)
+		}
+		sort.Strings(tickers)
+		var weights float64
+		for _, ticker := range tickers {
+			weight := model.Weights[ticker]
+			if strings.TrimSpace(ticker) == "" || weight <= 0 {
+				v.add(path+".weights", "every ticker needs a positive weight, got %q: %g", ticker, weight)
+			}
+			weights += weight
+		}
+		if math.Abs(weights-1) > 0.001 {
+			v.add(path+".weights", "weights must sum to 1, got %g", weights)
+		}
+	}
+
+	location, err := time.LoadLocation(c.TimezoneName)
+	if err != nil {
+		v.add("timezone", "unknown time zone %q", c.TimezoneName)
+	} else {
+		c.Timezone = location
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		v.add("log_level", "must be one of debug, info, warn, error, got %q", c.LogLevel)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		v.add("log_format", "must be text or json, got %q", c.LogFormat)
+	}
+
+	problems := filterProblems(v.problems, components)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// filterProblems drops problems in sections of components that are not used
+func filterProblems(problems []Problem, used []Component) []Problem {
+	if len(used) == 0 {
+		return problems
+	}
+
+	var filtered []Problem
+	for _, p := range problems {
+		section := Component(strings.SplitN(p.Path, ".", 2)[0])
+		if slices.Contains(allComponents, section) && !slices.Contains(used, section) {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+// assetClasses are the asset classes a classification may set
+var assetClasses = []string{"equity", "bond", "fund", "cash", "derivative", "other"}
+
+// readClassification reads the classification overrides and keys them by upper-case ticker or FIGI
+func readClassification(path string) (map[string]Classification, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot be read: %v", err)
+	}
+	var entries map[string]Classification
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("cannot be parsed: %v", err)
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	overrides := make(map[string]Classification, len(entries))
+	for _, key := range keys {
+		entry := entries[key]
+		id := strings.ToUpper(strings.TrimSpace(key))
+		switch {
+		case id == "":
+			return nil, errors.New("has an entry without a ticker")
+		case entry.AssetClass != "" && !slices.Contains(assetClasses, entry.AssetClass):
+			return nil, fmt.Errorf("%s has asset class %q, want one of %s", key, entry.AssetClass, strings.Join(assetClasses, ", "))
+		}
+		if _, ok := overrides[id]; ok {
+			return nil, fmt.Errorf("%s is listed twice", id)
+		}
+		entry.Country = strings.ToUpper(entry.Country)
+		overrides[id] = entry
+	}
+	return overrides, nil
+}
@@ -0,0 +1,197 @@
+// Package contributions checks the deposits of each account against the contribution plan:
+// whether the money of the month is in, how far the yearly goals are, and how the new
+// money should be split between the instruments to approach the target allocation.
+package contributions
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/refresh"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// monthsPerYear turns a monthly deposit into the default yearly goal
+const monthsPerYear = 12
+
+// Broker supplies the deposits and the positions; *invest.Client is the production one
+type Broker interface {
+	AccountID() string
+	GetAccounts(ctx context.Context) ([]invest.Account, error)
+	GetAccountCashFlows(ctx context.Context, accountID string, from, to time.Time) ([]invest.CashFlow, error)
+	GetPortfolio(ctx context.Context) (*invest.Portfolio, error)
+}
+
+// Progress shows the deposits of an account against its plan
+type Progress struct {
+	Account   string  `json:"account"`
+	Name      string  `json:"name"`
+	Monthly   float64 `json:"monthly"`
+	Deposited float64 `json:"deposited"` // this month
+	Missing   float64 `json:"missing"`   // left to deposit this month
+	Yearly    float64 `json:"yearly"`
+	YearTotal float64 `json:"year_total"` // deposited this year
+	OnTrack   bool    `json:"on_track"`   // the year is at least as far as its elapsed months
+}
+
+// Split is the part of the new money for an instrument
+type Split struct {
+	Ticker  string  `json:"ticker"`
+	Amount  float64 `json:"amount"`
+	Current float64 `json:"current"` // weight among the target instruments now
+	Target  float64 `json:"target"`
+}
+
+// Status is the state of the plan at a moment
+type Status struct {
+	Time     time.Time  `json:"time"`
+	Accounts []Progress `json:"accounts"`
+	Met      bool       `json:"met"`              // every account has its money of the month
+	Amount   float64    `json:"amount,omitempty"` // new money of the selected account that Split distributes
+	Split    []Split    `json:"split,omitempty"`
+}
+
+// Plan checks the deposits against the configured plan. It is safe for concurrent use.
+type Plan struct {
+	broker Broker
+	logger *slog.Logger
+	latest refresh.Latest[config.ContributionsConfig, Status]
+}
+
+// New creates a plan
+func New(settings config.ContributionsConfig, broker Broker, logger *slog.Logger) *Plan {
+	p := &Plan{broker: broker, logger: logger}
+	p.latest.Reload(settings)
+	return p
+}
+
+// Reload applies the plan of a new configuration from the next check on
+func (p *Plan) Reload(settings config.ContributionsConfig) {
+	p.latest.Reload(settings)
+}
+
+// Enabled reports whether any account has a plan
+func (p *Plan) Enabled() bool {
+	return len(p.latest.Settings().Plans) > 0
+}
+
+// Refresh checks the deposits of the month and year of now, in its time zone. Without plans
+// there is nothing to check and the status is nil. A failed check leaves no status, so the
+// reminder does not go out with the deposits of an earlier day.
+func (p *Plan) Refresh(ctx context.Context, now time.Time) (*Status, error) {
+	return p.latest.Refresh(func(settings config.ContributionsConfig) (*Status, error) {
+		return p.check(ctx, settings, now)
+	})
+}
+
+// Latest returns the status of the last check, nil if it failed or there is no plan
+func (p *Plan) Latest() *Status {
+	return p.latest.Get()
+}
+
+// check builds the status of every planned account from its deposits
+func (p *Plan) check(ctx context.Context, settings config.ContributionsConfig, now time.Time) (*Status, error) {
+	if len(settings.Plans) == 0 {
+		return nil, nil
+	}
+
+	accounts, err := p.broker.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	names := make(map[string]string, len(accounts))
+	for _, acc := range accounts {
+		names[acc.ID] = acc.Name
+	}
+	selected := p.broker.AccountID()
+	if selected == "" && len(accounts) > 0 {
+		selected = accounts[0].ID
+	}
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	status := &Status{Time: now, Met: true}
+	for _, plan := range settings.Plans {
+		flows, err := p.broker.GetAccountCashFlows(ctx, plan.Account, yearStart, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get deposits of account %s: %w", plan.Account, err)
+		}
+		progress := Progress{Account: plan.Account, Name: names[plan.Account], Monthly: plan.Monthly, Yearly: plan.Yearly}
+		if progress.Yearly == 0 {
+			progress.Yearly = plan.Monthly * monthsPerYear
+		}
+		for _, f := range flows {
+			// Withdrawals do not undo a deposit: the plan is about putting money in
+			if f.Amount <= 0 {
+				continue
+			}
+			progress.YearTotal += f.Amount
+			if !f.Time.Before(monthStart) {
+				progress.Deposited += f.Amount
+			}
+		}
+		progress.Missing = math.Max(plan.Monthly-progress.Deposited, 0)
+		progress.OnTrack = progress.YearTotal >= progress.Yearly*float64(now.Month())/monthsPerYear
+		if progress.Missing > 0 {
+			status.Met = false
+		}
+		status.Accounts = append(status.Accounts, progress)
+
+		if plan.Account == selected && len(settings.Allocation) > 0 {
+			portfolio, err := p.broker.GetPortfolio(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get portfolio: %w", err)
+			}
+			status.Amount = plan.Monthly
+			status.Split = split(plan.Monthly, settings.Allocation, portfolio)
+		}
+	}
+	return status, nil
+}
+
+// split distributes the new money so the target instruments approach their weights: each gets
+// its shortfall from the target value after the deposit, scaled down to the money available
+func split(amount float64, targets map[string]float64, portfolio *invest.Portfolio) []Split {
+	current := make(map[string]float64, len(targets))
+	for _, pos := range portfolio.Positions {
+		ticker := strings.ToUpper(pos.Ticker)
+		current[ticker] += pos.Value()
+	}
+	var total float64
+	for ticker := range targets {
+		total += current[strings.ToUpper(ticker)]
+	}
+
+	after := total + amount
+	shortfalls := make(map[string]float64, len(targets))
+	var sum float64
+	for ticker, weight := range targets {
+		short := math.Max(weight*after-current[strings.ToUpper(ticker)], 0)
+		shortfalls[ticker] = short
+		sum += short
+	}
+
+	var result []Split
+	for ticker, weight := range targets {
+		s := Split{Ticker: strings.ToUpper(ticker), Target: weight}
+		if sum > 0 {
+			s.Amount = amount * shortfalls[ticker] / sum
+		}
+		if total > 0 {
+			s.Current = current[s.Ticker] / total
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Amount != result[j].Amount {
+			return result[i].Amount > result[j].Amount
+		}
+		return result[i].Ticker < result[j].Ticker
+	})
+	return result
+}
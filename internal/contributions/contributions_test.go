@@ -0,0 +1,122 @@
+package contributions
+
+import (
+	"context"
+	"invest-manager/internal/config"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/testutil"
+	"testing"
+	"time"
+)
+
+// The fake must stay usable in place of the production broker
+var (
+	_ Broker = (*fake.Broker)(nil)
+	_ Broker = (*invest.Client)(nil)
+)
+
+func testBroker() *fake.Broker {
+	return &fake.Broker{
+		Accounts: []invest.Account{{ID: "broker", Name: "Брокерский"}, {ID: "iis", Name: "ИИС", Type: "iis"}},
+		Portfolio: &invest.Portfolio{Positions: []invest.Position{
+			{Ticker: "SBER", Quantity: 100, CurrentPrice: 300},
+			{Ticker: "TMOS", Quantity: 1000, CurrentPrice: 7},
+			{Ticker: "GAZP", Quantity: 10, CurrentPrice: 150},
+		}},
+		AccountFlows: map[string][]invest.CashFlow{
+			"broker": {
+				{Time: testutil.Date(2026, time.January, 10, 10), Amount: 30000},
+				{Time: testutil.Date(2026, time.October, 3, 10), Amount: 20000},
+				{Time: testutil.Date(2026, time.October, 4, 10), Amount: -15000},
+			},
+			"iis": {
+				{Time: testutil.Date(2026, time.September, 20, 10), Amount: 400000},
+			},
+		},
+	}
+}
+
+func testSettings() config.ContributionsConfig {
+	return config.ContributionsConfig{
+		Plans: []config.ContributionPlan{
+			{Account: "broker", Monthly: 20000},
+			{Account: "iis", Monthly: 30000, Yearly: 400000},
+		},
+		Allocation: map[string]float64{"SBER": 0.5, "TMOS": 0.5},
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	plan := New(testSettings(), testBroker(), logging.Discard())
+	status, err := plan.Refresh(context.Background(), testutil.Date(2026, time.October, 18, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Latest() != status || len(status.Accounts) != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	// The withdrawal does not undo the deposit of the month
+	broker := status.Accounts[0]
+	if broker.Deposited != 20000 || broker.Missing != 0 || broker.YearTotal != 50000 || broker.Yearly != 240000 || broker.OnTrack {
+		t.Errorf("broker progress = %+v", broker)
+	}
+	// The IIS goal of the year is met, but nothing came in October
+	iis := status.Accounts[1]
+	if iis.Name != "ИИС" || iis.Deposited != 0 || iis.Missing != 30000 || !iis.OnTrack {
+		t.Errorf("IIS progress = %+v", iis)
+	}
+	if status.Met {
+		t.Error("plan met without the IIS deposit of the month")
+	}
+
+	// SBER is 30000 and TMOS 7000: after 20000 both should be 28500, so all goes to TMOS
+	if status.Amount != 20000 || len(status.Split) != 2 {
+		t.Fatalf("split of %v = %+v", status.Amount, status.Split)
+	}
+	if s := status.Split[0]; s.Ticker != "TMOS" || !testutil.AlmostEqual(s.Amount, 20000) || !testutil.AlmostEqual(s.Current, 7.0/37) {
+		t.Errorf("split = %+v", status.Split)
+	}
+}
+
+func TestRefreshMet(t *testing.T) {
+	broker := testBroker()
+	broker.AccountFlows["iis"] = append(broker.AccountFlows["iis"], invest.CashFlow{Time: testutil.Date(2026, time.October, 5, 10), Amount: 30000})
+	status, err := New(testSettings(), broker, logging.Discard()).Refresh(context.Background(), testutil.Date(2026, time.October, 18, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Met {
+		t.Errorf("plan not met: %+v", status.Accounts)
+	}
+}
+
+func TestRefreshWithoutPlan(t *testing.T) {
+	plan := New(config.ContributionsConfig{}, testBroker(), logging.Discard())
+	status, err := plan.Refresh(context.Background(), testutil.Date(2026, time.October, 18, 10))
+	if status != nil || err != nil || plan.Enabled() {
+		t.Errorf("without plans = %+v, %v; want nothing to check", status, err)
+	}
+}
+
+func TestSplitScalesShortfalls(t *testing.T) {
+	portfolio := &invest.Portfolio{Positions: []invest.Position{
+		{Ticker: "SBER", Quantity: 10, CurrentPrice: 100},
+		{Ticker: "TMOS", Quantity: 10, CurrentPrice: 100},
+	}}
+	// LKOH is not held yet; tickers are matched in any case
+	got := split(1000, map[string]float64{"sber": 0.4, "tmos": 0.4, "lkoh": 0.2}, portfolio)
+	amounts := make(map[string]float64)
+	var total float64
+	for _, s := range got {
+		amounts[s.Ticker] = s.Amount
+		total += s.Amount
+	}
+	// After 1000 the total is 3000: SBER and TMOS should be 1200 and LKOH 600
+	if !testutil.AlmostEqual(total, 1000) || !testutil.AlmostEqual(amounts["SBER"], 200) ||
+		!testutil.AlmostEqual(amounts["TMOS"], 200) || !testutil.AlmostEqual(amounts["LKOH"], 600) {
+		t.Errorf("split = %+v", got)
+	}
+}
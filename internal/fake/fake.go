@@ -0,0 +1,522 @@
+// Package fake provides in-memory implementations of the external dependencies
+// (broker, news, LLM and Telegram) for tests.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/models"
+	"invest-manager/internal/news"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Broker serves a fixed portfolio and market data
+type Broker struct {
+	Portfolio *invest.Portfolio
+	Accounts  []invest.Account
+	Candles   map[string][]invest.Candle // by FIGI
+	History   []invest.ValuePoint
+	PnL       *invest.PeriodPnL
+	Err       error // returned by every call if set
+
+	Instruments       map[string]*invest.Instrument // by FIGI
+	OrderBooks        map[string]*invest.OrderBook  // by instrument ID
+	OrderStatus       invest.OrderStatus            // reported for placed orders, filled if empty
+	TradedAmount      float64                       // executed trades of the day
+	LastPrices        map[string]float64            // by FIGI
+	Listed            []invest.Instrument           // tradable shares and ETFs
+	Dividends         map[string][]invest.Dividend  // by FIGI
+	Coupons           map[string][]invest.Coupon    // by FIGI
+	StopOrders        []invest.StopOrder            // placed and configured stop orders
+	CashFlows         []invest.CashFlow             // deposits and withdrawals, oldest first
+	Operations        []invest.Operation            // executed operations, oldest first
+	AccountFlows      map[string][]invest.CashFlow  // deposits and withdrawals by account ID
+	AccountPortfolios map[string]*invest.Portfolio  // portfolios by account ID
+	AccountOperations map[string][]invest.Operation // executed operations by account ID, oldest first
+
+	mu      sync.Mutex
+	account string
+	orders  []invest.OrderRequest
+}
+
+// GetPortfolio returns the configured portfolio
+func (b *Broker) GetPortfolio(ctx context.Context) (*invest.Portfolio, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	if b.Portfolio == nil {
+		return nil, errors.New("fake: no portfolio")
+	}
+	return b.Portfolio, nil
+}
+
+// GetAccounts returns the configured accounts
+func (b *Broker) GetAccounts(ctx context.Context) ([]invest.Account, error) {
+	return b.Accounts, b.Err
+}
+
+// AccountID returns the account selected with SetAccount
+func (b *Broker) AccountID() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.account
+}
+
+// SetAccount selects an account
+func (b *Broker) SetAccount(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.account = id
+}
+
+// GetDailyCandles returns the candles of the instrument within the period
+func (b *Broker) GetDailyCandles(ctx context.Context, figi string, from, to time.Time) ([]invest.Candle, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	var candles []invest.Candle
+	for _, c := range b.Candles[figi] {
+		if !c.Time.Before(from) && !c.Time.After(to) {
+			candles = append(candles, c)
+		}
+	}
+	return candles, nil
+}
+
+// GetCashFlows returns the configured deposits and withdrawals within the period
+func (b *Broker) GetCashFlows(ctx context.Context, from, to time.Time) ([]invest.CashFlow, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	var flows []invest.CashFlow
+	for _, f := range b.CashFlows {
+		if !f.Time.Before(from) && !f.Time.After(to) {
+			flows = append(flows, f)
+		}
+	}
+	return flows, nil
+}
+
+// GetAccountCashFlows returns the configured deposits and withdrawals of an account within the period
+func (b *Broker) GetAccountCashFlows(ctx context.Context, accountID string, from, to time.Time) ([]invest.CashFlow, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	var flows []invest.CashFlow
+	for _, f := range b.AccountFlows[accountID] {
+		if !f.Time.Before(from) && !f.Time.After(to) {
+			flows = append(flows, f)
+		}
+	}
+	return flows, nil
+}
+
+// GetOperations returns the configured operations within the period
+func (b *Broker) GetOperations(ctx context.Context, from, to time.Time) ([]invest.Operation, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	var operations []invest.Operation
+	for _, op := range b.Operations {
+		if !op.Time.Before(from) && !op.Time.After(to) {
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+// GetAccountPortfolio returns the configured portfolio of an account
+func (b *Broker) GetAccountPortfolio(ctx context.Context, accountID string) (*invest.Portfolio, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	portfolio, ok := b.AccountPortfolios[accountID]
+	if !ok {
+		return nil, fmt.Errorf("fake: no portfolio of account %s", accountID)
+	}
+	return portfolio, nil
+}
+
+// GetAccountOperations returns the configured operations of an account within the period
+func (b *Broker) GetAccountOperations(ctx context.Context, accountID string, from, to time.Time) ([]invest.Operation, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	var operations []invest.Operation
+	for _, op := range b.AccountOperations[accountID] {
+		if !op.Time.Before(from) && !op.Time.After(to) {
+			operations = append(operations, op)
+		}
+	}
+	return operations, nil
+}
+
+// GetPortfolioHistory returns the configured history from the given time on
+func (b *Broker) GetPortfolioHistory(ctx context.Context, portfolio *invest.Portfolio, from time.Time) ([]invest.ValuePoint, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	var points []invest.ValuePoint
+	for _, p := range b.History {
+		if !p.Time.Before(from) {
+			points = append(points, p)
+		}
+	}
+	return points, nil
+}
+
+// GetPeriodPnL returns the configured P&L
+func (b *Broker) GetPeriodPnL(ctx context.Context, from time.Time) (*invest.PeriodPnL, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	if b.PnL == nil {
+		return &invest.PeriodPnL{From: from, To: time.Now()}, nil
+	}
+	return b.PnL, nil
+}
+
+// GetInstrument returns the configured instrument
+func (b *Broker) GetInstrument(ctx context.Context, figi string) (*invest.Instrument, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	instr, ok := b.Instruments[figi]
+	if !ok {
+		return nil, errors.New("fake: unknown instrument")
+	}
+	return instr, nil
+}
+
+// FindInstrument looks up a configured instrument by ticker
+func (b *Broker) FindInstrument(ctx context.Context, ticker string) (*invest.Instrument, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	for _, instr := range b.Instruments {
+		if strings.EqualFold(instr.Ticker, ticker) {
+			return instr, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", invest.ErrInstrumentNotFound, ticker)
+}
+
+// FindIndex looks up a configured instrument of the index type by ticker
+func (b *Broker) FindIndex(ctx context.Context, ticker string) (*invest.Instrument, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	for _, instr := range b.Instruments {
+		if instr.Type == "index" && strings.EqualFold(instr.Ticker, ticker) {
+			return instr, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: index %s", invest.ErrInstrumentNotFound, ticker)
+}
+
+// GetLastPrices returns the configured prices of the requested instruments
+func (b *Broker) GetLastPrices(ctx context.Context, figis []string) (map[string]float64, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	prices := make(map[string]float64, len(figis))
+	for _, figi := range figis {
+		if price, ok := b.LastPrices[figi]; ok {
+			prices[figi] = price
+		}
+	}
+	return prices, nil
+}
+
+// ListTradable returns the configured tradable instruments
+func (b *Broker) ListTradable(ctx context.Context) ([]invest.Instrument, error) {
+	return b.Listed, b.Err
+}
+
+// GetDividends returns the dividends of the instrument with a record date within the period
+func (b *Broker) GetDividends(ctx context.Context, figi string, from, to time.Time) ([]invest.Dividend, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	var dividends []invest.Dividend
+	for _, d := range b.Dividends[figi] {
+		if !d.RecordDate.Before(from) && !d.RecordDate.After(to) {
+			dividends = append(dividends, d)
+		}
+	}
+	return dividends, nil
+}
+
+// GetBondCoupons returns the coupons of the bond paid within the period
+func (b *Broker) GetBondCoupons(ctx context.Context, figi string, from, to time.Time) ([]invest.Coupon, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	var coupons []invest.Coupon
+	for _, c := range b.Coupons[figi] {
+		if !c.Date.Before(from) && !c.Date.After(to) {
+			coupons = append(coupons, c)
+		}
+	}
+	return coupons, nil
+}
+
+// GetOrderBook returns the configured order book, empty if there is none
+func (b *Broker) GetOrderBook(ctx context.Context, instrumentID string, depth int) (*invest.OrderBook, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	if book, ok := b.OrderBooks[instrumentID]; ok {
+		return book, nil
+	}
+	return &invest.OrderBook{}, nil
+}
+
+// PostOrder records the order; the same ID is placed only once
+func (b *Broker) PostOrder(ctx context.Context, req invest.OrderRequest) (*invest.OrderState, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !slices.ContainsFunc(b.orders, func(o invest.OrderRequest) bool { return o.ID == req.ID }) {
+		b.orders = append(b.orders, req)
+	}
+	return &invest.OrderState{ID: req.ID, Status: invest.OrderNew, LotsRequested: req.Lots}, nil
+}
+
+// GetOrderState reports a placed order with the configured status
+func (b *Broker) GetOrderState(ctx context.Context, orderID string) (*invest.OrderState, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, o := range b.orders {
+		if o.ID != orderID {
+			continue
+		}
+		state := &invest.OrderState{ID: o.ID, Status: b.OrderStatus, LotsRequested: o.Lots}
+		if state.Status == "" {
+			state.Status = invest.OrderFilled
+		}
+		if state.Status == invest.OrderFilled {
+			state.LotsExecuted = o.Lots
+		}
+		return state, nil
+	}
+	return nil, errors.New("fake: unknown order")
+}
+
+// GetTradedAmount returns the configured amount
+func (b *Broker) GetTradedAmount(ctx context.Context, from time.Time) (float64, error) {
+	return b.TradedAmount, b.Err
+}
+
+// Orders returns the orders placed so far
+func (b *Broker) Orders() []invest.OrderRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]invest.OrderRequest(nil), b.orders...)
+}
+
+// PostStopOrder records an active stop order
+func (b *Broker) PostStopOrder(ctx context.Context, req invest.StopOrderRequest) (string, error) {
+	if b.Err != nil {
+		return "", b.Err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := fmt.Sprintf("stop-%d", len(b.StopOrders)+1)
+	b.StopOrders = append(b.StopOrders, invest.StopOrder{
+		ID: id, FIGI: req.InstrumentID, Type: req.Type, Direction: invest.OrderSell,
+		Lots: req.Lots, StopPrice: req.StopPrice, Status: invest.StopOrderActive,
+	})
+	return id, nil
+}
+
+// GetStopOrders returns the stop orders in any status
+func (b *Broker) GetStopOrders(ctx context.Context, from time.Time) ([]invest.StopOrder, error) {
+	if b.Err != nil {
+		return nil, b.Err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]invest.StopOrder(nil), b.StopOrders...), nil
+}
+
+// CancelStopOrder marks an active stop order cancelled
+func (b *Broker) CancelStopOrder(ctx context.Context, orderID string) error {
+	if b.Err != nil {
+		return b.Err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.StopOrders {
+		if b.StopOrders[i].ID == orderID && b.StopOrders[i].Status == invest.StopOrderActive {
+			b.StopOrders[i].Status = invest.StopOrderCancelled
+			return nil
+		}
+	}
+	return errors.New("fake: no active stop order")
+}
+
+// News serves fixed articles and records the queries
+type News struct {
+	Articles []news.Article
+	Err      error
+	Disabled bool
+
+	mu      sync.Mutex
+	queries []string
+}
+
+// Enabled reports whether the source is switched on
+func (n *News) Enabled() bool {
+	return !n.Disabled
+}
+
+// FetchMarketNews returns the configured articles
+func (n *News) FetchMarketNews() ([]news.Article, error) {
+	return n.FetchNews("", len(n.Articles))
+}
+
+// FetchNews returns up to limit of the configured articles
+func (n *News) FetchNews(query string, limit int) ([]news.Article, error) {
+	n.mu.Lock()
+	n.queries = append(n.queries, query)
+	n.mu.Unlock()
+
+	if n.Err != nil {
+		return nil, n.Err
+	}
+	if limit < len(n.Articles) {
+		return n.Articles[:limit], nil
+	}
+	return n.Articles, nil
+}
+
+// Queries returns the queries asked so far; market news is an empty query
+func (n *News) Queries() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.queries...)
+}
+
+// LLM answers every prompt with a fixed response and records the prompts
+type LLM struct {
+	Response         string
+	PromptTokens     int // reported as the usage of every completion
+	CompletionTokens int
+	Err              error
+
+	mu      sync.Mutex
+	prompts []analysis.Prompt
+}
+
+// NewLLMFromFile creates an LLM answering with a recorded response
+func NewLLMFromFile(path string) (*LLM, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &LLM{Response: string(data)}, nil
+}
+
+// Complete records the prompt and returns the configured response
+func (l *LLM) Complete(ctx context.Context, prompt analysis.Prompt) (analysis.Completion, error) {
+	l.mu.Lock()
+	l.prompts = append(l.prompts, prompt)
+	l.mu.Unlock()
+
+	if l.Err != nil {
+		return analysis.Completion{}, l.Err
+	}
+	return analysis.Completion{Text: l.Response, PromptTokens: l.PromptTokens, CompletionTokens: l.CompletionTokens}, nil
+}
+
+// Prompts returns the prompts received so far
+func (l *LLM) Prompts() []analysis.Prompt {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]analysis.Prompt(nil), l.prompts...)
+}
+
+// Sent is one report delivered through the Notifier
+type Sent struct {
+	Portfolio *invest.Portfolio
+	Analysis  *analysis.PortfolioAnalysis
+	Articles  []news.Article
+}
+
+// Notifier records the reports instead of delivering them
+type Notifier struct {
+	Err       error // returned when sending a report
+	ChartsErr error // returned when sending charts
+
+	mu          sync.Mutex
+	sent        []Sent
+	charts      int
+	comparisons []*models.Report
+}
+
+// SendPortfolioAnalysis records the report
+func (n *Notifier) SendPortfolioAnalysis(portfolio *invest.Portfolio, result *analysis.PortfolioAnalysis, articles []news.Article) error {
+	if n.Err != nil {
+		return n.Err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, Sent{Portfolio: portfolio, Analysis: result, Articles: articles})
+	return nil
+}
+
+// SendPortfolioCharts counts the chart deliveries
+func (n *Notifier) SendPortfolioCharts(ctx context.Context, portfolio *invest.Portfolio) error {
+	if n.ChartsErr != nil {
+		return n.ChartsErr
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.charts++
+	return nil
+}
+
+// SendModelComparison records the comparison with the model portfolios
+func (n *Notifier) SendModelComparison(report *models.Report) error {
+	if n.Err != nil {
+		return n.Err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.comparisons = append(n.comparisons, report)
+	return nil
+}
+
+// Sent returns the reports delivered so far
+func (n *Notifier) Sent() []Sent {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Sent(nil), n.sent...)
+}
+
+// Charts returns how many times charts were delivered
+func (n *Notifier) Charts() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.charts
+}
+
+// Comparisons returns the model comparisons delivered so far
+func (n *Notifier) Comparisons() []*models.Report {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*models.Report(nil), n.comparisons...)
+}
@@ -0,0 +1,66 @@
+package invest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CashFlow is money deposited to or withdrawn from the account
+type CashFlow struct {
+	Time   time.Time `json:"time"`
+	Amount float64   `json:"amount"` // positive for deposits, negative for withdrawals
+}
+
+// GetCashFlows returns the RUB deposits and withdrawals of the selected account within the interval, oldest first
+func (c *Client) GetCashFlows(ctx context.Context, from, to time.Time) ([]CashFlow, error) {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetAccountCashFlows(ctx, accountID, from, to)
+}
+
+// GetAccountCashFlows returns the RUB deposits and withdrawals of an account within the interval, oldest first
+func (c *Client) GetAccountCashFlows(ctx context.Context, accountID string, from, to time.Time) ([]CashFlow, error) {
+	operations, err := c.GetAccountOperations(ctx, accountID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var flows []CashFlow
+	for _, op := range operations {
+		if op.Type != OperationInput && op.Type != OperationOutput {
+			continue
+		}
+		// Deposits in other currencies are converted by the broker at an unknown rate
+		if op.Currency != "rub" {
+			continue
+		}
+		flows = append(flows, CashFlow{Time: op.Time, Amount: op.Payment})
+	}
+	return flows, nil
+}
+
+// FindIndex looks up a market index such as IMOEX by its ticker (case-insensitive).
+// Index candles are requested by the UID of the result.
+func (c *Client) FindIndex(ctx context.Context, ticker string) (*Instrument, error) {
+	resp, err := c.sdk.NewInstrumentsServiceClient().FindInstrument(ticker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find index %s: %w", ticker, err)
+	}
+	for _, candidate := range resp.GetInstruments() {
+		if strings.EqualFold(candidate.GetTicker(), ticker) && candidate.GetInstrumentType() == "index" {
+			return &Instrument{
+				FIGI:      candidate.GetFigi(),
+				UID:       candidate.GetUid(),
+				Ticker:    candidate.GetTicker(),
+				Name:      candidate.GetName(),
+				Type:      candidate.GetInstrumentType(),
+				ClassCode: candidate.GetClassCode(),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: index %s", ErrInstrumentNotFound, strings.ToUpper(ticker))
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"invest-manager/internal/config"
-	"log"
+	"invest-manager/internal/monitoring"
+	"log/slog"
+	"strings"
+	"sync"
 
 	"github.com/russianinvestments/invest-api-go-sdk/investgo"
 	proto "github.com/russianinvestments/invest-api-go-sdk/proto"
@@ -13,42 +16,111 @@ import (
 // Client wraps Tinkoff Invest API client
 type Client struct {
 	sdk       *investgo.Client
-	logger    *log.Logger
+	logger    *slog.Logger
 	config    *config.Config
+
+	// accountID is the account selected for reports; empty means the first one
+	mu        sync.RWMutex
+	accountID string
+
+	// issuers caches the issuer names by asset UID, they do not change
+	issuersMu sync.Mutex
+	issuers   map[string]string
+}
+
+// Account represents a brokerage account
+type Account struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
 }
 
 // Position represents a position in portfolio
 type Position struct {
-	FIGI           string
-	Ticker         string
-	Name           string
-	InstrumentType string
-	Quantity       float64
-	AveragePrice   float64
-	CurrentPrice   float64
-	ExpectedYield  float64
-	Currency       string
+	FIGI           string  `json:"figi"`
+	Ticker         string  `json:"ticker"`
+	Name           string  `json:"name"`
+	InstrumentType string  `json:"instrument_type"`
+	Quantity       float64 `json:"quantity"`
+	AveragePrice   float64 `json:"average_price"`
+	CurrentPrice   float64 `json:"current_price"`
+	ExpectedYield  float64 `json:"expected_yield"`
+	Currency       string  `json:"currency"`
+	Sector         string  `json:"sector,omitempty"`
+	AssetCurrency  string  `json:"asset_currency,omitempty"`
+	Country        string  `json:"country,omitempty"`     // country of risk, e.g. RU
+	Issuer         string  `json:"issuer,omitempty"`
+	AssetClass     string  `json:"asset_class,omitempty"` // equity, bond, fund, cash, derivative or other
 }
 
 // Portfolio contains all positions and total values
 type Portfolio struct {
-	Positions     []Position
-	TotalAmount   float64
-	ExpectedYield float64
-	Currency      string
+	Positions     []Position `json:"positions"`
+	TotalAmount   float64    `json:"total_amount"`
+	ExpectedYield float64    `json:"expected_yield"`
+	Currency      string     `json:"currency"`
+	Watched       []Quote    `json:"watched,omitempty"`    // watchlist instruments analyzed alongside the positions
+	Screen        string     `json:"screen,omitempty"`     // screen that picked the candidates, empty if opportunities are not screened
+	Candidates    []Quote    `json:"candidates,omitempty"` // screen matches, the only instruments allowed as opportunities
+	Exposures     []Exposure `json:"exposures,omitempty"`  // shares of the positions by asset class, sector, country and issuer
+}
+
+// Quote is the last price of an instrument that is not held
+type Quote struct {
+	FIGI     string  `json:"figi"`
+	Ticker   string  `json:"ticker"`
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Currency string  `json:"currency"`
+	Price    float64 `json:"price"`
+
+	// Metrics are the screened figures of a candidate by metric name
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+// Value returns the current market value of the position
+func (p Position) Value() float64 {
+	return p.Quantity * p.CurrentPrice
+}
+
+// YieldPercent returns the unrealized P&L of the position in percent of its cost
+func (p Position) YieldPercent() float64 {
+	cost := p.Quantity * p.AveragePrice
+	if cost == 0 {
+		return 0
+	}
+	return p.ExpectedYield / cost * 100
+}
+
+// Weight returns the share of the position in the portfolio value in percent
+func (p *Portfolio) Weight(pos Position) float64 {
+	if p.TotalAmount == 0 {
+		return 0
+	}
+	return pos.Value() / p.TotalAmount * 100
+}
+
+// FindPosition looks up a position by ticker (case-insensitive)
+func (p *Portfolio) FindPosition(ticker string) *Position {
+	for i := range p.Positions {
+		if strings.EqualFold(p.Positions[i].Ticker, ticker) {
+			return &p.Positions[i]
+		}
+	}
+	return nil
 }
 
 // NewClient creates a new Tinkoff Invest API client
-func NewClient(cfg *config.Config, logger *log.Logger) (*Client, error) {
+func NewClient(cfg *config.Config, logger *slog.Logger) (*Client, error) {
 	// Set up connection config
 	sdkConfig := investgo.Config{
-		Token:     cfg.TinkoffToken,
+		Token:     cfg.Tinkoff.Token.Value(),
 		AppName:   "invest-manager-bot",
 	}
 
 	// Set endpoint if provided
-	if cfg.TinkoffEndpoint != "" {
-		sdkConfig.EndPoint = cfg.TinkoffEndpoint
+	if cfg.Tinkoff.Endpoint != "" {
+		sdkConfig.EndPoint = cfg.Tinkoff.Endpoint
 	}
 
 	// Initialize SDK client
@@ -61,9 +133,90 @@ func NewClient(cfg *config.Config, logger *log.Logger) (*Client, error) {
 		sdk:       client,
 		logger:    logger,
 		config:    cfg,
+		accountID: cfg.Tinkoff.AccountID,
+		issuers:   make(map[string]string),
 	}, nil
 }
 
+// GetAccounts returns all accounts available for the token
+func (c *Client) GetAccounts(ctx context.Context) ([]Account, error) {
+	accountsClient := c.sdk.NewUsersServiceClient()
+	accountsResp, err := accountsClient.GetAccounts(proto.AccountStatus_ACCOUNT_STATUS_OPEN.Enum())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	accounts := make([]Account, 0, len(accountsResp.Accounts))
+	for _, acc := range accountsResp.Accounts {
+		accounts = append(accounts, Account{
+			ID:   acc.GetId(),
+			Name: acc.GetName(),
+			Type: accountTypeName(acc.GetType()),
+		})
+	}
+	return accounts, nil
+}
+
+// Ping checks that the API is reachable and the token is accepted
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.GetAccounts(ctx)
+	return err
+}
+
+// SetAccount selects the account used for portfolio requests
+func (c *Client) SetAccount(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accountID = id
+}
+
+// Reload applies the account setting of a new configuration.
+// An account picked with /account is kept unless the configured one changes.
+func (c *Client) Reload(cfg *config.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cfg.Tinkoff.AccountID != c.config.Tinkoff.AccountID {
+		c.accountID = cfg.Tinkoff.AccountID
+	}
+	c.config = cfg
+}
+
+// AccountID returns the selected account ID, or an empty string if none was selected
+func (c *Client) AccountID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.accountID
+}
+
+// resolveAccountID returns the selected account or falls back to the first available one
+func (c *Client) resolveAccountID(ctx context.Context) (string, error) {
+	if id := c.AccountID(); id != "" {
+		return id, nil
+	}
+
+	accounts, err := c.GetAccounts(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(accounts) == 0 {
+		return "", fmt.Errorf("no accounts found")
+	}
+	return accounts[0].ID, nil
+}
+
+// accountTypeName converts account type to a short readable name
+func accountTypeName(t proto.AccountType) string {
+	switch t {
+	case proto.AccountType_ACCOUNT_TYPE_TINKOFF:
+		return "broker"
+	case proto.AccountType_ACCOUNT_TYPE_TINKOFF_IIS:
+		return "iis"
+	case proto.AccountType_ACCOUNT_TYPE_INVEST_BOX:
+		return "invest_box"
+	}
+	return "unknown"
+}
+
 // Close closes the client connection
 func (c *Client) Close() {
 	c.sdk.Stop()
@@ -85,24 +238,37 @@ func quotationToFloat64(q *proto.Quotation) float64 {
 	return float64(q.Units) + float64(q.Nano)/1e9
 }
 
-// GetPortfolio retrieves the current portfolio
+// GetPortfolio retrieves the current portfolio of the selected account
 func (c *Client) GetPortfolio(ctx context.Context) (*Portfolio, error) {
-	accountsClient := c.sdk.NewUsersServiceClient()
-	accountsResp, err := accountsClient.GetAccounts(proto.AccountStatus_ACCOUNT_STATUS_UNSPECIFIED.Enum())
+	accountId, err := c.resolveAccountID(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get accounts: %w", err)
+		return nil, err
+	}
+	portfolio, err := c.GetAccountPortfolio(ctx, accountId)
+	if err != nil {
+		return nil, err
 	}
-	if len(accountsResp.Accounts) == 0 {
-		return nil, fmt.Errorf("no accounts found")
+
+	values := make(map[string]float64, len(portfolio.Positions))
+	for _, pos := range portfolio.Positions {
+		values[pos.Ticker] += pos.Value()
 	}
-	accountId := accountsResp.Accounts[0].Id
+	monitoring.SetPortfolio(portfolio.TotalAmount, portfolio.ExpectedYield, values)
+	return portfolio, nil
+}
 
+// GetAccountPortfolio retrieves the current portfolio of an account
+func (c *Client) GetAccountPortfolio(ctx context.Context, accountId string) (*Portfolio, error) {
 	opsClient := c.sdk.NewOperationsServiceClient()
 	portfolioResp, err := opsClient.GetPortfolio(accountId, 0) // 0 = RUB
 	if err != nil {
 		return nil, fmt.Errorf("failed to get portfolio: %w", err)
 	}
 
+	c.mu.RLock()
+	overrides := c.config.Classification.Overrides
+	c.mu.RUnlock()
+
 	positions := make([]Position, 0, len(portfolioResp.Positions))
 	var totalAmount, totalYield float64
 	currency := "RUB"
@@ -116,7 +282,7 @@ func (c *Client) GetPortfolio(ctx context.Context) (*Portfolio, error) {
 		// Fetch instrument details by FIGI
 		instrClient := c.sdk.NewInstrumentsServiceClient()
 		instrResp, err := instrClient.InstrumentByFigi(pos.Figi)
-		var ticker, name string
+		var ticker, name, sector, assetCurrency, country, issuer string
 		if err != nil || instrResp.GetInstrument() == nil {
 			// Fallback to FIGI and instrument type if API call fails
 			ticker = pos.Figi
@@ -124,6 +290,10 @@ func (c *Client) GetPortfolio(ctx context.Context) (*Portfolio, error) {
 		} else {
 			ticker = instrResp.GetInstrument().GetTicker()
 			name = instrResp.GetInstrument().GetName()
+			sector = instrResp.GetInstrument().GetSector()
+			assetCurrency = strings.ToUpper(instrResp.GetInstrument().GetCurrency())
+			country = strings.ToUpper(instrResp.GetInstrument().GetCountryOfRisk())
+			issuer = c.issuerOf(ctx, pos.InstrumentType, instrResp.GetInstrument().GetAssetUid())
 		}
 
 		positions = append(positions, Position{
@@ -136,7 +306,13 @@ func (c *Client) GetPortfolio(ctx context.Context) (*Portfolio, error) {
 			CurrentPrice:   curPrice,
 			ExpectedYield:  yield,
 			Currency:       currency,
+			Sector:         sector,
+			AssetCurrency:  assetCurrency,
+			Country:        country,
+			Issuer:         issuer,
+			AssetClass:     assetClassOf(pos.InstrumentType),
 		})
+		classify(&positions[len(positions)-1], overrides)
 		totalAmount += qty * curPrice
 		totalYield += yield
 	}
@@ -146,5 +322,38 @@ func (c *Client) GetPortfolio(ctx context.Context) (*Portfolio, error) {
 		TotalAmount:   totalAmount,
 		ExpectedYield: totalYield,
 		Currency:      currency,
+		Exposures:     Exposures(positions),
 	}, nil
-}
\ No newline at end of file
+}
+
+// issuerOf returns the issuer of a share or a bond: the brand of its asset, which the ordinary
+// and preferred shares or the bonds of one company share. It is empty if the broker has no brand,
+// which the classification file can fill. Funds and currencies have no single issuer to be exposed to.
+func (c *Client) issuerOf(ctx context.Context, instrumentType, assetUID string) string {
+	if (instrumentType != "share" && instrumentType != "bond") || assetUID == "" {
+		return ""
+	}
+	c.issuersMu.Lock()
+	issuer, ok := c.issuers[assetUID]
+	c.issuersMu.Unlock()
+	if ok {
+		return issuer
+	}
+
+	resp, err := c.sdk.NewInstrumentsServiceClient().GetAssetBy(assetUID)
+	if err != nil {
+		// Not cached, so the next portfolio tries again
+		c.logger.WarnContext(ctx, "Could not get the issuer of an asset", "asset_uid", assetUID, "error", err)
+		return ""
+	}
+	if brand := resp.GetAsset().GetBrand(); brand != nil {
+		issuer = brand.GetName()
+		if issuer == "" {
+			issuer = brand.GetCompany()
+		}
+	}
+	c.issuersMu.Lock()
+	c.issuers[assetUID] = issuer
+	c.issuersMu.Unlock()
+	return issuer
+}
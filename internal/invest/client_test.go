@@ -0,0 +1,237 @@
+package invest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/sandbox"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// sandboxAddr is the endpoint of the sandbox serving testdata/scenario.yaml of the sandbox package
+var sandboxAddr string
+
+// TestMain starts one sandbox for all tests: the system certificate pool is read once per process,
+// so its certificate has to be in SSL_CERT_FILE before the first connection
+func TestMain(m *testing.M) {
+	os.Exit(runWithSandbox(m))
+}
+
+// runWithSandbox runs the tests against a sandbox and cleans up afterwards
+func runWithSandbox(m *testing.M) int {
+	scenario, err := sandbox.LoadScenario(filepath.Join("..", "sandbox", "testdata", "scenario.yaml"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	server, err := sandbox.NewServer(scenario, logging.Discard())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer server.Stop()
+
+	dir, err := os.MkdirTemp("", "sandbox")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "cert.pem")
+	if err := server.WriteCert(certFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Setenv("SSL_CERT_FILE", certFile)
+
+	sandboxAddr = server.Addr()
+	return m.Run()
+}
+
+// newSandboxClient connects a client to the sandbox
+func newSandboxClient(t *testing.T, token, accountID string) *Client {
+	t.Helper()
+	cfg := &config.Config{Tinkoff: config.TinkoffConfig{
+		Token:     config.Secret(token),
+		Endpoint:  sandboxAddr,
+		AccountID: accountID,
+	}}
+	client, err := NewClient(cfg, logging.Discard())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestClientAgainstSandbox(t *testing.T) {
+	client := newSandboxClient(t, "sandbox-token", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	accounts, err := client.GetAccounts(ctx)
+	if err != nil {
+		t.Fatalf("GetAccounts: %v", err)
+	}
+	if len(accounts) != 2 || accounts[0].Type != "broker" || accounts[1].Type != "iis" {
+		t.Errorf("accounts = %+v", accounts)
+	}
+
+	// Without a configured account the first one is used
+	portfolio, err := client.GetPortfolio(ctx)
+	if err != nil {
+		t.Fatalf("GetPortfolio: %v", err)
+	}
+	if len(portfolio.Positions) != 3 {
+		t.Fatalf("%d positions, want 3", len(portfolio.Positions))
+	}
+	sber := portfolio.FindPosition("SBER")
+	if sber == nil || sber.Name != "Сбербанк" || sber.Sector != "financial" || sber.CurrentPrice != 310.4 {
+		t.Errorf("SBER = %+v", sber)
+	}
+	if want := 100*310.4 + 200*158.2 + 15000; !almostEqual(portfolio.TotalAmount, want) {
+		t.Errorf("TotalAmount = %v, want %v", portfolio.TotalAmount, want)
+	}
+	if sber.Country != "RU" || sber.Issuer != "Сбер" || sber.AssetClass != AssetEquity {
+		t.Errorf("SBER classification = %+v", sber)
+	}
+	if cash := portfolio.ExposuresBy(GroupAssetClass); len(cash) != 2 || cash[1].Name != AssetCash || !almostEqual(cash[1].Value, 15000) {
+		t.Errorf("asset class exposures = %+v", cash)
+	}
+
+	candles, err := client.GetDailyCandles(ctx, sber.FIGI,
+		time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetDailyCandles: %v", err)
+	}
+	if len(candles) != 3 || candles[0].Close != 304.2 || candles[2].Close != 306.1 {
+		t.Errorf("candles = %+v", candles)
+	}
+
+	// Switching the account changes the portfolio
+	client.SetAccount("2000000002")
+	portfolio, err = client.GetPortfolio(ctx)
+	if err != nil {
+		t.Fatalf("GetPortfolio: %v", err)
+	}
+	if len(portfolio.Positions) != 1 || portfolio.Positions[0].Ticker != "LKOH" {
+		t.Errorf("IIS positions = %+v", portfolio.Positions)
+	}
+}
+
+func TestFindInstrument(t *testing.T) {
+	client := newSandboxClient(t, "sandbox-token", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sber, err := client.FindInstrument(ctx, "sber")
+	if err != nil {
+		t.Fatalf("FindInstrument: %v", err)
+	}
+	if sber.FIGI != "BBG004730N88" || !sber.Tradable || !sber.Shortable {
+		t.Errorf("SBER = %+v", sber)
+	}
+	if lkoh, err := client.FindInstrument(ctx, "LKOH"); err != nil || lkoh.Shortable {
+		t.Errorf("LKOH = %+v, %v; want a long-only instrument", lkoh, err)
+	}
+	// A ticker is matched exactly, not by a part of it
+	if _, err := client.FindInstrument(ctx, "SBE"); !errors.Is(err, ErrInstrumentNotFound) {
+		t.Errorf("FindInstrument(SBE) error = %v, want ErrInstrumentNotFound", err)
+	}
+}
+
+func TestListTradableAndDividends(t *testing.T) {
+	client := newSandboxClient(t, "sandbox-token", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	listed, err := client.ListTradable(ctx)
+	if err != nil {
+		t.Fatalf("ListTradable: %v", err)
+	}
+	types := make(map[string]string)
+	for _, instr := range listed {
+		types[instr.Ticker] = instr.Type
+	}
+	if len(listed) != 4 || types["SBER"] != "share" || types["TMOS"] != "etf" {
+		t.Errorf("listed = %+v, want the three shares and TMOS without the currency", listed)
+	}
+
+	dividends, err := client.GetDividends(ctx, "BBG004730N88",
+		time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetDividends: %v", err)
+	}
+	if len(dividends) != 1 || dividends[0].Amount != 33.3 || dividends[0].Currency != "RUB" {
+		t.Errorf("dividends = %+v, want the one of 2026", dividends)
+	}
+}
+
+func TestCashFlowsAndIndex(t *testing.T) {
+	client := newSandboxClient(t, "sandbox-token", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	flows, err := client.GetCashFlows(ctx, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetCashFlows: %v", err)
+	}
+	if len(flows) != 2 || flows[0].Amount != 100000 || flows[1].Amount != -5000 {
+		t.Errorf("flows = %+v, want the deposit and the withdrawal without trades and dividends", flows)
+	}
+	iis, err := client.GetAccountCashFlows(ctx, "2000000002", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(iis) != 1 || iis[0].Amount != 50000 {
+		t.Errorf("IIS flows = %+v, %v; want the one deposit", iis, err)
+	}
+
+	index, err := client.FindIndex(ctx, "imoex")
+	if err != nil {
+		t.Fatalf("FindIndex: %v", err)
+	}
+	candles, err := client.GetDailyCandles(ctx, index.UID,
+		time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(candles) != 2 || candles[1].Close != 2894.1 {
+		t.Errorf("IMOEX candles = %+v, %v", candles, err)
+	}
+	// Trades come with their units and prices; the withdrawal ends the ledger
+	operations, err := client.GetOperations(ctx, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetOperations: %v", err)
+	}
+	if len(operations) != 8 || operations[7].Type != OperationOutput {
+		t.Fatalf("operations = %+v", operations)
+	}
+	if sell := operations[6]; sell.Type != OperationSell || sell.Quantity != 20 || sell.Price != 300 ||
+		sell.Payment != 6000 || sell.Currency != "rub" || sell.InstrumentType != "share" {
+		t.Errorf("sell = %+v", sell)
+	}
+
+	// Shares are not indices
+	if _, err := client.FindIndex(ctx, "SBER"); !errors.Is(err, ErrInstrumentNotFound) {
+		t.Errorf("FindIndex(SBER) error = %v, want ErrInstrumentNotFound", err)
+	}
+}
+
+func TestClientRejectedWithWrongToken(t *testing.T) {
+	client := newSandboxClient(t, "wrong-token", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := client.GetAccounts(ctx); err == nil {
+		t.Fatal("GetAccounts() with a wrong token succeeded")
+	}
+}
+
+// almostEqual compares amounts that went through the fixed-point API format
+func almostEqual(a, b float64) bool {
+	return a-b < 1e-6 && b-a < 1e-6
+}
@@ -0,0 +1,154 @@
+package invest
+
+import (
+	"invest-manager/internal/config"
+	"sort"
+	"strings"
+)
+
+// Asset classes of the positions
+const (
+	AssetEquity     = "equity"
+	AssetBond       = "bond"
+	AssetFund       = "fund"
+	AssetCash       = "cash"
+	AssetDerivative = "derivative"
+	AssetOther      = "other"
+)
+
+// Groups the exposures are aggregated by
+const (
+	GroupAssetClass = "asset_class"
+	GroupSector     = "sector"
+	GroupCountry    = "country"
+	GroupIssuer     = "issuer"
+)
+
+// ExposureGroups lists the groups in the order they are reported
+var ExposureGroups = []string{GroupAssetClass, GroupSector, GroupCountry, GroupIssuer}
+
+// Unclassified names the positions without metadata for a group
+const Unclassified = "unknown"
+
+// Exposure is the share of the portfolio in one asset class, sector, country or issuer
+type Exposure struct {
+	Group  string  `json:"group"`
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+	Weight float64 `json:"weight"` // percent of the value of the positions
+}
+
+// ExposuresBy returns the exposures of a group, the largest first
+func (p *Portfolio) ExposuresBy(group string) []Exposure {
+	var result []Exposure
+	for _, e := range p.Exposures {
+		if e.Group == group {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// assetClassOf maps an instrument type of the API to its asset class
+func assetClassOf(instrumentType string) string {
+	switch instrumentType {
+	case "share":
+		return AssetEquity
+	case "bond":
+		return AssetBond
+	case "etf":
+		return AssetFund
+	case "currency":
+		return AssetCash
+	case "futures", "option":
+		return AssetDerivative
+	}
+	return AssetOther
+}
+
+// classify replaces the metadata of a position with its override by ticker or FIGI, if any
+func classify(pos *Position, overrides map[string]config.Classification) {
+	o, ok := overrides[strings.ToUpper(pos.Ticker)]
+	if !ok {
+		o, ok = overrides[strings.ToUpper(pos.FIGI)]
+	}
+	if !ok {
+		return
+	}
+	if o.Sector != "" {
+		pos.Sector = o.Sector
+	}
+	if o.Country != "" {
+		pos.Country = o.Country
+	}
+	if o.Issuer != "" {
+		pos.Issuer = o.Issuer
+	}
+	if o.AssetClass != "" {
+		pos.AssetClass = o.AssetClass
+	}
+}
+
+// Exposures aggregates the positions by every group of ExposureGroups, the largest first within
+// a group. Positions without an issuer, such as funds and cash, are left out of the issuer group.
+func Exposures(positions []Position) []Exposure {
+	var total float64
+	for _, pos := range positions {
+		total += pos.Value()
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var result []Exposure
+	for _, group := range ExposureGroups {
+		values := make(map[string]float64)
+		for _, pos := range positions {
+			name := pos.groupName(group)
+			if name == "" {
+				continue
+			}
+			values[name] += pos.Value()
+		}
+
+		exposures := make([]Exposure, 0, len(values))
+		for name, value := range values {
+			exposures = append(exposures, Exposure{Group: group, Name: name, Value: value, Weight: value / total * 100})
+		}
+		sort.Slice(exposures, func(i, j int) bool {
+			if exposures[i].Value != exposures[j].Value {
+				return exposures[i].Value > exposures[j].Value
+			}
+			return exposures[i].Name < exposures[j].Name
+		})
+		result = append(result, exposures...)
+	}
+	return result
+}
+
+// groupName returns the name of the position in a group, Unclassified for missing metadata
+// and empty if the position does not belong to the group at all
+func (p Position) groupName(group string) string {
+	var name string
+	switch group {
+	case GroupAssetClass:
+		name = p.AssetClass
+	case GroupSector:
+		if p.AssetClass == AssetCash {
+			return AssetCash
+		}
+		name = p.Sector
+	case GroupCountry:
+		name = p.Country
+	case GroupIssuer:
+		// Shares and bonds always have one, even if it is unknown
+		if p.AssetClass != AssetEquity && p.AssetClass != AssetBond {
+			return p.Issuer
+		}
+		name = p.Issuer
+	}
+	if name == "" {
+		return Unclassified
+	}
+	return name
+}
@@ -0,0 +1,70 @@
+package invest
+
+import (
+	"invest-manager/internal/config"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	overrides := map[string]config.Classification{
+		"TMOS":         {Sector: "diversified", Country: "RU"},
+		"SU26238RMFS4": {Issuer: "Минфин России", AssetClass: AssetBond},
+	}
+
+	tmos := Position{Ticker: "tmos", Sector: "other", AssetClass: AssetFund}
+	classify(&tmos, overrides)
+	if tmos.Sector != "diversified" || tmos.Country != "RU" || tmos.AssetClass != AssetFund {
+		t.Errorf("TMOS = %+v", tmos)
+	}
+
+	// An override by FIGI applies when the ticker has none
+	ofz := Position{FIGI: "SU26238RMFS4", Ticker: "OFZ", Issuer: "ОФЗ 26238", AssetClass: AssetOther}
+	classify(&ofz, overrides)
+	if ofz.Issuer != "Минфин России" || ofz.AssetClass != AssetBond {
+		t.Errorf("OFZ = %+v", ofz)
+	}
+}
+
+func TestExposures(t *testing.T) {
+	positions := []Position{
+		{Ticker: "SBER", Quantity: 10, CurrentPrice: 300, Sector: "financial", Country: "RU", Issuer: "Сбербанк", AssetClass: AssetEquity},
+		{Ticker: "GAZP", Quantity: 10, CurrentPrice: 150, Sector: "energy", Country: "RU", Issuer: "Газпром", AssetClass: AssetEquity},
+		{Ticker: "TMOS", Quantity: 100, CurrentPrice: 4.5, AssetClass: AssetFund},
+		{Ticker: "RUB000UTSTOM", Quantity: 1050, CurrentPrice: 1, AssetClass: AssetCash},
+	}
+	portfolio := &Portfolio{Exposures: Exposures(positions)}
+
+	classes := portfolio.ExposuresBy(GroupAssetClass)
+	if len(classes) != 3 || classes[0].Name != AssetEquity || !almostEqual(classes[0].Weight, 75) {
+		t.Errorf("asset classes = %+v", classes)
+	}
+	// Cash is a sector of its own, a fund without metadata is unknown
+	sectors := portfolio.ExposuresBy(GroupSector)
+	want := []string{"financial", "energy", AssetCash, Unclassified}
+	if len(sectors) != len(want) {
+		t.Fatalf("sectors = %+v", sectors)
+	}
+	for i, name := range want {
+		if sectors[i].Name != name {
+			t.Errorf("sector %d = %s, want %s", i, sectors[i].Name, name)
+		}
+	}
+	if countries := portfolio.ExposuresBy(GroupCountry); len(countries) != 2 || countries[0].Name != "RU" || countries[1].Name != Unclassified {
+		t.Errorf("countries = %+v", countries)
+	}
+	// Only the shares have an issuer
+	if issuers := portfolio.ExposuresBy(GroupIssuer); len(issuers) != 2 || !almostEqual(issuers[0].Weight, 50) {
+		t.Errorf("issuers = %+v", issuers)
+	}
+
+	// A share without a known issuer is still an issuer exposure
+	positions[1].Issuer = ""
+	portfolio = &Portfolio{Exposures: Exposures(positions)}
+	if issuers := portfolio.ExposuresBy(GroupIssuer); len(issuers) != 2 || issuers[1].Name != Unclassified {
+		t.Errorf("issuers with an unknown one = %+v", issuers)
+	}
+
+	if Exposures([]Position{{Ticker: "SBER"}}) != nil {
+		t.Error("exposures of an empty portfolio")
+	}
+}
@@ -0,0 +1,138 @@
+package invest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	proto "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+// Dividend is a dividend payment per share
+type Dividend struct {
+	Amount      float64   `json:"amount"` // net of the issuer's tax, per share
+	Currency    string    `json:"currency"`
+	RecordDate  time.Time `json:"record_date"`
+	PaymentDate time.Time `json:"payment_date"`
+}
+
+// Coupon is a coupon payment of a bond
+type Coupon struct {
+	Amount   float64   `json:"amount"` // per bond
+	Currency string    `json:"currency"`
+	FixDate  time.Time `json:"fix_date"` // the holders at the end of the day are paid
+	Date     time.Time `json:"date"`     // payment date
+}
+
+// tradableListing is what shares and ETFs have in common in the instruments service
+type tradableListing interface {
+	GetFigi() string
+	GetUid() string
+	GetTicker() string
+	GetName() string
+	GetSector() string
+	GetCurrency() string
+	GetClassCode() string
+	GetLot() int32
+	GetApiTradeAvailableFlag() bool
+	GetBuyAvailableFlag() bool
+	GetSellAvailableFlag() bool
+	GetShortEnabledFlag() bool
+}
+
+// ListTradable returns the shares and ETFs of the main Moscow Exchange boards
+// that are available for trading through the API
+func (c *Client) ListTradable(ctx context.Context) ([]Instrument, error) {
+	instruments := c.sdk.NewInstrumentsServiceClient()
+
+	shares, err := instruments.Shares(proto.InstrumentStatus_INSTRUMENT_STATUS_BASE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shares: %w", err)
+	}
+	etfs, err := instruments.Etfs(proto.InstrumentStatus_INSTRUMENT_STATUS_BASE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ETFs: %w", err)
+	}
+
+	var result []Instrument
+	for _, share := range shares.GetInstruments() {
+		if share.GetClassCode() == mainShareBoard && share.GetApiTradeAvailableFlag() {
+			result = append(result, listedInstrument(share, "share"))
+		}
+	}
+	for _, etf := range etfs.GetInstruments() {
+		if etf.GetClassCode() == mainETFBoard && etf.GetApiTradeAvailableFlag() {
+			result = append(result, listedInstrument(etf, "etf"))
+		}
+	}
+	return result, nil
+}
+
+// listedInstrument converts a share or an ETF of the instruments list
+func listedInstrument(l tradableListing, instrumentType string) Instrument {
+	return Instrument{
+		FIGI:      l.GetFigi(),
+		UID:       l.GetUid(),
+		Ticker:    l.GetTicker(),
+		Name:      l.GetName(),
+		Type:      instrumentType,
+		ClassCode: l.GetClassCode(),
+		Sector:    l.GetSector(),
+		Currency:  strings.ToUpper(l.GetCurrency()),
+		Lot:       int64(l.GetLot()),
+		Tradable:  l.GetApiTradeAvailableFlag(),
+		Buyable:   l.GetBuyAvailableFlag(),
+		Sellable:  l.GetSellAvailableFlag(),
+		Shortable: l.GetShortEnabledFlag(),
+	}
+}
+
+// GetDividends returns the dividends of a share with a record date in the given interval
+func (c *Client) GetDividends(ctx context.Context, figi string, from, to time.Time) ([]Dividend, error) {
+	resp, err := c.sdk.NewInstrumentsServiceClient().GetDividents(figi, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dividends of %s: %w", figi, err)
+	}
+
+	dividends := make([]Dividend, 0, len(resp.GetDividends()))
+	for _, d := range resp.GetDividends() {
+		dividend := Dividend{
+			Amount:   moneyValueToFloat64(d.GetDividendNet()),
+			Currency: strings.ToUpper(d.GetDividendNet().GetCurrency()),
+		}
+		if d.GetRecordDate() != nil {
+			dividend.RecordDate = d.GetRecordDate().AsTime()
+		}
+		if d.GetPaymentDate() != nil {
+			dividend.PaymentDate = d.GetPaymentDate().AsTime()
+		}
+		dividends = append(dividends, dividend)
+	}
+	return dividends, nil
+}
+
+// GetBondCoupons returns the coupons of a bond paid in the given interval
+func (c *Client) GetBondCoupons(ctx context.Context, figi string, from, to time.Time) ([]Coupon, error) {
+	resp, err := c.sdk.NewInstrumentsServiceClient().GetBondCoupons(figi, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupons of %s: %w", figi, err)
+	}
+
+	coupons := make([]Coupon, 0, len(resp.GetEvents()))
+	for _, e := range resp.GetEvents() {
+		coupon := Coupon{
+			Amount:   moneyValueToFloat64(e.GetPayOneBond()),
+			Currency: strings.ToUpper(e.GetPayOneBond().GetCurrency()),
+		}
+		if e.GetCouponDate() != nil {
+			coupon.Date = e.GetCouponDate().AsTime()
+		}
+		coupon.FixDate = coupon.Date
+		if e.GetFixDate() != nil {
+			coupon.FixDate = e.GetFixDate().AsTime()
+		}
+		coupons = append(coupons, coupon)
+	}
+	return coupons, nil
+}
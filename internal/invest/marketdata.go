@@ -0,0 +1,240 @@
+package invest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	proto "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+// Candle represents a single price bar of an instrument
+type Candle struct {
+	Time   time.Time `json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume int64     `json:"volume"`
+}
+
+// ValuePoint is the portfolio value at a moment in time
+type ValuePoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// PositionPnL contains the price-driven result of a single position over a period
+type PositionPnL struct {
+	Position   Position
+	StartPrice float64
+	Change     float64
+	ChangePct  float64
+}
+
+// PeriodPnL contains the price-driven result of the portfolio over a period
+type PeriodPnL struct {
+	From      time.Time
+	To        time.Time
+	Positions []PositionPnL
+	Total     float64
+	TotalPct  float64
+	Currency  string
+}
+
+// GetDailyCandles retrieves daily candles of an instrument for the given interval
+func (c *Client) GetDailyCandles(ctx context.Context, figi string, from, to time.Time) ([]Candle, error) {
+	mdClient := c.sdk.NewMarketDataServiceClient()
+	resp, err := mdClient.GetHistoricCandles(&investgo.GetHistoricCandlesRequest{
+		Instrument: figi,
+		Interval:   proto.CandleInterval_CANDLE_INTERVAL_DAY,
+		From:       from,
+		To:         to,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candles for %s: %w", figi, err)
+	}
+
+	candles := make([]Candle, 0, len(resp))
+	for _, candle := range resp {
+		candles = append(candles, Candle{
+			Time:   candle.GetTime().AsTime(),
+			Open:   quotationToFloat64(candle.GetOpen()),
+			High:   quotationToFloat64(candle.GetHigh()),
+			Low:    quotationToFloat64(candle.GetLow()),
+			Close:  quotationToFloat64(candle.GetClose()),
+			Volume: candle.GetVolume(),
+		})
+	}
+	return candles, nil
+}
+
+// GetPeriodPnL calculates how the current positions changed in value since the given moment.
+// The result is based on price movement only: trades made during the period are not taken into account.
+func (c *Client) GetPeriodPnL(ctx context.Context, from time.Time) (*PeriodPnL, error) {
+	portfolio, err := c.GetPortfolio(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := &PeriodPnL{
+		From:     from,
+		To:       now,
+		Currency: portfolio.Currency,
+	}
+
+	var startValue float64
+	for _, pos := range portfolio.Positions {
+		// Cash positions have no price history
+		if pos.InstrumentType == "currency" {
+			continue
+		}
+
+		candles, err := c.GetDailyCandles(ctx, pos.FIGI, from, now)
+		if err != nil || len(candles) == 0 {
+			c.logger.WarnContext(ctx, "Skipping position in P&L calculation: no price history", "ticker", pos.Ticker, "error", err)
+			continue
+		}
+		unit, err := c.quoteUnit(ctx, pos)
+		if err != nil {
+			c.logger.WarnContext(ctx, "Skipping position in P&L calculation: quotes cannot be valued", "ticker", pos.Ticker, "error", err)
+			continue
+		}
+
+		startPrice := candles[0].Open * unit
+		change := pos.Quantity * (pos.CurrentPrice - startPrice)
+		changePct := 0.0
+		if startPrice != 0 {
+			changePct = (pos.CurrentPrice - startPrice) / startPrice * 100
+		}
+
+		result.Positions = append(result.Positions, PositionPnL{
+			Position:   pos,
+			StartPrice: startPrice,
+			Change:     change,
+			ChangePct:  changePct,
+		})
+		result.Total += change
+		startValue += pos.Quantity * startPrice
+	}
+
+	if startValue != 0 {
+		result.TotalPct = result.Total / startValue * 100
+	}
+	return result, nil
+}
+
+// GetPortfolioHistory reconstructs the daily value of the current positions since the given moment.
+// Quantities are taken as they are now, so trades and deposits made during the period are not reflected.
+func (c *Client) GetPortfolioHistory(ctx context.Context, portfolio *Portfolio, from time.Time) ([]ValuePoint, error) {
+	now := time.Now()
+	var held []heldPrices
+	var cash float64
+
+	for _, pos := range portfolio.Positions {
+		// Cash positions keep their value over the whole period
+		if pos.InstrumentType == "currency" {
+			cash += pos.Value()
+			continue
+		}
+
+		candles, err := c.GetDailyCandles(ctx, pos.FIGI, from, now)
+		if err != nil {
+			c.logger.WarnContext(ctx, "Skipping position in portfolio history", "ticker", pos.Ticker, "error", err)
+			continue
+		}
+		unit, err := c.quoteUnit(ctx, pos)
+		if err != nil {
+			c.logger.WarnContext(ctx, "Skipping position in portfolio history: quotes cannot be valued", "ticker", pos.Ticker, "error", err)
+			continue
+		}
+		if len(candles) > 0 {
+			held = append(held, heldPrices{units: pos.Quantity * unit, candles: candles})
+		}
+	}
+
+	points := valueHistory(held, cash)
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no price history available")
+	}
+	return points, nil
+}
+
+// heldPrices is a position of the portfolio history with its daily candles
+type heldPrices struct {
+	units   float64 // quantity, scaled to turn a quote into money
+	candles []Candle
+}
+
+// valueHistory values the positions on every day any of them has a candle. A position
+// without a candle on a day keeps its last close, or its first one before it starts
+// trading, so a missing candle does not show as a drop.
+func valueHistory(held []heldPrices, cash float64) []ValuePoint {
+	closes := make([]map[time.Time]float64, len(held))
+	dayset := make(map[time.Time]bool)
+	for i, h := range held {
+		closes[i] = make(map[time.Time]float64, len(h.candles))
+		for _, candle := range h.candles {
+			day := candle.Time.Truncate(24 * time.Hour)
+			closes[i][day] = candle.Close
+			dayset[day] = true
+		}
+	}
+	days := make([]time.Time, 0, len(dayset))
+	for day := range dayset {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	last := make([]float64, len(held))
+	for i, h := range held {
+		last[i] = h.candles[0].Close
+	}
+	points := make([]ValuePoint, 0, len(days))
+	for _, day := range days {
+		value := cash
+		for i, h := range held {
+			if price, ok := closes[i][day]; ok {
+				last[i] = price
+			}
+			value += h.units * last[i]
+		}
+		points = append(points, ValuePoint{Time: day, Value: value})
+	}
+	return points
+}
+
+// quoteUnit returns the money per unit one point of the quotes of a position is worth
+func (c *Client) quoteUnit(ctx context.Context, pos Position) (float64, error) {
+	if pos.InstrumentType != "bond" {
+		return 1, nil
+	}
+	instr, err := c.GetInstrument(ctx, pos.FIGI)
+	if err != nil {
+		return 0, err
+	}
+	if instr.Nominal <= 0 {
+		return 0, fmt.Errorf("bond %s has no nominal", pos.Ticker)
+	}
+	return instr.QuoteValue(1), nil
+}
+
+// GetLastPrices returns the last trade price of each instrument by FIGI.
+// Instruments without trades are left out.
+func (c *Client) GetLastPrices(ctx context.Context, figis []string) (map[string]float64, error) {
+	resp, err := c.sdk.NewMarketDataServiceClient().GetLastPrices(figis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last prices: %w", err)
+	}
+
+	prices := make(map[string]float64, len(figis))
+	for _, lp := range resp.GetLastPrices() {
+		if price := quotationToFloat64(lp.GetPrice()); price > 0 {
+			prices[lp.GetFigi()] = price
+		}
+	}
+	return prices, nil
+}
@@ -0,0 +1,71 @@
+package invest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValueHistory(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 7, 0, 0, 0, time.UTC) }
+	held := []heldPrices{
+		// 10 shares with a missing candle on the 2nd
+		{units: 10, candles: []Candle{{Time: day(1), Close: 100}, {Time: day(3), Close: 110}}},
+		// 5 bonds with a nominal of 1000 quoted in percent, listed from the 2nd
+		{units: 5 * 10, candles: []Candle{{Time: day(2), Close: 98}, {Time: day(3), Close: 99}}},
+	}
+
+	points := valueHistory(held, 500)
+	want := []float64{500 + 1000 + 4900, 500 + 1000 + 4900, 500 + 1100 + 4950}
+	if len(points) != len(want) {
+		t.Fatalf("points = %+v", points)
+	}
+	for i, w := range want {
+		if !almostEqual(points[i].Value, w) {
+			t.Errorf("day %d = %v, want %v", i+1, points[i].Value, w)
+		}
+	}
+
+	if points := valueHistory(nil, 500); len(points) != 0 {
+		t.Errorf("history without positions = %+v", points)
+	}
+}
+
+func TestQuoteValue(t *testing.T) {
+	bond := &Instrument{Type: "bond", Nominal: 1000}
+	if v := bond.QuoteValue(98.5); !almostEqual(v, 985) {
+		t.Errorf("bond quote = %v, want 985", v)
+	}
+	share := &Instrument{Type: "share"}
+	if v := share.QuoteValue(310.4); v != 310.4 {
+		t.Errorf("share quote = %v", v)
+	}
+}
+
+func TestPriceRounding(t *testing.T) {
+	share := &Instrument{Type: "share", MinPriceIncrement: 0.05}
+	tests := []struct {
+		quote, floor, ceil float64
+	}{
+		{quote: 266.03, floor: 266, ceil: 266.05},
+		{quote: 266.05, floor: 266.05, ceil: 266.05},
+		{quote: 0.3, floor: 0.3, ceil: 0.3},
+	}
+	for _, tt := range tests {
+		if got := share.FloorPrice(tt.quote); !almostEqual(got, tt.floor) {
+			t.Errorf("FloorPrice(%v) = %v, want %v", tt.quote, got, tt.floor)
+		}
+		if got := share.CeilPrice(tt.quote); !almostEqual(got, tt.ceil) {
+			t.Errorf("CeilPrice(%v) = %v, want %v", tt.quote, got, tt.ceil)
+		}
+	}
+
+	if got := (&Instrument{}).FloorPrice(266.03); got != 266.03 {
+		t.Errorf("FloorPrice without a step = %v, want the quote unchanged", got)
+	}
+
+	// 940 RUB of a 1 000 RUB bond is a quote of 94%
+	bond := &Instrument{Type: "bond", Nominal: 1000, MinPriceIncrement: 0.01}
+	if got := bond.FloorPrice(bond.QuoteOf(940.123)); !almostEqual(got, 94.01) {
+		t.Errorf("bond stop quote = %v, want 94.01", got)
+	}
+}
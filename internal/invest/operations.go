@@ -0,0 +1,96 @@
+package invest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	proto "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+// Operation types of the ledger
+const (
+	OperationInput       = "input"  // deposit
+	OperationOutput      = "output" // withdrawal
+	OperationBuy         = "buy"
+	OperationSell        = "sell"
+	OperationDividend    = "dividend"
+	OperationDividendTax = "dividend_tax"
+	OperationCoupon      = "coupon"
+	OperationBrokerFee   = "broker_fee"
+	OperationTax         = "tax" // income tax withheld by the broker, or refunded when positive
+)
+
+// operationTypes maps the API operation types to the ledger ones; others are left out
+var operationTypes = map[proto.OperationType]string{
+	proto.OperationType_OPERATION_TYPE_INPUT:        OperationInput,
+	proto.OperationType_OPERATION_TYPE_OUTPUT:       OperationOutput,
+	proto.OperationType_OPERATION_TYPE_BUY:          OperationBuy,
+	proto.OperationType_OPERATION_TYPE_SELL:         OperationSell,
+	proto.OperationType_OPERATION_TYPE_DIVIDEND:     OperationDividend,
+	proto.OperationType_OPERATION_TYPE_DIVIDEND_TAX: OperationDividendTax,
+	proto.OperationType_OPERATION_TYPE_COUPON:       OperationCoupon,
+	proto.OperationType_OPERATION_TYPE_BROKER_FEE:   OperationBrokerFee,
+	proto.OperationType_OPERATION_TYPE_TAX:          OperationTax,
+	// The tax at the higher rate and the corrections, refunds included, count towards the tax withheld
+	proto.OperationType_OPERATION_TYPE_TAX_PROGRESSIVE:            OperationTax,
+	proto.OperationType_OPERATION_TYPE_TAX_CORRECTION:             OperationTax,
+	proto.OperationType_OPERATION_TYPE_TAX_CORRECTION_PROGRESSIVE: OperationTax,
+}
+
+// Operation is an executed operation of the account
+type Operation struct {
+	Time           time.Time `json:"time"`
+	Type           string    `json:"type"` // one of the Operation constants
+	FIGI           string    `json:"figi,omitempty"`
+	InstrumentType string    `json:"instrument_type,omitempty"`
+	Quantity       float64   `json:"quantity,omitempty"` // units, not lots
+	Price          float64   `json:"price,omitempty"`    // per unit
+	Payment        float64   `json:"payment"`            // signed: positive when money comes to the account
+	Currency       string    `json:"currency"`
+}
+
+// GetOperations returns the executed operations of the selected account within the interval, oldest first
+func (c *Client) GetOperations(ctx context.Context, from, to time.Time) ([]Operation, error) {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetAccountOperations(ctx, accountID, from, to)
+}
+
+// GetAccountOperations returns the executed operations of an account within the interval, oldest first
+func (c *Client) GetAccountOperations(ctx context.Context, accountID string, from, to time.Time) ([]Operation, error) {
+	resp, err := c.sdk.NewOperationsServiceClient().GetOperations(&investgo.GetOperationsRequest{
+		AccountId: accountID,
+		State:     proto.OperationState_OPERATION_STATE_EXECUTED,
+		From:      from,
+		To:        to,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operations: %w", err)
+	}
+
+	var operations []Operation
+	for _, op := range resp.GetOperations() {
+		kind, ok := operationTypes[op.GetOperationType()]
+		if !ok {
+			continue
+		}
+		operations = append(operations, Operation{
+			Time:           op.GetDate().AsTime(),
+			Type:           kind,
+			FIGI:           op.GetFigi(),
+			InstrumentType: op.GetInstrumentType(),
+			Quantity:       float64(op.GetQuantity()),
+			Price:          moneyValueToFloat64(op.GetPrice()),
+			Payment:        moneyValueToFloat64(op.GetPayment()),
+			Currency:       strings.ToLower(op.GetPayment().GetCurrency()),
+		})
+	}
+	sort.SliceStable(operations, func(i, j int) bool { return operations[i].Time.Before(operations[j].Time) })
+	return operations, nil
+}
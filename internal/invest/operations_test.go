@@ -0,0 +1,20 @@
+package invest
+
+import (
+	"testing"
+
+	proto "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+func TestOperationTypesCountTaxCorrections(t *testing.T) {
+	for _, kind := range []proto.OperationType{
+		proto.OperationType_OPERATION_TYPE_TAX,
+		proto.OperationType_OPERATION_TYPE_TAX_PROGRESSIVE,
+		proto.OperationType_OPERATION_TYPE_TAX_CORRECTION,
+		proto.OperationType_OPERATION_TYPE_TAX_CORRECTION_PROGRESSIVE,
+	} {
+		if got := operationTypes[kind]; got != OperationTax {
+			t.Errorf("operation type %v maps to %q, want %q", kind, got, OperationTax)
+		}
+	}
+}
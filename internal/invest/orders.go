@@ -0,0 +1,356 @@
+package invest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	proto "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+// Order directions, matching the actions of recommendations
+const (
+	OrderBuy  = "BUY"
+	OrderSell = "SELL"
+)
+
+// OrderStatus is the execution state of an order
+type OrderStatus string
+
+// Order statuses
+const (
+	OrderNew             OrderStatus = "new"
+	OrderPartiallyFilled OrderStatus = "partially_filled"
+	OrderFilled          OrderStatus = "filled"
+	OrderRejected        OrderStatus = "rejected"
+	OrderCancelled       OrderStatus = "cancelled"
+)
+
+// Final reports whether the order will not change anymore
+func (s OrderStatus) Final() bool {
+	return s == OrderFilled || s == OrderRejected || s == OrderCancelled
+}
+
+// ErrInstrumentNotFound is returned when no instrument matches a ticker
+var ErrInstrumentNotFound = errors.New("instrument not found")
+
+// Instrument holds the trading parameters of an instrument
+type Instrument struct {
+	FIGI      string `json:"figi"`
+	UID       string `json:"uid"`
+	Ticker    string `json:"ticker"`
+	Name      string `json:"name"`
+	Type      string `json:"type"` // share, bond, etf, currency...
+	ClassCode string `json:"class_code"`
+	Sector    string `json:"sector,omitempty"`
+	Currency  string `json:"currency"`
+	Lot       int64  `json:"lot"`      // units per lot
+	Tradable  bool   `json:"tradable"` // available for trading through the API
+	Buyable   bool   `json:"buyable"`
+	Sellable  bool   `json:"sellable"`
+	Shortable bool   `json:"shortable"` // can be sold short
+
+	Nominal           float64 `json:"nominal,omitempty"`             // face value of a bond, its quotes are percent of it
+	AccruedInterest   float64 `json:"accrued_interest,omitempty"`    // coupon accrued on a bond, paid on top of its price
+	MinPriceIncrement float64 `json:"min_price_increment,omitempty"` // price step of the quotes
+}
+
+// QuoteValue converts a quote of the instrument to money per unit: bonds are quoted
+// in percent of their nominal, other instruments per unit
+func (i *Instrument) QuoteValue(quote float64) float64 {
+	if i.Type == "bond" {
+		return quote * i.Nominal / 100
+	}
+	return quote
+}
+
+// QuoteOf converts money per unit to a quote of the instrument, the reverse of QuoteValue
+func (i *Instrument) QuoteOf(value float64) float64 {
+	if i.Type == "bond" && i.Nominal > 0 {
+		return value / i.Nominal * 100
+	}
+	return value
+}
+
+// FloorPrice rounds a quote down to the price step, the exchange rejects prices between steps
+func (i *Instrument) FloorPrice(quote float64) float64 {
+	return i.roundPrice(quote, math.Floor)
+}
+
+// CeilPrice rounds a quote up to the price step
+func (i *Instrument) CeilPrice(quote float64) float64 {
+	return i.roundPrice(quote, math.Ceil)
+}
+
+// roundPrice rounds a quote to a whole number of price steps, unchanged if the step is unknown
+func (i *Instrument) roundPrice(quote float64, round func(float64) float64) float64 {
+	if i.MinPriceIncrement <= 0 {
+		return quote
+	}
+	// A quote already on a step must not move to the next one through a float error
+	steps := round(math.Round(quote/i.MinPriceIncrement*1e6) / 1e6)
+	return math.Round(steps*i.MinPriceIncrement*1e9) / 1e9
+}
+
+// OrderBookLevel is a price level of the order book
+type OrderBookLevel struct {
+	Price float64 `json:"price"`
+	Lots  int64   `json:"lots"`
+}
+
+// OrderBook holds the best bids and asks, best price first
+type OrderBook struct {
+	Bids []OrderBookLevel `json:"bids"`
+	Asks []OrderBookLevel `json:"asks"`
+}
+
+// OrderRequest describes a limit order to place
+type OrderRequest struct {
+	ID           string // idempotency key; placing the same ID twice creates one order
+	InstrumentID string // FIGI or instrument UID
+	Direction    string // OrderBuy or OrderSell
+	Lots         int64
+	Price        float64 // limit price per unit
+}
+
+// OrderState is the execution state of a placed order
+type OrderState struct {
+	ID            string      `json:"id"`
+	Status        OrderStatus `json:"status"`
+	LotsRequested int64       `json:"lots_requested"`
+	LotsExecuted  int64       `json:"lots_executed"`
+	Amount        float64     `json:"amount"`         // total amount of the order including commission
+	ExecutedPrice float64     `json:"executed_price"` // average price per unit of the executed lots
+	Commission    float64     `json:"commission"`     // executed commission, or the expected one while open
+	Message       string      `json:"message,omitempty"`
+}
+
+// ExecutedAmount returns the amount of the executed lots without commission
+func (s *OrderState) ExecutedAmount(lotSize int64) float64 {
+	return s.ExecutedPrice * float64(s.LotsExecuted*lotSize)
+}
+
+// GetInstrument returns the trading parameters of an instrument by FIGI
+func (c *Client) GetInstrument(ctx context.Context, figi string) (*Instrument, error) {
+	resp, err := c.sdk.NewInstrumentsServiceClient().InstrumentByFigi(figi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instrument %s: %w", figi, err)
+	}
+	instr := resp.GetInstrument()
+	if instr == nil {
+		return nil, fmt.Errorf("instrument %s not found", figi)
+	}
+	result := &Instrument{
+		FIGI:      instr.GetFigi(),
+		UID:       instr.GetUid(),
+		Ticker:    instr.GetTicker(),
+		Name:      instr.GetName(),
+		Type:      instr.GetInstrumentType(),
+		ClassCode: instr.GetClassCode(),
+		Sector:    instr.GetSector(),
+		Currency:  strings.ToUpper(instr.GetCurrency()),
+		Lot:       int64(instr.GetLot()),
+		Tradable:  instr.GetApiTradeAvailableFlag(),
+		Buyable:   instr.GetBuyAvailableFlag(),
+		Sellable:  instr.GetSellAvailableFlag(),
+		Shortable: instr.GetShortEnabledFlag(),
+
+		MinPriceIncrement: quotationToFloat64(instr.GetMinPriceIncrement()),
+	}
+
+	// The quotes of a bond are meaningless without its nominal
+	if result.Type == "bond" {
+		bondResp, err := c.sdk.NewInstrumentsServiceClient().BondByFigi(figi)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bond %s: %w", figi, err)
+		}
+		bond := bondResp.GetInstrument()
+		result.Nominal = moneyValueToFloat64(bond.GetNominal())
+		result.AccruedInterest = moneyValueToFloat64(bond.GetAciValue())
+	}
+	return result, nil
+}
+
+// FindInstrument resolves a ticker to an instrument. The same ticker is listed on
+// several boards, so the main board and API-tradable listings are preferred.
+func (c *Client) FindInstrument(ctx context.Context, ticker string) (*Instrument, error) {
+	resp, err := c.sdk.NewInstrumentsServiceClient().FindInstrument(ticker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find instrument %s: %w", ticker, err)
+	}
+
+	var best *proto.InstrumentShort
+	bestScore := -1
+	for _, candidate := range resp.GetInstruments() {
+		if !strings.EqualFold(candidate.GetTicker(), ticker) {
+			continue
+		}
+		score := 0
+		if candidate.GetApiTradeAvailableFlag() {
+			score += 2
+		}
+		if candidate.GetClassCode() == mainShareBoard {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInstrumentNotFound, strings.ToUpper(ticker))
+	}
+	return c.GetInstrument(ctx, best.GetFigi())
+}
+
+// Moscow Exchange boards of most shares and ETFs traded by retail investors
+const (
+	mainShareBoard = "TQBR"
+	mainETFBoard   = "TQTF"
+)
+
+// GetOrderBook returns the order book of an instrument up to the given depth
+func (c *Client) GetOrderBook(ctx context.Context, instrumentID string, depth int) (*OrderBook, error) {
+	resp, err := c.sdk.NewMarketDataServiceClient().GetOrderBook(instrumentID, int32(depth))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order book of %s: %w", instrumentID, err)
+	}
+	return &OrderBook{
+		Bids: orderBookLevels(resp.GetBids()),
+		Asks: orderBookLevels(resp.GetAsks()),
+	}, nil
+}
+
+// orderBookLevels converts the orders of one side of the book
+func orderBookLevels(orders []*proto.Order) []OrderBookLevel {
+	levels := make([]OrderBookLevel, 0, len(orders))
+	for _, o := range orders {
+		levels = append(levels, OrderBookLevel{Price: quotationToFloat64(o.GetPrice()), Lots: o.GetQuantity()})
+	}
+	return levels
+}
+
+// PostOrder places a limit order on the selected account
+func (c *Client) PostOrder(ctx context.Context, req OrderRequest) (*OrderState, error) {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	direction := proto.OrderDirection_ORDER_DIRECTION_BUY
+	if req.Direction == OrderSell {
+		direction = proto.OrderDirection_ORDER_DIRECTION_SELL
+	}
+	resp, err := c.sdk.NewOrdersServiceClient().PostOrder(&investgo.PostOrderRequest{
+		InstrumentId: req.InstrumentID,
+		Quantity:     req.Lots,
+		Price:        floatToQuotation(req.Price),
+		Direction:    direction,
+		AccountId:    accountID,
+		OrderType:    proto.OrderType_ORDER_TYPE_LIMIT,
+		OrderId:      req.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to post order: %w", err)
+	}
+
+	state := orderStateOf(resp)
+	state.Message = resp.GetMessage()
+	return state, nil
+}
+
+// GetOrderState returns the execution state of an order on the selected account
+func (c *Client) GetOrderState(ctx context.Context, orderID string) (*OrderState, error) {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.sdk.NewOrdersServiceClient().GetOrderState(accountID, orderID, proto.PriceType_PRICE_TYPE_CURRENCY)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state of order %s: %w", orderID, err)
+	}
+
+	return orderStateOf(resp), nil
+}
+
+// GetTradedAmount returns the amount of executed buys and sells on the selected account since from
+func (c *Client) GetTradedAmount(ctx context.Context, from time.Time) (float64, error) {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.sdk.NewOperationsServiceClient().GetOperations(&investgo.GetOperationsRequest{
+		AccountId: accountID,
+		State:     proto.OperationState_OPERATION_STATE_EXECUTED,
+		From:      from,
+		To:        time.Now(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get operations: %w", err)
+	}
+
+	var amount float64
+	for _, op := range resp.GetOperations() {
+		switch op.GetOperationType() {
+		case proto.OperationType_OPERATION_TYPE_BUY, proto.OperationType_OPERATION_TYPE_SELL:
+			amount += math.Abs(moneyValueToFloat64(op.GetPayment()))
+		}
+	}
+	return amount, nil
+}
+
+// orderReport is the execution report shared by placed orders and order states
+type orderReport interface {
+	GetOrderId() string
+	GetExecutionReportStatus() proto.OrderExecutionReportStatus
+	GetLotsRequested() int64
+	GetLotsExecuted() int64
+	GetTotalOrderAmount() *proto.MoneyValue
+	GetExecutedOrderPrice() *proto.MoneyValue
+	GetInitialCommission() *proto.MoneyValue
+	GetExecutedCommission() *proto.MoneyValue
+}
+
+// orderStateOf converts an execution report
+func orderStateOf(r orderReport) *OrderState {
+	// The executed commission is only known once lots are executed
+	commission := moneyValueToFloat64(r.GetExecutedCommission())
+	if commission == 0 {
+		commission = moneyValueToFloat64(r.GetInitialCommission())
+	}
+	return &OrderState{
+		ID:            r.GetOrderId(),
+		Status:        orderStatus(r.GetExecutionReportStatus()),
+		LotsRequested: r.GetLotsRequested(),
+		LotsExecuted:  r.GetLotsExecuted(),
+		Amount:        moneyValueToFloat64(r.GetTotalOrderAmount()),
+		ExecutedPrice: moneyValueToFloat64(r.GetExecutedOrderPrice()),
+		Commission:    commission,
+	}
+}
+
+// orderStatus converts an execution report status
+func orderStatus(s proto.OrderExecutionReportStatus) OrderStatus {
+	switch s {
+	case proto.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_FILL:
+		return OrderFilled
+	case proto.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_REJECTED:
+		return OrderRejected
+	case proto.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_CANCELLED:
+		return OrderCancelled
+	case proto.OrderExecutionReportStatus_EXECUTION_REPORT_STATUS_PARTIALLYFILL:
+		return OrderPartiallyFilled
+	}
+	return OrderNew
+}
+
+// floatToQuotation converts a price to Quotation, rounded to nano units
+func floatToQuotation(v float64) *proto.Quotation {
+	nanos := int64(math.Round(v * 1e9))
+	return &proto.Quotation{Units: nanos / 1e9, Nano: int32(nanos % 1e9)}
+}
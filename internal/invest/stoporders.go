@@ -0,0 +1,152 @@
+package invest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/russianinvestments/invest-api-go-sdk/investgo"
+	proto "github.com/russianinvestments/invest-api-go-sdk/proto"
+)
+
+// Stop order types
+const (
+	StopLoss   = "stop_loss"   // sells at market once the price falls to the stop price
+	TakeProfit = "take_profit" // sells at market once the price rises to the stop price
+)
+
+// StopOrderStatus is the state of a stop order
+type StopOrderStatus string
+
+// Stop order statuses
+const (
+	StopOrderActive    StopOrderStatus = "active"
+	StopOrderExecuted  StopOrderStatus = "executed"
+	StopOrderCancelled StopOrderStatus = "cancelled"
+	StopOrderExpired   StopOrderStatus = "expired"
+)
+
+// StopOrderRequest describes a stop order closing a long position, kept until cancelled
+type StopOrderRequest struct {
+	InstrumentID string // FIGI or instrument UID
+	Type         string // StopLoss or TakeProfit
+	Lots         int64
+	StopPrice    float64 // price per unit that activates the order
+}
+
+// StopOrder is a stop order of the selected account
+type StopOrder struct {
+	ID        string          `json:"id"`
+	FIGI      string          `json:"figi"`
+	Type      string          `json:"type"`
+	Direction string          `json:"direction"` // OrderBuy or OrderSell
+	Lots      int64           `json:"lots"`
+	StopPrice float64         `json:"stop_price"`
+	Currency  string          `json:"currency"`
+	Status    StopOrderStatus `json:"status"`
+	Created   time.Time       `json:"created"`
+	Activated time.Time       `json:"activated,omitempty"` // when an executed order was triggered
+}
+
+// PostStopOrder places a sell stop order on the selected account and returns its ID
+func (c *Client) PostStopOrder(ctx context.Context, req StopOrderRequest) (string, error) {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	orderType := proto.StopOrderType_STOP_ORDER_TYPE_STOP_LOSS
+	if req.Type == TakeProfit {
+		orderType = proto.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT
+	}
+	resp, err := c.sdk.NewStopOrdersServiceClient().PostStopOrder(&investgo.PostStopOrderRequest{
+		InstrumentId:   req.InstrumentID,
+		Quantity:       req.Lots,
+		StopPrice:      floatToQuotation(req.StopPrice),
+		Direction:      proto.StopOrderDirection_STOP_ORDER_DIRECTION_SELL,
+		AccountId:      accountID,
+		ExpirationType: proto.StopOrderExpirationType_STOP_ORDER_EXPIRATION_TYPE_GOOD_TILL_CANCEL,
+		StopOrderType:  orderType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to post stop order: %w", err)
+	}
+	return resp.GetStopOrderId(), nil
+}
+
+// GetStopOrders returns the stop orders of the selected account created since from, in any status
+func (c *Client) GetStopOrders(ctx context.Context, from time.Time) ([]StopOrder, error) {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.sdk.NewStopOrdersServiceClient().GetStopOrders(&investgo.GetStopOrdersRequest{
+		AccountId: accountID,
+		Status:    proto.StopOrderStatusOption_STOP_ORDER_STATUS_ALL,
+		From:      from,
+		To:        time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stop orders: %w", err)
+	}
+
+	orders := make([]StopOrder, 0, len(resp.GetStopOrders()))
+	for _, o := range resp.GetStopOrders() {
+		order := StopOrder{
+			ID:        o.GetStopOrderId(),
+			FIGI:      o.GetFigi(),
+			Type:      stopOrderType(o.GetOrderType()),
+			Direction: OrderBuy,
+			Lots:      o.GetLotsRequested(),
+			StopPrice: moneyValueToFloat64(o.GetStopPrice()),
+			Currency:  strings.ToUpper(o.GetCurrency()),
+			Status:    stopOrderStatus(o.GetStatus()),
+		}
+		if o.GetDirection() == proto.StopOrderDirection_STOP_ORDER_DIRECTION_SELL {
+			order.Direction = OrderSell
+		}
+		if o.GetCreateDate() != nil {
+			order.Created = o.GetCreateDate().AsTime()
+		}
+		if o.GetActivationDateTime() != nil {
+			order.Activated = o.GetActivationDateTime().AsTime()
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// CancelStopOrder cancels an active stop order of the selected account
+func (c *Client) CancelStopOrder(ctx context.Context, orderID string) error {
+	accountID, err := c.resolveAccountID(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := c.sdk.NewStopOrdersServiceClient().CancelStopOrder(accountID, orderID); err != nil {
+		return fmt.Errorf("failed to cancel stop order %s: %w", orderID, err)
+	}
+	return nil
+}
+
+// stopOrderType converts a stop order type; stop-limit orders are reported as stop-losses
+func stopOrderType(t proto.StopOrderType) string {
+	if t == proto.StopOrderType_STOP_ORDER_TYPE_TAKE_PROFIT {
+		return TakeProfit
+	}
+	return StopLoss
+}
+
+// stopOrderStatus converts a stop order status
+func stopOrderStatus(s proto.StopOrderStatusOption) StopOrderStatus {
+	switch s {
+	case proto.StopOrderStatusOption_STOP_ORDER_STATUS_EXECUTED:
+		return StopOrderExecuted
+	case proto.StopOrderStatusOption_STOP_ORDER_STATUS_CANCELED:
+		return StopOrderCancelled
+	case proto.StopOrderStatusOption_STOP_ORDER_STATUS_EXPIRED:
+		return StopOrderExpired
+	}
+	return StopOrderActive
+}
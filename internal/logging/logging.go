@@ -0,0 +1,128 @@
+// Package logging sets up structured logging with log/slog: the level and format
+// come from the configuration, correlation IDs travel in contexts, and account IDs
+// are masked. Secrets are masked by writing through a secrets.Redactor.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Attribute keys with a special meaning
+const (
+	CorrelationIDKey = "correlation_id"
+	AccountIDKey     = "account_id"
+)
+
+// New creates a logger writing records at or above the level in the given format.
+// The level can be changed later, e.g. on config reload.
+func New(w io.Writer, level *slog.LevelVar, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceAttr}
+
+	var handler slog.Handler
+	if format == FormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// Discard returns a logger that drops everything, for tests
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// ParseLevel converts a configured level name (debug, info, warn or error)
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToLower(name))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// LevelVar returns a level variable set to the named level, falling back to info
+func LevelVar(name string) *slog.LevelVar {
+	v := &slog.LevelVar{}
+	if level, err := ParseLevel(name); err == nil {
+		v.Set(level)
+	}
+	return v
+}
+
+// replaceAttr masks account IDs wherever they are logged
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == AccountIDKey {
+		return slog.String(a.Key, MaskAccountID(a.Value.String()))
+	}
+	return a
+}
+
+// MaskAccountID keeps only the last four characters of an account ID,
+// enough to tell accounts apart in logs
+func MaskAccountID(id string) string {
+	const visible = 4
+	if len(id) <= visible {
+		return strings.Repeat("*", len(id))
+	}
+	return strings.Repeat("*", len(id)-visible) + id[len(id)-visible:]
+}
+
+// correlationKey is the context key of the correlation ID
+type correlationKey struct{}
+
+// NewCorrelationID generates a short random ID that ties together the records of one job run or command
+func NewCorrelationID() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%08x", uint32(time.Now().UnixNano()))
+	}
+	return hex.EncodeToString(buf)
+}
+
+// WithCorrelationID returns a context whose log records carry a new correlation ID
+func WithCorrelationID(ctx context.Context) context.Context {
+	return context.WithValue(ctx, correlationKey{}, NewCorrelationID())
+}
+
+// CorrelationID returns the correlation ID of a context, or an empty string
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// contextHandler adds the correlation ID of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := CorrelationID(ctx); id != "" {
+		r.AddAttrs(slog.String(CorrelationIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
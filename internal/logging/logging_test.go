@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"invest-manager/internal/secrets"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggerFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   []string
+	}{
+		{
+			name:   "text",
+			format: FormatText,
+			want:   []string{"level=INFO", `msg="Switched account"`, "account_id=******7890", "correlation_id="},
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			want:   []string{`"level":"INFO"`, `"msg":"Switched account"`, `"account_id":"******7890"`, `"correlation_id":"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := New(&buf, LevelVar("info"), tt.format)
+			logger.InfoContext(WithCorrelationID(context.Background()), "Switched account", AccountIDKey, "1234567890")
+
+			out := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output %q does not contain %q", out, want)
+				}
+			}
+			if strings.Contains(out, "1234567890") {
+				t.Errorf("output %q contains the full account ID", out)
+			}
+		})
+	}
+}
+
+func TestCorrelationIDIsShared(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, LevelVar("info"), FormatJSON)
+
+	ctx := WithCorrelationID(context.Background())
+	logger.InfoContext(ctx, "first")
+	logger.With("job", "scheduled").InfoContext(ctx, "second")
+	logger.Info("without context")
+
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON record %q: %v", line, err)
+		}
+		id, _ := record[CorrelationIDKey].(string)
+		ids = append(ids, id)
+	}
+
+	if len(ids) != 3 || ids[0] == "" || ids[0] != ids[1] || ids[0] != CorrelationID(ctx) {
+		t.Errorf("records of one context should share its correlation ID, got %q", ids)
+	}
+	if ids[2] != "" {
+		t.Errorf("record without a context has correlation ID %q", ids[2])
+	}
+}
+
+func TestLevelChanges(t *testing.T) {
+	var buf bytes.Buffer
+	level := LevelVar("warn")
+	logger := New(&buf, level, FormatText)
+
+	logger.Info("hidden")
+	level.Set(slog.LevelDebug)
+	logger.Debug("shown")
+
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "shown") {
+		t.Errorf("unexpected output after a level change: %q", out)
+	}
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	redactor := secrets.NewRedactor(&buf)
+	redactor.Add("t.secret-token")
+	redactor.AddMasked("2000123456", MaskAccountID("2000123456"))
+	logger := New(redactor, LevelVar("info"), FormatText)
+
+	logger.Error("Request failed", "error", "unauthorized token t.secret-token for account 2000123456")
+
+	out := buf.String()
+	if strings.Contains(out, "t.secret-token") || strings.Contains(out, "2000123456") {
+		t.Errorf("output leaks a secret: %q", out)
+	}
+	if !strings.Contains(out, "******3456") {
+		t.Errorf("output %q does not contain the masked account ID", out)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warn": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(name); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel accepted an unknown level")
+	}
+}
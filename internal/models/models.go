@@ -0,0 +1,384 @@
+// Package models simulates the model portfolios declared in the configuration from
+// daily candles and compares every account with the model it follows: the return,
+// the risk and how far its positions have drifted from the target weights.
+package models
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/refresh"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDeviations is the number of the largest deviations from the weights listed per account
+const maxDeviations = 3
+
+// Broker supplies the accounts, their operations, the price history and the income of the
+// instruments; *invest.Client is the production one
+type Broker interface {
+	GetAccounts(ctx context.Context) ([]invest.Account, error)
+	GetAccountPortfolio(ctx context.Context, accountID string) (*invest.Portfolio, error)
+	GetAccountOperations(ctx context.Context, accountID string, from, to time.Time) ([]invest.Operation, error)
+	FindInstrument(ctx context.Context, ticker string) (*invest.Instrument, error)
+	GetInstrument(ctx context.Context, figi string) (*invest.Instrument, error)
+	GetDailyCandles(ctx context.Context, figi string, from, to time.Time) ([]invest.Candle, error)
+	GetDividends(ctx context.Context, figi string, from, to time.Time) ([]invest.Dividend, error)
+	GetBondCoupons(ctx context.Context, figi string, from, to time.Time) ([]invest.Coupon, error)
+}
+
+// Stats are the return and the risk of a portfolio over the simulated days
+type Stats struct {
+	Return     float64 `json:"return"`     // time-weighted, percent
+	Volatility float64 `json:"volatility"` // annualized standard deviation of the daily returns, percent
+	Drawdown   float64 `json:"drawdown"`   // largest fall from a peak, percent
+}
+
+// Deviation is the weight of an instrument in the account against its target, both in percent
+type Deviation struct {
+	Ticker string  `json:"ticker"`
+	Actual float64 `json:"actual"`
+	Target float64 `json:"target"`
+}
+
+// Comparison is a model with the account that follows it
+type Comparison struct {
+	Model      string      `json:"model"`
+	From       time.Time   `json:"from,omitempty"` // first simulated day, zero without a price history
+	Simulated  Stats       `json:"simulated"`
+	Account    string      `json:"account,omitempty"`
+	Name       string      `json:"name,omitempty"`       // of the account
+	Real       *Stats      `json:"real,omitempty"`       // over the same days, nil for a model without an account
+	Drift      float64     `json:"drift,omitempty"`      // percent of the account value to trade to match the weights
+	Deviations []Deviation `json:"deviations,omitempty"` // largest first
+	Unpriced   []string    `json:"unpriced,omitempty"`   // model tickers without a price history, left out
+	PriceOnly  []string    `json:"price_only,omitempty"` // model tickers whose income could not be loaded, simulated on prices alone
+}
+
+// Report compares every model at a moment
+type Report struct {
+	Time        time.Time    `json:"time"`
+	Comparisons []Comparison `json:"comparisons"` // by model name
+}
+
+// Comparer simulates the models and compares the accounts with them. It is safe for concurrent use.
+type Comparer struct {
+	broker Broker
+	logger *slog.Logger
+	latest refresh.Latest[config.ModelsConfig, Report]
+}
+
+// New creates a comparer
+func New(settings config.ModelsConfig, broker Broker, logger *slog.Logger) *Comparer {
+	c := &Comparer{broker: broker, logger: logger}
+	c.latest.Reload(settings)
+	return c
+}
+
+// Reload applies the models of a new configuration from the next comparison on
+func (c *Comparer) Reload(settings config.ModelsConfig) {
+	c.latest.Reload(settings)
+}
+
+// Enabled reports whether any model is declared
+func (c *Comparer) Enabled() bool {
+	return len(c.latest.Settings().Portfolios) > 0
+}
+
+// Refresh simulates every model over the configured period up to now and replays the accounts
+// that follow them. Without models there is nothing to compare and the report is nil.
+func (c *Comparer) Refresh(ctx context.Context, now time.Time) (*Report, error) {
+	return c.latest.Refresh(func(settings config.ModelsConfig) (*Report, error) {
+		return c.compare(ctx, settings, now)
+	})
+}
+
+// Latest returns the report of the last comparison, nil if it failed or there are no models
+func (c *Comparer) Latest() *Report {
+	return c.latest.Get()
+}
+
+// compare simulates the models in the order of their names
+func (c *Comparer) compare(ctx context.Context, settings config.ModelsConfig, now time.Time) (*Report, error) {
+	if len(settings.Portfolios) == 0 {
+		return nil, nil
+	}
+
+	accounts, err := c.broker.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	names := make(map[string]string, len(accounts))
+	for _, acc := range accounts {
+		names[acc.ID] = acc.Name
+	}
+
+	modelNames := make([]string, 0, len(settings.Portfolios))
+	for name := range settings.Portfolios {
+		modelNames = append(modelNames, name)
+	}
+	sort.Strings(modelNames)
+
+	h := &history{broker: c.broker, logger: c.logger, from: now.AddDate(0, 0, -settings.PeriodDays), to: now,
+		figis: make(map[string]string), instruments: make(map[string]*invest.Instrument),
+		candles: make(map[string][]invest.Candle), payments: make(map[string][]payment), failed: make(map[string]bool)}
+	report := &Report{Time: now}
+	for _, name := range modelNames {
+		model := settings.Portfolios[name]
+		comparison, err := c.compareModel(ctx, h, name, model, settings.RebalanceMonths)
+		if err != nil {
+			return nil, fmt.Errorf("model %s: %w", name, err)
+		}
+		comparison.Name = names[model.Account]
+		report.Comparisons = append(report.Comparisons, comparison)
+	}
+	return report, nil
+}
+
+// compareModel simulates a model and replays its account over the same days
+func (c *Comparer) compareModel(ctx context.Context, h *history, name string, model config.ModelPortfolio,
+	rebalanceMonths int) (Comparison, error) {
+	comparison := Comparison{Model: name, Account: model.Account}
+
+	weights := make(map[string]float64, len(model.Weights))
+	targets := make(map[string]float64, len(model.Weights))
+	income := make(map[string][]payment, len(model.Weights))
+	for ticker, weight := range model.Weights {
+		ticker = strings.ToUpper(ticker)
+		targets[ticker] = weight
+		figi, candles := h.instrument(ctx, ticker)
+		if len(candles) == 0 {
+			comparison.Unpriced = append(comparison.Unpriced, ticker)
+			continue
+		}
+		weights[figi] = weight
+		payments, ok := h.income(ctx, figi)
+		if !ok {
+			comparison.PriceOnly = append(comparison.PriceOnly, ticker)
+		}
+		income[figi] = payments
+	}
+	sort.Strings(comparison.Unpriced)
+	sort.Strings(comparison.PriceOnly)
+
+	// The account is replayed with its dividends and coupons, so the model gets them too
+	simulated := simulate(weights, h.candles, income, rebalanceMonths)
+	if len(simulated) > 0 {
+		comparison.From = simulated[0].day
+		comparison.Simulated = statsOf(simulated)
+	}
+	if model.Account == "" {
+		return comparison, nil
+	}
+
+	portfolio, err := c.broker.GetAccountPortfolio(ctx, model.Account)
+	if err != nil {
+		return comparison, fmt.Errorf("failed to get portfolio of account %s: %w", model.Account, err)
+	}
+	comparison.Drift, comparison.Deviations = drift(portfolio, targets)
+	if len(simulated) == 0 {
+		return comparison, nil
+	}
+
+	operations, err := c.broker.GetAccountOperations(ctx, model.Account, h.from, h.to)
+	if err != nil {
+		return comparison, fmt.Errorf("failed to get operations of account %s: %w", model.Account, err)
+	}
+	// Instruments traded over the period but no longer held are priced too
+	types := make(map[string]string)
+	for _, op := range operations {
+		if op.FIGI != "" {
+			types[op.FIGI] = op.InstrumentType
+		}
+	}
+	for _, pos := range portfolio.Positions {
+		if !isRubles(pos) {
+			types[pos.FIGI] = pos.InstrumentType
+		}
+	}
+	prices := make(map[string][]invest.Candle, len(types))
+	for figi, instrumentType := range types {
+		prices[figi] = h.prices(ctx, figi, instrumentType)
+	}
+	days := make([]time.Time, len(simulated))
+	for i, p := range simulated {
+		days[i] = p.day
+	}
+	replayed := statsOf(replay(portfolio, operations, prices, days))
+	comparison.Real = &replayed
+	return comparison, nil
+}
+
+// history loads the daily candles of the period once per comparison
+type history struct {
+	broker   Broker
+	logger   *slog.Logger
+	from, to time.Time
+
+	figis       map[string]string             // by ticker
+	instruments map[string]*invest.Instrument // by FIGI, of the resolved tickers and the replayed bonds
+	candles     map[string][]invest.Candle    // by FIGI, nil if they could not be loaded
+	payments    map[string][]payment          // by FIGI, of the resolved tickers
+	failed      map[string]bool               // FIGIs whose income could not be loaded
+}
+
+// instrument resolves a ticker and returns its FIGI and candles; both are empty if it is unknown
+func (h *history) instrument(ctx context.Context, ticker string) (string, []invest.Candle) {
+	figi, ok := h.figis[ticker]
+	if !ok {
+		instrument, err := h.broker.FindInstrument(ctx, ticker)
+		if err != nil {
+			h.logger.WarnContext(ctx, "Model instrument not found", "ticker", ticker, "error", err)
+		} else {
+			figi = instrument.FIGI
+			h.instruments[figi] = instrument
+		}
+		h.figis[ticker] = figi
+	}
+	if figi == "" {
+		return "", nil
+	}
+	return figi, h.load(ctx, figi)
+}
+
+// income returns the dividends or coupons of a resolved instrument per unit in the currency of
+// its candles: coupons in percent of the nominal, like bond quotes. It reports false if they
+// could not be loaded; funds reinvest their income, so they have none.
+func (h *history) income(ctx context.Context, figi string) ([]payment, bool) {
+	if payments, ok := h.payments[figi]; ok {
+		return payments, !h.failed[figi]
+	}
+	payments, err := h.loadIncome(ctx, h.instruments[figi])
+	if err != nil {
+		h.logger.WarnContext(ctx, "Could not load income for the model comparison, simulating prices only",
+			"figi", figi, "error", err)
+		h.failed[figi] = true
+	}
+	h.payments[figi] = payments
+	return payments, err == nil
+}
+
+// loadIncome asks the broker for the dividends of a share or the coupons of a bond in its currency
+func (h *history) loadIncome(ctx context.Context, instrument *invest.Instrument) ([]payment, error) {
+	var payments []payment
+	switch instrument.Type {
+	case "share":
+		dividends, err := h.broker.GetDividends(ctx, instrument.FIGI, h.from, h.to)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range dividends {
+			if strings.EqualFold(d.Currency, instrument.Currency) {
+				payments = append(payments, payment{day: dayOf(d.RecordDate), amount: d.Amount})
+			}
+		}
+	case "bond":
+		if instrument.Nominal <= 0 {
+			return nil, fmt.Errorf("the nominal of %s is unknown", instrument.Ticker)
+		}
+		coupons, err := h.broker.GetBondCoupons(ctx, instrument.FIGI, h.from, h.to)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range coupons {
+			if strings.EqualFold(c.Currency, instrument.Currency) {
+				payments = append(payments, payment{day: dayOf(c.FixDate), amount: c.Amount / instrument.Nominal * 100})
+			}
+		}
+	}
+	return payments, nil
+}
+
+// load returns the candles of an instrument, loading them on the first call
+func (h *history) load(ctx context.Context, figi string) []invest.Candle {
+	candles, ok := h.candles[figi]
+	if ok {
+		return candles
+	}
+	candles, err := h.broker.GetDailyCandles(ctx, figi, h.from, h.to)
+	if err != nil {
+		h.logger.WarnContext(ctx, "Could not load candles for the model comparison", "figi", figi, "error", err)
+	}
+	h.candles[figi] = candles
+	return candles
+}
+
+// prices returns the candles of an instrument in money per unit, as the account is valued in
+// money: bond quotes are percent of the nominal. A bond whose nominal is unknown has none, so
+// it is valued at its price now.
+func (h *history) prices(ctx context.Context, figi, instrumentType string) []invest.Candle {
+	candles := h.load(ctx, figi)
+	if instrumentType != "bond" || len(candles) == 0 {
+		return candles
+	}
+	instrument, ok := h.instruments[figi]
+	if !ok {
+		var err error
+		if instrument, err = h.broker.GetInstrument(ctx, figi); err != nil {
+			h.logger.WarnContext(ctx, "Could not get the nominal of a bond for the model comparison", "figi", figi, "error", err)
+			return nil
+		}
+		h.instruments[figi] = instrument
+	}
+	if instrument.Nominal <= 0 {
+		return nil
+	}
+	values := make([]invest.Candle, len(candles))
+	for i, c := range candles {
+		c.Open, c.High, c.Low, c.Close = instrument.QuoteValue(c.Open), instrument.QuoteValue(c.High),
+			instrument.QuoteValue(c.Low), instrument.QuoteValue(c.Close)
+		values[i] = c
+	}
+	return values
+}
+
+// drift compares the weights of the account now with the targets by ticker. Positions outside
+// the model, cash included, have a zero target. It returns the share of the account value that
+// would have to be traded to match the targets, and the largest deviations.
+func drift(portfolio *invest.Portfolio, targets map[string]float64) (float64, []Deviation) {
+	var total float64
+	actual := make(map[string]float64)
+	for _, pos := range portfolio.Positions {
+		actual[strings.ToUpper(pos.Ticker)] += pos.Value()
+		total += pos.Value()
+	}
+	if total <= 0 {
+		return 0, nil
+	}
+
+	deviations := make([]Deviation, 0, len(actual)+len(targets))
+	var sum float64
+	for ticker, value := range actual {
+		deviations = append(deviations, Deviation{Ticker: ticker, Actual: value / total * 100, Target: targets[ticker] * 100})
+	}
+	for ticker, weight := range targets {
+		if _, ok := actual[ticker]; !ok {
+			deviations = append(deviations, Deviation{Ticker: ticker, Target: weight * 100})
+		}
+	}
+	for _, d := range deviations {
+		sum += math.Abs(d.Actual - d.Target)
+	}
+	sort.Slice(deviations, func(i, j int) bool {
+		di, dj := math.Abs(deviations[i].Actual-deviations[i].Target), math.Abs(deviations[j].Actual-deviations[j].Target)
+		if di != dj {
+			return di > dj
+		}
+		return deviations[i].Ticker < deviations[j].Ticker
+	})
+	if len(deviations) > maxDeviations {
+		deviations = deviations[:maxDeviations]
+	}
+	// Every percent over the target has a matching percent under it, so half the sum is traded
+	return sum / 2, deviations
+}
+
+// isRubles reports whether a position is the ruble cash of the account
+func isRubles(pos invest.Position) bool {
+	return pos.InstrumentType == "currency" && strings.HasPrefix(strings.ToUpper(pos.Ticker), "RUB")
+}
@@ -0,0 +1,152 @@
+package models_test
+
+import (
+	"context"
+	"invest-manager/internal/config"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/models"
+	"invest-manager/internal/testutil"
+	"testing"
+	"time"
+)
+
+// The fake must stay usable in place of the production broker
+var (
+	_ models.Broker = (*fake.Broker)(nil)
+	_ models.Broker = (*invest.Client)(nil)
+)
+
+func testBroker() *fake.Broker {
+	return &fake.Broker{
+		Accounts: []invest.Account{{ID: "div", Name: "Дивидендный"}},
+		Instruments: map[string]*invest.Instrument{
+			"sber": {FIGI: "sber", Ticker: "SBER"},
+			"gazp": {FIGI: "gazp", Ticker: "GAZP"},
+		},
+		Candles: map[string][]invest.Candle{
+			"sber": testutil.Candles(testutil.Date(2026, time.October, 1, 7), 100, 110, 121, 110, 121),
+			"gazp": testutil.Candles(testutil.Date(2026, time.October, 1, 7), 100, 100, 100, 100, 100),
+		},
+		// 10 SBER were held from the start, 10 more were bought with a deposit on the third day
+		AccountPortfolios: map[string]*invest.Portfolio{"div": {Positions: []invest.Position{
+			{FIGI: "sber", Ticker: "SBER", InstrumentType: "share", Quantity: 20, CurrentPrice: 121},
+		}}},
+		AccountOperations: map[string][]invest.Operation{"div": {
+			{Time: testutil.Date(2026, time.October, 3, 9), Type: invest.OperationInput, Payment: 1210, Currency: "rub"},
+			testutil.Buy(testutil.Date(2026, time.October, 3, 10), "sber", 10, 121),
+		}},
+	}
+}
+
+func testSettings() config.ModelsConfig {
+	return config.ModelsConfig{
+		PeriodDays: 30,
+		Portfolios: map[string]config.ModelPortfolio{
+			"dividend": {Account: "div", Weights: map[string]float64{"sber": 0.5, "GAZP": 0.5}},
+			"bonds":    {Weights: map[string]float64{"OFZ": 0.5, "GAZP": 0.5}},
+		},
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	comparer := models.New(testSettings(), testBroker(), logging.Discard())
+	report, err := comparer.Refresh(context.Background(), testutil.Date(2026, time.October, 18, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comparer.Latest() != report || len(report.Comparisons) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	// OFZ cannot be priced, so the model is all GAZP, which stayed flat
+	bonds := report.Comparisons[0]
+	if bonds.Model != "bonds" || len(bonds.Unpriced) != 1 || bonds.Unpriced[0] != "OFZ" || bonds.Simulated.Return != 0 || bonds.Real != nil {
+		t.Errorf("bonds = %+v", bonds)
+	}
+
+	// Half in SBER: 100, 105, 110.5, 105, 110.5
+	dividend := report.Comparisons[1]
+	if dividend.Name != "Дивидендный" || !dividend.From.Equal(testutil.Date(2026, time.October, 1, 0)) {
+		t.Fatalf("dividend = %+v", dividend)
+	}
+	if s := dividend.Simulated; !testutil.AlmostEqual(s.Return, 10.5) || !testutil.AlmostEqual(s.Drawdown, (1-105/110.5)*100) || s.Volatility <= 0 {
+		t.Errorf("simulated = %+v", s)
+	}
+	// The deposit is taken out, so the account earns what SBER did
+	if r := dividend.Real; r == nil || !testutil.AlmostEqual(r.Return, 21) || !testutil.AlmostEqual(r.Drawdown, (1-110.0/121)*100) {
+		t.Errorf("real = %+v", dividend.Real)
+	}
+	if dividend.Drift != 50 || len(dividend.Deviations) != 2 || dividend.Deviations[0].Ticker != "GAZP" || dividend.Deviations[1].Actual != 100 {
+		t.Errorf("drift %v, deviations %+v", dividend.Drift, dividend.Deviations)
+	}
+}
+
+func TestRefreshWithoutModels(t *testing.T) {
+	comparer := models.New(config.ModelsConfig{PeriodDays: 30}, testBroker(), logging.Discard())
+	report, err := comparer.Refresh(context.Background(), testutil.Date(2026, time.October, 18, 12))
+	if report != nil || err != nil || comparer.Enabled() {
+		t.Errorf("without models = %+v, %v; want nothing to compare", report, err)
+	}
+}
+
+func TestRefreshReinvestsIncome(t *testing.T) {
+	broker := &fake.Broker{
+		Instruments: map[string]*invest.Instrument{
+			"gazp": {FIGI: "gazp", Ticker: "GAZP", Type: "share", Currency: "RUB"},
+			"ofz":  {FIGI: "ofz", Ticker: "OFZ", Type: "bond", Currency: "RUB"},
+		},
+		Candles: map[string][]invest.Candle{
+			"gazp": testutil.Candles(testutil.Date(2026, time.October, 1, 7), 100, 100, 100, 100, 100),
+			"ofz":  testutil.Candles(testutil.Date(2026, time.October, 1, 7), 98, 98, 98, 98, 98),
+		},
+		Dividends: map[string][]invest.Dividend{"gazp": {{Amount: 10, Currency: "RUB", RecordDate: testutil.Date(2026, time.October, 3, 0)}}},
+	}
+	settings := config.ModelsConfig{PeriodDays: 30, Portfolios: map[string]config.ModelPortfolio{
+		"income": {Weights: map[string]float64{"GAZP": 1}},
+		"bonds":  {Weights: map[string]float64{"OFZ": 1}},
+	}}
+	report, err := models.New(settings, broker, logging.Discard()).Refresh(context.Background(), testutil.Date(2026, time.October, 18, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the nominal the coupons cannot be valued, so the bond model is price-only
+	if bonds := report.Comparisons[0]; len(bonds.PriceOnly) != 1 || bonds.PriceOnly[0] != "OFZ" || bonds.Simulated.Return != 0 {
+		t.Errorf("bonds = %+v", bonds)
+	}
+	// The dividend of 10 is reinvested in GAZP, which stayed flat
+	if income := report.Comparisons[1]; len(income.PriceOnly) != 0 || !testutil.AlmostEqual(income.Simulated.Return, 10) {
+		t.Errorf("income = %+v", income)
+	}
+}
+
+func TestRefreshValuesBondsByNominal(t *testing.T) {
+	broker := &fake.Broker{
+		Instruments: map[string]*invest.Instrument{
+			"gazp": {FIGI: "gazp", Ticker: "GAZP", Type: "share", Currency: "RUB"},
+			"ofz":  {FIGI: "ofz", Ticker: "OFZ", Type: "bond", Currency: "RUB", Nominal: 1000},
+		},
+		Candles: map[string][]invest.Candle{
+			"gazp": testutil.Candles(testutil.Date(2026, time.October, 1, 7), 100, 100, 100),
+			"ofz":  testutil.Candles(testutil.Date(2026, time.October, 1, 7), 100, 105, 110),
+		},
+		AccountPortfolios: map[string]*invest.Portfolio{"iis": {Positions: []invest.Position{
+			{FIGI: "ofz", Ticker: "OFZ", InstrumentType: "bond", Quantity: 10, CurrentPrice: 1100},
+			{FIGI: "rub", Ticker: "RUB000UTSTOM", InstrumentType: "currency", Quantity: 1000, CurrentPrice: 1},
+		}}},
+	}
+	settings := config.ModelsConfig{PeriodDays: 30, Portfolios: map[string]config.ModelPortfolio{
+		"shares": {Account: "iis", Weights: map[string]float64{"GAZP": 1}},
+	}}
+	report, err := models.New(settings, broker, logging.Discard()).Refresh(context.Background(), testutil.Date(2026, time.October, 18, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The bonds are worth 10 000 and then 11 000 next to 1 000 in cash, not 1 000 and 1 100
+	if r := report.Comparisons[0].Real; r == nil || !testutil.AlmostEqual(r.Return, 1000.0/11000*100) {
+		t.Errorf("real = %+v, want a return of %v", r, 1000.0/11000*100)
+	}
+}
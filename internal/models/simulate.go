@@ -0,0 +1,222 @@
+package models
+
+import (
+	"invest-manager/internal/invest"
+	"math"
+	"sort"
+	"time"
+)
+
+// Simulation settings
+const (
+	startValue         = 100 // value a model is bought for
+	tradingDaysPerYear = 252
+)
+
+// point is the value of a portfolio at the close of a day, with the money deposited
+// (positive) or withdrawn (negative) since the previous day
+type point struct {
+	day   time.Time
+	value float64
+	flow  float64
+}
+
+// payment is income paid to the holders at the end of a day, per unit in the currency of the candles
+type payment struct {
+	day    time.Time
+	amount float64
+}
+
+// dayOf returns the date of a moment as midnight UTC, the key candles are matched by
+func dayOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// closes indexes the candles of every instrument by day
+func closes(candles map[string][]invest.Candle) map[string]map[time.Time]float64 {
+	result := make(map[string]map[time.Time]float64, len(candles))
+	for figi, list := range candles {
+		byDay := make(map[time.Time]float64, len(list))
+		for _, c := range list {
+			byDay[dayOf(c.Time)] = c.Close
+		}
+		result[figi] = byDay
+	}
+	return result
+}
+
+// simulate buys the weights by FIGI at the close of the first day every instrument has a price
+// and brings the model back to them on the first day of every rebalanceMonths months; with 0 it
+// is never rebalanced. Income by FIGI is reinvested in the instrument that paid it at the close
+// of the first day on or after it is paid. Prices are carried over days without a candle. It
+// returns nil if the instruments never have a price on the same day.
+func simulate(weights map[string]float64, candles map[string][]invest.Candle, income map[string][]payment,
+	rebalanceMonths int) []point {
+	if len(weights) == 0 {
+		return nil
+	}
+	var total float64
+	byDay := make(map[time.Time]bool)
+	for figi, weight := range weights {
+		total += weight
+		for _, c := range candles[figi] {
+			byDay[dayOf(c.Time)] = true
+		}
+	}
+	days := make([]time.Time, 0, len(byDay))
+	for day := range byDay {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	prices := closes(candles)
+	last := make(map[string]float64, len(weights))
+	units := make(map[string]float64, len(weights))
+	var points []point
+	var rebalanced int // month index of the last rebalancing
+	for _, day := range days {
+		for figi := range weights {
+			if price, ok := prices[figi][day]; ok {
+				last[figi] = price
+			}
+		}
+		if len(last) < len(weights) {
+			continue
+		}
+
+		month := day.Year()*12 + int(day.Month())
+		value := float64(startValue)
+		if len(points) > 0 {
+			prev := points[len(points)-1].day
+			for figi, n := range units {
+				for _, p := range income[figi] {
+					if p.day.After(prev) && !p.day.After(day) {
+						units[figi] += n * p.amount / last[figi]
+					}
+				}
+			}
+			value = 0
+			for figi, n := range units {
+				value += n * last[figi]
+			}
+		}
+		if len(points) == 0 || (rebalanceMonths > 0 && month-rebalanced >= rebalanceMonths) {
+			// Weights of the tickers left out are spread over the others
+			for figi, weight := range weights {
+				units[figi] = value * weight / total / last[figi]
+			}
+			rebalanced = month
+		}
+		points = append(points, point{day: day, value: value})
+	}
+	return points
+}
+
+// replay rebuilds the value of an account at the close of every day from its positions now and
+// its operations since the first day: the trades and payments made after a day are undone to get
+// its holdings. Ruble cash is taken at face value, other instruments at the last close on or
+// before the day, or the first one, from candles in money per unit. Operations paid in other currencies are left out, as their
+// rate is unknown.
+func replay(portfolio *invest.Portfolio, operations []invest.Operation, candles map[string][]invest.Candle, days []time.Time) []point {
+	quantities := make(map[string]float64)
+	current := make(map[string]float64) // price now, for instruments without candles
+	var cash float64
+	for _, pos := range portfolio.Positions {
+		if isRubles(pos) {
+			cash += pos.Value()
+			continue
+		}
+		quantities[pos.FIGI] += pos.Quantity
+		current[pos.FIGI] = pos.CurrentPrice
+	}
+
+	var rubles []invest.Operation
+	for _, op := range operations {
+		if op.Currency == "" || op.Currency == "rub" {
+			rubles = append(rubles, op)
+		}
+	}
+	sort.SliceStable(rubles, func(i, j int) bool { return rubles[i].Time.Before(rubles[j].Time) })
+
+	// Walk back from today, undoing the operations made after the close of each day
+	points := make([]point, len(days))
+	next := len(rubles) - 1
+	for i := len(days) - 1; i >= 0; i-- {
+		end := days[i].AddDate(0, 0, 1)
+		for ; next >= 0 && !rubles[next].Time.Before(end); next-- {
+			op := rubles[next]
+			switch op.Type {
+			case invest.OperationBuy:
+				quantities[op.FIGI] -= op.Quantity
+			case invest.OperationSell:
+				quantities[op.FIGI] += op.Quantity
+			case invest.OperationInput, invest.OperationOutput:
+				// Made after this day, so they count towards the next one
+				if i+1 < len(points) {
+					points[i+1].flow += op.Payment
+				}
+			}
+			cash -= op.Payment
+		}
+
+		value := cash
+		for figi, n := range quantities {
+			if n != 0 {
+				value += n * priceOn(candles[figi], days[i], current[figi])
+			}
+		}
+		points[i].day = days[i]
+		points[i].value = value
+	}
+	return points
+}
+
+// priceOn returns the last close on or before the day, the first close for an earlier day,
+// or fallback without candles
+func priceOn(candles []invest.Candle, day time.Time, fallback float64) float64 {
+	if len(candles) == 0 {
+		return fallback
+	}
+	price := candles[0].Close
+	for _, c := range candles {
+		if dayOf(c.Time).After(day) {
+			break
+		}
+		price = c.Close
+	}
+	return price
+}
+
+// statsOf computes the time-weighted return and the risk of the daily values, taking the
+// deposits and withdrawals of each day out of its growth
+func statsOf(points []point) Stats {
+	var returns []float64
+	for i := 1; i < len(points); i++ {
+		if prev := points[i-1].value; prev > 0 {
+			returns = append(returns, (points[i].value-points[i].flow)/prev-1)
+		}
+	}
+	if len(returns) == 0 {
+		return Stats{}
+	}
+
+	growth, peak, drawdown := 1.0, 1.0, 0.0
+	var sum float64
+	for _, r := range returns {
+		growth *= 1 + r
+		peak = math.Max(peak, growth)
+		drawdown = math.Max(drawdown, 1-growth/peak)
+		sum += r
+	}
+	stats := Stats{Return: (growth - 1) * 100, Drawdown: drawdown * 100}
+	if len(returns) > 1 {
+		mean := sum / float64(len(returns))
+		var squares float64
+		for _, r := range returns {
+			squares += (r - mean) * (r - mean)
+		}
+		stats.Volatility = math.Sqrt(squares/float64(len(returns)-1)*tradingDaysPerYear) * 100
+	}
+	return stats
+}
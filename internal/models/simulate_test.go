@@ -0,0 +1,42 @@
+package models
+
+import (
+	"invest-manager/internal/invest"
+	"invest-manager/internal/testutil"
+	"testing"
+	"time"
+)
+
+func TestSimulateRebalances(t *testing.T) {
+	history := map[string][]invest.Candle{
+		"a": {{Time: testutil.Date(2026, time.January, 31, 7), Close: 100}, {Time: testutil.Date(2026, time.February, 1, 7), Close: 200}, {Time: testutil.Date(2026, time.February, 2, 7), Close: 100}},
+		"b": {{Time: testutil.Date(2026, time.January, 31, 7), Close: 100}, {Time: testutil.Date(2026, time.February, 1, 7), Close: 100}, {Time: testutil.Date(2026, time.February, 2, 7), Close: 100}},
+	}
+	weights := map[string]float64{"a": 0.5, "b": 0.5}
+
+	// Brought back to half and half at 150 on February 1: 0.375 A and 0.75 B
+	if points := simulate(weights, history, nil, 1); len(points) != 3 || !testutil.AlmostEqual(points[2].value, 112.5) {
+		t.Errorf("rebalanced monthly = %+v", points)
+	}
+	if points := simulate(weights, history, nil, 0); len(points) != 3 || !testutil.AlmostEqual(points[2].value, 100) {
+		t.Errorf("never rebalanced = %+v", points)
+	}
+
+	// B starts trading a day later, and so does the model
+	history["b"] = history["b"][1:]
+	if points := simulate(weights, history, nil, 0); len(points) != 2 || !points[0].day.Equal(testutil.Date(2026, time.February, 1, 0)) {
+		t.Errorf("late listing = %+v", points)
+	}
+}
+
+func TestSimulateReinvestsIncome(t *testing.T) {
+	history := map[string][]invest.Candle{
+		"a": {{Time: testutil.Date(2026, time.March, 2, 7), Close: 100}, {Time: testutil.Date(2026, time.March, 3, 7), Close: 100}, {Time: testutil.Date(2026, time.March, 5, 7), Close: 90}},
+	}
+	// Paid on a day without a candle, reinvested at 90 on the next one: 1 unit and 10/90 more
+	income := map[string][]payment{"a": {{day: testutil.Date(2026, time.March, 4, 0), amount: 10}}}
+	points := simulate(map[string]float64{"a": 1}, history, income, 0)
+	if len(points) != 3 || !testutil.AlmostEqual(points[1].value, 100) || !testutil.AlmostEqual(points[2].value, 100) {
+		t.Errorf("with income = %+v, want the dividend to make up for the fall", points)
+	}
+}
@@ -0,0 +1,154 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDisabled is returned by a check of an integration that is switched off in the configuration
+var ErrDisabled = errors.New("disabled in the configuration")
+
+// Check probes a dependency and returns an error if it cannot be reached
+type Check func(ctx context.Context) error
+
+// Dependency statuses
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDisabled = "disabled"
+	StatusUnknown  = "unknown" // not checked yet
+)
+
+// checkTimeout limits a single check, so a hanging dependency does not block the others
+const checkTimeout = 15 * time.Second
+
+// DependencyStatus is the result of the latest checks of a dependency
+type DependencyStatus struct {
+	Status      string     `json:"status"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// dependency is a registered check with its latest results
+type dependency struct {
+	name   string
+	check  Check
+	status DependencyStatus
+}
+
+// Health tracks the reachability of dependencies and whether the bot is ready to serve
+type Health struct {
+	mu           sync.RWMutex
+	dependencies []*dependency
+	ready        bool
+}
+
+// NewHealth creates a tracker without dependencies that is not ready yet
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// Register adds a dependency checked by CheckAll
+func (h *Health) Register(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dependencies = append(h.dependencies, &dependency{
+		name:   name,
+		check:  check,
+		status: DependencyStatus{Status: StatusUnknown},
+	})
+	dependencyUp.WithLabelValues(name).Set(0)
+}
+
+// CheckAll runs every check concurrently and records the results
+func (h *Health) CheckAll(ctx context.Context) {
+	h.mu.RLock()
+	dependencies := append([]*dependency(nil), h.dependencies...)
+	h.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, dep := range dependencies {
+		wg.Add(1)
+		go func(dep *dependency) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			h.record(dep, dep.check(checkCtx), time.Now())
+		}(dep)
+	}
+	wg.Wait()
+}
+
+// record stores the result of a check
+func (h *Health) record(dep *dependency, err error, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case errors.Is(err, ErrDisabled):
+		dep.status.Status = StatusDisabled
+		dep.status.Error = ""
+	case err != nil:
+		dep.status.Status = StatusFailing
+		dep.status.LastFailure = &now
+		dep.status.Error = err.Error()
+	default:
+		dep.status.Status = StatusOK
+		dep.status.LastSuccess = &now
+		dep.status.Error = ""
+	}
+
+	up := 0.0
+	if dep.status.Status == StatusOK {
+		up = 1
+	}
+	dependencyUp.WithLabelValues(dep.name).Set(up)
+}
+
+// Run checks all dependencies right away and then at every interval until the context is canceled
+func (h *Health) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		h.CheckAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the status of every dependency and whether none of them is failing
+func (h *Health) Status() (map[string]DependencyStatus, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	statuses := make(map[string]DependencyStatus, len(h.dependencies))
+	healthy := true
+	for _, dep := range h.dependencies {
+		statuses[dep.name] = dep.status
+		if dep.status.Status == StatusFailing {
+			healthy = false
+		}
+	}
+	return statuses, healthy
+}
+
+// SetReady marks the bot as ready to serve, or not ready, e.g. while shutting down
+func (h *Health) SetReady(ready bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = ready
+}
+
+// Ready reports whether the bot has started and is not shutting down
+func (h *Health) Ready() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.ready
+}
@@ -0,0 +1,135 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		checks      map[string]error
+		wantHealthy bool
+		wantStatus  map[string]string
+	}{
+		{
+			name:        "all reachable",
+			checks:      map[string]error{"broker": nil, "telegram": nil},
+			wantHealthy: true,
+			wantStatus:  map[string]string{"broker": StatusOK, "telegram": StatusOK},
+		},
+		{
+			name:        "disabled integration is not a failure",
+			checks:      map[string]error{"broker": nil, "llm": ErrDisabled},
+			wantHealthy: true,
+			wantStatus:  map[string]string{"broker": StatusOK, "llm": StatusDisabled},
+		},
+		{
+			name:        "unreachable dependency",
+			checks:      map[string]error{"broker": errors.New("connection refused"), "telegram": nil},
+			wantHealthy: false,
+			wantStatus:  map[string]string{"broker": StatusFailing, "telegram": StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := NewHealth()
+			for name, err := range tt.checks {
+				err := err
+				health.Register(name, func(ctx context.Context) error { return err })
+			}
+			health.CheckAll(context.Background())
+
+			statuses, healthy := health.Status()
+			if healthy != tt.wantHealthy {
+				t.Errorf("healthy = %v, want %v", healthy, tt.wantHealthy)
+			}
+			for name, want := range tt.wantStatus {
+				got := statuses[name]
+				if got.Status != want {
+					t.Errorf("%s status = %q, want %q", name, got.Status, want)
+				}
+				if want == StatusOK && got.LastSuccess == nil {
+					t.Errorf("%s has no last success time", name)
+				}
+				if want == StatusFailing && (got.LastFailure == nil || got.Error == "") {
+					t.Errorf("%s failure is not recorded: %+v", name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthKeepsLastSuccess(t *testing.T) {
+	health := NewHealth()
+	var err error
+	health.Register("broker", func(ctx context.Context) error { return err })
+
+	health.CheckAll(context.Background())
+	err = errors.New("timeout")
+	health.CheckAll(context.Background())
+
+	statuses, _ := health.Status()
+	if got := statuses["broker"]; got.Status != StatusFailing || got.LastSuccess == nil {
+		t.Errorf("broker = %+v, want failing with the earlier success kept", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	health := NewHealth()
+	brokerErr := errors.New("connection refused")
+	health.Register("broker", func(ctx context.Context) error { return brokerErr })
+	health.CheckAll(context.Background())
+	handler := newHandler(health)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/healthz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz with a failing dependency = %d, want 503", rec.Code)
+	}
+	var body healthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("/healthz body: %v", err)
+	}
+	if body.Dependencies["broker"].Error != "connection refused" {
+		t.Errorf("/healthz body = %+v", body)
+	}
+
+	brokerErr = nil
+	health.CheckAll(context.Background())
+	if rec := get("/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("/healthz after recovery = %d, want 200", rec.Code)
+	}
+
+	if rec := get("/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before start = %d, want 503", rec.Code)
+	}
+	health.SetReady(true)
+	if rec := get("/readyz"); rec.Code != http.StatusOK {
+		t.Errorf("/readyz after start = %d, want 200", rec.Code)
+	}
+
+	ObserveJob("scheduled", time.Now(), nil)
+	rec = get("/metrics")
+	for _, want := range []string{
+		`invest_manager_dependency_up{dependency="broker"} 1`,
+		`invest_manager_job_runs_total{job="scheduled",outcome="success"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("/metrics does not contain %q", want)
+		}
+	}
+}
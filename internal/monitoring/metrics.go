@@ -0,0 +1,180 @@
+// Package monitoring exposes Prometheus metrics and the health of the external
+// dependencies over HTTP, so the bot can be scraped and supervised.
+package monitoring
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace prefixes every metric name
+const namespace = "invest_manager"
+
+// Outcome label values
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+// Registry holds the metrics served on /metrics
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	jobRuns = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Analysis runs by job and outcome.",
+	}, []string{"job", "outcome"})
+
+	jobDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Duration of analysis runs.",
+		Buckets:   []float64{1, 2, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"job", "outcome"})
+
+	jobLastSuccess = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "job_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful analysis run.",
+	}, []string{"job"})
+
+	llmDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "Latency of LLM completion requests.",
+		Buckets:   []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"model", "outcome"})
+
+	llmTokens = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "Tokens used by LLM requests, by kind (prompt or completion).",
+	}, []string{"model", "kind"})
+
+	llmCost = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_cost_usd_total",
+		Help:      "Cost of LLM requests in USD by the configured price table.",
+	}, []string{"model", "job"})
+
+	newsFetches = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "news_fetches_total",
+		Help:      "NewsAPI requests by outcome.",
+	}, []string{"outcome"})
+
+	newsArticles = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "news_articles_total",
+		Help:      "Articles returned by NewsAPI.",
+	})
+
+	telegramSends = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_sends_total",
+		Help:      "Messages, edits and photos sent to Telegram, by outcome.",
+	}, []string{"outcome"})
+
+	orders = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_total",
+		Help:      "Orders placed from the chat, by direction and final status.",
+	}, []string{"direction", "status"})
+
+	portfolioValue = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "portfolio_value_rub",
+		Help:      "Current value of the selected portfolio.",
+	})
+
+	portfolioYield = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "portfolio_expected_yield_rub",
+		Help:      "Unrealized P&L of the selected portfolio.",
+	})
+
+	positionValue = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "position_value_rub",
+		Help:      "Current value of each position of the selected portfolio.",
+	}, []string{"ticker"})
+
+	dependencyUp = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dependency_up",
+		Help:      "Whether the last health check of a dependency succeeded.",
+	}, []string{"dependency"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// outcome returns the outcome label of an error
+func outcome(err error) string {
+	if err != nil {
+		return outcomeError
+	}
+	return outcomeSuccess
+}
+
+// ObserveJob records a finished analysis run that started at start
+func ObserveJob(job string, start time.Time, err error) {
+	jobRuns.WithLabelValues(job, outcome(err)).Inc()
+	jobDuration.WithLabelValues(job, outcome(err)).Observe(time.Since(start).Seconds())
+	if err == nil {
+		jobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+	}
+}
+
+// ObserveLLMRequest records a finished completion request that started at start
+func ObserveLLMRequest(model string, start time.Time, err error) {
+	llmDuration.WithLabelValues(model, outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+// AddLLMTokens records the token usage of a completion request
+func AddLLMTokens(model string, prompt, completion int) {
+	llmTokens.WithLabelValues(model, "prompt").Add(float64(prompt))
+	llmTokens.WithLabelValues(model, "completion").Add(float64(completion))
+}
+
+// AddLLMCost records the cost of a completion request made by a job
+func AddLLMCost(model, job string, cost float64) {
+	llmCost.WithLabelValues(model, job).Add(cost)
+}
+
+// ObserveNewsFetch records a NewsAPI request and the number of articles it returned
+func ObserveNewsFetch(articles int, err error) {
+	newsFetches.WithLabelValues(outcome(err)).Inc()
+	newsArticles.Add(float64(articles))
+}
+
+// ObserveTelegramSend records a request that sends or edits a message
+func ObserveTelegramSend(err error) {
+	telegramSends.WithLabelValues(outcome(err)).Inc()
+}
+
+// ObserveOrder records an order that reached a final status, or "error" if it could not be placed
+func ObserveOrder(direction, status string) {
+	orders.WithLabelValues(direction, status).Inc()
+}
+
+// SetPortfolio updates the portfolio gauges; positions maps tickers to position values.
+// Positions that are no longer held disappear from the metrics.
+func SetPortfolio(total, expectedYield float64, positions map[string]float64) {
+	portfolioValue.Set(total)
+	portfolioYield.Set(expectedYield)
+	positionValue.Reset()
+	for ticker, value := range positions {
+		positionValue.WithLabelValues(ticker).Set(value)
+	}
+}
@@ -0,0 +1,123 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// shutdownTimeout limits how long in-flight requests may take on shutdown
+const shutdownTimeout = 5 * time.Second
+
+// healthResponse is the body of /healthz
+type healthResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// newHandler serves /healthz, /readyz and /metrics
+func newHandler(health *Health) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		dependencies, healthy := health.Status()
+		resp := healthResponse{Status: StatusOK, Dependencies: dependencies}
+		code := http.StatusOK
+		if !healthy {
+			resp.Status = StatusFailing
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !health.Ready() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+// Server serves the health and metrics endpoints
+type Server struct {
+	server *http.Server
+	logger *slog.Logger
+}
+
+// NewServer creates a server listening on addr
+func NewServer(addr string, health *Health, logger *slog.Logger) *Server {
+	return &Server{
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           newHandler(health),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+// Start listens on the address and serves requests in the background.
+// Listening happens before returning, so a busy port is reported as an error.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
+	}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Monitoring listener stopped", "error", err)
+		}
+	}()
+
+	s.logger.Info("Monitoring listener started", "addr", listener.Addr().String())
+	return nil
+}
+
+// Stop shuts the listener down waiting for in-flight requests
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.Error("Failed to shut down monitoring listener", "error", err)
+	}
+}
+
+// Notify reports a state change such as "READY=1" or "STOPPING=1" to systemd
+// when running as a Type=notify service. It does nothing otherwise.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Abstract sockets are passed with a leading @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
@@ -4,14 +4,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"invest-manager/internal/config"
+	"invest-manager/internal/monitoring"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
 // Fetcher handles news API requests
 type Fetcher struct {
+	mu      sync.RWMutex
+	enabled bool
 	apiKey  string
+	query   string
+	limit   int
 	baseURL string
 	client  *http.Client
 }
@@ -35,17 +41,52 @@ type newsResponse struct {
 
 // NewFetcher creates a new news fetcher
 func NewFetcher(cfg *config.Config) *Fetcher {
-	return &Fetcher{
-		apiKey:  cfg.NewsAPIToken,
+	f := &Fetcher{
 		baseURL: "https://newsapi.org/v2/everything",
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
 	}
+	f.Reload(cfg)
+	return f
 }
 
-// FetchNews fetches top news articles about Russian stocks
+// Reload applies the news settings of a new configuration
+func (f *Fetcher) Reload(cfg *config.Config) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enabled = cfg.News.Enabled
+	f.apiKey = cfg.News.APIToken.Value()
+	f.query = cfg.News.Query
+	f.limit = cfg.News.Limit
+}
+
+// Enabled reports whether the news integration is switched on
+func (f *Fetcher) Enabled() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.enabled
+}
+
+// FetchMarketNews fetches news for reports using the configured query and limit
+func (f *Fetcher) FetchMarketNews() ([]Article, error) {
+	f.mu.RLock()
+	query, limit := f.query, f.limit
+	f.mu.RUnlock()
+	return f.FetchNews(query, limit)
+}
+
+// FetchNews fetches top news articles about Russian stocks.
+// It returns no articles without calling the API if the integration is disabled.
 func (f *Fetcher) FetchNews(query string, limit int) ([]Article, error) {
+	f.mu.RLock()
+	enabled, apiKey := f.enabled, f.apiKey
+	f.mu.RUnlock()
+
+	if !enabled {
+		return []Article{}, nil
+	}
+
 	if query == "" {
 		query = "Russia stocks" // Default query
 	}
@@ -54,6 +95,13 @@ func (f *Fetcher) FetchNews(query string, limit int) ([]Article, error) {
 		limit = 5 // Default limit
 	}
 
+	articles, err := f.fetch(apiKey, query, limit)
+	monitoring.ObserveNewsFetch(len(articles), err)
+	return articles, err
+}
+
+// fetch requests articles from the API
+func (f *Fetcher) fetch(apiKey, query string, limit int) ([]Article, error) {
 	// Build the request URL
 	reqURL, err := url.Parse(f.baseURL)
 	if err != nil {
@@ -74,7 +122,7 @@ func (f *Fetcher) FetchNews(query string, limit int) ([]Article, error) {
 	}
 
 	// Add API key header
-	req.Header.Add("X-Api-Key", f.apiKey)
+	req.Header.Add("X-Api-Key", apiKey)
 
 	// Make the request
 	resp, err := f.client.Do(req)
@@ -0,0 +1,494 @@
+// Package paper simulates a portfolio that follows every BUY and SELL
+// recommendation of the analyzer, so the advice can be judged against the
+// real account before any of it is automated. Trades are filled at the
+// current market price in whole units; lot sizes are not simulated.
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/atomicfile"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Market prices the instruments the real portfolio does not hold and reports the deposits and
+// withdrawals of the real account; *invest.Client is the production one
+type Market interface {
+	GetLastPrices(ctx context.Context, figis []string) (map[string]float64, error)
+	FindInstrument(ctx context.Context, ticker string) (*invest.Instrument, error)
+	GetCashFlows(ctx context.Context, from, to time.Time) ([]invest.CashFlow, error)
+}
+
+// Holding is a position of the paper portfolio
+type Holding struct {
+	FIGI         string  `json:"figi"`
+	Ticker       string  `json:"ticker"`
+	Name         string  `json:"name"`
+	Quantity     float64 `json:"quantity"`
+	AveragePrice float64 `json:"average_price"`
+	LastPrice    float64 `json:"last_price"`
+	Nominal      float64 `json:"nominal,omitempty"` // of bonds bought on paper only, whose market quotes are in percent of it
+}
+
+// Value returns the holding at its last known price
+func (h Holding) Value() float64 {
+	return h.Quantity * h.LastPrice
+}
+
+// Trade is a simulated fill
+type Trade struct {
+	Time       time.Time `json:"time"`
+	Ticker     string    `json:"ticker"`
+	FIGI       string    `json:"figi"`
+	Action     string    `json:"action"` // BUY or SELL
+	Quantity   float64   `json:"quantity"`
+	Price      float64   `json:"price"`
+	Commission float64   `json:"commission"`
+}
+
+// EquityPoint is the value of the paper and the real portfolio after a run
+type EquityPoint struct {
+	Time  time.Time `json:"time"`
+	Paper float64   `json:"paper"`
+	Real  float64   `json:"real"`
+	Flow  float64   `json:"flow,omitempty"` // deposits less withdrawals of the real account since the previous run
+}
+
+// state is what the file keeps
+type state struct {
+	Started  time.Time           `json:"started"`
+	Cash     float64             `json:"cash"`
+	Holdings map[string]*Holding `json:"holdings"` // by FIGI
+	Trades   []Trade             `json:"trades"`
+	Equity   []EquityPoint       `json:"equity"`
+}
+
+// equity returns the cash and holdings together
+func (s *state) equity() float64 {
+	total := s.Cash
+	for _, h := range s.Holdings {
+		total += h.Value()
+	}
+	return total
+}
+
+// Comparison sums up the paper portfolio against the real one after the latest run
+type Comparison struct {
+	Since       time.Time `json:"since"`
+	Paper       float64   `json:"paper"`
+	Real        float64   `json:"real"`
+	PaperReturn float64   `json:"paper_return"` // percent since the start
+	RealReturn  float64   `json:"real_return"`  // percent since the start, time-weighted between runs so deposits do not count
+	Trades      []Trade   `json:"trades"`       // made by the latest run
+}
+
+// Snapshot is the current paper portfolio
+type Snapshot struct {
+	Cash     float64
+	Holdings []Holding // by value, largest first
+	Trades   []Trade   // most recent last
+}
+
+// Account is the paper portfolio. It is safe for concurrent use.
+type Account struct {
+	path   string
+	market Market
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu    sync.Mutex
+	rules config.PaperConfig
+	state state
+}
+
+// Open reads the paper portfolio from its file, which is created by the first run.
+// With an empty path the portfolio is only kept in memory.
+// A nil market leaves holdings the real portfolio lacks at their last price, skips
+// buys of instruments it does not hold and takes the real account as free of deposits.
+func Open(path string, rules config.PaperConfig, market Market, logger *slog.Logger) (*Account, error) {
+	a := &Account{
+		path:   path,
+		market: market,
+		logger: logger,
+		now:    time.Now,
+		rules:  rules,
+		state:  state{Holdings: make(map[string]*Holding)},
+	}
+	if path == "" {
+		return a, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paper portfolio: %w", err)
+	}
+	if err := json.Unmarshal(data, &a.state); err != nil {
+		return nil, fmt.Errorf("failed to parse paper portfolio %s: %w", path, err)
+	}
+	if a.state.Holdings == nil {
+		a.state.Holdings = make(map[string]*Holding)
+	}
+	return a, nil
+}
+
+// Reload applies the sizing rules of a new configuration
+func (a *Account) Reload(rules config.PaperConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules = rules
+}
+
+// Enabled reports whether the paper portfolio follows the analyses
+func (a *Account) Enabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rules.Enabled
+}
+
+// Follow trades the BUY and SELL recommendations of an analysis at the prices of
+// the real portfolio and records the equity of both. On the first run the paper
+// portfolio starts from the configured cash or as a copy of the real one.
+// A ticker is traded at most once a day, so repeated analyses do not pile up orders.
+// Buys of instruments the real portfolio does not hold, such as watched ones, are
+// priced by the market.
+func (a *Account) Follow(ctx context.Context, portfolio *invest.Portfolio, result *analysis.PortfolioAnalysis) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.rules.Enabled {
+		return nil
+	}
+	now := a.now()
+	// Without the deposits the real return would be wrong, so the run is left out;
+	// the next one covers the deposits since the last recorded run
+	flow, err := a.realFlow(ctx, now)
+	if err != nil {
+		return err
+	}
+	if a.state.Started.IsZero() {
+		a.start(portfolio, now)
+	}
+	a.markToMarket(ctx, portfolio)
+
+	for _, rec := range result.Recommendations {
+		action := strings.ToUpper(rec.Action)
+		if action != invest.OrderBuy && action != invest.OrderSell {
+			continue
+		}
+		q := a.quoteFor(ctx, portfolio, rec.Ticker, action == invest.OrderBuy)
+		if q == nil || a.tradedToday(q.figi, now) {
+			continue
+		}
+		var trade *Trade
+		if action == invest.OrderBuy {
+			trade = a.buy(q, now)
+		} else {
+			trade = a.sell(q, now)
+		}
+		if trade != nil {
+			a.state.Trades = append(a.state.Trades, *trade)
+			a.logger.InfoContext(ctx, "Paper trade", "ticker", trade.Ticker, "action", trade.Action,
+				"quantity", trade.Quantity, "price", trade.Price)
+		}
+	}
+
+	a.state.Equity = append(a.state.Equity, EquityPoint{Time: now, Paper: a.state.equity(), Real: portfolio.TotalAmount, Flow: flow})
+	return a.save()
+}
+
+// realFlow returns the deposits less withdrawals of the real account since the latest run
+func (a *Account) realFlow(ctx context.Context, now time.Time) (float64, error) {
+	if a.market == nil || len(a.state.Equity) == 0 {
+		return 0, nil
+	}
+	flows, err := a.market.GetCashFlows(ctx, a.state.Equity[len(a.state.Equity)-1].Time, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deposits of the real account: %w", err)
+	}
+	var total float64
+	for _, f := range flows {
+		total += f.Amount
+	}
+	return total, nil
+}
+
+// quote is the instrument and price a paper trade is filled at
+type quote struct {
+	figi, ticker, name string
+	price              float64
+	nominal            float64 // of a bond priced by the market
+}
+
+// quoteFor prices a recommended ticker from the real portfolio. Only buys may go to the
+// market for instruments the real portfolio does not hold; nil means it cannot be traded.
+func (a *Account) quoteFor(ctx context.Context, portfolio *invest.Portfolio, ticker string, buy bool) *quote {
+	if pos := portfolio.FindPosition(ticker); pos != nil {
+		if pos.CurrentPrice <= 0 {
+			return nil
+		}
+		return &quote{figi: pos.FIGI, ticker: pos.Ticker, name: pos.Name, price: pos.CurrentPrice}
+	}
+	if !buy || a.market == nil {
+		return nil
+	}
+
+	instr, err := a.market.FindInstrument(ctx, ticker)
+	if err != nil {
+		a.logger.WarnContext(ctx, "Could not find the instrument of a paper buy", "ticker", ticker, "error", err)
+		return nil
+	}
+	if instr.Type == "bond" && instr.Nominal <= 0 {
+		a.logger.WarnContext(ctx, "Could not value the bond of a paper buy", "ticker", ticker)
+		return nil
+	}
+	prices, err := a.market.GetLastPrices(ctx, []string{instr.FIGI})
+	if err != nil || prices[instr.FIGI] <= 0 {
+		a.logger.WarnContext(ctx, "Could not price a paper buy", "ticker", ticker, "error", err)
+		return nil
+	}
+	// Bonds are quoted in percent of the nominal, the portfolio values them in rubles
+	q := &quote{figi: instr.FIGI, ticker: instr.Ticker, name: instr.Name, price: instr.QuoteValue(prices[instr.FIGI])}
+	if instr.Type == "bond" {
+		q.nominal = instr.Nominal
+	}
+	return q
+}
+
+// start opens the paper portfolio with the configured cash, or with the positions and cash of the real one
+func (a *Account) start(portfolio *invest.Portfolio, now time.Time) {
+	a.state.Started = now
+	if a.rules.InitialCash > 0 {
+		a.state.Cash = a.rules.InitialCash
+		return
+	}
+	for _, pos := range portfolio.Positions {
+		if pos.InstrumentType == "currency" {
+			a.state.Cash += pos.Value()
+			continue
+		}
+		a.state.Holdings[pos.FIGI] = &Holding{
+			FIGI:         pos.FIGI,
+			Ticker:       pos.Ticker,
+			Name:         pos.Name,
+			Quantity:     pos.Quantity,
+			AveragePrice: pos.AveragePrice,
+			LastPrice:    pos.CurrentPrice,
+		}
+	}
+}
+
+// markToMarket updates the prices of the holdings from the real portfolio,
+// and asks the price source for those the real portfolio does not hold
+func (a *Account) markToMarket(ctx context.Context, portfolio *invest.Portfolio) {
+	var missing []string
+	for figi, h := range a.state.Holdings {
+		if price := currentPrice(portfolio, figi); price > 0 {
+			h.LastPrice = price
+		} else {
+			missing = append(missing, figi)
+		}
+	}
+	if len(missing) == 0 || a.market == nil {
+		return
+	}
+
+	prices, err := a.market.GetLastPrices(ctx, missing)
+	if err != nil {
+		// Stale prices only make the equity less precise
+		a.logger.WarnContext(ctx, "Could not price paper holdings, keeping the last prices", "error", err)
+		return
+	}
+	for figi, price := range prices {
+		if h, ok := a.state.Holdings[figi]; ok {
+			if h.Nominal > 0 {
+				price = price * h.Nominal / 100
+			}
+			h.LastPrice = price
+		}
+	}
+}
+
+// currentPrice returns the price of an instrument in the real portfolio, 0 if it is not held
+func currentPrice(portfolio *invest.Portfolio, figi string) float64 {
+	for _, pos := range portfolio.Positions {
+		if pos.FIGI == figi {
+			return pos.CurrentPrice
+		}
+	}
+	return 0
+}
+
+// tradedToday reports whether an instrument was already traded on the day of now
+func (a *Account) tradedToday(figi string, now time.Time) bool {
+	for i := len(a.state.Trades) - 1; i >= 0; i-- {
+		t := a.state.Trades[i]
+		if !sameDay(t.Time, now) {
+			return false
+		}
+		if t.FIGI == figi {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDay reports whether two times fall on the same calendar day in the zone of b
+func sameDay(a, b time.Time) bool {
+	a = a.In(b.Location())
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// buy spends the configured share of the equity on an instrument, without growing its
+// holding beyond the maximum share and without going below zero cash
+func (a *Account) buy(q *quote, now time.Time) *Trade {
+	equity := a.state.equity()
+	amount := equity * a.rules.BuyShare
+
+	var held float64
+	if h, ok := a.state.Holdings[q.figi]; ok {
+		held = h.Value()
+	}
+	amount = math.Min(amount, equity*a.rules.MaxPositionShare-held)
+	amount = math.Min(amount, a.state.Cash/(1+a.rules.CommissionRate))
+
+	quantity := math.Floor(amount / q.price)
+	if quantity < 1 {
+		return nil
+	}
+	cost := quantity * q.price
+	commission := cost * a.rules.CommissionRate
+
+	h, ok := a.state.Holdings[q.figi]
+	if !ok {
+		h = &Holding{FIGI: q.figi, Ticker: q.ticker, Name: q.name, Nominal: q.nominal}
+		a.state.Holdings[q.figi] = h
+	}
+	h.AveragePrice = (h.AveragePrice*h.Quantity + cost) / (h.Quantity + quantity)
+	h.Quantity += quantity
+	h.LastPrice = q.price
+	a.state.Cash -= cost + commission
+
+	return &Trade{Time: now, Ticker: q.ticker, FIGI: q.figi, Action: invest.OrderBuy,
+		Quantity: quantity, Price: q.price, Commission: commission}
+}
+
+// sell sells the configured share of a holding, at least one unit
+func (a *Account) sell(q *quote, now time.Time) *Trade {
+	h, ok := a.state.Holdings[q.figi]
+	if !ok || h.Quantity < 1 {
+		return nil
+	}
+	quantity := math.Max(math.Floor(h.Quantity*a.rules.SellShare), 1)
+	if a.rules.SellShare >= 1 {
+		quantity = h.Quantity
+	}
+	proceeds := quantity * q.price
+	commission := proceeds * a.rules.CommissionRate
+
+	h.Quantity -= quantity
+	if h.Quantity <= 0 {
+		delete(a.state.Holdings, q.figi)
+	}
+	a.state.Cash += proceeds - commission
+
+	return &Trade{Time: now, Ticker: q.ticker, FIGI: q.figi, Action: invest.OrderSell,
+		Quantity: quantity, Price: q.price, Commission: commission}
+}
+
+// Comparison returns the paper portfolio against the real one as of the latest run,
+// or nil before the first run or while disabled
+func (a *Account) Comparison() *Comparison {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.rules.Enabled || len(a.state.Equity) == 0 {
+		return nil
+	}
+	first, last := a.state.Equity[0], a.state.Equity[len(a.state.Equity)-1]
+	c := &Comparison{
+		Since:       a.state.Started,
+		Paper:       last.Paper,
+		Real:        last.Real,
+		PaperReturn: percentChange(first.Paper, last.Paper),
+		RealReturn:  timeWeightedReturn(a.state.Equity),
+	}
+	for _, t := range a.state.Trades {
+		if t.Time.Equal(last.Time) {
+			c.Trades = append(c.Trades, t)
+		}
+	}
+	return c
+}
+
+// timeWeightedReturn chains the returns of the real portfolio between runs in percent,
+// taking the deposits and withdrawals out of the value they arrived in
+func timeWeightedReturn(points []EquityPoint) float64 {
+	growth := 1.0
+	for i := 1; i < len(points); i++ {
+		if prev := points[i-1].Real; prev > 0 {
+			growth *= (points[i].Real - points[i].Flow) / prev
+		}
+	}
+	return (growth - 1) * 100
+}
+
+// percentChange returns the change from a to b in percent
+func percentChange(a, b float64) float64 {
+	if a == 0 {
+		return 0
+	}
+	return (b/a - 1) * 100
+}
+
+// Snapshot returns the current holdings and the trades so far
+func (a *Account) Snapshot() Snapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := Snapshot{Cash: a.state.Cash, Trades: append([]Trade(nil), a.state.Trades...)}
+	for _, h := range a.state.Holdings {
+		s.Holdings = append(s.Holdings, *h)
+	}
+	sort.Slice(s.Holdings, func(i, j int) bool {
+		return s.Holdings[i].Value() > s.Holdings[j].Value()
+	})
+	return s
+}
+
+// Equity returns the equity curves of the paper and the real portfolio
+func (a *Account) Equity() []EquityPoint {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]EquityPoint(nil), a.state.Equity...)
+}
+
+// save writes the portfolio to its file.
+// The caller must hold the lock.
+func (a *Account) save() error {
+	if a.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(a.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode paper portfolio: %w", err)
+	}
+	if err := atomicfile.Write(a.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to save paper portfolio: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testRules = config.PaperConfig{
+	Enabled:          true,
+	BuyShare:         0.1,
+	SellShare:        1,
+	MaxPositionShare: 0.3,
+	CommissionRate:   0.001,
+}
+
+// realPortfolio holds 10 000 RUB of SBER, 5 000 of GAZP and 5 000 in cash
+func realPortfolio(sber, gazp float64) *invest.Portfolio {
+	p := &invest.Portfolio{Currency: "RUB", Positions: []invest.Position{
+		{FIGI: "sber", Ticker: "SBER", InstrumentType: "share", Quantity: 40, AveragePrice: 250, CurrentPrice: sber},
+		{FIGI: "gazp", Ticker: "GAZP", InstrumentType: "share", Quantity: 50, AveragePrice: 100, CurrentPrice: gazp},
+		{FIGI: "rub", Ticker: "RUB000UTSTOM", InstrumentType: "currency", Quantity: 5000, CurrentPrice: 1},
+	}}
+	for _, pos := range p.Positions {
+		p.TotalAmount += pos.Value()
+	}
+	return p
+}
+
+func advice(actions ...string) *analysis.PortfolioAnalysis {
+	result := &analysis.PortfolioAnalysis{}
+	for i := 0; i < len(actions); i += 2 {
+		result.Recommendations = append(result.Recommendations, analysis.Recommendation{Ticker: actions[i], Action: actions[i+1]})
+	}
+	return result
+}
+
+func newAccount(t *testing.T, path string, rules config.PaperConfig, market Market) (*Account, *time.Time) {
+	t.Helper()
+	account, err := Open(path, rules, market, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 18, 7, 0, 0, 0, time.UTC)
+	account.now = func() time.Time { return now }
+	return account, &now
+}
+
+func TestFollowCopiesRealPortfolioAndTrades(t *testing.T) {
+	rules := testRules
+	rules.MaxPositionShare = 0.6
+	account, _ := newAccount(t, "", rules, nil)
+	ctx := context.Background()
+
+	// 10% of 20 000 is 2 000: 8 SBER at 250; GAZP is sold completely
+	if err := account.Follow(ctx, realPortfolio(250, 100), advice("SBER", "BUY", "GAZP", "SELL", "LKOH", "BUY")); err != nil {
+		t.Fatal(err)
+	}
+	s := account.Snapshot()
+	if len(s.Holdings) != 1 || s.Holdings[0].Ticker != "SBER" || s.Holdings[0].Quantity != 48 {
+		t.Fatalf("holdings = %+v, want 48 SBER", s.Holdings)
+	}
+	wantCash := 5000 - 2000*1.001 + 5000*0.999
+	if math.Abs(s.Cash-wantCash) > 1e-6 {
+		t.Errorf("cash = %g, want %g", s.Cash, wantCash)
+	}
+	if len(s.Trades) != 2 {
+		t.Errorf("trades = %+v, want a buy and a sell; unknown tickers are skipped", s.Trades)
+	}
+
+	c := account.Comparison()
+	if c == nil || len(c.Trades) != 2 || c.Real != 20000 {
+		t.Fatalf("comparison = %+v", c)
+	}
+}
+
+func TestFollowSizingRules(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("buys stop at the maximum share", func(t *testing.T) {
+		// SBER already is half of the portfolio
+		account, _ := newAccount(t, "", testRules, nil)
+		account.Follow(ctx, realPortfolio(250, 100), advice("SBER", "BUY"))
+		if trades := account.Snapshot().Trades; len(trades) != 0 {
+			t.Errorf("trades = %+v, want none", trades)
+		}
+	})
+
+	t.Run("buys are limited by cash", func(t *testing.T) {
+		rules := testRules
+		rules.InitialCash = 1000
+		rules.BuyShare = 1
+		rules.MaxPositionShare = 1
+		account, _ := newAccount(t, "", rules, nil)
+		account.Follow(ctx, realPortfolio(300, 100), advice("SBER", "BUY"))
+		s := account.Snapshot()
+		if len(s.Trades) != 1 || s.Trades[0].Quantity != 3 || s.Cash < 0 {
+			t.Errorf("snapshot = %+v, want 3 SBER bought within 1000 RUB", s)
+		}
+	})
+
+	t.Run("partial sells keep the rest", func(t *testing.T) {
+		rules := testRules
+		rules.SellShare = 0.5
+		account, _ := newAccount(t, "", rules, nil)
+		account.Follow(ctx, realPortfolio(250, 100), advice("GAZP", "SELL"))
+		if h := account.Snapshot().Holdings; len(h) != 2 || h[1].Quantity != 25 {
+			t.Errorf("holdings = %+v, want 25 GAZP left", h)
+		}
+	})
+
+	t.Run("nothing to sell", func(t *testing.T) {
+		rules := testRules
+		rules.InitialCash = 1000
+		account, _ := newAccount(t, "", rules, nil)
+		account.Follow(ctx, realPortfolio(250, 100), advice("GAZP", "SELL"))
+		if trades := account.Snapshot().Trades; len(trades) != 0 {
+			t.Errorf("trades = %+v, want none", trades)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		account, _ := newAccount(t, "", config.PaperConfig{}, nil)
+		account.Follow(ctx, realPortfolio(250, 100), advice("GAZP", "SELL"))
+		if account.Comparison() != nil || len(account.Equity()) != 0 {
+			t.Error("a disabled paper portfolio must not follow")
+		}
+	})
+}
+
+func TestFollowTradesOncePerDay(t *testing.T) {
+	rules := testRules
+	rules.InitialCash = 100000
+	account, now := newAccount(t, "", rules, nil)
+	ctx := context.Background()
+
+	account.Follow(ctx, realPortfolio(100, 100), advice("SBER", "BUY"))
+	*now = now.Add(time.Hour)
+	account.Follow(ctx, realPortfolio(100, 100), advice("SBER", "BUY"))
+	if trades := account.Snapshot().Trades; len(trades) != 1 {
+		t.Fatalf("trades = %d, want 1 on the same day", len(trades))
+	}
+	if c := account.Comparison(); len(c.Trades) != 0 {
+		t.Errorf("the second run made trades: %+v", c.Trades)
+	}
+
+	*now = now.AddDate(0, 0, 1)
+	account.Follow(ctx, realPortfolio(100, 100), advice("SBER", "BUY"))
+	if trades := account.Snapshot().Trades; len(trades) != 2 {
+		t.Errorf("trades = %d, want 2 on the next day", len(trades))
+	}
+}
+
+func TestFollowBuysInstrumentsNotHeld(t *testing.T) {
+	market := &fake.Broker{
+		Instruments: map[string]*invest.Instrument{
+			"lkoh": {FIGI: "lkoh", Ticker: "LKOH", Name: "Лукойл", Type: "share"},
+			"ofz":  {FIGI: "ofz", Ticker: "SU26238RMFS4", Type: "bond", Nominal: 1000},
+		},
+		LastPrices: map[string]float64{"lkoh": 500, "ofz": 60},
+	}
+	rules := testRules
+	rules.InitialCash = 20000
+	account, now := newAccount(t, "", rules, market)
+	ctx := context.Background()
+
+	// 10% of 20 000: 4 LKOH at 500 and 3 bonds at 60% of 1 000; GAZP is not held, so it cannot be sold
+	err := account.Follow(ctx, realPortfolio(250, 100), advice("LKOH", "BUY", "SU26238RMFS4", "BUY", "YNDX", "BUY", "GAZP", "SELL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := account.Snapshot()
+	if len(s.Trades) != 2 || s.Trades[0].Quantity != 4 || s.Trades[1].Quantity != 3 || s.Trades[1].Price != 600 {
+		t.Fatalf("trades = %+v, want 4 LKOH and 3 bonds at 600", s.Trades)
+	}
+
+	// Bonds the real portfolio does not hold keep their value in rubles
+	market.LastPrices["ofz"] = 70
+	*now = now.AddDate(0, 0, 1)
+	if err := account.Follow(ctx, realPortfolio(250, 100), advice()); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range account.Snapshot().Holdings {
+		if h.FIGI == "ofz" && h.LastPrice != 700 {
+			t.Errorf("bond price = %g, want 700", h.LastPrice)
+		}
+	}
+}
+
+func TestRealReturnExcludesDeposits(t *testing.T) {
+	market := &fake.Broker{}
+	account, now := newAccount(t, "", testRules, market)
+	ctx := context.Background()
+
+	if err := account.Follow(ctx, realPortfolio(250, 100), advice()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 000 deposited, then the value grows by 10%
+	*now = now.AddDate(0, 0, 1)
+	market.CashFlows = []invest.CashFlow{{Time: now.Add(-time.Hour), Amount: 10000}}
+	real := realPortfolio(250, 100)
+	real.TotalAmount = 30000
+	if err := account.Follow(ctx, real, advice()); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.AddDate(0, 0, 1)
+	real.TotalAmount = 33000
+	if err := account.Follow(ctx, real, advice()); err != nil {
+		t.Fatal(err)
+	}
+	if c := account.Comparison(); math.Abs(c.RealReturn-10) > 1e-9 {
+		t.Errorf("real return = %g, want 10 without the deposit", c.RealReturn)
+	}
+
+	// A run that cannot see the deposits is left out, the next one catches up
+	market.Err = errors.New("unavailable")
+	*now = now.AddDate(0, 0, 1)
+	if err := account.Follow(ctx, real, advice()); err == nil || len(account.Equity()) != 3 {
+		t.Errorf("run without deposits: err %v, %d points; want an error and no new point", err, len(account.Equity()))
+	}
+}
+
+func TestPersistenceAndMarkToMarket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paper.json")
+	ctx := context.Background()
+
+	account, now := newAccount(t, path, testRules, nil)
+	// Keep GAZP on paper while the real account sells it
+	account.Follow(ctx, realPortfolio(250, 100), advice())
+
+	// GAZP is priced by the market once the real portfolio no longer has it
+	reopened, _ := newAccount(t, path, testRules, &fake.Broker{LastPrices: map[string]float64{"gazp": 120}})
+	reopened.now = func() time.Time { return now.AddDate(0, 0, 1) }
+	real := realPortfolio(300, 100)
+	real.Positions = real.Positions[:1]
+	real.TotalAmount = 12000
+	if err := reopened.Follow(ctx, real, advice()); err != nil {
+		t.Fatal(err)
+	}
+
+	c := reopened.Comparison()
+	// Paper: 40 SBER at 300 + 50 GAZP at 120 + 5 000 cash
+	if c == nil || c.Paper != 23000 || math.Abs(c.PaperReturn-15) > 1e-9 || math.Abs(c.RealReturn+40) > 1e-9 {
+		t.Errorf("comparison = %+v, want paper 23000 (+15%%) and real -40%%", c)
+	}
+	if !c.Since.Equal(*now) || len(reopened.Equity()) != 2 {
+		t.Errorf("history was not kept: since %v, %d points", c.Since, len(reopened.Equity()))
+	}
+}
@@ -0,0 +1,318 @@
+// Package performance records the daily value of the account and reports how it
+// performed with deposits and withdrawals taken into account: the money-weighted
+// return (XIRR) and the time-weighted return (TWR), next to market indices.
+package performance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invest-manager/internal/atomicfile"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/refresh"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoHistory is returned before the first value of the account is recorded
+var ErrNoHistory = errors.New("no portfolio values recorded yet")
+
+// Periods of a report
+const (
+	PeriodMonth = "month" // since the start of the month
+	PeriodYear  = "year"  // since the start of the year
+	Period12M   = "12m"   // the last twelve months
+	PeriodAll   = "all"   // since the first recorded value, or the first deposit for XIRR
+)
+
+// Data ranges
+const (
+	historyStart      = 2015                // operations are searched from the start of this year, before the broker opened accounts
+	benchmarkLookback = 10 * 24 * time.Hour // index candles before a period start, to cover holidays
+)
+
+// Broker supplies the deposits, withdrawals and index prices; *invest.Client is the production one
+type Broker interface {
+	AccountID() string
+	GetCashFlows(ctx context.Context, from, to time.Time) ([]invest.CashFlow, error)
+	FindIndex(ctx context.Context, ticker string) (*invest.Instrument, error)
+	GetDailyCandles(ctx context.Context, figi string, from, to time.Time) ([]invest.Candle, error)
+}
+
+// Snapshot is the value of the account at a moment
+type Snapshot struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Benchmark is the change of an index over the days the TWR covers
+type Benchmark struct {
+	Ticker string  `json:"ticker"`
+	Return float64 `json:"return"` // percent
+}
+
+// Return is the performance of the account over a period
+type Return struct {
+	Period     string      `json:"period"`
+	Since      time.Time   `json:"since"` // start of the TWR, later than the period start if the history is shorter
+	Start      float64     `json:"start"` // account value at Since
+	End        float64     `json:"end"`
+	NetFlows   float64     `json:"net_flows"` // deposits less withdrawals
+	TWR        float64     `json:"twr"`       // percent over the period
+	MWR        float64     `json:"mwr"`       // money-weighted, percent over the period
+	XIRR       float64     `json:"xirr"`      // money-weighted, percent a year
+	HasTWR     bool        `json:"has_twr"`
+	HasMWR     bool        `json:"has_mwr"`
+	Benchmarks []Benchmark `json:"benchmarks"`
+}
+
+// Report holds the returns of the account over every period
+type Report struct {
+	Time    time.Time `json:"time"`
+	Returns []Return  `json:"returns"`
+}
+
+// Tracker records the daily values of each account and computes its returns. It is safe for concurrent use.
+type Tracker struct {
+	path   string
+	broker Broker
+	logger *slog.Logger
+
+	latest refresh.Latest[config.PerformanceConfig, Report]
+
+	mu      sync.Mutex
+	history map[string][]Snapshot // by account ID, oldest first
+	indices map[string]string     // index UIDs by ticker
+}
+
+// Open reads the recorded values from their file, which is created by the first record.
+// With an empty path the values are only kept in memory.
+func Open(path string, settings config.PerformanceConfig, broker Broker, logger *slog.Logger) (*Tracker, error) {
+	t := &Tracker{
+		path:    path,
+		broker:  broker,
+		logger:  logger,
+		history: make(map[string][]Snapshot),
+		indices: make(map[string]string),
+	}
+	t.latest.Reload(settings)
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read portfolio values: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.history); err != nil {
+		return nil, fmt.Errorf("failed to parse portfolio values %s: %w", path, err)
+	}
+	if t.history == nil {
+		t.history = make(map[string][]Snapshot)
+	}
+	return t, nil
+}
+
+// Reload applies the benchmarks of a new configuration from the next report on
+func (t *Tracker) Reload(settings config.PerformanceConfig) {
+	t.latest.Reload(settings)
+}
+
+// Record keeps the value of the selected account. There is one value a day in the time zone
+// of now; a later record of the same day replaces the earlier one.
+func (t *Tracker) Record(portfolio *invest.Portfolio, now time.Time) error {
+	account := t.broker.AccountID()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := Snapshot{Time: now, Value: portfolio.TotalAmount}
+	previous := t.history[account]
+	snapshots := append([]Snapshot(nil), previous...)
+	if n := len(snapshots); n > 0 && sameDay(snapshots[n-1].Time, now) {
+		snapshots[n-1] = snapshot
+	} else {
+		snapshots = append(snapshots, snapshot)
+	}
+
+	t.history[account] = snapshots
+	if err := t.save(); err != nil {
+		t.history[account] = previous
+		return err
+	}
+	return nil
+}
+
+// Refresh computes the returns of the selected account up to its latest recorded value.
+// Indices that cannot be priced are left out.
+func (t *Tracker) Refresh(ctx context.Context, now time.Time) (*Report, error) {
+	return t.latest.Refresh(func(settings config.PerformanceConfig) (*Report, error) {
+		return t.compute(ctx, settings.Benchmarks, now)
+	})
+}
+
+// Latest returns the returns of the last refresh for the monthly report, nil if it failed
+func (t *Tracker) Latest() *Report {
+	return t.latest.Get()
+}
+
+// compute builds the report from a copy of the recorded values, without holding the lock over broker calls
+func (t *Tracker) compute(ctx context.Context, benchmarks []string, now time.Time) (*Report, error) {
+	account := t.broker.AccountID()
+	t.mu.Lock()
+	snapshots := append([]Snapshot(nil), t.history[account]...)
+	t.mu.Unlock()
+
+	if len(snapshots) == 0 {
+		return nil, ErrNoHistory
+	}
+	end := snapshots[len(snapshots)-1]
+	flows, err := t.broker.GetCashFlows(ctx, time.Date(historyStart, 1, 1, 0, 0, 0, 0, time.UTC), end.Time)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deposits and withdrawals: %w", err)
+	}
+
+	starts := []struct {
+		period string
+		from   time.Time
+	}{
+		{PeriodMonth, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())},
+		{PeriodYear, time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())},
+		{Period12M, now.AddDate(-1, 0, 0)},
+		{PeriodAll, time.Time{}},
+	}
+	report := &Report{Time: now}
+	for _, s := range starts {
+		report.Returns = append(report.Returns, periodReturn(s.period, snapshots, flows, s.from))
+	}
+
+	for _, ticker := range benchmarks {
+		candles, err := t.indexCandles(ctx, ticker, snapshots[0].Time, end.Time)
+		if err != nil {
+			t.logger.WarnContext(ctx, "Failed to get benchmark prices", "index", ticker, "error", err)
+			continue
+		}
+		for i := range report.Returns {
+			r := &report.Returns[i]
+			if !r.HasTWR {
+				continue
+			}
+			if change, ok := indexChange(candles, r.Since); ok {
+				r.Benchmarks = append(r.Benchmarks, Benchmark{Ticker: strings.ToUpper(ticker), Return: change * 100})
+			}
+		}
+	}
+	return report, nil
+}
+
+// periodReturn computes the returns since from up to the last snapshot. The period starts
+// at the last snapshot on or before from, or at the first one if the history is shorter.
+func periodReturn(period string, snapshots []Snapshot, flows []invest.CashFlow, from time.Time) Return {
+	first := 0
+	for i, s := range snapshots {
+		if s.Time.After(from) {
+			break
+		}
+		first = i
+	}
+	start, end := snapshots[first], snapshots[len(snapshots)-1]
+	r := Return{Period: period, Since: start.Time, Start: start.Value, End: end.Value}
+
+	if growth, ok := twr(snapshots[first:], flows); ok {
+		r.TWR, r.HasTWR = growth*100, true
+	}
+
+	// The all-time XIRR starts from nothing at the first deposit, so it is not limited by the history
+	investorFlows := []flow{{time: start.Time, amount: -start.Value}}
+	window := flows
+	if period == PeriodAll && len(flows) > 0 && flows[0].Time.Before(start.Time) {
+		investorFlows = nil
+	} else {
+		window = nil
+		for _, f := range flows {
+			if f.Time.After(start.Time) && !f.Time.After(end.Time) {
+				window = append(window, f)
+			}
+		}
+	}
+	for _, f := range window {
+		investorFlows = append(investorFlows, flow{time: f.Time, amount: -f.Amount})
+		r.NetFlows += f.Amount
+	}
+	investorFlows = append(investorFlows, flow{time: end.Time, amount: end.Value})
+
+	if span := end.Time.Sub(investorFlows[0].time); span >= 24*time.Hour {
+		if rate, ok := xirr(investorFlows); ok {
+			r.XIRR, r.MWR, r.HasMWR = rate*100, periodRate(rate, span)*100, true
+		}
+	}
+	return r
+}
+
+// indexCandles returns the daily candles of an index covering the interval
+func (t *Tracker) indexCandles(ctx context.Context, ticker string, from, to time.Time) ([]invest.Candle, error) {
+	t.mu.Lock()
+	uid, ok := t.indices[strings.ToUpper(ticker)]
+	t.mu.Unlock()
+	if !ok {
+		index, err := t.broker.FindIndex(ctx, ticker)
+		if err != nil {
+			return nil, err
+		}
+		uid = index.UID
+		t.mu.Lock()
+		t.indices[strings.ToUpper(ticker)] = uid
+		t.mu.Unlock()
+	}
+	return t.broker.GetDailyCandles(ctx, uid, from.Add(-benchmarkLookback), to)
+}
+
+// indexChange returns the change of the close from the last candle on or before since to the latest one
+func indexChange(candles []invest.Candle, since time.Time) (float64, bool) {
+	if len(candles) == 0 {
+		return 0, false
+	}
+	base := candles[0]
+	for _, c := range candles {
+		if c.Time.After(since) {
+			break
+		}
+		base = c
+	}
+	last := candles[len(candles)-1]
+	if base.Close <= 0 {
+		return 0, false
+	}
+	return last.Close/base.Close - 1, true
+}
+
+// sameDay reports whether two moments fall on the same date in the time zone of b
+func sameDay(a, b time.Time) bool {
+	a = a.In(b.Location())
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// save writes the values to their file.
+// The caller must hold the lock.
+func (t *Tracker) save() error {
+	if t.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(t.history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode portfolio values: %w", err)
+	}
+	if err := atomicfile.Write(t.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to save portfolio values: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package performance
+
+import (
+	"context"
+	"errors"
+	"invest-manager/internal/config"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/testutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The fake must stay usable in place of the production broker
+var (
+	_ Broker = (*fake.Broker)(nil)
+	_ Broker = (*invest.Client)(nil)
+)
+
+func TestXIRR(t *testing.T) {
+	start := testutil.Date(2026, time.January, 1, 7)
+	tests := []struct {
+		name  string
+		flows []flow
+		want  float64
+		ok    bool
+	}{
+		{"a year at 10%", []flow{{start, -1000}, {start.AddDate(0, 0, 365), 1100}}, 0.10, true},
+		{"a loss", []flow{{start, -1000}, {start.AddDate(0, 0, 365), 800}}, -0.20, true},
+		{"no investment", []flow{{start, 100}, {start.AddDate(0, 0, 365), 100}}, 0, false},
+		{"a single flow", []flow{{start, -1000}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := xirr(tt.flows)
+			if ok != tt.ok || math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("xirr = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	// A deposit halfway: the rate is the one that discounts every flow to zero
+	flows := []flow{{start, -1000}, {start.AddDate(0, 0, 180), -1000}, {start.AddDate(0, 0, 365), 2300}}
+	rate, ok := xirr(flows)
+	if !ok {
+		t.Fatal("no rate for a deposit halfway")
+	}
+	var npv float64
+	for _, f := range flows {
+		npv += f.amount / math.Pow(1+rate, f.time.Sub(start).Hours()/24/daysPerYear)
+	}
+	if math.Abs(npv) > 1e-6 || rate < 0.15 || rate > 0.25 {
+		t.Errorf("rate %v leaves a net present value of %v", rate, npv)
+	}
+}
+
+func TestTWRTakesOutFlows(t *testing.T) {
+	snapshots := []Snapshot{
+		{testutil.Date(2026, time.January, 1, 7), 100},
+		{testutil.Date(2026, time.January, 2, 7), 110},
+		{testutil.Date(2026, time.January, 3, 7), 231},
+	}
+	flows := []invest.CashFlow{{Time: testutil.Date(2026, time.January, 2, 7).Add(time.Hour), Amount: 100}}
+	got, ok := twr(snapshots, flows)
+	// 110 / 100 and 131 / 110: the deposit of 100 is not growth
+	if want := 1.1*131/110 - 1; !ok || math.Abs(got-want) > 1e-12 {
+		t.Errorf("twr = %v, %v, want %v", got, ok, want)
+	}
+	if _, ok := twr(snapshots[:1], flows); ok {
+		t.Error("twr of a single snapshot reported")
+	}
+}
+
+// testTracker has four values recorded since the end of 2025, a deposit before the first
+// of them and one in March, and IMOEX prices
+func testTracker(t *testing.T) (*Tracker, *fake.Broker) {
+	t.Helper()
+	broker := &fake.Broker{
+		CashFlows: []invest.CashFlow{
+			{Time: testutil.Date(2025, time.December, 1, 7), Amount: 1000},
+			{Time: testutil.Date(2026, time.March, 5, 7), Amount: 100},
+		},
+		Instruments: map[string]*invest.Instrument{
+			"imoex-uid": {UID: "imoex-uid", Ticker: "IMOEX", Type: "index"},
+		},
+		Candles: map[string][]invest.Candle{
+			"imoex-uid": {
+				{Time: testutil.Date(2025, time.December, 30, 7), Close: 3000},
+				{Time: testutil.Date(2026, time.February, 27, 7), Close: 3100},
+				{Time: testutil.Date(2026, time.March, 14, 7), Close: 3300},
+			},
+		},
+	}
+	tracker, err := Open("", config.PerformanceConfig{Benchmarks: []string{"IMOEX", "MCFTRR"}}, broker, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []Snapshot{
+		{testutil.Date(2025, time.December, 31, 7), 1000},
+		{testutil.Date(2026, time.February, 28, 7), 1100},
+		{testutil.Date(2026, time.March, 10, 7), 1300},
+		{testutil.Date(2026, time.March, 15, 7), 1400},
+	} {
+		if err := tracker.Record(&invest.Portfolio{TotalAmount: s.Value}, s.Time); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tracker, broker
+}
+
+func TestRefresh(t *testing.T) {
+	tracker, _ := testTracker(t)
+	report, err := tracker.Refresh(context.Background(), testutil.Date(2026, time.March, 15, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Returns) != 4 || tracker.Latest() != report {
+		t.Fatalf("report = %+v", report)
+	}
+	month, year, twelve, all := report.Returns[0], report.Returns[1], report.Returns[2], report.Returns[3]
+
+	// The month starts at the value of February 28; the deposit of March 5 is not growth
+	if !month.Since.Equal(testutil.Date(2026, time.February, 28, 7)) || month.NetFlows != 100 {
+		t.Errorf("month since %v with flows %v", month.Since, month.NetFlows)
+	}
+	if want := (1200.0/1100*1400/1300 - 1) * 100; !month.HasTWR || !testutil.AlmostEqual(month.TWR, want) {
+		t.Errorf("month TWR = %v, want %v", month.TWR, want)
+	}
+	if len(month.Benchmarks) != 1 || !testutil.AlmostEqual(month.Benchmarks[0].Return, (3300.0/3100-1)*100) {
+		t.Errorf("month benchmarks = %+v, want IMOEX since February 27 and no MCFTRR, which is unknown", month.Benchmarks)
+	}
+	if !month.HasMWR || month.MWR <= 0 {
+		t.Errorf("month MWR = %v, %v, want a gain", month.MWR, month.HasMWR)
+	}
+
+	// The history is shorter than twelve months, so both start at the first value
+	if want := (1.1*1200/1100*1400/1300 - 1) * 100; !testutil.AlmostEqual(year.TWR, want) || twelve.Since != year.Since {
+		t.Errorf("year TWR = %v, want %v; 12m since %v", year.TWR, want, twelve.Since)
+	}
+	if len(year.Benchmarks) != 1 || !testutil.AlmostEqual(year.Benchmarks[0].Return, 10) {
+		t.Errorf("year benchmarks = %+v, want IMOEX +10%%", year.Benchmarks)
+	}
+
+	// The all-time XIRR starts at the first deposit, before the first recorded value
+	if all.NetFlows != 1100 || !all.HasMWR || all.MWR < 25 || all.MWR > 35 || all.XIRR <= all.MWR {
+		t.Errorf("all time = %+v, want 1100 paid in and about 30%% over 104 days", all)
+	}
+}
+
+func TestRefreshWithoutHistory(t *testing.T) {
+	empty, _ := Open("", config.PerformanceConfig{}, &fake.Broker{}, logging.Discard())
+	if _, err := empty.Refresh(context.Background(), testutil.Date(2026, time.March, 15, 7)); !errors.Is(err, ErrNoHistory) {
+		t.Errorf("refresh without history error = %v, want ErrNoHistory", err)
+	}
+}
+
+func TestRecordKeepsOneValueADayPerAccount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "performance.json")
+	broker := &fake.Broker{}
+	tracker, err := Open(path, config.PerformanceConfig{}, broker, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(value float64, at time.Time) {
+		t.Helper()
+		if err := tracker.Record(&invest.Portfolio{TotalAmount: value}, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	broker.SetAccount("broker")
+	record(100, testutil.Date(2026, time.March, 1, 7))
+	record(105, testutil.Date(2026, time.March, 1, 7).Add(8*time.Hour))
+	record(110, testutil.Date(2026, time.March, 2, 7))
+	broker.SetAccount("iis")
+	record(500, testutil.Date(2026, time.March, 2, 7))
+
+	reopened, err := Open(path, config.PerformanceConfig{}, broker, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.history["broker"]; len(got) != 2 || got[0].Value != 105 || got[1].Value != 110 {
+		t.Errorf("broker values = %+v, want the later value of March 1 and March 2", got)
+	}
+	if got := reopened.history["iis"]; len(got) != 1 || got[0].Value != 500 {
+		t.Errorf("iis values = %+v", got)
+	}
+}
@@ -0,0 +1,90 @@
+package performance
+
+import (
+	"invest-manager/internal/invest"
+	"math"
+	"time"
+)
+
+// Solver settings
+const (
+	daysPerYear   = 365.0
+	xirrTolerance = 1e-10
+	xirrMaxRate   = 1e6 // a million times a year is treated as no solution
+)
+
+// flow is a cash flow seen by the investor: negative when money goes into the account
+type flow struct {
+	time   time.Time
+	amount float64
+}
+
+// xirr finds the annual rate at which the flows have a zero net present value.
+// The first flow is the earliest. It reports false if the flows have no such rate.
+func xirr(flows []flow) (float64, bool) {
+	if len(flows) < 2 {
+		return 0, false
+	}
+	start := flows[0].time
+	npv := func(rate float64) float64 {
+		var sum float64
+		for _, f := range flows {
+			years := f.time.Sub(start).Hours() / 24 / daysPerYear
+			sum += f.amount / math.Pow(1+rate, years)
+		}
+		return sum
+	}
+
+	// The value falls as the rate grows when money is paid in before it is taken out,
+	// so bracket the root and bisect, which cannot diverge like Newton's method
+	lo, hi := -0.9999, 1.0
+	for npv(hi) > 0 && hi < xirrMaxRate {
+		hi *= 2
+	}
+	if npv(lo) < 0 || npv(hi) > 0 {
+		return 0, false
+	}
+	for hi-lo > xirrTolerance {
+		mid := (lo + hi) / 2
+		if npv(mid) > 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2, true
+}
+
+// twr chains the growth between consecutive snapshots, taking out the deposits and
+// withdrawals made in between. It reports false for fewer than two snapshots.
+func twr(snapshots []Snapshot, flows []invest.CashFlow) (float64, bool) {
+	if len(snapshots) < 2 {
+		return 0, false
+	}
+	growth := 1.0
+	for i := 1; i < len(snapshots); i++ {
+		prev, cur := snapshots[i-1], snapshots[i]
+		if prev.Value <= 0 {
+			// Nothing was invested yet, so the money paid in has not grown
+			continue
+		}
+		growth *= (cur.Value - netFlows(flows, prev.Time, cur.Time)) / prev.Value
+	}
+	return growth - 1, true
+}
+
+// netFlows sums the deposits less withdrawals made after from and up to to
+func netFlows(flows []invest.CashFlow, from, to time.Time) float64 {
+	var net float64
+	for _, f := range flows {
+		if f.Time.After(from) && !f.Time.After(to) {
+			net += f.Amount
+		}
+	}
+	return net
+}
+
+// periodRate converts an annual rate to the rate over the given time
+func periodRate(annual float64, d time.Duration) float64 {
+	return math.Pow(1+annual, d.Hours()/24/daysPerYear) - 1
+}
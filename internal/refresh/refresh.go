@@ -0,0 +1,46 @@
+// Package refresh keeps the result of a component that is computed from the broker on a
+// schedule and read by the bot and the reports in between.
+package refresh
+
+import "sync"
+
+// Latest holds the settings a component computes with and the result of its last refresh.
+// It is safe for concurrent use.
+type Latest[S, R any] struct {
+	mu       sync.Mutex
+	settings S
+	result   *R
+}
+
+// Reload applies new settings from the next refresh on
+func (l *Latest[S, R]) Reload(settings S) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.settings = settings
+}
+
+// Settings returns the settings of the next refresh
+func (l *Latest[S, R]) Settings() S {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.settings
+}
+
+// Refresh computes the result with the current settings, without holding the lock over the
+// computation, and keeps it for Get. A failed refresh clears the result, so an outdated one
+// is not reported as current.
+func (l *Latest[S, R]) Refresh(compute func(settings S) (*R, error)) (*R, error) {
+	result, err := compute(l.Settings())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.result = result
+	return result, err
+}
+
+// Get returns the result of the last refresh, nil if it failed or there was nothing to compute
+func (l *Latest[S, R]) Get() *R {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.result
+}
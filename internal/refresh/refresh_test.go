@@ -0,0 +1,25 @@
+package refresh
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRefresh(t *testing.T) {
+	var latest Latest[int, string]
+	latest.Reload(2)
+	result, err := latest.Refresh(func(settings int) (*string, error) {
+		s := "computed with 2"
+		if settings != 2 {
+			s = "computed with old settings"
+		}
+		return &s, nil
+	})
+	if err != nil || latest.Get() != result || *result != "computed with 2" {
+		t.Fatalf("refresh = %v, %v; latest = %v", result, err, latest.Get())
+	}
+
+	if _, err := latest.Refresh(func(int) (*string, error) { return nil, errors.New("unavailable") }); err == nil || latest.Get() != nil {
+		t.Errorf("refresh error = %v, latest = %v; want an error and no result", err, latest.Get())
+	}
+}
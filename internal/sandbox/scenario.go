@@ -0,0 +1,253 @@
+// Package sandbox is an in-process stand-in for the Tinkoff Invest gRPC API.
+// It serves accounts, positions, prices and operations from a scenario file,
+// so the broker client can be exercised without network access or a real account.
+package sandbox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is the broker state served by the sandbox
+type Scenario struct {
+	Token       string                `yaml:"token"` // token clients must present; any token is accepted if empty
+	Accounts    []Account             `yaml:"accounts"`
+	Instruments []Instrument          `yaml:"instruments"`
+	Candles     map[string][]Candle   `yaml:"candles"`   // daily candles by FIGI
+	Dividends   map[string][]Dividend `yaml:"dividends"` // dividends by FIGI
+}
+
+// Account is a brokerage account with its holdings and history
+type Account struct {
+	ID         string      `yaml:"id"`
+	Name       string      `yaml:"name"`
+	Type       string      `yaml:"type"` // broker, iis or invest_box
+	Positions  []Position  `yaml:"positions"`
+	Operations []Operation `yaml:"operations"`
+}
+
+// Position is a holding of an account
+type Position struct {
+	FIGI         string  `yaml:"figi"`
+	Quantity     float64 `yaml:"quantity"`
+	AveragePrice float64 `yaml:"average_price"`
+	CurrentPrice float64 `yaml:"current_price"` // defaults to the last close, or 1 for currencies
+}
+
+// Instrument describes a security
+type Instrument struct {
+	FIGI     string `yaml:"figi"`
+	Ticker   string `yaml:"ticker"`
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"` // share, bond, etf, currency or index
+	Sector   string `yaml:"sector"`
+	Country  string `yaml:"country"` // country of risk, e.g. RU
+	Currency string `yaml:"currency"`
+	Lot      int32  `yaml:"lot"`
+	Short    bool   `yaml:"short"` // can be sold short
+	Brand    string `yaml:"brand"` // brand of the asset, the issuer of shares and bonds
+}
+
+// Candle is a daily price bar; open, high and low default to the close
+type Candle struct {
+	Date   time.Time `yaml:"date"`
+	Open   float64   `yaml:"open"`
+	High   float64   `yaml:"high"`
+	Low    float64   `yaml:"low"`
+	Close  float64   `yaml:"close"`
+	Volume int64     `yaml:"volume"`
+}
+
+// Dividend is a dividend per share; the currency defaults to rub
+type Dividend struct {
+	RecordDate  time.Time `yaml:"record_date"`
+	PaymentDate time.Time `yaml:"payment_date"`
+	Amount      float64   `yaml:"amount"`
+	Currency    string    `yaml:"currency"`
+}
+
+// Operation is an executed operation of an account
+type Operation struct {
+	ID       string    `yaml:"id"`
+	Date     time.Time `yaml:"date"`
+	Type     string    `yaml:"type"` // see operationTypes
+	FIGI     string    `yaml:"figi"`
+	Quantity int64     `yaml:"quantity"`
+	Price    float64   `yaml:"price"`
+	Payment  float64   `yaml:"payment"` // signed cash flow; for trades defaults to -quantity*price for buys and the opposite for sells
+	Currency string    `yaml:"currency"`
+}
+
+// accountTypes lists the account types a scenario may use
+var accountTypes = []string{"broker", "iis", "invest_box"}
+
+// LoadScenario reads a scenario from a YAML file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	var scenario Scenario
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&scenario); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+
+	if err := scenario.normalize(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+// normalize checks references between sections and fills in defaults
+func (s *Scenario) normalize() error {
+	if len(s.Accounts) == 0 {
+		return errors.New("no accounts")
+	}
+
+	instruments := make(map[string]bool)
+	for i := range s.Instruments {
+		instr := &s.Instruments[i]
+		if instr.FIGI == "" || instr.Ticker == "" {
+			return fmt.Errorf("instrument %d: figi and ticker are required", i+1)
+		}
+		if instruments[instr.FIGI] {
+			return fmt.Errorf("instrument %s is listed twice", instr.FIGI)
+		}
+		instruments[instr.FIGI] = true
+		if instr.Type == "" {
+			instr.Type = "share"
+		}
+		if instr.Currency == "" {
+			instr.Currency = "rub"
+		}
+		if instr.Lot == 0 {
+			instr.Lot = 1
+		}
+	}
+
+	for figi, candles := range s.Candles {
+		if !instruments[figi] {
+			return fmt.Errorf("candles of unknown instrument %s", figi)
+		}
+		for i := range candles {
+			c := &candles[i]
+			if c.Open == 0 {
+				c.Open = c.Close
+			}
+			if c.High == 0 {
+				c.High = max(c.Open, c.Close)
+			}
+			if c.Low == 0 {
+				c.Low = min(c.Open, c.Close)
+			}
+		}
+		sort.Slice(candles, func(i, j int) bool { return candles[i].Date.Before(candles[j].Date) })
+	}
+
+	for figi, dividends := range s.Dividends {
+		if !instruments[figi] {
+			return fmt.Errorf("dividends of unknown instrument %s", figi)
+		}
+		for i := range dividends {
+			if dividends[i].Currency == "" {
+				dividends[i].Currency = "rub"
+			}
+		}
+	}
+
+	ids := make(map[string]bool)
+	for i := range s.Accounts {
+		acc := &s.Accounts[i]
+		if acc.ID == "" || ids[acc.ID] {
+			return fmt.Errorf("account %d: id is missing or not unique", i+1)
+		}
+		ids[acc.ID] = true
+		if acc.Type == "" {
+			acc.Type = "broker"
+		}
+		if !slices.Contains(accountTypes, acc.Type) {
+			return fmt.Errorf("account %s: unknown type %q", acc.ID, acc.Type)
+		}
+
+		for j := range acc.Positions {
+			pos := &acc.Positions[j]
+			if !instruments[pos.FIGI] {
+				return fmt.Errorf("account %s: position in unknown instrument %s", acc.ID, pos.FIGI)
+			}
+			if pos.CurrentPrice == 0 {
+				pos.CurrentPrice = s.lastClose(pos.FIGI)
+			}
+			if pos.AveragePrice == 0 {
+				pos.AveragePrice = pos.CurrentPrice
+			}
+		}
+
+		for j := range acc.Operations {
+			op := &acc.Operations[j]
+			if _, ok := operationTypes[op.Type]; !ok {
+				return fmt.Errorf("account %s: operation %d has unknown type %q", acc.ID, j+1, op.Type)
+			}
+			if op.FIGI != "" && !instruments[op.FIGI] {
+				return fmt.Errorf("account %s: operation %d in unknown instrument %s", acc.ID, j+1, op.FIGI)
+			}
+			if op.ID == "" {
+				op.ID = fmt.Sprintf("%s-%d", acc.ID, j+1)
+			}
+			if op.Currency == "" {
+				op.Currency = "rub"
+			}
+			if op.Payment == 0 {
+				switch op.Type {
+				case "buy":
+					op.Payment = -float64(op.Quantity) * op.Price
+				case "sell":
+					op.Payment = float64(op.Quantity) * op.Price
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// instrument finds an instrument by FIGI
+func (s *Scenario) instrument(figi string) *Instrument {
+	for i := range s.Instruments {
+		if s.Instruments[i].FIGI == figi {
+			return &s.Instruments[i]
+		}
+	}
+	return nil
+}
+
+// account finds an account by ID
+func (s *Scenario) account(id string) *Account {
+	for i := range s.Accounts {
+		if s.Accounts[i].ID == id {
+			return &s.Accounts[i]
+		}
+	}
+	return nil
+}
+
+// lastClose returns the last known price of an instrument; currencies are worth 1
+func (s *Scenario) lastClose(figi string) float64 {
+	candles := s.Candles[figi]
+	if len(candles) > 0 {
+		return candles[len(candles)-1].Close
+	}
+	if instr := s.instrument(figi); instr != nil && instr.Type == "currency" {
+		return 1
+	}
+	return 0
+}
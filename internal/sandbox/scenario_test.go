@@ -0,0 +1,63 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadScenario(t *testing.T) {
+	scenario, err := LoadScenario(filepath.Join("testdata", "scenario.yaml"))
+	if err != nil {
+		t.Fatalf("LoadScenario: %v", err)
+	}
+
+	acc := scenario.account("2000000001")
+	if acc == nil || len(acc.Positions) != 3 {
+		t.Fatalf("account 2000000001 = %+v", acc)
+	}
+	// Current prices default to the last close, and to 1 for cash
+	if got := acc.Positions[0].CurrentPrice; got != 310.4 {
+		t.Errorf("SBER current price = %v, want the last close 310.4", got)
+	}
+	if got := acc.Positions[2].CurrentPrice; got != 1 {
+		t.Errorf("RUB current price = %v, want 1", got)
+	}
+	// Trade payments are derived from quantity and price
+	if got := acc.Operations[1].Payment; got != -25000 {
+		t.Errorf("buy payment = %v, want -25000", got)
+	}
+	// Candles given as a close only get a flat bar
+	if c := scenario.Candles["BBG004730RP0"][0]; c.Open != c.Close || c.High != c.Close || c.Low != c.Close {
+		t.Errorf("candle = %+v, want open, high and low equal to the close", c)
+	}
+}
+
+func TestLoadScenarioErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"no accounts", "instruments: []", "no accounts"},
+		{"unknown key", "acounts: []", "field acounts not found"},
+		{"unknown instrument", "accounts: [{id: a, positions: [{figi: X, quantity: 1}]}]", "unknown instrument X"},
+		{"duplicate account", "accounts: [{id: a}, {id: a}]", "not unique"},
+		{"unknown account type", "accounts: [{id: a, type: margin}]", `unknown type "margin"`},
+		{"unknown operation", "accounts: [{id: a, operations: [{type: gift}]}]", `unknown type "gift"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scenario.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadScenario(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadScenario() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
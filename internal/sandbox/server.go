@@ -0,0 +1,155 @@
+package sandbox
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves a scenario over gRPC with TLS, like the real API endpoint.
+// The SDK always dials with TLS, so clients have to trust CertPEM, e.g. through SSL_CERT_FILE.
+type Server struct {
+	scenario *Scenario
+	logger   *slog.Logger
+	grpc     *grpc.Server
+	listener net.Listener
+	certPEM  []byte
+}
+
+// NewServer creates a server for the scenario with a fresh self-signed certificate
+// for localhost and 127.0.0.1
+func NewServer(scenario *Scenario, logger *slog.Logger) (*Server, error) {
+	cert, certPEM, err := selfSignedCert()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	s := &Server{scenario: scenario, logger: logger, certPEM: certPEM}
+	s.grpc = grpc.NewServer(
+		grpc.Creds(credentials.NewServerTLSFromCert(&cert)),
+		grpc.UnaryInterceptor(s.authorize),
+	)
+	pb.RegisterUsersServiceServer(s.grpc, &usersService{scenario: scenario})
+	pb.RegisterOperationsServiceServer(s.grpc, &operationsService{scenario: scenario})
+	pb.RegisterInstrumentsServiceServer(s.grpc, &instrumentsService{scenario: scenario})
+	pb.RegisterMarketDataServiceServer(s.grpc, &marketDataService{scenario: scenario})
+	return s, nil
+}
+
+// Start listens on the address and serves requests in the background; use port 0 for a free port
+func (s *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	s.listener = listener
+
+	go func() {
+		if err := s.grpc.Serve(listener); err != nil {
+			s.logger.Error("Sandbox server stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address to put into TINKOFF_ENDPOINT
+func (s *Server) Addr() string {
+	// The certificate is issued for localhost, so an unspecified host is reported as such
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return net.JoinHostPort("localhost", port)
+}
+
+// CertPEM returns the certificate clients have to trust
+func (s *Server) CertPEM() []byte {
+	return s.certPEM
+}
+
+// WriteCert saves the certificate, e.g. for SSL_CERT_FILE
+func (s *Server) WriteCert(path string) error {
+	if err := os.WriteFile(path, s.certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+	return nil
+}
+
+// Stop stops serving and closes open connections
+func (s *Server) Stop() {
+	s.grpc.Stop()
+}
+
+// authorize checks the bearer token of every request, as the real API does
+func (s *Server) authorize(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token = strings.TrimPrefix(values[0], "Bearer ")
+	}
+
+	if token == "" || (s.scenario.Token != "" && token != s.scenario.Token) {
+		s.logger.Warn("Rejected request: invalid token", "method", info.FullMethod)
+		return nil, status.Error(codes.Unauthenticated, "40003: authentication token is missing or invalid")
+	}
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		s.logger.Info("Request failed", "method", info.FullMethod, "error", err)
+	}
+	return resp, err
+}
+
+// selfSignedCert creates a short-lived certificate for the local host names
+func selfSignedCert() (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "invest-manager sandbox"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(0, 0, 30),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	return cert, certPEM, nil
+}
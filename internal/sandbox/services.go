@@ -0,0 +1,367 @@
+package sandbox
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	pb "github.com/russianinvestments/invest-api-go-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// operationTypes maps the operation types of a scenario to the API ones
+var operationTypes = map[string]pb.OperationType{
+	"input":        pb.OperationType_OPERATION_TYPE_INPUT,
+	"output":       pb.OperationType_OPERATION_TYPE_OUTPUT,
+	"buy":          pb.OperationType_OPERATION_TYPE_BUY,
+	"sell":         pb.OperationType_OPERATION_TYPE_SELL,
+	"dividend":     pb.OperationType_OPERATION_TYPE_DIVIDEND,
+	"dividend_tax": pb.OperationType_OPERATION_TYPE_DIVIDEND_TAX,
+	"coupon":       pb.OperationType_OPERATION_TYPE_COUPON,
+	"broker_fee":   pb.OperationType_OPERATION_TYPE_BROKER_FEE,
+	"tax":          pb.OperationType_OPERATION_TYPE_TAX,
+}
+
+// accountTypeValues maps the account types of a scenario to the API ones
+var accountTypeValues = map[string]pb.AccountType{
+	"broker":     pb.AccountType_ACCOUNT_TYPE_TINKOFF,
+	"iis":        pb.AccountType_ACCOUNT_TYPE_TINKOFF_IIS,
+	"invest_box": pb.AccountType_ACCOUNT_TYPE_INVEST_BOX,
+}
+
+// usersService serves the accounts
+type usersService struct {
+	pb.UnimplementedUsersServiceServer
+	scenario *Scenario
+}
+
+// GetAccounts lists the accounts; they are all open
+func (u *usersService) GetAccounts(ctx context.Context, req *pb.GetAccountsRequest) (*pb.GetAccountsResponse, error) {
+	resp := &pb.GetAccountsResponse{}
+	for _, acc := range u.scenario.Accounts {
+		resp.Accounts = append(resp.Accounts, &pb.Account{
+			Id:     acc.ID,
+			Name:   acc.Name,
+			Type:   accountTypeValues[acc.Type],
+			Status: pb.AccountStatus_ACCOUNT_STATUS_OPEN,
+		})
+	}
+	return resp, nil
+}
+
+// operationsService serves portfolios and operations
+type operationsService struct {
+	pb.UnimplementedOperationsServiceServer
+	scenario *Scenario
+}
+
+// GetPortfolio returns the positions of an account valued at their current prices
+func (o *operationsService) GetPortfolio(ctx context.Context, req *pb.PortfolioRequest) (*pb.PortfolioResponse, error) {
+	acc := o.scenario.account(req.GetAccountId())
+	if acc == nil {
+		return nil, status.Errorf(codes.NotFound, "50004: account %s not found", req.GetAccountId())
+	}
+
+	resp := &pb.PortfolioResponse{AccountId: acc.ID}
+	var total, yield float64
+	for _, pos := range acc.Positions {
+		instr := o.scenario.instrument(pos.FIGI)
+		positionYield := pos.Quantity * (pos.CurrentPrice - pos.AveragePrice)
+		resp.Positions = append(resp.Positions, &pb.PortfolioPosition{
+			Figi:                 pos.FIGI,
+			InstrumentType:       instr.Type,
+			Quantity:             quotation(pos.Quantity),
+			AveragePositionPrice: money(pos.AveragePrice, instr.Currency),
+			ExpectedYield:        quotation(positionYield),
+			CurrentPrice:         money(pos.CurrentPrice, instr.Currency),
+		})
+		total += pos.Quantity * pos.CurrentPrice
+		yield += positionYield
+	}
+	resp.TotalAmountPortfolio = money(total, "rub")
+	resp.ExpectedYield = quotation(yield)
+	return resp, nil
+}
+
+// GetOperations returns the operations of an account within the period, oldest first
+func (o *operationsService) GetOperations(ctx context.Context, req *pb.OperationsRequest) (*pb.OperationsResponse, error) {
+	acc := o.scenario.account(req.GetAccountId())
+	if acc == nil {
+		return nil, status.Errorf(codes.NotFound, "50004: account %s not found", req.GetAccountId())
+	}
+
+	from, to := timeRange(req.GetFrom(), req.GetTo())
+	resp := &pb.OperationsResponse{}
+	for _, op := range acc.Operations {
+		if op.Date.Before(from) || op.Date.After(to) {
+			continue
+		}
+		if figi := req.GetFigi(); figi != "" && op.FIGI != figi {
+			continue
+		}
+
+		operation := &pb.Operation{
+			Id:            op.ID,
+			Currency:      op.Currency,
+			Payment:       money(op.Payment, op.Currency),
+			Price:         money(op.Price, op.Currency),
+			State:         pb.OperationState_OPERATION_STATE_EXECUTED,
+			Quantity:      op.Quantity,
+			Figi:          op.FIGI,
+			Date:          timestamppb.New(op.Date),
+			Type:          op.Type,
+			OperationType: operationTypes[op.Type],
+		}
+		if instr := o.scenario.instrument(op.FIGI); instr != nil {
+			operation.InstrumentType = instr.Type
+		}
+		resp.Operations = append(resp.Operations, operation)
+	}
+	return resp, nil
+}
+
+// instrumentsService serves instrument details
+type instrumentsService struct {
+	pb.UnimplementedInstrumentsServiceServer
+	scenario *Scenario
+}
+
+// GetInstrumentBy finds an instrument by FIGI or ticker
+func (i *instrumentsService) GetInstrumentBy(ctx context.Context, req *pb.InstrumentRequest) (*pb.InstrumentResponse, error) {
+	for _, instr := range i.scenario.Instruments {
+		var match bool
+		switch req.GetIdType() {
+		case pb.InstrumentIdType_INSTRUMENT_ID_TYPE_FIGI, pb.InstrumentIdType_INSTRUMENT_ID_TYPE_UID:
+			match = instr.FIGI == req.GetId()
+		case pb.InstrumentIdType_INSTRUMENT_ID_TYPE_TICKER:
+			match = strings.EqualFold(instr.Ticker, req.GetId())
+		}
+		if match {
+			return &pb.InstrumentResponse{Instrument: &pb.Instrument{
+				Figi:                  instr.FIGI,
+				Uid:                   instr.FIGI,
+				AssetUid:              instr.FIGI,
+				Ticker:                instr.Ticker,
+				Name:                  instr.Name,
+				InstrumentType:        instr.Type,
+				Sector:                instr.Sector,
+				CountryOfRisk:         instr.Country,
+				Currency:              instr.Currency,
+				Lot:                   instr.Lot,
+				ApiTradeAvailableFlag: true,
+				BuyAvailableFlag:      true,
+				SellAvailableFlag:     true,
+				ShortEnabledFlag:      instr.Short,
+			}}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "50002: instrument %s not found", req.GetId())
+}
+
+// GetAssetBy returns the asset of an instrument, whose UID is the FIGI here, with its brand
+func (i *instrumentsService) GetAssetBy(ctx context.Context, req *pb.AssetRequest) (*pb.AssetResponse, error) {
+	for _, instr := range i.scenario.Instruments {
+		if instr.FIGI != req.GetId() {
+			continue
+		}
+		asset := &pb.AssetFull{Uid: instr.FIGI, Name: instr.Name}
+		if instr.Brand != "" {
+			asset.Brand = &pb.Brand{Uid: strings.ToLower(instr.Brand), Name: instr.Brand}
+		}
+		return &pb.AssetResponse{Asset: asset}, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "50013: asset %s not found", req.GetId())
+}
+
+// FindInstrument searches instruments by ticker or name
+func (i *instrumentsService) FindInstrument(ctx context.Context, req *pb.FindInstrumentRequest) (*pb.FindInstrumentResponse, error) {
+	query := strings.ToLower(req.GetQuery())
+	resp := &pb.FindInstrumentResponse{}
+	for _, instr := range i.scenario.Instruments {
+		if !strings.Contains(strings.ToLower(instr.Ticker), query) && !strings.Contains(strings.ToLower(instr.Name), query) {
+			continue
+		}
+		resp.Instruments = append(resp.Instruments, &pb.InstrumentShort{
+			Figi:                  instr.FIGI,
+			Uid:                   instr.FIGI,
+			Ticker:                instr.Ticker,
+			Name:                  instr.Name,
+			InstrumentType:        instr.Type,
+			ClassCode:             "TQBR",
+			ApiTradeAvailableFlag: true,
+		})
+	}
+	return resp, nil
+}
+
+// Shares lists the shares of the scenario on the main board
+func (i *instrumentsService) Shares(ctx context.Context, req *pb.InstrumentsRequest) (*pb.SharesResponse, error) {
+	resp := &pb.SharesResponse{}
+	for _, instr := range i.scenario.Instruments {
+		if instr.Type != "share" {
+			continue
+		}
+		resp.Instruments = append(resp.Instruments, &pb.Share{
+			Figi:                  instr.FIGI,
+			Uid:                   instr.FIGI,
+			Ticker:                instr.Ticker,
+			Name:                  instr.Name,
+			Sector:                instr.Sector,
+			CountryOfRisk:         instr.Country,
+			Currency:              instr.Currency,
+			ClassCode:             "TQBR",
+			Lot:                   instr.Lot,
+			ApiTradeAvailableFlag: true,
+			BuyAvailableFlag:      true,
+			SellAvailableFlag:     true,
+			ShortEnabledFlag:      instr.Short,
+		})
+	}
+	return resp, nil
+}
+
+// Etfs lists the ETFs of the scenario on the main board
+func (i *instrumentsService) Etfs(ctx context.Context, req *pb.InstrumentsRequest) (*pb.EtfsResponse, error) {
+	resp := &pb.EtfsResponse{}
+	for _, instr := range i.scenario.Instruments {
+		if instr.Type != "etf" {
+			continue
+		}
+		resp.Instruments = append(resp.Instruments, &pb.Etf{
+			Figi:                  instr.FIGI,
+			Uid:                   instr.FIGI,
+			Ticker:                instr.Ticker,
+			Name:                  instr.Name,
+			Sector:                instr.Sector,
+			CountryOfRisk:         instr.Country,
+			Currency:              instr.Currency,
+			ClassCode:             "TQTF",
+			Lot:                   instr.Lot,
+			ApiTradeAvailableFlag: true,
+			BuyAvailableFlag:      true,
+			SellAvailableFlag:     true,
+			ShortEnabledFlag:      instr.Short,
+		})
+	}
+	return resp, nil
+}
+
+// GetDividends returns the dividends of an instrument with a record date within the period
+func (i *instrumentsService) GetDividends(ctx context.Context, req *pb.GetDividendsRequest) (*pb.GetDividendsResponse, error) {
+	figi := req.GetInstrumentId()
+	if figi == "" {
+		figi = req.GetFigi()
+	}
+	if i.scenario.instrument(figi) == nil {
+		return nil, status.Errorf(codes.NotFound, "50002: instrument %s not found", figi)
+	}
+
+	from, to := timeRange(req.GetFrom(), req.GetTo())
+	resp := &pb.GetDividendsResponse{}
+	for _, d := range i.scenario.Dividends[figi] {
+		if d.RecordDate.Before(from) || d.RecordDate.After(to) {
+			continue
+		}
+		dividend := &pb.Dividend{
+			DividendNet: money(d.Amount, d.Currency),
+			RecordDate:  timestamppb.New(d.RecordDate),
+		}
+		if !d.PaymentDate.IsZero() {
+			dividend.PaymentDate = timestamppb.New(d.PaymentDate)
+		}
+		resp.Dividends = append(resp.Dividends, dividend)
+	}
+	return resp, nil
+}
+
+// marketDataService serves prices
+type marketDataService struct {
+	pb.UnimplementedMarketDataServiceServer
+	scenario *Scenario
+}
+
+// GetCandles returns the daily candles of an instrument within the period
+func (m *marketDataService) GetCandles(ctx context.Context, req *pb.GetCandlesRequest) (*pb.GetCandlesResponse, error) {
+	if req.GetInterval() != pb.CandleInterval_CANDLE_INTERVAL_DAY {
+		return nil, status.Error(codes.InvalidArgument, "30014: the sandbox only has daily candles")
+	}
+	figi := req.GetInstrumentId()
+	if figi == "" {
+		figi = req.GetFigi()
+	}
+	if m.scenario.instrument(figi) == nil {
+		return nil, status.Errorf(codes.NotFound, "50002: instrument %s not found", figi)
+	}
+
+	from, to := timeRange(req.GetFrom(), req.GetTo())
+	resp := &pb.GetCandlesResponse{}
+	for _, c := range m.scenario.Candles[figi] {
+		if c.Date.Before(from) || !c.Date.Before(to) {
+			continue
+		}
+		resp.Candles = append(resp.Candles, &pb.HistoricCandle{
+			Open:       quotation(c.Open),
+			High:       quotation(c.High),
+			Low:        quotation(c.Low),
+			Close:      quotation(c.Close),
+			Volume:     c.Volume,
+			Time:       timestamppb.New(c.Date),
+			IsComplete: true,
+		})
+	}
+	return resp, nil
+}
+
+// GetLastPrices returns the last close of each requested instrument
+func (m *marketDataService) GetLastPrices(ctx context.Context, req *pb.GetLastPricesRequest) (*pb.GetLastPricesResponse, error) {
+	ids := append(req.GetInstrumentId(), req.GetFigi()...)
+	resp := &pb.GetLastPricesResponse{}
+	for _, figi := range ids {
+		candles := m.scenario.Candles[figi]
+		if len(candles) == 0 {
+			continue
+		}
+		last := candles[len(candles)-1]
+		resp.LastPrices = append(resp.LastPrices, &pb.LastPrice{
+			Figi:          figi,
+			InstrumentUid: figi,
+			Price:         quotation(last.Close),
+			Time:          timestamppb.New(last.Date),
+		})
+	}
+	return resp, nil
+}
+
+// timeRange converts request bounds, treating missing ones as unbounded
+func timeRange(from, to *timestamppb.Timestamp) (time.Time, time.Time) {
+	start, end := time.Time{}, time.Now().AddDate(100, 0, 0)
+	if from != nil {
+		start = from.AsTime()
+	}
+	if to != nil {
+		end = to.AsTime()
+	}
+	return start, end
+}
+
+// quotation converts a number to the API fixed-point format
+func quotation(v float64) *pb.Quotation {
+	units, nano := splitNano(v)
+	return &pb.Quotation{Units: units, Nano: nano}
+}
+
+// money converts an amount to the API fixed-point format
+func money(v float64, currency string) *pb.MoneyValue {
+	units, nano := splitNano(v)
+	return &pb.MoneyValue{Currency: currency, Units: units, Nano: nano}
+}
+
+// splitNano splits a number into whole units and billionths with the same sign
+func splitNano(v float64) (int64, int32) {
+	units := math.Trunc(v)
+	nano := math.Round((v - units) * 1e9)
+	return int64(units), int32(nano)
+}
@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/news"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Report is the result of one run of the analysis pipeline
+type Report struct {
+	Time      time.Time                   `json:"time"`
+	Portfolio *invest.Portfolio           `json:"portfolio"`
+	Articles  []news.Article              `json:"articles"`
+	Analysis  *analysis.PortfolioAnalysis `json:"analysis"`
+}
+
+// Analyze gets the portfolio and fresh news and analyzes them, without sending anything.
+// The watchlist, if not nil, adds the watched instruments and checks the opportunities;
+// the screener, if not nil, supplies the only candidates for opportunities.
+func Analyze(ctx context.Context, logger *slog.Logger, investor PortfolioProvider, newsFetcher NewsSource,
+	watchlist Watchlist, screener Screener, analyzer Analyzer, isMonthlyReminder bool) (*Report, error) {
+	report := &Report{Time: time.Now()}
+
+	// Step 1: Get portfolio data
+	logger.InfoContext(ctx, "Getting portfolio data")
+	portfolio, err := investor.GetPortfolio(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get portfolio: %w", err)
+	}
+	report.Portfolio = portfolio
+
+	// Step 2: Fetch market news
+	logger.InfoContext(ctx, "Fetching fresh market news")
+	articles, err := newsFetcher.FetchMarketNews()
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to fetch news, continuing without news data", "error", err)
+		articles = []news.Article{} // Empty but continue
+	}
+	if watchlist != nil {
+		logger.InfoContext(ctx, "Adding watched instruments")
+		articles = watchlist.Include(ctx, portfolio, articles, newsFetcher)
+	}
+	report.Articles = articles
+	if screener != nil {
+		logger.InfoContext(ctx, "Screening opportunity candidates")
+		screener.Include(ctx, portfolio)
+	}
+
+	// Step 3: Analyze portfolio and news
+	logger.InfoContext(ctx, "Analyzing portfolio with OpenAI")
+	result, err := analyzer.AnalyzePortfolio(ctx, portfolio, articles, isMonthlyReminder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze portfolio: %w", err)
+	}
+	if watchlist != nil {
+		watchlist.ValidateOpportunities(ctx, result)
+	}
+	report.Analysis = result
+
+	return report, nil
+}
+
+// Pipeline is what every run does before its report is sent: the analysis, the paper portfolio
+// following the advice and the portfolio value recorded. The scheduled runs and /analyze share it.
+type Pipeline struct {
+	logger      *slog.Logger
+	investor    PortfolioProvider
+	newsFetcher NewsSource
+	analyzer    Analyzer
+	opts        Options
+}
+
+// NewPipeline creates the pipeline of the runs; the options left nil are skipped
+func NewPipeline(logger *slog.Logger, investor PortfolioProvider, newsFetcher NewsSource, analyzer Analyzer, opts Options) *Pipeline {
+	return &Pipeline{logger: logger, investor: investor, newsFetcher: newsFetcher, analyzer: analyzer, opts: opts}
+}
+
+// Run analyzes the portfolio and brings the paper portfolio and the recorded values up to date
+// with it. The value is recorded for the day of now, in the configured time zone. The monthly run
+// also refreshes the returns, the tax estimate and the deposit status its report shows. Only the
+// analysis can fail the run; the other steps log their failures.
+func (p *Pipeline) Run(ctx context.Context, now time.Time, isMonthlyReminder bool) (*Report, error) {
+	report, err := Analyze(ctx, p.logger, p.investor, p.newsFetcher, p.opts.Watchlist, p.opts.Screener, p.analyzer, isMonthlyReminder)
+	if err != nil {
+		return nil, err
+	}
+
+	// The paper portfolio follows the advice before the report compares it with the real one
+	if p.opts.Paper != nil {
+		if err := p.opts.Paper.Follow(ctx, report.Portfolio, report.Analysis); err != nil {
+			p.logger.WarnContext(ctx, "Failed to follow the advice on the paper portfolio", "error", err)
+		}
+	}
+
+	// The returns are computed before the monthly report, which shows them
+	if tracker := p.opts.Performance; tracker != nil {
+		if err := tracker.Record(report.Portfolio, now); err != nil {
+			p.logger.WarnContext(ctx, "Failed to record the portfolio value", "error", err)
+		}
+		if isMonthlyReminder {
+			if _, err := tracker.Refresh(ctx, now); err != nil {
+				p.logger.WarnContext(ctx, "Failed to compute returns", "error", err)
+			}
+		}
+	}
+	if estimator := p.opts.Tax; estimator != nil && isMonthlyReminder {
+		if _, err := estimator.Refresh(ctx, now); err != nil {
+			p.logger.WarnContext(ctx, "Failed to estimate the income tax", "error", err)
+		}
+	}
+	if planner := p.opts.Contributions; planner != nil && isMonthlyReminder {
+		// Without a status the report falls back to the plain reminder
+		if _, err := planner.Refresh(ctx, now); err != nil {
+			p.logger.WarnContext(ctx, "Failed to check the deposits against the plan", "error", err)
+		}
+	}
+	return report, nil
+}
+
+// Replay analyzes the portfolio and news recorded in an earlier report instead of fetching them.
+// If response is empty the LLM is called live, otherwise the response is parsed as if the LLM
+// had returned it, so the run is fully deterministic.
+func Replay(ctx context.Context, logger *slog.Logger, recorded *Report, analyzer Analyzer,
+	response string, isMonthlyReminder bool) (*Report, error) {
+	// Keep the recorded time, so the output matches the original run
+	report := &Report{Time: recorded.Time, Portfolio: recorded.Portfolio, Articles: recorded.Articles}
+	logger.InfoContext(ctx, "Replaying a recorded run", "time", recorded.Time.Format(time.RFC3339),
+		"positions", len(recorded.Portfolio.Positions), "articles", len(recorded.Articles))
+
+	var (
+		result *analysis.PortfolioAnalysis
+		err    error
+	)
+	if response == "" {
+		logger.InfoContext(ctx, "Analyzing portfolio with OpenAI")
+		result, err = analyzer.AnalyzePortfolio(ctx, report.Portfolio, report.Articles, isMonthlyReminder)
+	} else {
+		logger.InfoContext(ctx, "Parsing the recorded LLM response")
+		result, err = analysis.ParseResponse(response, report.Portfolio, isMonthlyReminder)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze portfolio: %w", err)
+	}
+	report.Analysis = result
+
+	return report, nil
+}
+
+// LoadReport reads a report saved as JSON, e.g. by "invest-manager analyze -record"
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	if report.Portfolio == nil {
+		return nil, fmt.Errorf("%s is not an analysis report", path)
+	}
+	return &report, nil
+}
+
+// SaveReport writes a report as JSON, so the run can be replayed or backtested later
+func SaveReport(path string, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+	return nil
+}
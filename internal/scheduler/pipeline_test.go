@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/logging"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const recordedResponse = `SUMMARY:
+Портфель сбалансирован.
+
+RECOMMENDATIONS:
+SBER - BUY
+Сильная отчётность.
+GAZP - SELL
+Снижение экспорта.
+`
+
+// recordedReport returns a report as saved by an earlier run
+func recordedReport() *Report {
+	return &Report{
+		Time:      time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC),
+		Portfolio: testPortfolio(),
+		Articles:  testArticles(),
+		Analysis:  &analysis.PortfolioAnalysis{RawText: recordedResponse},
+	}
+}
+
+func TestReplayIsDeterministic(t *testing.T) {
+	logger := logging.Discard()
+	analyzer := analysis.NewAnalyzer(&config.Config{})
+	recorded := recordedReport()
+
+	first, err := Replay(context.Background(), logger, recorded, analyzer, recorded.Analysis.RawText, false)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	second, err := Replay(context.Background(), logger, recorded, analyzer, recorded.Analysis.RawText, false)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("replays differ:\n%+v\n%+v", first, second)
+	}
+
+	if !first.Time.Equal(recorded.Time) {
+		t.Errorf("Time = %v, want the recorded %v", first.Time, recorded.Time)
+	}
+	var actions []string
+	for _, r := range first.Analysis.Recommendations {
+		actions = append(actions, r.Ticker+" "+r.Action)
+	}
+	if want := []string{"SBER BUY", "GAZP SELL"}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("recommendations = %v, want %v", actions, want)
+	}
+}
+
+func TestSaveAndLoadReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	report := recordedReport()
+
+	if err := SaveReport(path, report); err != nil {
+		t.Fatalf("SaveReport: %v", err)
+	}
+	loaded, err := LoadReport(path)
+	if err != nil {
+		t.Fatalf("LoadReport: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, report) {
+		t.Errorf("loaded report differs:\n%+v\n%+v", loaded, report)
+	}
+}
@@ -5,136 +5,344 @@ import (
 	"fmt"
 	"invest-manager/internal/analysis"
 	"invest-manager/internal/config"
+	"invest-manager/internal/contributions"
 	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/models"
+	"invest-manager/internal/monitoring"
 	"invest-manager/internal/news"
-	"invest-manager/internal/telegram"
-	"log"
+	"invest-manager/internal/performance"
+	"invest-manager/internal/tax"
+	"invest-manager/internal/usage"
+	"invest-manager/internal/watchlist"
+	"log/slog"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron/v3"
 )
 
+// PortfolioProvider supplies the current portfolio; *invest.Client is the production one
+type PortfolioProvider interface {
+	GetPortfolio(ctx context.Context) (*invest.Portfolio, error)
+}
+
+// NewsSource supplies fresh market news; *news.Fetcher is the production one
+type NewsSource interface {
+	FetchMarketNews() ([]news.Article, error)
+	FetchNews(query string, limit int) ([]news.Article, error)
+}
+
+// Watchlist adds instruments that are not held to the analysis and checks the
+// suggested opportunities; *watchlist.List is the production one
+type Watchlist interface {
+	Include(ctx context.Context, portfolio *invest.Portfolio, articles []news.Article, source watchlist.NewsSource) []news.Article
+	ValidateOpportunities(ctx context.Context, result *analysis.PortfolioAnalysis)
+}
+
+// Screener supplies the only candidates for opportunities; *screener.Screener is the production one
+type Screener interface {
+	Include(ctx context.Context, portfolio *invest.Portfolio)
+}
+
+// Analyzer asks the LLM for the analysis of a portfolio; *analysis.Analyzer is the production one
+type Analyzer interface {
+	AnalyzePortfolio(ctx context.Context, portfolio *invest.Portfolio, articles []news.Article, isMonthlyReminder bool) (*analysis.PortfolioAnalysis, error)
+}
+
+// Notifier delivers reports to the user; *telegram.Bot is the production one
+type Notifier interface {
+	SendPortfolioAnalysis(portfolio *invest.Portfolio, analysis *analysis.PortfolioAnalysis, articles []news.Article) error
+	SendPortfolioCharts(ctx context.Context, portfolio *invest.Portfolio) error
+	SendModelComparison(report *models.Report) error
+}
+
+// PaperTrader follows the recommendations on a paper portfolio; *paper.Account is the production one
+type PaperTrader interface {
+	Follow(ctx context.Context, portfolio *invest.Portfolio, result *analysis.PortfolioAnalysis) error
+}
+
+// PerformanceTracker records the daily portfolio value and computes the returns for the monthly report;
+// *performance.Tracker is the production one
+type PerformanceTracker interface {
+	Record(portfolio *invest.Portfolio, now time.Time) error
+	Refresh(ctx context.Context, now time.Time) (*performance.Report, error)
+}
+
+// TaxEstimator estimates the income tax of the year for the monthly report; *tax.Estimator is the production one
+type TaxEstimator interface {
+	Refresh(ctx context.Context, now time.Time) (*tax.Report, error)
+}
+
+// ContributionPlanner checks the deposits against the plan before the monthly reminder;
+// *contributions.Plan is the production one
+type ContributionPlanner interface {
+	Refresh(ctx context.Context, now time.Time) (*contributions.Status, error)
+}
+
+// ModelComparer compares the accounts with their model portfolios for the weekly report;
+// *models.Comparer is the production one
+type ModelComparer interface {
+	Enabled() bool
+	Refresh(ctx context.Context, now time.Time) (*models.Report, error)
+}
+
+// Options are the optional parts of the scheduled runs; the ones left nil are skipped
+type Options struct {
+	// Paper trades the recommendations of every run on the paper portfolio before the report is sent
+	Paper PaperTrader
+	// Watchlist adds the watched instruments to every run
+	Watchlist Watchlist
+	// Screener restricts the opportunities of every run to the matches of the default screen
+	Screener Screener
+	// Performance records the portfolio value on every run and computes the returns on the monthly one
+	Performance PerformanceTracker
+	// Tax estimates the income tax of the year on the monthly run
+	Tax TaxEstimator
+	// Contributions makes the monthly run remind about deposits only if the plan is not met
+	Contributions ContributionPlanner
+	// Models compares the accounts with their model portfolios on the weekly run
+	Models ModelComparer
+}
+
 // Job contains all dependencies needed for scheduled jobs
 type Job struct {
 	config    *config.Config
-	logger    *log.Logger
-	investor  *invest.Client
-	newsFetcher *news.Fetcher
-	analyzer  *analysis.Analyzer
-	telegramBot *telegram.Bot
+	logger    *slog.Logger
+	pipeline  *Pipeline
+	notifier  Notifier
+	models    ModelComparer
 }
 
 // Scheduler handles scheduling of portfolio analysis tasks
 type Scheduler struct {
 	job        *Job
+	logger     *slog.Logger
+
+	// mu guards the cron instance, which is replaced on config reload
+	mu         sync.Mutex
 	cron       *cron.Cron
 	timezone   *time.Location
-	logger     *log.Logger
+	schedule   config.ScheduleConfig
+	started    bool
 }
 
 // NewScheduler creates a new scheduler
 func NewScheduler(
 	cfg *config.Config,
-	logger *log.Logger,
-	investor *invest.Client,
-	newsFetcher *news.Fetcher,
-	analyzer *analysis.Analyzer,
-	telegramBot *telegram.Bot,
+	logger *slog.Logger,
+	investor PortfolioProvider,
+	newsFetcher NewsSource,
+	analyzer Analyzer,
+	notifier Notifier,
+	opts Options,
 ) *Scheduler {
 	job := &Job{
-		config:      cfg,
-		logger:      logger,
-		investor:    investor,
-		newsFetcher: newsFetcher,
-		analyzer:    analyzer,
-		telegramBot: telegramBot,
+		config:   cfg,
+		logger:   logger,
+		pipeline: NewPipeline(logger, investor, newsFetcher, analyzer, opts),
+		notifier: notifier,
+		models:   opts.Models,
 	}
 
-	// Create cron scheduler with the specified timezone
-	cronOptions := cron.WithLocation(cfg.Timezone)
-	cronScheduler := cron.New(cronOptions)
-	
 	return &Scheduler{
 		job:      job,
-		cron:     cronScheduler,
-		timezone: cfg.Timezone,
 		logger:   logger,
+		timezone: cfg.Timezone,
+		schedule: cfg.Schedule,
 	}
 }
 
 // Start begins the scheduler
 func (s *Scheduler) Start() error {
-	// Daily job at 7:00 MSK
-	_, err := s.cron.AddFunc("0 7 * * *", func() {
-		s.logger.Printf("Running daily portfolio analysis job")
-		
-		// Check if today is the 5th of the month
-		now := time.Now().In(s.timezone)
-		isMonthlyReminder := now.Day() == 5
-		
-		// Run the portfolio analysis
-		if err := s.runPortfolioAnalysis(isMonthlyReminder); err != nil {
-			s.logger.Printf("Error running portfolio analysis: %v", err)
-		}
-	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	
+	c, err := s.newCron(s.schedule, s.timezone)
 	if err != nil {
-		return fmt.Errorf("failed to schedule daily job: %w", err)
+		return err
 	}
 	
 	// Start the cron scheduler
-	s.cron.Start()
-	s.logger.Printf("Scheduler started. Timezone: %s", s.timezone.String())
+	c.Start()
+	s.cron = c
+	s.started = true
+	s.logger.Info("Scheduler started", "daily", s.schedule.Daily, "weekly", s.schedule.Weekly, "timezone", s.timezone.String())
+	return nil
+}
+
+// Reload re-registers the jobs with the schedule and time zone of a new configuration.
+// Jobs that are already running are allowed to finish.
+func (s *Scheduler) Reload(cfg *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if cfg.Schedule == s.schedule && cfg.Timezone.String() == s.timezone.String() {
+		s.job.config = cfg
+		return nil
+	}
+	
+	if s.started {
+		// Build the new cron first, so an error leaves the old configuration in effect
+		c, err := s.newCron(cfg.Schedule, cfg.Timezone)
+		if err != nil {
+			return err
+		}
+		s.cron.Stop()
+		c.Start()
+		s.cron = c
+	}
+	
+	s.job.config = cfg
+	s.schedule = cfg.Schedule
+	s.timezone = cfg.Timezone
+	s.logger.Info("Scheduler reloaded", "daily", s.schedule.Daily, "weekly", s.schedule.Weekly, "timezone", s.timezone.String())
 	return nil
 }
 
+// newCron creates a cron instance with the daily and weekly jobs registered
+func (s *Scheduler) newCron(schedule config.ScheduleConfig, timezone *time.Location) (*cron.Cron, error) {
+	// Create cron scheduler with the specified timezone
+	c := cron.New(cron.WithLocation(timezone))
+	
+	_, err := c.AddFunc(schedule.Daily, func() {
+		// Check if today is the day of the monthly reminder
+		isMonthlyReminder := isMonthlyReminderDay(schedule, time.Now().In(timezone))
+		
+		// Run the portfolio analysis; the outcome is logged by the run itself
+		s.runPortfolioAnalysis("scheduled", isMonthlyReminder)
+	})
+	
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule daily job: %w", err)
+	}
+	
+	if schedule.Weekly != "" {
+		_, err = c.AddFunc(schedule.Weekly, func() {
+			s.runModelComparison("weekly")
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to schedule weekly job: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// isMonthlyReminderDay reports whether the report of the given day includes the deposit reminder
+func isMonthlyReminderDay(schedule config.ScheduleConfig, now time.Time) bool {
+	return schedule.MonthlyReminderDay > 0 && now.Day() == schedule.MonthlyReminderDay
+}
+
 // Stop stops the scheduler
 func (s *Scheduler) Stop() {
-	ctx := s.cron.Stop()
+	s.mu.Lock()
+	c := s.cron
+	s.started = false
+	s.mu.Unlock()
+	
+	if c == nil {
+		return
+	}
+	ctx := c.Stop()
 	<-ctx.Done()
-	s.logger.Printf("Scheduler stopped")
+	s.logger.Info("Scheduler stopped")
 }
 
 // RunNow runs portfolio analysis immediately
 func (s *Scheduler) RunNow(isMonthlyReminder bool) error {
-	s.logger.Printf("Running portfolio analysis now (manual trigger)")
-	return s.runPortfolioAnalysis(isMonthlyReminder)
+	return s.runPortfolioAnalysis("manual", isMonthlyReminder)
 }
 
-// runPortfolioAnalysis runs the complete portfolio analysis workflow
-func (s *Scheduler) runPortfolioAnalysis(isMonthlyReminder bool) error {
+// runPortfolioAnalysis runs the complete portfolio analysis workflow,
+// recording its duration and outcome under the job name. All records of
+// the run share a correlation ID.
+func (s *Scheduler) runPortfolioAnalysis(job string, isMonthlyReminder bool) (err error) {
 	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	ctx := usage.WithTags(logging.WithCorrelationID(context.Background()), job, "")
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer cancel()
 	
-	// Step 1: Get portfolio data
-	s.logger.Printf("Getting portfolio data")
-	portfolio, err := s.job.investor.GetPortfolio(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get portfolio: %w", err)
-	}
+	start := time.Now()
+	s.logger.InfoContext(ctx, "Running portfolio analysis", "job", job, "monthly", isMonthlyReminder)
+	defer func() {
+		monitoring.ObserveJob(job, start, err)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Portfolio analysis failed", "job", job, "error", err)
+			return
+		}
+		s.logger.InfoContext(ctx, "Portfolio analysis completed", "job", job, "duration", time.Since(start).Round(time.Millisecond))
+	}()
 	
-	// Step 2: Fetch news about Russia
-	s.logger.Printf("Fetching fresh news about Russia")
-	articles, err := s.job.newsFetcher.FetchNews("Russia", 5)
+	// Steps 1-3: Get portfolio and news, analyze them and apply the advice
+	report, err := s.job.pipeline.Run(ctx, time.Now().In(s.currentTimezone()), isMonthlyReminder)
 	if err != nil {
-		s.logger.Printf("Warning: failed to fetch news: %v. Continuing without news data", err)
-		articles = []news.Article{} // Empty but continue
+		return err
 	}
 	
-	// Step 3: Analyze portfolio and news
-	s.logger.Printf("Analyzing portfolio with OpenAI")
-	analysis, err := s.job.analyzer.AnalyzePortfolio(ctx, portfolio, articles, isMonthlyReminder)
-	if err != nil {
-		return fmt.Errorf("failed to analyze portfolio: %w", err)
+	// Step 4: Send results to Telegram with fresh news
+	// Record the run, so it can be replayed; this must not stop the delivery
+	if dir := s.recordDir(); dir != "" {
+		path := filepath.Join(dir, report.Time.In(s.currentTimezone()).Format("2006-01-02T150405")+".json")
+		if err := SaveReport(path, report); err != nil {
+			s.logger.WarnContext(ctx, "Failed to record the report", "error", err)
+		}
 	}
 	
-	// Step 4: Send results to Telegram with fresh news
-	s.logger.Printf("Sending analysis to Telegram")
-	if err := s.job.telegramBot.SendPortfolioAnalysis(portfolio, analysis, articles); err != nil {
+	s.logger.InfoContext(ctx, "Sending analysis to Telegram")
+	if err := s.job.notifier.SendPortfolioAnalysis(report.Portfolio, report.Analysis, report.Articles); err != nil {
 		return fmt.Errorf("failed to send analysis to Telegram: %w", err)
 	}
 	
-	s.logger.Printf("Portfolio analysis completed successfully")
+	// Step 5: Send charts; the report is already delivered, so failures are not fatal
+	s.logger.InfoContext(ctx, "Sending portfolio charts to Telegram")
+	if err := s.job.notifier.SendPortfolioCharts(ctx, report.Portfolio); err != nil {
+		s.logger.WarnContext(ctx, "Failed to send portfolio charts", "error", err)
+	}
+	
 	return nil
-} 
\ No newline at end of file
+}
+
+// runModelComparison sends the comparison of the accounts with their model portfolios.
+// Without models there is nothing to send and the run is skipped.
+func (s *Scheduler) runModelComparison(job string) (err error) {
+	comparer := s.job.models
+	if comparer == nil || !comparer.Enabled() {
+		return nil
+	}
+	
+	ctx, cancel := context.WithTimeout(logging.WithCorrelationID(context.Background()), 2*time.Minute)
+	defer cancel()
+	
+	start := time.Now()
+	s.logger.InfoContext(ctx, "Comparing model portfolios", "job", job)
+	defer func() {
+		monitoring.ObserveJob(job, start, err)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "Model portfolio comparison failed", "job", job, "error", err)
+		}
+	}()
+	
+	report, err := comparer.Refresh(ctx, time.Now().In(s.currentTimezone()))
+	if err != nil {
+		return fmt.Errorf("failed to compare model portfolios: %w", err)
+	}
+	if err := s.job.notifier.SendModelComparison(report); err != nil {
+		return fmt.Errorf("failed to send model comparison to Telegram: %w", err)
+	}
+	return nil
+}
+
+// recordDir returns the directory reports are recorded to, empty if recording is off
+func (s *Scheduler) recordDir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.schedule.RecordDir
+}
+
+// currentTimezone returns the configured time zone
+func (s *Scheduler) currentTimezone() *time.Location {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.timezone
+}
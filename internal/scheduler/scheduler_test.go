@@ -0,0 +1,518 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/contributions"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/models"
+	"invest-manager/internal/news"
+	"invest-manager/internal/paper"
+	"invest-manager/internal/performance"
+	"invest-manager/internal/screener"
+	"invest-manager/internal/tax"
+	"invest-manager/internal/watchlist"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fakes must stay usable in place of the production dependencies
+var (
+	_ PortfolioProvider = (*fake.Broker)(nil)
+	_ NewsSource        = (*fake.News)(nil)
+	_ Notifier          = (*fake.Notifier)(nil)
+	_ analysis.LLM      = (*fake.LLM)(nil)
+
+	_ PortfolioProvider   = (*invest.Client)(nil)
+	_ Analyzer            = (*analysis.Analyzer)(nil)
+	_ NewsSource          = (*news.Fetcher)(nil)
+	_ PaperTrader         = (*paper.Account)(nil)
+	_ PerformanceTracker  = (*performance.Tracker)(nil)
+	_ TaxEstimator        = (*tax.Estimator)(nil)
+	_ ContributionPlanner = (*contributions.Plan)(nil)
+	_ ModelComparer       = (*models.Comparer)(nil)
+	_ Watchlist           = (*watchlist.List)(nil)
+	_ Screener            = (*screener.Screener)(nil)
+)
+
+func TestIsMonthlyReminderDay(t *testing.T) {
+	tests := []struct {
+		name string
+		day  int
+		now  time.Time
+		want bool
+	}{
+		{"reminder day", 5, time.Date(2026, 10, 5, 7, 0, 0, 0, time.UTC), true},
+		{"other day", 5, time.Date(2026, 10, 6, 7, 0, 0, 0, time.UTC), false},
+		{"disabled", 0, time.Date(2026, 10, 5, 7, 0, 0, 0, time.UTC), false},
+		{"first of month", 1, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), true},
+		{"28th in february", 28, time.Date(2027, 2, 28, 23, 59, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := config.ScheduleConfig{Daily: "0 7 * * *", MonthlyReminderDay: tt.day}
+			if got := isMonthlyReminderDay(schedule, tt.now); got != tt.want {
+				t.Errorf("isMonthlyReminderDay(%d, %s) = %v, want %v", tt.day, tt.now.Format("2006-01-02"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	// Friday evening in Moscow
+	from := time.Date(2026, 10, 16, 20, 0, 0, 0, moscow)
+
+	tests := []struct {
+		name     string
+		daily    string
+		timezone *time.Location
+		wantNext time.Time
+		wantErr  bool
+	}{
+		{"every day", "0 7 * * *", moscow, time.Date(2026, 10, 17, 7, 0, 0, 0, moscow), false},
+		{"weekdays skip the weekend", "30 8 * * 1-5", moscow, time.Date(2026, 10, 19, 8, 30, 0, 0, moscow), false},
+		{"time zone is applied", "0 7 * * *", time.UTC, time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC), false},
+		{"invalid spec", "every morning", moscow, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScheduler(t, testConfig(), &fake.Broker{}, &fake.News{}, &fake.LLM{}, &fake.Notifier{}, Options{})
+			c, err := s.newCron(config.ScheduleConfig{Daily: tt.daily}, tt.timezone)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("newCron() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newCron: %v", err)
+			}
+
+			entries := c.Entries()
+			if len(entries) != 1 {
+				t.Fatalf("%d jobs registered, want 1", len(entries))
+			}
+			if next := entries[0].Schedule.Next(from.In(tt.timezone)); !next.Equal(tt.wantNext) {
+				t.Errorf("next run = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestReloadKeepsScheduleOnError(t *testing.T) {
+	s := newTestScheduler(t, testConfig(), &fake.Broker{}, &fake.News{}, &fake.LLM{}, &fake.Notifier{}, Options{})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Stop()
+
+	updated := testConfig()
+	updated.Schedule.Daily = "30 8 * * 1-5"
+	if err := s.Reload(updated); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if s.schedule.Daily != "30 8 * * 1-5" {
+		t.Errorf("schedule = %q after reload", s.schedule.Daily)
+	}
+
+	broken := testConfig()
+	broken.Schedule.Daily = "never"
+	if err := s.Reload(broken); err == nil {
+		t.Fatal("Reload() with an invalid spec succeeded")
+	}
+	if s.schedule.Daily != "30 8 * * 1-5" {
+		t.Errorf("schedule = %q, want the previous one kept", s.schedule.Daily)
+	}
+	if s.job.config != updated {
+		t.Error("the rejected configuration was applied to the jobs")
+	}
+}
+
+func TestRunPortfolioAnalysis(t *testing.T) {
+	errBroker := errors.New("broker is down")
+	errNews := errors.New("rate limited")
+	errLLM := errors.New("context length exceeded")
+	errSend := errors.New("chat not found")
+
+	tests := []struct {
+		name        string
+		broker      *fake.Broker
+		news        *fake.News
+		llmErr      error
+		llmDisabled bool
+		notifier    *fake.Notifier
+		wantErr     error
+		wantSent    bool
+		wantCharts  int
+		wantPrompts int
+		wantNews    int
+	}{
+		{
+			name:        "full run",
+			broker:      &fake.Broker{Portfolio: testPortfolio()},
+			news:        &fake.News{Articles: testArticles()},
+			notifier:    &fake.Notifier{},
+			wantSent:    true,
+			wantCharts:  1,
+			wantPrompts: 1,
+			wantNews:    1,
+		},
+		{
+			name:     "broker failure stops the run",
+			broker:   &fake.Broker{Err: errBroker},
+			news:     &fake.News{Articles: testArticles()},
+			notifier: &fake.Notifier{},
+			wantErr:  errBroker,
+		},
+		{
+			name:        "news failure is not fatal",
+			broker:      &fake.Broker{Portfolio: testPortfolio()},
+			news:        &fake.News{Err: errNews},
+			notifier:    &fake.Notifier{},
+			wantSent:    true,
+			wantCharts:  1,
+			wantPrompts: 1,
+		},
+		{
+			name:        "LLM failure stops the run",
+			broker:      &fake.Broker{Portfolio: testPortfolio()},
+			news:        &fake.News{Articles: testArticles()},
+			llmErr:      errLLM,
+			notifier:    &fake.Notifier{},
+			wantErr:     errLLM,
+			wantPrompts: 1,
+		},
+		{
+			name:        "disabled LLM still sends the report",
+			broker:      &fake.Broker{Portfolio: testPortfolio()},
+			news:        &fake.News{Articles: testArticles()},
+			llmDisabled: true,
+			notifier:    &fake.Notifier{},
+			wantSent:    true,
+			wantCharts:  1,
+			wantNews:    1,
+		},
+		{
+			name:        "send failure is reported",
+			broker:      &fake.Broker{Portfolio: testPortfolio()},
+			news:        &fake.News{Articles: testArticles()},
+			notifier:    &fake.Notifier{Err: errSend},
+			wantErr:     errSend,
+			wantPrompts: 1,
+		},
+		{
+			name:        "chart failure is not fatal",
+			broker:      &fake.Broker{Portfolio: testPortfolio()},
+			news:        &fake.News{Articles: testArticles()},
+			notifier:    &fake.Notifier{ChartsErr: errSend},
+			wantSent:    true,
+			wantPrompts: 1,
+			wantNews:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.OpenAI.Enabled = !tt.llmDisabled
+			llm := testLLM(t)
+			llm.Err = tt.llmErr
+			s := newTestScheduler(t, cfg, tt.broker, tt.news, llm, tt.notifier, Options{})
+
+			err := s.RunNow(false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RunNow() error = %v, want %v", err, tt.wantErr)
+			}
+
+			sent := tt.notifier.Sent()
+			if got := len(sent) == 1; got != tt.wantSent {
+				t.Fatalf("%d reports sent, want sent = %v", len(sent), tt.wantSent)
+			}
+			if got := tt.notifier.Charts(); got != tt.wantCharts {
+				t.Errorf("charts sent %d times, want %d", got, tt.wantCharts)
+			}
+			if got := len(llm.Prompts()); got != tt.wantPrompts {
+				t.Errorf("LLM called %d times, want %d", got, tt.wantPrompts)
+			}
+			if !tt.wantSent {
+				return
+			}
+
+			report := sent[0]
+			if len(report.Articles) != tt.wantNews {
+				t.Errorf("%d articles sent, want %d", len(report.Articles), tt.wantNews)
+			}
+			if tt.llmDisabled {
+				if len(report.Analysis.Recommendations) != 0 {
+					t.Errorf("recommendations without the LLM: %+v", report.Analysis.Recommendations)
+				}
+				return
+			}
+			if got := len(report.Analysis.Recommendations); got != 2 {
+				t.Errorf("%d recommendations, want 2 from the recorded response", got)
+			}
+		})
+	}
+}
+
+func TestPromptContainsPortfolioAndNews(t *testing.T) {
+	llm := testLLM(t)
+	notifier := &fake.Notifier{}
+	s := newTestScheduler(t, testConfig(), &fake.Broker{Portfolio: testPortfolio()},
+		&fake.News{Articles: testArticles()}, llm, notifier, Options{})
+
+	if err := s.RunNow(true); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+
+	prompts := llm.Prompts()
+	if len(prompts) != 1 {
+		t.Fatalf("LLM called %d times, want 1", len(prompts))
+	}
+	for _, want := range []string{"SBER (Сбербанк)", "GAZP (Газпром)", "Ключевая ставка", "monthly review"} {
+		if !strings.Contains(prompts[0].User, want) {
+			t.Errorf("prompt does not mention %q:\n%s", want, prompts[0].User)
+		}
+	}
+	if !notifier.Sent()[0].Analysis.IsMonthlyReminder {
+		t.Error("report is not marked as the monthly one")
+	}
+}
+
+func TestRunAnalyzesWatchlist(t *testing.T) {
+	broker := &fake.Broker{
+		Portfolio: testPortfolio(),
+		Instruments: map[string]*invest.Instrument{
+			"rosn": {FIGI: "rosn", Ticker: "ROSN", Name: "Роснефть", Currency: "RUB", Tradable: true, Buyable: true},
+			"lkoh": {FIGI: "lkoh", Ticker: "LKOH", Name: "Лукойл", Tradable: true, Buyable: true},
+		},
+		LastPrices: map[string]float64{"rosn": 550},
+	}
+	cfg := testConfig()
+	cfg.Watchlist = config.WatchlistConfig{MaxItems: 5}
+	list, err := watchlist.Open("", cfg.Watchlist, broker, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := list.Add(context.Background(), "ROSN"); err != nil {
+		t.Fatal(err)
+	}
+	llm := testLLM(t)
+	notifier := &fake.Notifier{}
+	s := newTestScheduler(t, cfg, broker, &fake.News{Articles: testArticles()}, llm, notifier, Options{Watchlist: list})
+
+	if err := s.RunNow(false); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+
+	if prompt := llm.Prompts()[0].User; !strings.Contains(prompt, "ROSN (Роснефть)") || !strings.Contains(prompt, "550.00 RUB") {
+		t.Errorf("prompt does not mention the watched instrument:\n%s", prompt)
+	}
+	// YNDX is not a known instrument, so only LKOH is left
+	opportunities := notifier.Sent()[0].Analysis.Opportunities
+	if len(opportunities) != 1 || opportunities[0].Ticker != "LKOH" {
+		t.Errorf("opportunities = %+v, want LKOH only", opportunities)
+	}
+}
+
+func TestRunScreensOpportunities(t *testing.T) {
+	broker := &fake.Broker{
+		Portfolio: testPortfolio(),
+		Listed: []invest.Instrument{
+			{FIGI: "sber", Ticker: "SBER", Type: "share", Sector: "financial"},
+			{FIGI: "lkoh", Ticker: "LKOH", Name: "Лукойл", Type: "share", Sector: "energy"},
+			{FIGI: "rosn", Ticker: "ROSN", Name: "Роснефть", Type: "share", Sector: "energy"},
+		},
+	}
+	rule, err := config.ParseScreenRule("sector = energy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig()
+	cfg.Screener = config.ScreenerConfig{Default: "oil", MaxCandidates: 5, Rules: map[string][]config.ScreenRule{"oil": {rule}}}
+	llm := testLLM(t)
+	notifier := &fake.Notifier{}
+	s := newTestScheduler(t, cfg, broker, &fake.News{Articles: testArticles()}, llm, notifier,
+		Options{Screener: screener.New(cfg.Screener, broker, logging.Discard())})
+
+	if err := s.RunNow(false); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+
+	if prompt := llm.Prompts()[0].User; !strings.Contains(prompt, "- LKOH (Лукойл)") || !strings.Contains(prompt, "- ROSN (Роснефть)") {
+		t.Errorf("prompt does not list the candidates:\n%s", prompt)
+	}
+	// YNDX did not pass the screen
+	opportunities := notifier.Sent()[0].Analysis.Opportunities
+	if len(opportunities) != 1 || opportunities[0].Ticker != "LKOH" {
+		t.Errorf("opportunities = %+v, want LKOH only", opportunities)
+	}
+}
+
+func TestRunRecordsReport(t *testing.T) {
+	cfg := testConfig()
+	cfg.Schedule.RecordDir = t.TempDir()
+	s := newTestScheduler(t, cfg, &fake.Broker{Portfolio: testPortfolio()},
+		&fake.News{Articles: testArticles()}, testLLM(t), &fake.Notifier{}, Options{})
+
+	if err := s.RunNow(false); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(cfg.Schedule.RecordDir, "*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("recorded files = %v, %v; want one", files, err)
+	}
+	report, err := LoadReport(files[0])
+	if err != nil {
+		t.Fatalf("LoadReport: %v", err)
+	}
+	if report.Analysis.RawText == "" || len(report.Portfolio.Positions) != 2 {
+		t.Errorf("recorded report is incomplete: %+v", report)
+	}
+}
+
+func TestRunFollowsOnPaper(t *testing.T) {
+	cfg := testConfig()
+	notifier := &fake.Notifier{}
+	account, err := paper.Open("", config.PaperConfig{Enabled: true, BuyShare: 0.1, SellShare: 1, MaxPositionShare: 0.5},
+		nil, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestScheduler(t, cfg, &fake.Broker{Portfolio: testPortfolio()},
+		&fake.News{Articles: testArticles()}, testLLM(t), notifier, Options{Paper: account})
+
+	if err := s.RunNow(false); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	if len(account.Equity()) != 1 || len(notifier.Sent()) != 1 {
+		t.Errorf("paper equity = %+v; the run must follow the advice and still send the report", account.Equity())
+	}
+}
+
+func TestRunComputesMonthlyReturnsAndTax(t *testing.T) {
+	cfg := testConfig()
+	broker := &fake.Broker{Portfolio: testPortfolio()}
+	tracker, err := performance.Open("", config.PerformanceConfig{}, broker, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	estimator := tax.New(config.TaxConfig{HarvestMonth: 11, IISDeductionBase: 400000}, broker, logging.Discard())
+	s := newTestScheduler(t, cfg, broker, &fake.News{Articles: testArticles()}, testLLM(t), &fake.Notifier{},
+		Options{Performance: tracker, Tax: estimator})
+
+	if err := s.RunNow(false); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	if tracker.Latest() != nil || estimator.Latest() != nil {
+		t.Error("returns or tax computed on a regular run")
+	}
+	if err := s.RunNow(true); err != nil {
+		t.Fatalf("RunNow: %v", err)
+	}
+	if report := tracker.Latest(); report == nil || len(report.Returns) != 4 || report.Returns[0].End != testPortfolio().TotalAmount {
+		t.Errorf("monthly returns = %+v, want the recorded portfolio value", report)
+	}
+	if estimator.Latest() == nil {
+		t.Error("no tax estimate for the monthly report")
+	}
+}
+
+func TestRunModelComparison(t *testing.T) {
+	day := time.Now().UTC().AddDate(0, 0, -1)
+	broker := &fake.Broker{
+		Instruments: map[string]*invest.Instrument{"sber": {FIGI: "sber", Ticker: "SBER"}},
+		Candles: map[string][]invest.Candle{"sber": {
+			{Time: day.AddDate(0, 0, -1), Close: 100},
+			{Time: day, Close: 110},
+		}},
+	}
+	notifier := &fake.Notifier{}
+	comparer := models.New(config.ModelsConfig{PeriodDays: 30}, broker, logging.Discard())
+	s := newTestScheduler(t, testConfig(), broker, &fake.News{}, &fake.LLM{}, notifier, Options{Models: comparer})
+
+	// Without models the weekly run has nothing to send
+	if err := s.runModelComparison("weekly"); err != nil || len(notifier.Comparisons()) != 0 {
+		t.Fatalf("without models: err %v, %d sent", err, len(notifier.Comparisons()))
+	}
+
+	comparer.Reload(config.ModelsConfig{PeriodDays: 30, Portfolios: map[string]config.ModelPortfolio{
+		"growth": {Weights: map[string]float64{"SBER": 1}},
+	}})
+	if err := s.runModelComparison("weekly"); err != nil {
+		t.Fatalf("runModelComparison: %v", err)
+	}
+	sent := notifier.Comparisons()
+	if len(sent) != 1 || len(sent[0].Comparisons) != 1 || sent[0].Comparisons[0].Simulated.Return < 9.99 {
+		t.Fatalf("sent = %+v, want the growth model up 10%%", sent)
+	}
+
+	broker.Err = errors.New("broker is down")
+	if err := s.runModelComparison("weekly"); err == nil || len(notifier.Comparisons()) != 1 {
+		t.Errorf("broker error: err %v, %d sent", err, len(notifier.Comparisons()))
+	}
+}
+
+// testConfig returns a configuration with the LLM enabled
+func testConfig() *config.Config {
+	return &config.Config{
+		OpenAI:   config.OpenAIConfig{Enabled: true, Model: "test-model"},
+		Schedule: config.ScheduleConfig{Daily: "0 7 * * *", MonthlyReminderDay: 5},
+		Timezone: time.UTC,
+	}
+}
+
+// testPortfolio returns the positions the recorded LLM response refers to
+func testPortfolio() *invest.Portfolio {
+	return &invest.Portfolio{
+		Positions: []invest.Position{
+			{FIGI: "BBG004730N88", Ticker: "SBER", Name: "Сбербанк", Quantity: 10, CurrentPrice: 300, ExpectedYield: 500, Currency: "rub"},
+			{FIGI: "BBG004730RP0", Ticker: "GAZP", Name: "Газпром", Quantity: 20, CurrentPrice: 150, ExpectedYield: -200, Currency: "rub"},
+		},
+		TotalAmount: 6000,
+		Currency:    "rub",
+	}
+}
+
+// testArticles returns one news article
+func testArticles() []news.Article {
+	article := news.Article{Title: "Ключевая ставка", URL: "https://example.com/rate",
+		PublishedAt: time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)}
+	article.Source.Name = "Example"
+	return []news.Article{article}
+}
+
+// testLLM returns an LLM answering with a recorded response
+func testLLM(t *testing.T) *fake.LLM {
+	t.Helper()
+	llm, err := fake.NewLLMFromFile(filepath.Join("..", "analysis", "testdata", "standard.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return llm
+}
+
+// newTestScheduler wires a scheduler to fakes
+func newTestScheduler(t *testing.T, cfg *config.Config, broker *fake.Broker, newsSource *fake.News,
+	llm *fake.LLM, notifier *fake.Notifier, opts Options) *Scheduler {
+	t.Helper()
+	logger := logging.Discard()
+	if testing.Verbose() {
+		logger = logging.New(os.Stderr, logging.LevelVar("debug"), logging.FormatText)
+	}
+	return NewScheduler(cfg, logger, broker, newsSource, analysis.NewAnalyzerWithLLM(cfg, llm), notifier, opts)
+}
@@ -0,0 +1,107 @@
+package screener
+
+import (
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"time"
+)
+
+// Lookback periods of the market metrics
+const (
+	rsiPeriod      = 14
+	turnoverPeriod = 20 // sessions
+	changePeriod   = 30 * 24 * time.Hour
+	historyPeriod  = 45 * 24 * time.Hour // enough sessions for all of the above
+	dividendPeriod = 365 * 24 * time.Hour
+)
+
+// Metrics are the screened figures of an instrument; a metric that could not be
+// computed, e.g. RSI of a fresh listing, is left out and fails every rule
+type Metrics map[string]float64
+
+// marketMetrics computes the metrics that come from daily candles, oldest first
+func marketMetrics(candles []invest.Candle, lot int64, now time.Time) Metrics {
+	m := Metrics{}
+	if len(candles) == 0 {
+		return m
+	}
+	last := candles[len(candles)-1]
+	m[config.MetricPrice] = last.Close
+
+	if lot <= 0 {
+		lot = 1
+	}
+	recent := candles[max(len(candles)-turnoverPeriod, 0):]
+	var turnover float64
+	for _, c := range recent {
+		// Candle volume is in lots
+		turnover += c.Close * float64(c.Volume*lot)
+	}
+	m[config.MetricTurnover] = turnover / float64(len(recent))
+
+	closes := make([]float64, len(candles))
+	for i, c := range candles {
+		closes[i] = c.Close
+	}
+	if rsi, ok := relativeStrength(closes, rsiPeriod); ok {
+		m[config.MetricRSI] = rsi
+	}
+
+	// The change is measured from the first close at or after the start of the period
+	start := now.Add(-changePeriod)
+	for _, c := range candles {
+		if !c.Time.Before(start) {
+			if c.Close > 0 {
+				m[config.MetricChange] = (last.Close - c.Close) / c.Close * 100
+			}
+			break
+		}
+	}
+	return m
+}
+
+// relativeStrength computes Wilder's RSI of the closes; it needs period+1 closes
+func relativeStrength(closes []float64, period int) (float64, bool) {
+	if len(closes) <= period {
+		return 0, false
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		if d := closes[i] - closes[i-1]; d > 0 {
+			gain += d
+		} else {
+			loss -= d
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	for i := period + 1; i < len(closes); i++ {
+		d := closes[i] - closes[i-1]
+		up, down := max(d, 0), max(-d, 0)
+		gain = (gain*float64(period-1) + up) / float64(period)
+		loss = (loss*float64(period-1) + down) / float64(period)
+	}
+	if loss == 0 {
+		return 100, true
+	}
+	return 100 - 100/(1+gain/loss), true
+}
+
+// dividendYield relates the dividends of the last 12 months to the price, in percent.
+// Dividends paid in another currency than the instrument's are skipped.
+func dividendYield(dividends []invest.Dividend, currency string, price float64, now time.Time) float64 {
+	if price <= 0 {
+		return 0
+	}
+	var total float64
+	for _, d := range dividends {
+		if d.RecordDate.After(now) || d.RecordDate.Before(now.Add(-dividendPeriod)) {
+			continue
+		}
+		if d.Currency != "" && currency != "" && d.Currency != currency {
+			continue
+		}
+		total += d.Amount
+	}
+	return total / price * 100
+}
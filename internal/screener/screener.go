@@ -0,0 +1,345 @@
+// Package screener runs declarative screens over the tradable shares and ETFs
+// and supplies their matches as the only candidates for new opportunities.
+package screener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownScreen is returned for a screen that is not configured
+var ErrUnknownScreen = errors.New("unknown screen")
+
+// includeTimeout bounds the screening within an analysis, so a slow scan
+// leaves time for the LLM; the scanned data is cached for the next run
+const includeTimeout = 30 * time.Second
+
+// Market supplies the screened data; *invest.Client is the production one
+type Market interface {
+	ListTradable(ctx context.Context) ([]invest.Instrument, error)
+	GetDailyCandles(ctx context.Context, figi string, from, to time.Time) ([]invest.Candle, error)
+	GetDividends(ctx context.Context, figi string, from, to time.Time) ([]invest.Dividend, error)
+}
+
+// Match is an instrument that passed every rule of a screen
+type Match struct {
+	Instrument invest.Instrument
+	Metrics    Metrics
+}
+
+// Result is the outcome of a screen
+type Result struct {
+	Screen  string
+	Rules   []config.ScreenRule
+	Scanned int     // instruments checked
+	Matches []Match // the most traded first
+}
+
+// Screener runs the configured screens. Instruments and their metrics are cached
+// for the day, as they are computed from daily candles. It is safe for concurrent use.
+type Screener struct {
+	market Market
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	settings config.ScreenerConfig
+	day      string // date of the cached data
+	listed   []invest.Instrument
+	metrics  map[string]Metrics // candle metrics by FIGI
+	yields   map[string]float64 // dividend yields by FIGI
+}
+
+// New creates a screener
+func New(settings config.ScreenerConfig, market Market, logger *slog.Logger) *Screener {
+	return &Screener{market: market, logger: logger, now: time.Now, settings: settings}
+}
+
+// Reload applies the screens of a new configuration
+func (s *Screener) Reload(settings config.ScreenerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = settings
+}
+
+// Names returns the configured screens in alphabetical order
+func (s *Screener) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.settings.Rules))
+	for name := range s.settings.Rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rules returns the rules of a screen, nil if it is not configured
+func (s *Screener) Rules(name string) []config.ScreenRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings.Rules[name]
+}
+
+// Default returns the screen whose matches are the allowed opportunities, empty if there is none
+func (s *Screener) Default() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings.Default
+}
+
+// Run screens the tradable instruments. Rules on instrument data are checked first,
+// so candles are only loaded for the instruments that pass them, and dividends only
+// for those that pass every other rule of a screen that uses the dividend yield.
+func (s *Screener) Run(ctx context.Context, name string) (*Result, error) {
+	rules := s.Rules(name)
+	if rules == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownScreen, name)
+	}
+
+	listed, err := s.tradable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var textRules, marketRules, yieldRules []config.ScreenRule
+	for _, rule := range rules {
+		switch {
+		case !rule.Numeric():
+			textRules = append(textRules, rule)
+		case rule.Metric == config.MetricDividendYield:
+			yieldRules = append(yieldRules, rule)
+		default:
+			marketRules = append(marketRules, rule)
+		}
+	}
+
+	result := &Result{Screen: name, Rules: rules}
+	failed := 0
+	for _, instr := range listed {
+		if !matchText(textRules, instr) {
+			continue
+		}
+		result.Scanned++
+
+		metrics, err := s.marketMetrics(ctx, instr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("screen %q interrupted after %d instruments: %w", name, result.Scanned, ctx.Err())
+			}
+			s.logger.DebugContext(ctx, "Failed to get candles for screening", "ticker", instr.Ticker, "error", err)
+			failed++
+			continue
+		}
+		if !matchNumbers(marketRules, metrics) {
+			continue
+		}
+
+		if len(yieldRules) > 0 {
+			yield, err := s.dividendYield(ctx, instr, metrics[config.MetricPrice])
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, fmt.Errorf("screen %q interrupted after %d instruments: %w", name, result.Scanned, ctx.Err())
+				}
+				s.logger.DebugContext(ctx, "Failed to get dividends for screening", "ticker", instr.Ticker, "error", err)
+				failed++
+				continue
+			}
+			metrics[config.MetricDividendYield] = yield
+			if !matchNumbers(yieldRules, metrics) {
+				continue
+			}
+		}
+		result.Matches = append(result.Matches, Match{Instrument: instr, Metrics: metrics})
+	}
+
+	if failed > 0 {
+		s.logger.WarnContext(ctx, "Skipped instruments without market data", "screen", name, "skipped", failed)
+	}
+	sort.SliceStable(result.Matches, func(i, j int) bool {
+		return result.Matches[i].Metrics[config.MetricTurnover] > result.Matches[j].Metrics[config.MetricTurnover]
+	})
+	return result, nil
+}
+
+// Include runs the default screen and adds its matches that are not held to the
+// portfolio as the candidates for opportunities. Without a default screen the
+// portfolio is left unchanged. A failed screen is logged and leaves no candidates,
+// so the analysis suggests no opportunities rather than unscreened ones.
+func (s *Screener) Include(ctx context.Context, portfolio *invest.Portfolio) {
+	s.mu.Lock()
+	name, limit := s.settings.Default, s.settings.MaxCandidates
+	s.mu.Unlock()
+	if name == "" {
+		return
+	}
+	portfolio.Screen = name
+
+	ctx, cancel := context.WithTimeout(ctx, includeTimeout)
+	defer cancel()
+	result, err := s.Run(ctx, name)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Failed to run the screen, continuing without opportunity candidates", "screen", name, "error", err)
+		return
+	}
+
+	for _, m := range result.Matches {
+		if len(portfolio.Candidates) >= limit {
+			break
+		}
+		if portfolio.FindPosition(m.Instrument.Ticker) != nil {
+			continue
+		}
+		portfolio.Candidates = append(portfolio.Candidates, invest.Quote{
+			FIGI:     m.Instrument.FIGI,
+			Ticker:   m.Instrument.Ticker,
+			Name:     m.Instrument.Name,
+			Type:     m.Instrument.Type,
+			Currency: m.Instrument.Currency,
+			Price:    m.Metrics[config.MetricPrice],
+			Metrics:  m.Metrics,
+		})
+	}
+	s.logger.InfoContext(ctx, "Screened opportunity candidates", "screen", name,
+		"scanned", result.Scanned, "matches", len(result.Matches), "candidates", len(portfolio.Candidates))
+}
+
+// tradable returns the tradable instruments, listing them once a day
+func (s *Screener) tradable(ctx context.Context) ([]invest.Instrument, error) {
+	s.mu.Lock()
+	s.resetDay()
+	listed := s.listed
+	s.mu.Unlock()
+	if listed != nil {
+		return listed, nil
+	}
+
+	listed, err := s.market.ListTradable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.listed = listed
+	s.mu.Unlock()
+	return listed, nil
+}
+
+// marketMetrics returns the candle metrics of an instrument, cached for the day.
+// The returned map is a copy the caller may extend.
+func (s *Screener) marketMetrics(ctx context.Context, instr invest.Instrument) (Metrics, error) {
+	s.mu.Lock()
+	cached, ok := s.metrics[instr.FIGI]
+	s.mu.Unlock()
+
+	if !ok {
+		now := s.now()
+		candles, err := s.market.GetDailyCandles(ctx, instr.FIGI, now.Add(-historyPeriod), now)
+		if err != nil {
+			return nil, err
+		}
+		cached = marketMetrics(candles, instr.Lot, now)
+		s.mu.Lock()
+		s.metrics[instr.FIGI] = cached
+		s.mu.Unlock()
+	}
+
+	metrics := make(Metrics, len(cached)+1)
+	for k, v := range cached {
+		metrics[k] = v
+	}
+	return metrics, nil
+}
+
+// dividendYield returns the trailing dividend yield of an instrument, cached for the day.
+// ETFs reinvest their income and are not asked for dividends.
+func (s *Screener) dividendYield(ctx context.Context, instr invest.Instrument, price float64) (float64, error) {
+	if instr.Type == "etf" {
+		return 0, nil
+	}
+
+	s.mu.Lock()
+	yield, ok := s.yields[instr.FIGI]
+	s.mu.Unlock()
+	if ok {
+		return yield, nil
+	}
+
+	now := s.now()
+	dividends, err := s.market.GetDividends(ctx, instr.FIGI, now.Add(-dividendPeriod), now)
+	if err != nil {
+		return 0, err
+	}
+	yield = dividendYield(dividends, instr.Currency, price, now)
+	s.mu.Lock()
+	s.yields[instr.FIGI] = yield
+	s.mu.Unlock()
+	return yield, nil
+}
+
+// resetDay drops the cached data of a previous day; the caller holds the lock
+func (s *Screener) resetDay() {
+	day := s.now().Format(time.DateOnly)
+	if s.day == day {
+		return
+	}
+	s.day = day
+	s.listed = nil
+	s.metrics = make(map[string]Metrics)
+	s.yields = make(map[string]float64)
+}
+
+// matchText checks the rules on instrument data
+func matchText(rules []config.ScreenRule, instr invest.Instrument) bool {
+	for _, rule := range rules {
+		var value string
+		switch rule.Metric {
+		case config.MetricType:
+			value = instr.Type
+		case config.MetricSector:
+			value = instr.Sector
+		}
+		equal := strings.EqualFold(value, rule.Text)
+		if equal != (rule.Op == "=") {
+			return false
+		}
+	}
+	return true
+}
+
+// matchNumbers checks numeric rules; a metric that is missing fails its rule
+func matchNumbers(rules []config.ScreenRule, metrics Metrics) bool {
+	for _, rule := range rules {
+		value, ok := metrics[rule.Metric]
+		if !ok || !compare(value, rule.Op, rule.Number) {
+			return false
+		}
+	}
+	return true
+}
+
+// compare applies a rule operator
+func compare(value float64, op string, number float64) bool {
+	switch op {
+	case ">":
+		return value > number
+	case ">=":
+		return value >= number
+	case "<":
+		return value < number
+	case "<=":
+		return value <= number
+	case "=":
+		return value == number
+	case "!=":
+		return value != number
+	}
+	return false
+}
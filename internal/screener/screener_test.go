@@ -0,0 +1,217 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"invest-manager/internal/config"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"math"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+// dailyCandles builds candles of the last days from the closes, oldest first
+func dailyCandles(volume int64, closes ...float64) []invest.Candle {
+	candles := make([]invest.Candle, len(closes))
+	for i, c := range closes {
+		day := testNow.AddDate(0, 0, i-len(closes)+1)
+		candles[i] = invest.Candle{Time: day, Open: c, High: c, Low: c, Close: c, Volume: volume}
+	}
+	return candles
+}
+
+// trend returns n closes from start changing by step
+func trend(n int, start, step float64) []float64 {
+	closes := make([]float64, n)
+	for i := range closes {
+		closes[i] = start + float64(i)*step
+	}
+	return closes
+}
+
+func TestRelativeStrength(t *testing.T) {
+	tests := []struct {
+		name   string
+		closes []float64
+		want   float64
+		ok     bool
+	}{
+		{"too short", trend(14, 100, 1), 0, false},
+		{"only gains", trend(20, 100, 1), 100, true},
+		{"only losses", trend(20, 100, -1), 0, true},
+		{"balanced", []float64{10, 11, 10, 11, 10, 11, 10, 11, 10, 11, 10, 11, 10, 11, 10}, 50, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := relativeStrength(tt.closes, rsiPeriod)
+			if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("relativeStrength = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMarketMetrics(t *testing.T) {
+	// 40 sessions: the turnover averages the last 20, the change starts 30 days back
+	candles := dailyCandles(1000, trend(40, 100, 1)...)
+	m := marketMetrics(candles, 10, testNow)
+
+	if m[config.MetricPrice] != 139 {
+		t.Errorf("price = %v, want 139", m[config.MetricPrice])
+	}
+	// Closes 120..139 average 129.5, times 1000 lots of 10
+	if want := 129.5 * 10000; math.Abs(m[config.MetricTurnover]-want) > 1e-6 {
+		t.Errorf("turnover = %v, want %v", m[config.MetricTurnover], want)
+	}
+	if want := (139.0 - 109.0) / 109.0 * 100; math.Abs(m[config.MetricChange]-want) > 1e-9 {
+		t.Errorf("change = %v, want %v", m[config.MetricChange], want)
+	}
+	if m[config.MetricRSI] != 100 {
+		t.Errorf("rsi = %v, want 100 for a steady rise", m[config.MetricRSI])
+	}
+
+	if m := marketMetrics(dailyCandles(1, 100, 101), 1, testNow); len(m) != 3 {
+		t.Errorf("metrics of a fresh listing = %v, want price, turnover and change without RSI", m)
+	}
+}
+
+func TestDividendYield(t *testing.T) {
+	dividends := []invest.Dividend{
+		{Amount: 10, Currency: "RUB", RecordDate: testNow.AddDate(0, -2, 0)},
+		{Amount: 5, Currency: "RUB", RecordDate: testNow.AddDate(0, -11, 0)},
+		{Amount: 7, Currency: "RUB", RecordDate: testNow.AddDate(-1, -1, 0)}, // older than a year
+		{Amount: 9, Currency: "RUB", RecordDate: testNow.AddDate(0, 1, 0)},   // declared, not yet recorded
+		{Amount: 1, Currency: "USD", RecordDate: testNow.AddDate(0, -1, 0)},  // another currency
+	}
+	if got := dividendYield(dividends, "RUB", 150, testNow); math.Abs(got-10) > 1e-9 {
+		t.Errorf("dividendYield = %v, want 10", got)
+	}
+	if got := dividendYield(dividends, "RUB", 0, testNow); got != 0 {
+		t.Errorf("dividendYield without a price = %v, want 0", got)
+	}
+}
+
+// testMarket lists a liquid dividend payer, an illiquid one, a falling ETF and
+// a share without candles
+func testMarket() *fake.Broker {
+	return &fake.Broker{
+		Listed: []invest.Instrument{
+			{FIGI: "sber", Ticker: "SBER", Name: "Сбербанк", Type: "share", Sector: "financial", Currency: "RUB", Lot: 10},
+			{FIGI: "mrkc", Ticker: "MRKC", Name: "Россети Центр", Type: "share", Sector: "utilities", Currency: "RUB", Lot: 10000},
+			{FIGI: "tmos", Ticker: "TMOS", Name: "Т-Капитал Индекс", Type: "etf", Currency: "RUB", Lot: 1},
+			{FIGI: "new", Ticker: "NEWS", Name: "Новичок", Type: "share", Sector: "it", Currency: "RUB", Lot: 1},
+		},
+		Candles: map[string][]invest.Candle{
+			"sber": dailyCandles(100000, trend(40, 340, -1)...),
+			"mrkc": dailyCandles(10, trend(40, 1, -0.001)...),
+			"tmos": dailyCandles(1000000, trend(40, 8, -0.01)...),
+		},
+		Dividends: map[string][]invest.Dividend{
+			"sber": {{Amount: 34.84, Currency: "RUB", RecordDate: testNow.AddDate(0, -8, 0)}},
+			"mrkc": {{Amount: 0.1, Currency: "RUB", RecordDate: testNow.AddDate(0, -8, 0)}},
+		},
+	}
+}
+
+func testScreener(market Market, screens map[string][]string, defaultScreen string) *Screener {
+	settings := config.ScreenerConfig{Default: defaultScreen, MaxCandidates: 1, Rules: map[string][]config.ScreenRule{}}
+	for name, rules := range screens {
+		for _, r := range rules {
+			rule, err := config.ParseScreenRule(r)
+			if err != nil {
+				panic(err)
+			}
+			settings.Rules[name] = append(settings.Rules[name], rule)
+		}
+	}
+	s := New(settings, market, logging.Discard())
+	s.now = func() time.Time { return testNow }
+	return s
+}
+
+func TestRun(t *testing.T) {
+	s := testScreener(testMarket(), map[string][]string{
+		"dividends": {"dividend_yield > 8%", "rsi < 30"},
+		"liquid":    {"turnover > 1M", "type != etf"},
+		"funds":     {"type = etf", "change < 0"},
+	}, "")
+	ctx := context.Background()
+
+	tests := []struct {
+		screen  string
+		scanned int
+		want    []string
+	}{
+		// SBER yields 34.84 / 301 > 8%, MRKC 0.1 / 0.961 > 8%; both keep falling
+		{"dividends", 4, []string{"SBER", "MRKC"}},
+		{"liquid", 3, []string{"SBER"}},
+		{"funds", 1, []string{"TMOS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.screen, func(t *testing.T) {
+			result, err := s.Run(ctx, tt.screen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range result.Matches {
+				got = append(got, m.Instrument.Ticker)
+			}
+			if result.Scanned != tt.scanned || len(got) != len(tt.want) {
+				t.Fatalf("scanned %d, matches %v, want %d and %v", result.Scanned, got, tt.scanned, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("matches = %v, want %v, the most traded first", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := s.Run(ctx, "missing"); !errors.Is(err, ErrUnknownScreen) {
+		t.Errorf("Run of a missing screen error = %v, want ErrUnknownScreen", err)
+	}
+}
+
+func TestIncludeSkipsHeldAndLimits(t *testing.T) {
+	market := testMarket()
+	s := testScreener(market, map[string][]string{"dividends": {"dividend_yield > 8%"}}, "dividends")
+	ctx := context.Background()
+
+	portfolio := &invest.Portfolio{Positions: []invest.Position{{Ticker: "SBER"}}}
+	s.Include(ctx, portfolio)
+	if portfolio.Screen != "dividends" || len(portfolio.Candidates) != 1 || portfolio.Candidates[0].Ticker != "MRKC" {
+		t.Fatalf("screen %q, candidates %+v, want MRKC only; SBER is held", portfolio.Screen, portfolio.Candidates)
+	}
+	if c := portfolio.Candidates[0]; math.Abs(c.Price-0.961) > 1e-9 || c.Metrics[config.MetricDividendYield] == 0 {
+		t.Errorf("candidate = %+v, want the last close and the screened metrics", c)
+	}
+
+	// The data is cached for the day, so a failing broker does not change the outcome
+	market.Err = errors.New("unavailable")
+	portfolio = &invest.Portfolio{}
+	s.Include(ctx, portfolio)
+	if len(portfolio.Candidates) != 1 || portfolio.Candidates[0].Ticker != "SBER" {
+		t.Errorf("cached candidates = %+v, want SBER", portfolio.Candidates)
+	}
+
+	// A failed screen leaves no candidates rather than lifting the restriction
+	s.now = func() time.Time { return testNow.AddDate(0, 0, 1) }
+	portfolio = &invest.Portfolio{}
+	s.Include(ctx, portfolio)
+	if portfolio.Screen != "dividends" || len(portfolio.Candidates) != 0 {
+		t.Errorf("screen %q, candidates %+v after a failure, want the screen without candidates", portfolio.Screen, portfolio.Candidates)
+	}
+
+	// Without a default screen the opportunities are not screened
+	s.Reload(config.ScreenerConfig{MaxCandidates: 1})
+	portfolio = &invest.Portfolio{}
+	s.Include(ctx, portfolio)
+	if portfolio.Screen != "" {
+		t.Errorf("screen = %q without a default", portfolio.Screen)
+	}
+}
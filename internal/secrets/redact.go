@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secret values in logs and printed configuration
+const Redacted = "[REDACTED]"
+
+// minRedactLength keeps very short values from mangling unrelated log text
+const minRedactLength = 4
+
+// Redactor is an io.Writer that masks known secret values before passing output on.
+// Values can be added after creation, e.g. once the configuration is loaded.
+type Redactor struct {
+	out      io.Writer
+	mu       sync.RWMutex
+	replacer *strings.Replacer
+	masks    map[string]string // value to its replacement
+}
+
+// NewRedactor wraps a writer, typically the log output
+func NewRedactor(out io.Writer) *Redactor {
+	return &Redactor{out: out, masks: make(map[string]string)}
+}
+
+// Add registers secret values to be masked
+func (r *Redactor) Add(values ...string) {
+	for _, value := range values {
+		r.AddMasked(value, Redacted)
+	}
+}
+
+// AddMasked registers a value to be replaced with the given mask,
+// e.g. an account ID that should stay recognizable by its last digits
+func (r *Redactor) AddMasked(value, mask string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(value) < minRedactLength {
+		return
+	}
+	r.masks[value] = mask
+
+	// Replace longer values first in case one secret contains another
+	values := make([]string, 0, len(r.masks))
+	for v := range r.masks {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, r.masks[v])
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact masks known secrets in a string
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Write implements io.Writer
+func (r *Redactor) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.out, r.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	// Report the original length, since callers don't expect the output to be rewritten
+	return len(p), nil
+}
@@ -0,0 +1,231 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invest-manager/internal/atomicfile"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// vaultVersion is the version of the vault file format
+const vaultVersion = 1
+
+// Default scrypt parameters for deriving the vault key
+const (
+	scryptN   = 1 << 15
+	scryptR   = 8
+	scryptP   = 1
+	keyLength = 32 // AES-256
+	saltSize  = 16
+)
+
+// ErrWrongKey is returned when the vault cannot be decrypted with the given passphrase or key file
+var ErrWrongKey = errors.New("wrong vault passphrase or key file")
+
+// Entry is a single secret stored in the vault
+type Entry struct {
+	Value   string    `json:"value"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Vault is a set of named secrets stored in an encrypted file
+type Vault struct {
+	entries map[string]Entry
+	now     func() time.Time
+}
+
+// vaultFile is the on-disk form of the vault; only ciphertext carries secret data
+type vaultFile struct {
+	Version    int       `json:"version"`
+	KDF        kdfParams `json:"kdf"`
+	Salt       []byte    `json:"salt"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+}
+
+// kdfParams records how the encryption key was derived from the passphrase
+type kdfParams struct {
+	Name string `json:"name"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// New returns an empty vault
+func New() *Vault {
+	return &Vault{entries: make(map[string]Entry), now: time.Now}
+}
+
+// Open reads and decrypts the vault at path.
+// If the file does not exist, the returned error wraps fs.ErrNotExist.
+func Open(path string, passphrase []byte) (*Vault, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("vault passphrase or key file is not set")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse vault %s: %w", path, err)
+	}
+	if file.Version != vaultVersion {
+		return nil, fmt.Errorf("unsupported vault version %d", file.Version)
+	}
+	if file.KDF.Name != "scrypt" {
+		return nil, fmt.Errorf("unsupported vault key derivation %q", file.KDF.Name)
+	}
+
+	// Derive the key with the parameters stored in the file
+	key, err := scrypt.Key(passphrase, file.Salt, file.KDF.N, file.KDF.R, file.KDF.P, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive vault key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid vault nonce size %d", len(file.Nonce))
+	}
+
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, additionalData(file))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+
+	v := New()
+	if err := json.Unmarshal(plaintext, &v.entries); err != nil {
+		return nil, fmt.Errorf("failed to decode vault entries: %w", err)
+	}
+	return v, nil
+}
+
+// Save encrypts the vault with a fresh salt and nonce and atomically writes it to path
+func (v *Vault) Save(path string, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("vault passphrase or key file is not set")
+	}
+
+	file := vaultFile{
+		Version: vaultVersion,
+		KDF:     kdfParams{Name: "scrypt", N: scryptN, R: scryptR, P: scryptP},
+		Salt:    make([]byte, saltSize),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := scrypt.Key(passphrase, file.Salt, file.KDF.N, file.KDF.R, file.KDF.P, keyLength)
+	if err != nil {
+		return fmt.Errorf("failed to derive vault key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	plaintext, err := json.Marshal(v.entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode vault entries: %w", err)
+	}
+	file.Ciphertext = gcm.Seal(nil, file.Nonce, plaintext, additionalData(file))
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode vault: %w", err)
+	}
+	if err := atomicfile.Write(path, data); err != nil {
+		return fmt.Errorf("failed to save vault: %w", err)
+	}
+	return nil
+}
+
+// Get returns the value of a secret
+func (v *Vault) Get(name string) (string, bool) {
+	entry, ok := v.entries[name]
+	return entry.Value, ok
+}
+
+// Entry returns a secret together with its timestamps
+func (v *Vault) Entry(name string) (Entry, bool) {
+	entry, ok := v.entries[name]
+	return entry, ok
+}
+
+// Set stores a secret, keeping the creation time of an existing entry
+func (v *Vault) Set(name, value string) {
+	now := v.now().UTC()
+	entry, ok := v.entries[name]
+	if !ok {
+		entry.Created = now
+	}
+	entry.Value = value
+	entry.Updated = now
+	v.entries[name] = entry
+}
+
+// Delete removes a secret and reports whether it existed
+func (v *Vault) Delete(name string) bool {
+	_, ok := v.entries[name]
+	delete(v.entries, name)
+	return ok
+}
+
+// Names returns the names of all secrets in sorted order
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.entries))
+	for name := range v.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadKeyFile reads a key file, ignoring surrounding whitespace
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault key file: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("vault key file %s is empty", path)
+	}
+	return key, nil
+}
+
+// newGCM creates an AES-GCM cipher for the derived key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault cipher: %w", err)
+	}
+	return gcm, nil
+}
+
+// additionalData binds the ciphertext to the format and KDF parameters, so they cannot be swapped
+func additionalData(file vaultFile) []byte {
+	return []byte(fmt.Sprintf("invest-manager-vault:v%d:%s:%d:%d:%d", file.Version, file.KDF.Name, file.KDF.N, file.KDF.R, file.KDF.P))
+}
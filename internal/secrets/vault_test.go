@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	passphrase := []byte("correct horse battery staple")
+
+	v := New()
+	v.Set("tinkoff.token", "t.secret-token")
+	v.Set("news.api_token", "news-key")
+	if err := v.Save(path, passphrase); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret-token")) || bytes.Contains(data, []byte("tinkoff.token")) {
+		t.Fatal("vault file contains plaintext")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("vault file should be readable by the owner only, got %v", info.Mode())
+	}
+
+	opened, err := Open(path, passphrase)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if got, ok := opened.Get("tinkoff.token"); !ok || got != "t.secret-token" {
+		t.Errorf("Get = %q, %v", got, ok)
+	}
+	if names := opened.Names(); strings.Join(names, ",") != "news.api_token,tinkoff.token" {
+		t.Errorf("Names = %v", names)
+	}
+
+	if _, err := Open(path, []byte("wrong")); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected ErrWrongKey, got %v", err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.json"), passphrase); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestVaultRotateKeepsCreated(t *testing.T) {
+	v := New()
+	v.Set("telegram.token", "old")
+	created, _ := v.Entry("telegram.token")
+
+	v.Set("telegram.token", "new")
+	entry, _ := v.Entry("telegram.token")
+	if entry.Value != "new" || !entry.Created.Equal(created.Created) {
+		t.Errorf("unexpected entry after rotation: %+v", entry)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRedactor(&out)
+	r.Write([]byte("before add: abcdef\n"))
+
+	r.Add("abcdef", "abcdefgh", "ab", "")
+	r.Write([]byte("token abcdefgh and abcdef, ab stays\n"))
+
+	want := "before add: abcdef\ntoken [REDACTED] and [REDACTED], ab stays\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,265 @@
+package stops
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Monitor settings
+const (
+	checkTimeout   = 30 * time.Second
+	ordersLookback = 90 * 24 * time.Hour // followed stop orders older than this are not looked up
+)
+
+// Broker supplies positions, prices and stop orders; *invest.Client is the production one
+type Broker interface {
+	GetPortfolio(ctx context.Context) (*invest.Portfolio, error)
+	GetLastPrices(ctx context.Context, figis []string) (map[string]float64, error)
+	GetInstrument(ctx context.Context, figi string) (*invest.Instrument, error)
+	GetStopOrders(ctx context.Context, from time.Time) ([]invest.StopOrder, error)
+}
+
+// Notifier delivers alerts; *telegram.Bot is the production one
+type Notifier interface {
+	SendStopAlert(alert Alert) error
+	SendStopOrderUpdate(stop Stop, order invest.StopOrder) error
+}
+
+// Alert reports a level reached by the last price
+type Alert struct {
+	Stop     Stop
+	Side     string  // SideLoss or SideTake
+	Level    float64 // price of the level
+	Price    float64 // last price
+	Position invest.Position
+}
+
+// Monitor checks the levels against the last prices at the configured interval
+// and reports the followed stop orders that were executed, cancelled or expired
+type Monitor struct {
+	book     *Book
+	broker   Broker
+	notifier Notifier
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	interval time.Duration
+}
+
+// NewMonitor creates a monitor of the levels in the book
+func NewMonitor(book *Book, broker Broker, notifier Notifier, settings config.StopsConfig, logger *slog.Logger) *Monitor {
+	m := &Monitor{book: book, broker: broker, notifier: notifier, logger: logger}
+	m.Reload(settings)
+	return m
+}
+
+// Reload applies the check interval of a new configuration from the next check on
+func (m *Monitor) Reload(settings config.StopsConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.interval = time.Duration(settings.CheckMinutes) * time.Minute
+}
+
+// Run checks the levels until the context is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	for {
+		m.mu.Lock()
+		timer := time.NewTimer(m.interval)
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := m.Check(ctx); err != nil {
+			m.logger.WarnContext(ctx, "Failed to check stop levels", "error", err)
+		}
+	}
+}
+
+// Check compares the levels with the last prices once and sends an alert for every level
+// reached since the previous check. A level alerts again only after the price has moved back.
+func (m *Monitor) Check(ctx context.Context) error {
+	stops := m.book.Items()
+	if len(stops) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	portfolio, err := m.broker.GetPortfolio(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get portfolio: %w", err)
+	}
+	figis := make([]string, 0, len(stops))
+	for _, s := range stops {
+		figis = append(figis, s.FIGI)
+	}
+	prices, err := m.broker.GetLastPrices(ctx, figis)
+	if err != nil {
+		return fmt.Errorf("failed to get last prices: %w", err)
+	}
+	m.quoteValues(ctx, portfolio, prices)
+
+	alerts, err := m.book.observe(portfolio, prices)
+	if err != nil {
+		m.logger.WarnContext(ctx, "Failed to save stop levels", "error", err)
+	}
+	for _, alert := range alerts {
+		m.logger.InfoContext(ctx, "Stop level reached", "ticker", alert.Stop.Ticker, "side", alert.Side,
+			"level", alert.Level, "price", alert.Price)
+		if err := m.notifier.SendStopAlert(alert); err != nil {
+			m.logger.WarnContext(ctx, "Failed to send stop alert", "ticker", alert.Stop.Ticker, "error", err)
+		}
+	}
+
+	return m.followOrders(ctx, stops)
+}
+
+// quoteValues converts the last prices of bonds from percent of the nominal to money per bond,
+// the unit of the average price the levels are set from. A bond whose nominal is unknown loses
+// its last price, so the check falls back to the portfolio price.
+func (m *Monitor) quoteValues(ctx context.Context, portfolio *invest.Portfolio, prices map[string]float64) {
+	for _, pos := range portfolio.Positions {
+		quote, ok := prices[pos.FIGI]
+		if !ok || pos.InstrumentType != "bond" {
+			continue
+		}
+		instr, err := m.broker.GetInstrument(ctx, pos.FIGI)
+		if err != nil || instr.Nominal <= 0 {
+			m.logger.WarnContext(ctx, "Could not get the nominal of a bond", "ticker", pos.Ticker, "error", err)
+			delete(prices, pos.FIGI)
+			continue
+		}
+		prices[pos.FIGI] = instr.QuoteValue(quote)
+	}
+}
+
+// followOrders reports the followed stop orders that are no longer active and stops following them
+func (m *Monitor) followOrders(ctx context.Context, stops []Stop) error {
+	followed := false
+	for _, s := range stops {
+		followed = followed || len(s.Orders) > 0
+	}
+	if !followed {
+		return nil
+	}
+
+	orders, err := m.broker.GetStopOrders(ctx, time.Now().Add(-ordersLookback))
+	if err != nil {
+		return fmt.Errorf("failed to get stop orders: %w", err)
+	}
+	byID := make(map[string]invest.StopOrder, len(orders))
+	for _, o := range orders {
+		byID[o.ID] = o
+	}
+
+	for _, s := range stops {
+		for _, id := range s.Orders {
+			order, ok := byID[id]
+			if !ok || order.Status == invest.StopOrderActive {
+				continue
+			}
+			m.logger.InfoContext(ctx, "Stop order finished", "ticker", s.Ticker, "stop_order_id", id, "status", order.Status)
+			if err := m.notifier.SendStopOrderUpdate(s, order); err != nil {
+				// Keep following it, so the update is sent on the next check
+				m.logger.WarnContext(ctx, "Failed to send stop order update", "ticker", s.Ticker, "error", err)
+				continue
+			}
+			if err := m.book.dropOrder(s.Ticker, id); err != nil {
+				m.logger.WarnContext(ctx, "Failed to save stop levels", "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// observe updates the trailing highs and alert flags with the last prices and returns
+// the levels reached since the previous observation. Levels of positions that are no
+// longer held are skipped; a missing last price falls back to the portfolio price.
+func (b *Book) observe(portfolio *invest.Portfolio, prices map[string]float64) ([]Alert, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var alerts []Alert
+	stops := make([]Stop, len(b.stops))
+	changed := false
+	for i, s := range b.stops {
+		s = s.clone()
+		stops[i] = s
+
+		pos := portfolio.FindPosition(s.Ticker)
+		if pos == nil {
+			continue
+		}
+		price := prices[s.FIGI]
+		if price <= 0 {
+			price = pos.CurrentPrice
+		}
+		if price <= 0 {
+			continue
+		}
+
+		before := s
+		if s.Loss != nil && s.Loss.Kind == KindTrailing && price > s.High {
+			s.High = price
+		}
+		if level := s.LossPrice(pos.AveragePrice); level > 0 {
+			reached := price <= level
+			if reached && !s.LossHit {
+				alerts = append(alerts, Alert{Stop: s, Side: SideLoss, Level: level, Price: price, Position: *pos})
+			}
+			s.LossHit = reached
+		}
+		if level := s.TakePrice(pos.AveragePrice); level > 0 {
+			reached := price >= level
+			if reached && !s.TakeHit {
+				alerts = append(alerts, Alert{Stop: s, Side: SideTake, Level: level, Price: price, Position: *pos})
+			}
+			s.TakeHit = reached
+		}
+		if s.High != before.High || s.LossHit != before.LossHit || s.TakeHit != before.TakeHit {
+			changed = true
+		}
+		stops[i] = s
+	}
+
+	if !changed {
+		return alerts, nil
+	}
+	previous := b.stops
+	b.stops = stops
+	if err := b.save(); err != nil {
+		b.stops = previous
+		return alerts, err
+	}
+	return alerts, nil
+}
+
+// dropOrder stops following a broker stop order
+func (b *Book) dropOrder(ticker, orderID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(ticker)
+	if i < 0 {
+		return nil
+	}
+	s := b.stops[i].clone()
+	kept := s.Orders[:0]
+	for _, id := range s.Orders {
+		if !strings.EqualFold(id, orderID) {
+			kept = append(kept, id)
+		}
+	}
+	s.Orders = kept
+	return b.update(i, s)
+}
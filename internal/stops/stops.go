@@ -0,0 +1,296 @@
+// Package stops keeps the stop-loss and take-profit levels of the positions,
+// checks them against the last prices and follows the stop orders placed for them.
+package stops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invest-manager/internal/atomicfile"
+	"invest-manager/internal/invest"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoLevels is returned for a ticker without levels
+var ErrNoLevels = errors.New("no stop levels")
+
+// Level kinds
+const (
+	KindPrice    = "price"    // an absolute price
+	KindPercent  = "percent"  // percent from the average price of the position
+	KindTrailing = "trailing" // percent below the highest price since the level was set, stop-loss only
+)
+
+// Sides of a position's levels
+const (
+	SideLoss = "loss"
+	SideTake = "take"
+)
+
+// Level is a stop-loss or take-profit level
+type Level struct {
+	Kind  string  `json:"kind"`
+	Value float64 `json:"value"` // the price, or the percent of percent and trailing levels
+}
+
+// String formats the level the way it is entered
+func (l Level) String() string {
+	switch l.Kind {
+	case KindPercent:
+		return fmt.Sprintf("%g%%", l.Value)
+	case KindTrailing:
+		return fmt.Sprintf("trail %g%%", l.Value)
+	}
+	return fmt.Sprintf("%g", l.Value)
+}
+
+// ParseLevel parses an absolute price such as "280" or a percent such as "5%".
+// With trailing set, the value must be a percent.
+func ParseLevel(s string, trailing bool) (Level, error) {
+	value := strings.TrimSuffix(strings.TrimSpace(s), "%")
+	percent := value != strings.TrimSpace(s)
+	number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if err != nil || number <= 0 {
+		return Level{}, fmt.Errorf("%q is not a positive number", s)
+	}
+
+	switch {
+	case trailing && !percent:
+		return Level{}, fmt.Errorf("a trailing level is a percent, e.g. 7%%, got %q", s)
+	case trailing:
+		if number >= 100 {
+			return Level{}, fmt.Errorf("a trailing level must be below 100%%, got %q", s)
+		}
+		return Level{Kind: KindTrailing, Value: number}, nil
+	case percent:
+		return Level{Kind: KindPercent, Value: number}, nil
+	}
+	return Level{Kind: KindPrice, Value: number}, nil
+}
+
+// Stop holds the levels of a position
+type Stop struct {
+	FIGI   string `json:"figi"`
+	Ticker string `json:"ticker"`
+	Name   string `json:"name"`
+	Loss   *Level `json:"loss,omitempty"`
+	Take   *Level `json:"take,omitempty"`
+
+	High    float64  `json:"high,omitempty"`     // highest price seen since a trailing stop-loss was set
+	LossHit bool     `json:"loss_hit,omitempty"` // alerted, until the price moves back above the level
+	TakeHit bool     `json:"take_hit,omitempty"` // alerted, until the price moves back below the level
+	Orders  []string `json:"orders,omitempty"`   // broker stop orders placed for the levels, followed until final
+}
+
+// LossPrice returns the stop-loss price for the average price of the position, 0 without a stop-loss
+func (s *Stop) LossPrice(average float64) float64 {
+	if s.Loss == nil {
+		return 0
+	}
+	switch s.Loss.Kind {
+	case KindPercent:
+		return average * (1 - s.Loss.Value/100)
+	case KindTrailing:
+		return s.High * (1 - s.Loss.Value/100)
+	}
+	return s.Loss.Value
+}
+
+// TakePrice returns the take-profit price for the average price of the position, 0 without a take-profit
+func (s *Stop) TakePrice(average float64) float64 {
+	if s.Take == nil {
+		return 0
+	}
+	if s.Take.Kind == KindPercent {
+		return average * (1 + s.Take.Value/100)
+	}
+	return s.Take.Value
+}
+
+// Book keeps the levels of the positions. It is safe for concurrent use.
+type Book struct {
+	path   string
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	stops []Stop
+}
+
+// Open reads the levels from their file, which is created by the first change.
+// With an empty path the levels are only kept in memory.
+func Open(path string, logger *slog.Logger) (*Book, error) {
+	b := &Book{path: path, logger: logger}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stop levels: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.stops); err != nil {
+		return nil, fmt.Errorf("failed to parse stop levels %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Items returns the levels in the order they were set
+func (b *Book) Items() []Stop {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	items := make([]Stop, len(b.stops))
+	for i, s := range b.stops {
+		items[i] = s.clone()
+	}
+	return items
+}
+
+// Find returns the levels of a ticker (case-insensitive), nil if there are none
+func (b *Book) Find(ticker string) *Stop {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i := b.index(ticker); i >= 0 {
+		s := b.stops[i].clone()
+		return &s
+	}
+	return nil
+}
+
+// Set sets the stop-loss or take-profit level of a held position. The level is checked
+// against the current price, so it cannot trigger right away.
+func (b *Book) Set(pos invest.Position, side string, level Level) (*Stop, error) {
+	if side == SideTake && level.Kind == KindTrailing {
+		return nil, errors.New("only a stop-loss can trail the price")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(pos.Ticker)
+	var s Stop
+	if i >= 0 {
+		s = b.stops[i].clone()
+	} else {
+		s = Stop{FIGI: pos.FIGI, Ticker: pos.Ticker, Name: pos.Name}
+	}
+
+	switch side {
+	case SideLoss:
+		s.Loss, s.LossHit = &level, false
+		if level.Kind == KindTrailing {
+			s.High = pos.CurrentPrice
+		}
+		if price := s.LossPrice(pos.AveragePrice); price >= pos.CurrentPrice {
+			return nil, fmt.Errorf("the stop-loss of %.2f is not below the current price of %.2f", price, pos.CurrentPrice)
+		}
+	case SideTake:
+		s.Take, s.TakeHit = &level, false
+		if price := s.TakePrice(pos.AveragePrice); price <= pos.CurrentPrice {
+			return nil, fmt.Errorf("the take-profit of %.2f is not above the current price of %.2f", price, pos.CurrentPrice)
+		}
+	default:
+		return nil, fmt.Errorf("unknown side %q", side)
+	}
+
+	if err := b.update(i, s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Remove deletes the levels of a ticker; the broker stop orders placed for them stay active
+func (b *Book) Remove(ticker string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(ticker)
+	if i < 0 {
+		return fmt.Errorf("%s has %w", strings.ToUpper(ticker), ErrNoLevels)
+	}
+	previous := b.stops
+	b.stops = append(append([]Stop(nil), b.stops[:i]...), b.stops[i+1:]...)
+	if err := b.save(); err != nil {
+		b.stops = previous
+		return err
+	}
+	return nil
+}
+
+// AddOrders records broker stop orders placed for the levels of a ticker, so they are followed
+func (b *Book) AddOrders(ticker string, orderIDs ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(ticker)
+	if i < 0 {
+		return fmt.Errorf("%s has %w", strings.ToUpper(ticker), ErrNoLevels)
+	}
+	s := b.stops[i].clone()
+	s.Orders = append(s.Orders, orderIDs...)
+	return b.update(i, s)
+}
+
+// index finds the levels of a ticker; the caller must hold the lock
+func (b *Book) index(ticker string) int {
+	for i := range b.stops {
+		if strings.EqualFold(b.stops[i].Ticker, strings.TrimSpace(ticker)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// update replaces the levels at index i, or appends them if i is negative, and saves
+// the book, restoring it if saving fails. The caller must hold the lock.
+func (b *Book) update(i int, s Stop) error {
+	previous := b.stops
+	b.stops = append([]Stop(nil), b.stops...)
+	if i >= 0 {
+		b.stops[i] = s
+	} else {
+		b.stops = append(b.stops, s)
+	}
+	if err := b.save(); err != nil {
+		b.stops = previous
+		return err
+	}
+	return nil
+}
+
+// save writes the levels to their file.
+// The caller must hold the lock.
+func (b *Book) save() error {
+	if b.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(b.stops, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode stop levels: %w", err)
+	}
+	if err := atomicfile.Write(b.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to save stop levels: %w", err)
+	}
+	return nil
+}
+
+// clone copies the levels, so callers cannot change the book through the pointers
+func (s Stop) clone() Stop {
+	if s.Loss != nil {
+		loss := *s.Loss
+		s.Loss = &loss
+	}
+	if s.Take != nil {
+		take := *s.Take
+		s.Take = &take
+	}
+	s.Orders = append([]string(nil), s.Orders...)
+	return s
+}
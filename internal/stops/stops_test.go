@@ -0,0 +1,255 @@
+package stops
+
+import (
+	"context"
+	"errors"
+	"invest-manager/internal/config"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// The fake must stay usable in place of the production broker
+var (
+	_ Broker = (*fake.Broker)(nil)
+	_ Broker = (*invest.Client)(nil)
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in       string
+		trailing bool
+		want     Level
+		wantErr  bool
+	}{
+		{"280", false, Level{Kind: KindPrice, Value: 280}, false},
+		{"5%", false, Level{Kind: KindPercent, Value: 5}, false},
+		{"5,5%", false, Level{Kind: KindPercent, Value: 5.5}, false},
+		{"7%", true, Level{Kind: KindTrailing, Value: 7}, false},
+		{"280", true, Level{}, true},
+		{"100%", true, Level{}, true},
+		{"-5%", false, Level{}, true},
+		{"abc", false, Level{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in, tt.trailing)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLevel(%q, %v) = %v, %v, want %v, error %v", tt.in, tt.trailing, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// sber is a position bought at 300 and now priced at 320
+var sber = invest.Position{FIGI: "sber", Ticker: "SBER", Name: "Сбербанк", AveragePrice: 300, CurrentPrice: 320, Quantity: 20}
+
+func TestSetValidatesAgainstCurrentPrice(t *testing.T) {
+	book, _ := Open("", logging.Discard())
+
+	tests := []struct {
+		side  string
+		level Level
+		ok    bool
+	}{
+		{SideLoss, Level{Kind: KindPrice, Value: 280}, true},
+		{SideLoss, Level{Kind: KindPrice, Value: 330}, false},
+		{SideLoss, Level{Kind: KindPercent, Value: 5}, true}, // 285
+		{SideLoss, Level{Kind: KindTrailing, Value: 7}, true},
+		{SideTake, Level{Kind: KindPercent, Value: 5}, false}, // 315 is already passed
+		{SideTake, Level{Kind: KindPrice, Value: 350}, true},
+		{SideTake, Level{Kind: KindTrailing, Value: 7}, false},
+	}
+	for _, tt := range tests {
+		_, err := book.Set(sber, tt.side, tt.level)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(%s, %v) error = %v, want ok %v", tt.side, tt.level, err, tt.ok)
+		}
+	}
+
+	s := book.Find("sber")
+	if s == nil || s.Loss.Kind != KindTrailing || s.High != 320 || s.Take.Value != 350 {
+		t.Fatalf("levels = %+v, want the last accepted ones with the high seeded", s)
+	}
+	if got := s.LossPrice(sber.AveragePrice); math.Abs(got-297.6) > 1e-9 {
+		t.Errorf("trailing stop-loss price = %v, want 297.6", got)
+	}
+}
+
+func TestBookPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stops.json")
+	book, err := Open(path, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := book.Set(sber, SideLoss, Level{Kind: KindPercent, Value: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := book.AddOrders("SBER", "stop-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := reopened.Items()
+	if len(items) != 1 || items[0].Loss.Value != 5 || len(items[0].Orders) != 1 {
+		t.Fatalf("reopened levels = %+v", items)
+	}
+
+	if err := reopened.Remove("sber"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Remove("sber"); !errors.Is(err, ErrNoLevels) {
+		t.Errorf("second Remove error = %v, want ErrNoLevels", err)
+	}
+}
+
+// recorder collects what the monitor sends
+type recorder struct {
+	alerts  []Alert
+	updates []invest.StopOrder
+}
+
+func (r *recorder) SendStopAlert(alert Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func (r *recorder) SendStopOrderUpdate(stop Stop, order invest.StopOrder) error {
+	r.updates = append(r.updates, order)
+	return nil
+}
+
+func TestCheckAlertsOncePerCrossing(t *testing.T) {
+	book, _ := Open("", logging.Discard())
+	if _, err := book.Set(sber, SideLoss, Level{Kind: KindTrailing, Value: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := book.Set(sber, SideTake, Level{Kind: KindPrice, Value: 360}); err != nil {
+		t.Fatal(err)
+	}
+
+	broker := &fake.Broker{Portfolio: &invest.Portfolio{Positions: []invest.Position{sber}}}
+	notifier := &recorder{}
+	monitor := NewMonitor(book, broker, notifier, config.StopsConfig{CheckMinutes: 5}, logging.Discard())
+	ctx := context.Background()
+
+	steps := []struct {
+		price float64
+		want  string // side alerted at this price, empty for none
+	}{
+		{340, ""},       // the high rises to 340, the stop-loss to 306
+		{310, ""},       // above the trailing stop-loss
+		{305, SideLoss}, // below 306
+		{300, ""},       // still below, already alerted
+		{330, ""},       // back above, the alert is re-armed
+		{365, SideTake}, // the high rises with the take-profit
+		{340, ""},       // above 328.5 and back below the take-profit
+		{328, SideLoss}, // 90% of 365 is 328.5
+	}
+	for _, step := range steps {
+		broker.LastPrices = map[string]float64{"sber": step.price}
+		before := len(notifier.alerts)
+		if err := monitor.Check(ctx); err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if len(notifier.alerts) > before {
+			got = notifier.alerts[len(notifier.alerts)-1].Side
+		}
+		if len(notifier.alerts)-before > 1 || got != step.want {
+			t.Fatalf("at %v alerts %+v, want %q", step.price, notifier.alerts[before:], step.want)
+		}
+	}
+
+	// Levels of a position that is no longer held stay silent
+	broker.Portfolio = &invest.Portfolio{}
+	broker.LastPrices = map[string]float64{"sber": 100}
+	before := len(notifier.alerts)
+	if err := monitor.Check(ctx); err != nil || len(notifier.alerts) != before {
+		t.Errorf("alerts for a sold position: %v, %+v", err, notifier.alerts[before:])
+	}
+}
+
+func TestCheckConvertsBondQuotes(t *testing.T) {
+	bond := invest.Position{FIGI: "ofz", Ticker: "SU26238", InstrumentType: "bond", AveragePrice: 700, CurrentPrice: 690, Quantity: 10}
+	book, _ := Open("", logging.Discard())
+	if _, err := book.Set(bond, SideLoss, Level{Kind: KindPercent, Value: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := book.Set(bond, SideTake, Level{Kind: KindPrice, Value: 750}); err != nil {
+		t.Fatal(err)
+	}
+
+	broker := &fake.Broker{
+		Portfolio:   &invest.Portfolio{Positions: []invest.Position{bond}},
+		Instruments: map[string]*invest.Instrument{"ofz": {FIGI: "ofz", Ticker: "SU26238", Type: "bond", Nominal: 1000}},
+	}
+	notifier := &recorder{}
+	monitor := NewMonitor(book, broker, notifier, config.StopsConfig{CheckMinutes: 5}, logging.Discard())
+	ctx := context.Background()
+
+	steps := []struct {
+		quote float64 // percent of the nominal
+		want  string
+	}{
+		{69, ""},       // 690 is between the levels, though the quote is below the stop-loss of 630
+		{76, SideTake}, // 760
+		{62, SideLoss}, // 620
+	}
+	for _, step := range steps {
+		broker.LastPrices = map[string]float64{"ofz": step.quote}
+		before := len(notifier.alerts)
+		if err := monitor.Check(ctx); err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if len(notifier.alerts) > before {
+			got = notifier.alerts[len(notifier.alerts)-1].Side
+			if price := notifier.alerts[len(notifier.alerts)-1].Price; price != step.quote*10 {
+				t.Errorf("alert price = %v, want %v", price, step.quote*10)
+			}
+		}
+		if len(notifier.alerts)-before > 1 || got != step.want {
+			t.Fatalf("at %v%% alerts %+v, want %q", step.quote, notifier.alerts[before:], step.want)
+		}
+	}
+}
+
+func TestCheckFollowsStopOrders(t *testing.T) {
+	book, _ := Open("", logging.Discard())
+	if _, err := book.Set(sber, SideLoss, Level{Kind: KindPrice, Value: 280}); err != nil {
+		t.Fatal(err)
+	}
+	broker := &fake.Broker{Portfolio: &invest.Portfolio{Positions: []invest.Position{sber}}}
+	ctx := context.Background()
+	loss, _ := broker.PostStopOrder(ctx, invest.StopOrderRequest{InstrumentID: "sber", Type: invest.StopLoss, Lots: 2, StopPrice: 280})
+	take, _ := broker.PostStopOrder(ctx, invest.StopOrderRequest{InstrumentID: "sber", Type: invest.TakeProfit, Lots: 2, StopPrice: 350})
+	if err := book.AddOrders("SBER", loss, take); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := &recorder{}
+	monitor := NewMonitor(book, broker, notifier, config.StopsConfig{CheckMinutes: 5}, logging.Discard())
+	if err := monitor.Check(ctx); err != nil || len(notifier.updates) != 0 {
+		t.Fatalf("updates for active orders: %v, %+v", err, notifier.updates)
+	}
+
+	if err := broker.CancelStopOrder(ctx, take); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := monitor.Check(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(notifier.updates) != 1 || notifier.updates[0].ID != take || notifier.updates[0].Status != invest.StopOrderCancelled {
+		t.Fatalf("updates = %+v, want the cancelled take-profit once", notifier.updates)
+	}
+	if orders := book.Find("SBER").Orders; len(orders) != 1 || orders[0] != loss {
+		t.Errorf("followed orders = %v, want the active stop-loss only", orders)
+	}
+}
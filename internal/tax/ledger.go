@@ -0,0 +1,180 @@
+package tax
+
+import (
+	"invest-manager/internal/invest"
+	"math"
+	"time"
+)
+
+// Tax rules for the gains on securities of residents
+const (
+	baseRate           = 0.13
+	highRate           = 0.15
+	highRateFrom       = 2400000.0 // yearly income taxed at the base rate; the excess is taxed at the high rate
+	exemptAfterYears   = 3         // securities held longer are exempt (long-term ownership benefit)
+	exemptLimitPerYear = 3000000.0 // yearly exempt gain per full year of ownership, averaged over the units sold
+	deductionRate      = 0.13      // IIS type A deduction, a share of the contributions
+)
+
+// exemptTypes lists the instrument types the long-term ownership benefit applies to
+var exemptTypes = map[string]bool{"share": true, "bond": true, "etf": true}
+
+// lot is a purchase that is still held, fully or in part
+type lot struct {
+	instrumentType string
+	bought         time.Time
+	quantity       float64
+	price          float64 // cost per unit, from the payment
+}
+
+// ledger matches sales with the earliest purchases of the same instrument (FIFO, as brokers do)
+// and sums the taxable results of one year
+type ledger struct {
+	year     int
+	location *time.Location
+	exempt   bool // whether the long-term ownership benefit applies to the account
+
+	lots        map[string][]lot // open lots by FIGI, oldest first
+	gain        float64          // taxable gains less losses of the year, complete after finish
+	exemptGain  float64          // gains of the year left untaxed by the benefit, set by finish
+	longGain    float64          // gains of the year on lots held long enough for the benefit
+	longUnits   float64          // units of those lots sold in the year
+	longYears   float64          // units of those lots times their full years of ownership
+	fees        float64          // broker fees of the year
+	withheld    float64          // tax withheld by the broker in the year, less refunds
+	contributed float64          // deposits of the year
+	unmatched   int              // sales of the year without the purchase in the history
+	skipped     int              // operations of the year in other currencies
+}
+
+func newLedger(year int, location *time.Location, exempt bool) *ledger {
+	return &ledger{year: year, location: location, exempt: exempt, lots: make(map[string][]lot)}
+}
+
+// inYear reports whether a moment falls into the year of the ledger
+func (l *ledger) inYear(t time.Time) bool {
+	return t.In(l.location).Year() == l.year
+}
+
+// apply adds an operation; operations must come oldest first
+func (l *ledger) apply(op invest.Operation) {
+	current := l.inYear(op.Time)
+	if op.Currency != "" && op.Currency != "rub" {
+		// Gains in other currencies are taxed at the central bank rates of each day, which are not known here
+		if current {
+			l.skipped++
+		}
+		return
+	}
+
+	switch op.Type {
+	case invest.OperationBuy:
+		if op.Quantity <= 0 {
+			return
+		}
+		l.lots[op.FIGI] = append(l.lots[op.FIGI], lot{
+			instrumentType: op.InstrumentType,
+			bought:         op.Time,
+			quantity:       op.Quantity,
+			price:          math.Abs(op.Payment) / op.Quantity,
+		})
+	case invest.OperationSell:
+		if op.Quantity <= 0 {
+			return
+		}
+		l.sell(op, current)
+	case invest.OperationBrokerFee:
+		if current {
+			l.fees -= op.Payment
+		}
+	case invest.OperationTax:
+		if current {
+			l.withheld -= op.Payment
+		}
+	case invest.OperationInput:
+		if current {
+			l.contributed += op.Payment
+		}
+	}
+}
+
+// sell closes the earliest lots and, for a sale of the ledger year, adds its result
+func (l *ledger) sell(op invest.Operation, current bool) {
+	price := op.Payment / op.Quantity
+	remaining := op.Quantity
+	lots := l.lots[op.FIGI]
+	for remaining > 0 && len(lots) > 0 {
+		open := &lots[0]
+		quantity := math.Min(remaining, open.quantity)
+		if current {
+			gain := quantity * (price - open.price)
+			if years := l.longTermYears(*open, op.Time); years > 0 {
+				// The cap of the benefit depends on all such sales of the year, see finish
+				l.longUnits += quantity
+				l.longYears += quantity * float64(years)
+				if gain > 0 {
+					l.longGain += gain
+					gain = 0
+				}
+			}
+			l.gain += gain
+		}
+		open.quantity -= quantity
+		remaining -= quantity
+		if open.quantity <= 1e-9 {
+			lots = lots[1:]
+		}
+	}
+	l.lots[op.FIGI] = lots
+	if remaining > 1e-9 && current {
+		// A transferred position or a short sale: the cost is unknown, so no result is counted
+		l.unmatched++
+	}
+}
+
+// longTermYears returns the full years of ownership of a lot sold under the long-term
+// ownership benefit, 0 if the benefit does not apply to it
+func (l *ledger) longTermYears(open lot, sold time.Time) int {
+	if !l.exempt || !exemptTypes[open.instrumentType] || sold.Before(exemptFrom(open.bought)) {
+		return 0
+	}
+	return heldYears(open.bought, sold)
+}
+
+// finish applies the yearly cap of the long-term ownership benefit once all operations are in:
+// the exempt gain is limited to the yearly limit times the full years of ownership averaged
+// over the units sold under the benefit, and the rest of their gains is taxed
+func (l *ledger) finish() {
+	if l.longUnits <= 0 {
+		return
+	}
+	limit := exemptLimitPerYear * l.longYears / l.longUnits
+	l.exemptGain = math.Min(l.longGain, limit)
+	l.gain += l.longGain - l.exemptGain
+}
+
+// heldYears returns the full years between a purchase and a sale
+func heldYears(bought, sold time.Time) int {
+	years := sold.Year() - bought.Year()
+	if bought.AddDate(years, 0, 0).After(sold) {
+		years--
+	}
+	return years
+}
+
+// exemptFrom returns the first day a lot bought at the given time is sold tax-free:
+// the benefit needs more than the full years of ownership
+func exemptFrom(bought time.Time) time.Time {
+	return bought.AddDate(exemptAfterYears, 0, 1)
+}
+
+// taxOn returns the tax on a yearly base: the base rate up to the threshold, the high rate above it
+func taxOn(base float64) float64 {
+	if base <= 0 {
+		return 0
+	}
+	if base <= highRateFrom {
+		return base * baseRate
+	}
+	return highRateFrom*baseRate + (base-highRateFrom)*highRate
+}
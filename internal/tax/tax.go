@@ -0,0 +1,253 @@
+// Package tax estimates the personal income tax (НДФЛ) on the trades of the account from its
+// operations: the tax due for the year so far, the lots about to become exempt after three
+// years of ownership, the losses worth realizing before the year ends and the use of the
+// IIS contribution limit.
+package tax
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/refresh"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+)
+
+// historyStart is the year operations are searched from, before the broker opened accounts
+const historyStart = 2015
+
+// Broker supplies the operations and prices of the account; *invest.Client is the production one
+type Broker interface {
+	AccountID() string
+	GetAccounts(ctx context.Context) ([]invest.Account, error)
+	GetOperations(ctx context.Context, from, to time.Time) ([]invest.Operation, error)
+	GetPortfolio(ctx context.Context) (*invest.Portfolio, error)
+}
+
+// ExemptLot is a held purchase that becomes tax-free soon
+type ExemptLot struct {
+	Ticker   string    `json:"ticker"`
+	Quantity float64   `json:"quantity"`
+	Exempt   time.Time `json:"exempt"` // first day the lot is sold tax-free
+	Gain     float64   `json:"gain"`   // unrealized gain at the current price
+	Saving   float64   `json:"saving"` // tax saved by selling on Exempt rather than now
+}
+
+// HarvestLot is a held position whose sale at a loss would reduce the tax of the year
+type HarvestLot struct {
+	Ticker   string  `json:"ticker"`
+	Quantity float64 `json:"quantity"`
+	Loss     float64 `json:"loss"` // unrealized loss at the current price, positive
+}
+
+// Report is the tax estimate of the account for the year so far
+type Report struct {
+	Time        time.Time    `json:"time"`
+	Year        int          `json:"year"`
+	IIS         bool         `json:"iis"`         // the tax is deferred until the account is closed
+	Gain        float64      `json:"gain"`        // taxable gains less losses of the sales
+	ExemptGain  float64      `json:"exempt_gain"` // gains left untaxed after three years of ownership
+	Fees        float64      `json:"fees"`
+	Base        float64      `json:"base"`     // gain less fees
+	Tax         float64      `json:"tax"`      // on the base
+	Withheld    float64      `json:"withheld"` // by the broker this year
+	Due         float64      `json:"due"`      // tax less withheld; negative is returned by the broker
+	Unmatched   int          `json:"unmatched,omitempty"`
+	Skipped     int          `json:"skipped,omitempty"`
+	SoonExempt  []ExemptLot  `json:"soon_exempt,omitempty"`
+	Harvest     []HarvestLot `json:"harvest,omitempty"`
+	HarvestSave float64      `json:"harvest_save,omitempty"` // tax saved by selling every lot of Harvest
+	InSeason    bool         `json:"in_season"`              // the month is late enough to suggest harvesting
+
+	// IIS only
+	Contributed       float64 `json:"contributed,omitempty"` // deposits of the year
+	ContributionLimit float64 `json:"contribution_limit,omitempty"`
+	DeductionBase     float64 `json:"deduction_base,omitempty"`
+	Deduction         float64 `json:"deduction,omitempty"` // type A deduction earned by the deposits
+}
+
+// Estimator computes the tax estimate of the selected account. It is safe for concurrent use.
+type Estimator struct {
+	broker Broker
+	logger *slog.Logger
+	latest refresh.Latest[config.TaxConfig, Report]
+}
+
+// New creates an estimator
+func New(settings config.TaxConfig, broker Broker, logger *slog.Logger) *Estimator {
+	e := &Estimator{broker: broker, logger: logger}
+	e.latest.Reload(settings)
+	return e
+}
+
+// Reload applies the rates and notice days of a new configuration from the next estimate on
+func (e *Estimator) Reload(settings config.TaxConfig) {
+	e.latest.Reload(settings)
+}
+
+// Refresh estimates the tax of the year of now in its time zone. The monthly report takes
+// the estimate from Latest, which is empty after a failed refresh.
+func (e *Estimator) Refresh(ctx context.Context, now time.Time) (*Report, error) {
+	return e.latest.Refresh(func(settings config.TaxConfig) (*Report, error) {
+		return e.estimate(ctx, settings, now)
+	})
+}
+
+// Latest returns the estimate of the last refresh, nil if it failed
+func (e *Estimator) Latest() *Report {
+	return e.latest.Get()
+}
+
+// estimate builds the report of the year from all the operations of the account
+func (e *Estimator) estimate(ctx context.Context, settings config.TaxConfig, now time.Time) (*Report, error) {
+	iis, err := e.isIIS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	operations, err := e.broker.GetOperations(ctx, time.Date(historyStart, 1, 1, 0, 0, 0, 0, time.UTC), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operations: %w", err)
+	}
+	portfolio, err := e.broker.GetPortfolio(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get portfolio: %w", err)
+	}
+
+	// The long-term ownership benefit does not combine with the IIS
+	l := newLedger(now.Year(), now.Location(), !iis)
+	for _, op := range operations {
+		l.apply(op)
+	}
+	l.finish()
+
+	r := &Report{
+		Time:       now,
+		Year:       now.Year(),
+		IIS:        iis,
+		Gain:       l.gain,
+		ExemptGain: l.exemptGain,
+		Fees:       l.fees,
+		Base:       math.Max(l.gain-l.fees, 0),
+		Withheld:   l.withheld,
+		Unmatched:  l.unmatched,
+		Skipped:    l.skipped,
+		InSeason:   int(now.Month()) >= settings.HarvestMonth,
+	}
+	r.Tax = taxOn(r.Base)
+	r.Due = r.Tax - r.Withheld
+
+	if iis {
+		r.Contributed = l.contributed
+		r.ContributionLimit = settings.IISContributionLimit
+		r.DeductionBase = settings.IISDeductionBase
+		r.Deduction = math.Min(l.contributed, settings.IISDeductionBase) * deductionRate
+		if r.Deduction < 0 {
+			r.Deduction = 0
+		}
+		// The tax on an IIS is paid when it is closed, so neither list would save anything this year
+		return r, nil
+	}
+
+	prices := make(map[string]invest.Position, len(portfolio.Positions))
+	for _, p := range portfolio.Positions {
+		prices[p.FIGI] = p
+	}
+	r.SoonExempt = soonExempt(l.lots, prices, now, settings.ExemptNoticeDays)
+	r.Harvest, r.HarvestSave = harvest(l.lots, prices, r.Base)
+	return r, nil
+}
+
+// isIIS reports whether the selected account, or the first one if none is selected, is an IIS
+func (e *Estimator) isIIS(ctx context.Context) (bool, error) {
+	accounts, err := e.broker.GetAccounts(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	id := e.broker.AccountID()
+	for i, acc := range accounts {
+		if acc.ID == id || (id == "" && i == 0) {
+			return acc.Type == "iis", nil
+		}
+	}
+	return false, nil
+}
+
+// soonExempt lists the held lots with a gain that become tax-free within the notice days, soonest first
+func soonExempt(lots map[string][]lot, positions map[string]invest.Position, now time.Time, noticeDays int) []ExemptLot {
+	until := now.AddDate(0, 0, noticeDays)
+	var result []ExemptLot
+	for figi, open := range lots {
+		pos, ok := positions[figi]
+		if !ok {
+			continue
+		}
+		for _, lt := range open {
+			exempt := exemptFrom(lt.bought)
+			if !exemptTypes[lt.instrumentType] || !exempt.After(now) || exempt.After(until) {
+				continue
+			}
+			gain := lt.quantity * (pos.CurrentPrice - lt.price)
+			if gain <= 0 {
+				continue
+			}
+			result = append(result, ExemptLot{
+				Ticker:   pos.Ticker,
+				Quantity: lt.quantity,
+				Exempt:   exempt,
+				Gain:     gain,
+				Saving:   gain * baseRate,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Exempt.Equal(result[j].Exempt) {
+			return result[i].Exempt.Before(result[j].Exempt)
+		}
+		return result[i].Ticker < result[j].Ticker
+	})
+	return result
+}
+
+// harvest picks the held positions with the largest unrealized losses until they cover the
+// taxable base, and returns them with the tax their sale would save
+func harvest(lots map[string][]lot, positions map[string]invest.Position, base float64) ([]HarvestLot, float64) {
+	if base <= 0 {
+		return nil, 0
+	}
+	var candidates []HarvestLot
+	for figi, open := range lots {
+		pos, ok := positions[figi]
+		if !ok {
+			continue
+		}
+		// A sale closes the earliest lots first, so only the leading lots bought above the price realize a loss
+		var h HarvestLot
+		for _, lt := range open {
+			loss := lt.quantity * (lt.price - pos.CurrentPrice)
+			if loss <= 0 {
+				break
+			}
+			h.Quantity += lt.quantity
+			h.Loss += loss
+		}
+		if h.Loss > 0 {
+			h.Ticker = pos.Ticker
+			candidates = append(candidates, h)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Loss > candidates[j].Loss })
+
+	var picked []HarvestLot
+	var loss float64
+	for _, c := range candidates {
+		if loss >= base {
+			break
+		}
+		picked = append(picked, c)
+		loss += c.Loss
+	}
+	return picked, taxOn(base) - taxOn(base-loss)
+}
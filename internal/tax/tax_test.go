@@ -0,0 +1,156 @@
+package tax
+
+import (
+	"context"
+	"invest-manager/internal/config"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/testutil"
+	"math"
+	"testing"
+	"time"
+)
+
+// The fake must stay usable in place of the production broker
+var (
+	_ Broker = (*fake.Broker)(nil)
+	_ Broker = (*invest.Client)(nil)
+)
+
+func testSettings() config.TaxConfig {
+	return config.TaxConfig{ExemptNoticeDays: 90, HarvestMonth: 11, IISContributionLimit: 1000000, IISDeductionBase: 400000}
+}
+
+func TestRefreshBrokerAccount(t *testing.T) {
+	broker := &fake.Broker{
+		Accounts: []invest.Account{{ID: "broker", Type: "broker"}, {ID: "iis", Type: "iis"}},
+		Portfolio: &invest.Portfolio{Positions: []invest.Position{
+			{FIGI: "sber", Ticker: "SBER", CurrentPrice: 300},
+			{FIGI: "lkoh", Ticker: "LKOH", CurrentPrice: 6000},
+		}},
+		Operations: []invest.Operation{
+			testutil.Buy(testutil.Date(2022, time.January, 10, 10), "gazp", 100, 150),
+			testutil.Buy(testutil.Date(2023, time.November, 1, 10), "sber", 10, 200),
+			testutil.Buy(testutil.Date(2025, time.February, 1, 10), "lkoh", 2, 7000),
+			// Held for more than three years: exempt
+			testutil.Sell(testutil.Date(2026, time.March, 1, 10), "gazp", 100, 200),
+			testutil.Sell(testutil.Date(2026, time.May, 1, 10), "lkoh", 1, 8000),
+			testutil.Buy(testutil.Date(2026, time.June, 1, 10), "ydex", 10, 4000),
+			testutil.Sell(testutil.Date(2026, time.July, 1, 10), "ydex", 10, 4300),
+			{Time: testutil.Date(2026, time.July, 1, 10), Type: invest.OperationBrokerFee, Payment: -100, Currency: "rub"},
+			{Time: testutil.Date(2026, time.August, 1, 10), Type: invest.OperationTax, Payment: -250, Currency: "rub"},
+			// A correction refunds part of it
+			{Time: testutil.Date(2026, time.August, 15, 10), Type: invest.OperationTax, Payment: 50, Currency: "rub"},
+			// A transferred position and a trade in dollars
+			testutil.Sell(testutil.Date(2026, time.September, 1, 10), "tcsg", 5, 3000),
+			{Time: testutil.Date(2026, time.September, 2, 10), Type: invest.OperationBuy, FIGI: "aapl", Quantity: 1, Payment: -200, Currency: "usd"},
+		},
+	}
+	estimator := New(testSettings(), broker, logging.Discard())
+
+	report, err := estimator.Refresh(context.Background(), testutil.Date(2026, time.October, 18, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimator.Latest() != report || report.IIS {
+		t.Fatalf("report = %+v", report)
+	}
+	// LKOH 1000 and YDEX 3000 are taxed, GAZP 5000 is not; the fees reduce the base
+	if report.Gain != 4000 || report.ExemptGain != 5000 || report.Base != 3900 {
+		t.Errorf("gain %v, exempt %v, base %v; want 4000, 5000, 3900", report.Gain, report.ExemptGain, report.Base)
+	}
+	if !testutil.AlmostEqual(report.Tax, 507) || !testutil.AlmostEqual(report.Due, 307) {
+		t.Errorf("tax %v, due %v; want 507 and 307 after 200 withheld", report.Tax, report.Due)
+	}
+	if report.Unmatched != 1 || report.Skipped != 1 {
+		t.Errorf("unmatched %d, skipped %d; want 1 and 1", report.Unmatched, report.Skipped)
+	}
+
+	// SBER bought on November 1, 2023 is tax-free from November 2, 2026
+	if len(report.SoonExempt) != 1 {
+		t.Fatalf("soon exempt = %+v", report.SoonExempt)
+	}
+	if lot := report.SoonExempt[0]; lot.Ticker != "SBER" || !lot.Exempt.Equal(testutil.Date(2026, time.November, 2, 10)) || lot.Gain != 1000 {
+		t.Errorf("soon exempt = %+v", lot)
+	}
+
+	// The remaining LKOH is 1000 below its cost and offsets a quarter of the base
+	if len(report.Harvest) != 1 || report.Harvest[0].Ticker != "LKOH" || report.Harvest[0].Loss != 1000 {
+		t.Errorf("harvest = %+v", report.Harvest)
+	}
+	if !testutil.AlmostEqual(report.HarvestSave, 130) || report.InSeason {
+		t.Errorf("harvest saves %v in season %v; want 130 out of season in October", report.HarvestSave, report.InSeason)
+	}
+}
+
+func TestRefreshIIS(t *testing.T) {
+	broker := &fake.Broker{
+		Accounts:  []invest.Account{{ID: "broker", Type: "broker"}, {ID: "iis", Type: "iis"}},
+		Portfolio: &invest.Portfolio{Positions: []invest.Position{{FIGI: "sber", Ticker: "SBER", CurrentPrice: 100}}},
+		Operations: []invest.Operation{
+			{Time: testutil.Date(2025, time.March, 1, 10), Type: invest.OperationInput, Payment: 100000, Currency: "rub"},
+			{Time: testutil.Date(2026, time.February, 1, 10), Type: invest.OperationInput, Payment: 500000, Currency: "rub"},
+			testutil.Buy(testutil.Date(2022, time.February, 1, 10), "sber", 10, 200),
+			testutil.Buy(testutil.Date(2022, time.February, 1, 10), "gazp", 10, 100),
+			testutil.Sell(testutil.Date(2026, time.March, 1, 10), "gazp", 10, 200),
+		},
+	}
+	broker.SetAccount("iis")
+	report, err := New(testSettings(), broker, logging.Discard()).Refresh(context.Background(), testutil.Date(2026, time.November, 20, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No exemption on an IIS and nothing to harvest, since the tax is deferred
+	if !report.IIS || report.Gain != 1000 || report.ExemptGain != 0 || report.Harvest != nil {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Contributed != 500000 || report.Deduction != 52000 || report.ContributionLimit != 1000000 {
+		t.Errorf("contributed %v, deduction %v, limit %v", report.Contributed, report.Deduction, report.ContributionLimit)
+	}
+}
+
+func TestExemption(t *testing.T) {
+	bought := testutil.Date(2023, time.March, 15, 10)
+	l := newLedger(2026, time.UTC, true)
+	l.apply(testutil.Buy(bought, "sber", 20, 100))
+	// Exactly three years are not enough
+	l.apply(testutil.Sell(testutil.Date(2026, time.March, 15, 10), "sber", 10, 200))
+	l.apply(testutil.Sell(testutil.Date(2026, time.March, 16, 10), "sber", 10, 200))
+	l.finish()
+	if l.gain != 1000 || l.exemptGain != 1000 {
+		t.Errorf("gain %v, exempt %v; want 1000 each", l.gain, l.exemptGain)
+	}
+	if got := heldYears(bought, testutil.Date(2026, time.March, 14, 10)); got != 2 {
+		t.Errorf("heldYears = %d, want 2", got)
+	}
+}
+
+func TestExemptionYearlyCap(t *testing.T) {
+	l := newLedger(2026, time.UTC, true)
+	// 4 and 5 full years: the cap is 3M times 4.5 years on average, once for the whole year
+	l.apply(testutil.Buy(testutil.Date(2022, time.January, 10, 10), "sber", 1000, 100))
+	l.apply(testutil.Buy(testutil.Date(2021, time.January, 10, 10), "gazp", 1000, 100))
+	l.apply(testutil.Sell(testutil.Date(2026, time.February, 1, 10), "sber", 1000, 8100))
+	l.apply(testutil.Sell(testutil.Date(2026, time.February, 1, 10), "gazp", 1000, 8100))
+	l.finish()
+	if l.exemptGain != 13500000 || l.gain != 2500000 {
+		t.Errorf("gain %v, exempt %v; want 2500000 taxed and 13500000 exempt", l.gain, l.exemptGain)
+	}
+}
+
+func TestTaxOn(t *testing.T) {
+	tests := []struct {
+		base, want float64
+	}{
+		{-500, 0},
+		{1000000, 130000},
+		{2400000, 312000},
+		{3400000, 312000 + 150000},
+	}
+	for _, tt := range tests {
+		if got := taxOn(tt.base); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("taxOn(%v) = %v, want %v", tt.base, got, tt.want)
+		}
+	}
+}
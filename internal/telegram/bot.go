@@ -5,66 +5,238 @@ import (
 	"fmt"
 	"invest-manager/internal/analysis"
 	"invest-manager/internal/config"
+	"invest-manager/internal/contributions"
 	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/models"
+	"invest-manager/internal/monitoring"
 	"invest-manager/internal/news"
-	"log"
+	"invest-manager/internal/paper"
+	"invest-manager/internal/performance"
+	"invest-manager/internal/scheduler"
+	"invest-manager/internal/screener"
+	"invest-manager/internal/stops"
+	"invest-manager/internal/tax"
+	"invest-manager/internal/watchlist"
+	"invest-manager/internal/telegram/render"
+	"invest-manager/internal/trading"
+	"invest-manager/internal/usage"
+	"log/slog"
 	"strings"
 	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+// Broker is the part of the broker API the bot commands read; *invest.Client is the production one
+type Broker interface {
+	GetPortfolio(ctx context.Context) (*invest.Portfolio, error)
+	GetAccounts(ctx context.Context) ([]invest.Account, error)
+	AccountID() string
+	SetAccount(id string)
+	GetDailyCandles(ctx context.Context, figi string, from, to time.Time) ([]invest.Candle, error)
+	GetPortfolioHistory(ctx context.Context, portfolio *invest.Portfolio, from time.Time) ([]invest.ValuePoint, error)
+	GetPeriodPnL(ctx context.Context, from time.Time) (*invest.PeriodPnL, error)
+	GetInstrument(ctx context.Context, figi string) (*invest.Instrument, error)
+}
+
+// Trader places the orders confirmed with /trade; *invest.Client is the production one
+type Trader interface {
+	GetOrderBook(ctx context.Context, instrumentID string, depth int) (*invest.OrderBook, error)
+	PostOrder(ctx context.Context, req invest.OrderRequest) (*invest.OrderState, error)
+	GetOrderState(ctx context.Context, orderID string) (*invest.OrderState, error)
+	GetTradedAmount(ctx context.Context, from time.Time) (float64, error)
+}
+
+// StopOrders manages the broker stop orders of /stop; *invest.Client is the production one
+type StopOrders interface {
+	PostStopOrder(ctx context.Context, req invest.StopOrderRequest) (string, error)
+	GetStopOrders(ctx context.Context, from time.Time) ([]invest.StopOrder, error)
+	CancelStopOrder(ctx context.Context, orderID string) error
+}
+
+// Analyzer reports the token usage of the LLM analysis for /usage; *analysis.Analyzer is the production one
+type Analyzer interface {
+	UsageReport() *usage.Report
+}
+
+// NewsSource searches news for the bot commands; *news.Fetcher is the production one
+type NewsSource interface {
+	Enabled() bool
+	FetchMarketNews() ([]news.Article, error)
+	FetchNews(query string, limit int) ([]news.Article, error)
+}
+
+// Options are the optional features of the bot; the commands of the ones left nil are disabled
+type Options struct {
+	// Pipeline enables /analyze, which runs the analysis of the scheduled report with its paper trading and records
+	Pipeline *scheduler.Pipeline
+	// Paper makes reports and /paper compare the paper portfolio with the real account
+	Paper *paper.Account
+	// Watchlist enables /watch
+	Watchlist *watchlist.List
+	// Screener enables /screen
+	Screener *screener.Screener
+	// Stops enables /stop for the stop-loss and take-profit levels of the positions
+	Stops *stops.Book
+	// Performance enables /performance; the monthly report shows the returns
+	Performance *performance.Tracker
+	// Tax enables /tax; the monthly report shows the tax estimate of the year
+	Tax *tax.Estimator
+	// Contributions enables /plan; the monthly reminder fires only if the plan is not met
+	Contributions *contributions.Plan
+	// Models enables /models for the comparison of the accounts with their model portfolios
+	Models *models.Comparer
+}
+
 // Bot handles Telegram communication
 type Bot struct {
 	api         *tgbotapi.BotAPI
-	chatID      string
-	logger      *log.Logger
-	investor    *invest.Client
-	analyzer    *analysis.Analyzer
-	newsFetcher *news.Fetcher
+	logger      *slog.Logger
+	investor    Broker
+	trader      Trader
+	stopOrders  StopOrders
+	analyzer    Analyzer
+	newsFetcher NewsSource
+	pipeline    *scheduler.Pipeline
+	paper       *paper.Account
+	watchlist   *watchlist.List
+	screener    *screener.Screener
+	stops       *stops.Book
+	performance *performance.Tracker
+	tax         *tax.Estimator
+	contributions *contributions.Plan
+	models      *models.Comparer
+	callbacks   *callbackRouter
+	mode        string
+	webhookCfg  config.WebhookConfig
+	webhook     *webhookServer
 	stopChan    chan struct{}
 	wg          sync.WaitGroup
+
+	// lastAnalysis keeps the most recent report so commands can refer to it
+	mu           sync.Mutex
+	lastAnalysis *analysis.PortfolioAnalysis
+
+	// pendingOrders are placed orders still tracked, counted against the daily limit;
+	// orderMu is held from the limit check until a placed order is counted
+	orderMu       sync.Mutex
+	pendingOrders trading.Pending
+
+	// settings that can change on config reload
+	settingsMu sync.RWMutex
+	chatID     string
+	schedule   config.ScheduleConfig
+	timezone   *time.Location
+	trading    config.TradingConfig
 }
 
 // NewBot creates a new Telegram bot
-func NewBot(cfg *config.Config, logger *log.Logger, 
-	investor *invest.Client, analyzer *analysis.Analyzer, 
-	newsFetcher *news.Fetcher) (*Bot, error) {
-	api, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+func NewBot(cfg *config.Config, logger *slog.Logger, 
+	investor Broker, trader Trader, stopOrders StopOrders,
+	analyzer Analyzer, newsFetcher NewsSource, opts Options) (*Bot, error) {
+	// The library logs request errors with the bot token in the URL,
+	// so route them through our logger, which redacts secrets
+	if err := tgbotapi.SetLogger(slog.NewLogLogger(logger.Handler(), slog.LevelWarn)); err != nil {
+		return nil, fmt.Errorf("failed to set Telegram logger: %w", err)
+	}
+
+	api, err := tgbotapi.NewBotAPI(cfg.Telegram.Token.Value())
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize Telegram bot: %w", err)
 	}
 	
-	return &Bot{
+	bot := &Bot{
 		api:         api,
-		chatID:      cfg.TelegramChatID,
 		logger:      logger,
 		investor:    investor,
+		trader:      trader,
+		stopOrders:  stopOrders,
 		analyzer:    analyzer,
 		newsFetcher: newsFetcher,
+		pipeline:    opts.Pipeline,
+		paper:       opts.Paper,
+		watchlist:   opts.Watchlist,
+		screener:    opts.Screener,
+		stops:       opts.Stops,
+		performance: opts.Performance,
+		tax:         opts.Tax,
+		contributions: opts.Contributions,
+		models:      opts.Models,
+		callbacks:   newCallbackRouter(),
+		mode:        cfg.Telegram.Mode,
+		webhookCfg:  cfg.Telegram.Webhook,
 		stopChan:    make(chan struct{}),
-	}, nil
+	}
+	bot.registerCallbacks()
+	bot.Reload(cfg)
+	
+	return bot, nil
 }
 
-// Start begins listening for commands from the authorized user
-func (b *Bot) Start() {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+// Reload applies the chat, schedule and trading settings of a new configuration.
+// The token and update mode are bound to the running connection and need a restart.
+func (b *Bot) Reload(cfg *config.Config) {
+	b.settingsMu.Lock()
+	defer b.settingsMu.Unlock()
+	b.chatID = cfg.Telegram.ChatID
+	b.schedule = cfg.Schedule
+	b.timezone = cfg.Timezone
+	b.trading = cfg.Trading
+}
+
+// currentChatID returns the authorized chat
+func (b *Bot) currentChatID() string {
+	b.settingsMu.RLock()
+	defer b.settingsMu.RUnlock()
+	return b.chatID
+}
 
-	updates := b.api.GetUpdatesChan(u)
+// Start begins listening for commands from the authorized user,
+// either by long polling or through a webhook depending on the configuration
+func (b *Bot) Start() error {
+	var updates tgbotapi.UpdatesChannel
+	
+	if b.mode == config.TelegramModeWebhook {
+		webhook, err := newWebhookServer(b.api, b.webhookCfg, b.logger)
+		if err != nil {
+			return err
+		}
+		updates, err = webhook.Start()
+		if err != nil {
+			return err
+		}
+		b.webhook = webhook
+	} else {
+		// Telegram refuses getUpdates while a webhook is set, e.g. after switching modes
+		if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			return fmt.Errorf("failed to delete webhook before polling: %w", err)
+		}
+		
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		updates = b.api.GetUpdatesChan(u)
+	}
 
 	b.wg.Add(1)
 	go b.handleUpdates(updates)
 	
-	b.logger.Println("Telegram bot started and listening for commands")
+	b.logger.Info("Telegram bot started and listening for commands", "mode", b.mode)
+	return nil
 }
 
 // Stop stops the bot
 func (b *Bot) Stop() {
+	// Stop receiving new updates first, then let the handler finish
+	if b.webhook != nil {
+		b.webhook.Stop()
+	} else {
+		b.api.StopReceivingUpdates()
+	}
 	close(b.stopChan)
 	b.wg.Wait()
-	b.api.StopReceivingUpdates()
-	b.logger.Println("Telegram bot stopped")
+	b.logger.Info("Telegram bot stopped")
 }
 
 // handleUpdates processes incoming messages and commands
@@ -75,84 +247,129 @@ func (b *Bot) handleUpdates(updates tgbotapi.UpdatesChannel) {
 		select {
 		case <-b.stopChan:
 			return
-		case update := <-updates:
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			// Every update gets its own correlation ID, shared by the records of the work it starts,
+			// and LLM usage is attributed to the user who sent it
+			ctx := logging.WithCorrelationID(context.Background())
+			ctx = usage.WithTags(ctx, "command", update.SentFrom().String())
+			if update.CallbackQuery != nil {
+				query := update.CallbackQuery
+				if query.Message == nil || fmt.Sprintf("%d", query.Message.Chat.ID) != b.currentChatID() {
+					b.logger.WarnContext(ctx, "Received callback from unauthorized chat")
+					continue
+				}
+				b.handleCallback(ctx, query)
+				continue
+			}
+
 			if update.Message == nil {
 				continue
 			}
 
 			// Only process messages from authorized chat ID
 			chatIDStr := fmt.Sprintf("%d", update.Message.Chat.ID)
-			if chatIDStr != b.chatID {
-				b.logger.Printf("Received message from unauthorized chat: %s", chatIDStr)
+			if chatIDStr != b.currentChatID() {
+				b.logger.WarnContext(ctx, "Received message from unauthorized chat", "chat_id", chatIDStr)
 				continue
 			}
 
 			// Process commands
 			if update.Message.IsCommand() {
-				b.handleCommand(update.Message)
+				b.handleCommand(ctx, update.Message)
 			}
 		}
 	}
 }
 
 // handleCommand processes bot commands
-func (b *Bot) handleCommand(message *tgbotapi.Message) {
+func (b *Bot) handleCommand(ctx context.Context, message *tgbotapi.Message) {
+	b.logger.InfoContext(ctx, "Received command", "command", message.Command())
 	switch message.Command() {
 	case "analyze":
-		b.logger.Printf("Received analyze command from chat ID: %d", message.Chat.ID)
-		b.handleAnalyzeCommand(message)
+		b.handleAnalyzeCommand(ctx, message)
 	case "help":
 		b.handleHelpCommand(message)
 	case "status":
 		b.handleStatusCommand(message)
+	case "portfolio":
+		b.handlePortfolioCommand(ctx, message)
+	case "position":
+		b.handlePositionCommand(ctx, message)
+	case "news":
+		b.handleNewsCommand(ctx, message)
+	case "pnl":
+		b.handlePnLCommand(ctx, message)
+	case "account":
+		b.handleAccountCommand(ctx, message)
+	case "chart":
+		b.handleChartCommand(ctx, message)
+	case "usage":
+		b.handleUsageCommand(message)
+	case "trade":
+		b.handleTradeCommand(ctx, message)
+	case "paper":
+		b.handlePaperCommand(message)
+	case "watch":
+		b.handleWatchCommand(ctx, message)
+	case "screen":
+		b.handleScreenCommand(ctx, message)
+	case "stop":
+		b.handleStopCommand(ctx, message)
+	case "performance":
+		b.handlePerformanceCommand(ctx, message)
+	case "tax":
+		b.handleTaxCommand(ctx, message)
+	case "plan":
+		b.handlePlanCommand(ctx, message)
+	case "models":
+		b.handleModelsCommand(ctx, message)
 	default:
 		b.sendMessage("Неизвестная команда. Используйте /help для списка доступных команд.")
 	}
 }
 
 // handleAnalyzeCommand performs immediate portfolio analysis
-func (b *Bot) handleAnalyzeCommand(message *tgbotapi.Message) {
-	replyMsg := tgbotapi.NewMessage(message.Chat.ID, "🔄 Запускаю анализ вашего портфеля...")
-	b.api.Send(replyMsg)
+func (b *Bot) handleAnalyzeCommand(ctx context.Context, message *tgbotapi.Message) {
+	b.startAnalysis(ctx)
+}
+
+// startAnalysis runs the full analysis in the background and sends the report
+func (b *Bot) startAnalysis(ctx context.Context) {
+	if b.pipeline == nil {
+		b.sendMessage("Анализ недоступен.")
+		return
+	}
+	b.sendMessage("🔄 Запускаю анализ вашего портфеля...")
 	
 	// Run analysis in a separate goroutine to not block message handling
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 60*1000*1000*1000) // 60 sec timeout
+		ctx, cancel := context.WithTimeout(ctx, 60*1000*1000*1000) // 60 sec timeout
 		defer cancel()
 		
-		// Get portfolio
-		b.logger.Println("Getting portfolio...")
-		portfolio, err := b.investor.GetPortfolio(ctx)
-		if err != nil {
-			errorMsg := fmt.Sprintf("Ошибка при получении портфеля: %v", err)
-			b.logger.Println(errorMsg)
-			b.sendMessage(errorMsg)
-			return
-		}
+		start := time.Now()
+		var err error
+		defer func() { monitoring.ObserveJob("command", start, err) }()
 		
-		// Get news about Russia
-		b.logger.Println("Fetching news about Russia...")
-		articles, err := b.newsFetcher.FetchNews("Russia", 5)
+		// The same steps as the scheduled report, so the paper portfolio and the records follow it too
+		report, err := b.pipeline.Run(ctx, b.localNow(), false)
 		if err != nil {
-			b.logger.Printf("Warning: could not fetch news: %v, continuing without news", err)
-			articles = []news.Article{} // Empty but continue
+			b.replyError(ctx, "Ошибка при анализе портфеля", err)
+			return
 		}
 		
-		// Analyze portfolio
-		b.logger.Println("Analyzing portfolio...")
-		analysis, err := b.analyzer.AnalyzePortfolio(ctx, portfolio, articles, false)
+		// Send analysis results with fresh news
+		err = b.SendPortfolioAnalysis(report.Portfolio, report.Analysis, report.Articles)
 		if err != nil {
-			errorMsg := fmt.Sprintf("Ошибка при анализе портфеля: %v", err)
-			b.logger.Println(errorMsg)
-			b.sendMessage(errorMsg)
+			b.replyError(ctx, "Ошибка при отправке анализа", err)
 			return
 		}
 		
-		// Send analysis results with fresh news
-		err = b.SendPortfolioAnalysis(portfolio, analysis, articles)
-		if err != nil {
-			b.logger.Printf("Error sending portfolio analysis: %v", err)
-			b.sendMessage(fmt.Sprintf("Ошибка при отправке анализа: %v", err))
+		// Charts are a supplement to the report, so failures are only logged
+		if err := b.SendPortfolioCharts(ctx, report.Portfolio); err != nil {
+			b.logger.WarnContext(ctx, "Could not send portfolio charts", "error", err)
 		}
 	}()
 }
@@ -162,22 +379,70 @@ func (b *Bot) handleHelpCommand(message *tgbotapi.Message) {
 	helpText := `🤖 *Доступные команды*:
 
 /analyze - запустить анализ портфеля прямо сейчас
+/portfolio - показать позиции портфеля
+/position SBER - подробности по позиции
+/news - свежие новости (можно указать тикер)
+/pnl - доходность за период: day, week, month, year
+/account - выбрать счёт для отчётов
+/chart - график: value, allocation, pnl, paper или position SBER
+/usage - расход токенов LLM и бюджет
+/trade - заявка по рекомендации (можно указать тикер)
+/paper - бумажный портфель, следующий рекомендациям
+/watch add|remove|list - список наблюдения для анализа вне портфеля
+/screen - отбор акций и фондов по правилам (можно указать скрин)
+/stop - стоп-лосс и тейк-профит по позициям: /stop SBER loss 5%
+/performance - доходность с учётом пополнений (XIRR и TWR) против индексов
+/tax - оценка НДФЛ за год, льгота трёх лет, лимит ИИС
+/plan - пополнения по плану за месяц и год, как вложить новые деньги
+/models - счета против модельных портфелей: доходность, отклонение, риск
 /status - проверить статус бота
 /help - показать это сообщение
 
-Бот также автоматически анализирует ваш портфель каждый день в 7:00 (МСК).`
+Бот также автоматически анализирует ваш портфель ` + escapeLegacyMarkdown(b.scheduleDescription()) + "."
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
 	msg.ParseMode = tgbotapi.ModeMarkdown
-	b.api.Send(msg)
+	b.send(msg)
 }
 
 // handleStatusCommand shows bot status
 func (b *Bot) handleStatusCommand(message *tgbotapi.Message) {
-	statusText := "✅ Бот работает нормально. Ежедневный анализ портфеля выполняется в 7:00 (МСК)."
+	statusText := "✅ Бот работает нормально. Анализ портфеля выполняется " + b.scheduleDescription() + "."
 	
 	msg := tgbotapi.NewMessage(message.Chat.ID, statusText)
-	b.api.Send(msg)
+	b.send(msg)
+}
+
+// scheduleDescription describes when the scheduled analysis runs, e.g. "каждый день в 7:00 (МСК)"
+func (b *Bot) scheduleDescription() string {
+	b.settingsMu.RLock()
+	schedule, timezone := b.schedule, b.timezone
+	b.settingsMu.RUnlock()
+	
+	zone := "МСК"
+	if timezone != nil && timezone.String() != "Europe/Moscow" {
+		zone = timezone.String()
+	}
+	
+	// Describe the common "minute hour * * *" spec in words, anything else as is
+	desc := fmt.Sprintf("по расписанию %s (%s)", schedule.Daily, zone)
+	fields := strings.Fields(schedule.Daily)
+	if len(fields) == 5 && fields[2] == "*" && fields[3] == "*" && fields[4] == "*" {
+		var minute, hour int
+		if _, err := fmt.Sscanf(fields[0]+" "+fields[1], "%d %d", &minute, &hour); err == nil {
+			desc = fmt.Sprintf("каждый день в %d:%02d (%s)", hour, minute, zone)
+		}
+	}
+	
+	if schedule.MonthlyReminderDay > 0 {
+		desc += fmt.Sprintf(", %d-го числа — с напоминанием о пополнении", schedule.MonthlyReminderDay)
+	}
+	return desc
+}
+
+// escapeLegacyMarkdown escapes text inserted into legacy Markdown messages
+func escapeLegacyMarkdown(text string) string {
+	return strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[").Replace(text)
 }
 
 // SendMessage sends a simple text message
@@ -185,15 +450,37 @@ func (b *Bot) SendMessage(text string) error {
 	return b.sendMessage(text)
 }
 
+// send sends or edits a message, recording the outcome in the metrics
+func (b *Bot) send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	msg, err := b.api.Send(c)
+	monitoring.ObserveTelegramSend(err)
+	return msg, err
+}
+
+// sendMediaGroup sends several photos as one message, recording the outcome in the metrics
+func (b *Bot) sendMediaGroup(c tgbotapi.MediaGroupConfig) ([]tgbotapi.Message, error) {
+	msgs, err := b.api.SendMediaGroup(c)
+	monitoring.ObserveTelegramSend(err)
+	return msgs, err
+}
+
+// Ping checks that the Bot API is reachable and the token is accepted
+func (b *Bot) Ping(ctx context.Context) error {
+	if _, err := b.api.GetMe(); err != nil {
+		return fmt.Errorf("failed to reach Telegram: %w", err)
+	}
+	return nil
+}
+
 // sendMessage is an internal method to send a simple text message
 func (b *Bot) sendMessage(text string) error {
 	// Check if message is too long for Telegram
-	const maxMessageLength = 4096
+	const maxMessageLength = render.MaxMessageLength
 	
-	if len(text) <= maxMessageLength {
+	if render.UTF16Len(text) <= maxMessageLength {
 		// Send as a single message
-		msg := tgbotapi.NewMessage(parseChatID(b.chatID), text)
-		_, err := b.api.Send(msg)
+		msg := tgbotapi.NewMessage(parseChatID(b.currentChatID()), text)
+		_, err := b.send(msg)
 		if err != nil {
 			return fmt.Errorf("failed to send Telegram message: %w", err)
 		}
@@ -201,10 +488,10 @@ func (b *Bot) sendMessage(text string) error {
 		// Split into multiple messages
 		chunks := splitMessage(text, maxMessageLength)
 		for i, chunk := range chunks {
-			b.logger.Printf("Sending message part %d/%d", i+1, len(chunks))
+			b.logger.Debug("Sending message part", "part", i+1, "parts", len(chunks))
 			
-			msg := tgbotapi.NewMessage(parseChatID(b.chatID), chunk)
-			_, err := b.api.Send(msg)
+			msg := tgbotapi.NewMessage(parseChatID(b.currentChatID()), chunk)
+			_, err := b.send(msg)
 			if err != nil {
 				return fmt.Errorf("failed to send Telegram message part %d: %w", i+1, err)
 			}
@@ -214,86 +501,60 @@ func (b *Bot) sendMessage(text string) error {
 	return nil
 }
 
-// SendPortfolioAnalysis sends a formatted portfolio analysis report along with fresh news articles
-func (b *Bot) SendPortfolioAnalysis(portfolio *invest.Portfolio, analysis *analysis.PortfolioAnalysis, articles []news.Article) error {
-	var sb strings.Builder
-	
-	// Add fresh news section
-	sb.WriteString("📰 *NEWS:*")
-	if len(articles) == 0 {
-		sb.WriteString("\nНет доступных новостей.\n\n")
-	} else {
-		sb.WriteString("\n\n")
-		for _, article := range articles {
-			sb.WriteString(fmt.Sprintf("*%s*\n", article.Title))
-			sb.WriteString(fmt.Sprintf("Source: %s\n", article.Source.Name))
-			sb.WriteString(fmt.Sprintf("Date: %s\n", article.PublishedAt.Format("2006-01-02")))
-			sb.WriteString(fmt.Sprintf("URL: %s\n\n", article.URL))
-		}
-	}
-	
-	// Portfolio analysis header
-	sb.WriteString("📊 *PORTFOLIO ANALYSIS* 📊\n\n")
-	
-	// Add summary
-	sb.WriteString("*SUMMARY:*\n")
-	sb.WriteString(analysis.Summary)
-	sb.WriteString("\n\n")
-	
-	// Add portfolio overview
-	sb.WriteString("*PORTFOLIO OVERVIEW:*\n")
-	sb.WriteString(fmt.Sprintf("Total Value: %.2f %s\n", portfolio.TotalAmount, portfolio.Currency))
-	sb.WriteString(fmt.Sprintf("Expected Yield: %.2f %s\n\n", portfolio.ExpectedYield, portfolio.Currency))
-	
-	// Add recommendations
-	sb.WriteString("*RECOMMENDATIONS:*\n\n")
-	
-	for _, rec := range analysis.Recommendations {
-		// Format action with emoji
-		actionEmoji := "🔄" // HOLD
-		if rec.Action == "BUY" {
-			actionEmoji = "🟢"
-		} else if rec.Action == "SELL" {
-			actionEmoji = "🔴"
+// sendRendered sends a formatted message as MarkdownV2, split into parts that fit Telegram limits.
+// The keyboard, if any, is attached to the last part.
+func (b *Bot) sendRendered(message *render.Message, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	parts := message.Split(render.MarkdownV2, render.MaxMessageLength)
+	for i, part := range parts {
+		msg := tgbotapi.NewMessage(parseChatID(b.currentChatID()), part.Render(render.MarkdownV2))
+		msg.ParseMode = tgbotapi.ModeMarkdownV2
+		if keyboard != nil && i == len(parts)-1 {
+			msg.ReplyMarkup = *keyboard
 		}
 		
-		sb.WriteString(fmt.Sprintf("*%s (%s)* - %s %s\n", rec.Ticker, rec.Name, actionEmoji, rec.Action))
-		sb.WriteString(fmt.Sprintf("_%s_\n\n", rec.Reason))
-	}
-	
-	// After sending recommendations, add opportunities if available
-	if analysis.Opportunities != nil && len(analysis.Opportunities) > 0 {
-		sb.WriteString("\n*OPPORTUNITIES:*\n")
-		for _, opp := range analysis.Opportunities {
-			// Determine emoji for LONG/SHORT
-			actionEmoji := "📈" // default LONG
-			if strings.ToUpper(opp.Action) == "SHORT" {
-				actionEmoji = "📉"
+		_, err := b.send(msg)
+		if err != nil {
+			// If formatting is rejected anyway, send this part without it
+			b.logger.Warn("Formatted message part rejected, sending without formatting", "part", i+1, "parts", len(parts), "error", err)
+			msg.Text = part.Render(render.Plain)
+			msg.ParseMode = ""
+			if _, err := b.send(msg); err != nil {
+				return fmt.Errorf("failed to send Telegram message part %d: %w", i+1, err)
 			}
-			sb.WriteString(fmt.Sprintf("*%s (%s)* - %s %s\n", opp.Ticker, opp.Name, actionEmoji, strings.ToUpper(opp.Action)))
-			sb.WriteString(fmt.Sprintf("_%s_\n\n", opp.Reason))
 		}
 	}
 	
-	// Add monthly reminder if needed
-	if analysis.IsMonthlyReminder {
-		sb.WriteString("\n⚠️ *REMINDER* ⚠️\n")
-		sb.WriteString("Don't forget to add funds and redistribute your portfolio this month!\n")
+	return nil
+}
+
+// SendPortfolioAnalysis sends a formatted portfolio analysis report along with fresh news articles
+func (b *Bot) SendPortfolioAnalysis(portfolio *invest.Portfolio, analysis *analysis.PortfolioAnalysis, articles []news.Article) error {
+	b.mu.Lock()
+	b.lastAnalysis = analysis
+	b.mu.Unlock()
+
+	// Send the report with buttons for details and re-run
+	keyboard := b.reportKeyboard(analysis)
+	var comparison *paper.Comparison
+	if b.paper != nil {
+		comparison = b.paper.Comparison()
 	}
-	
-	// Send the message
-	msg := tgbotapi.NewMessage(parseChatID(b.chatID), sb.String())
-	msg.ParseMode = tgbotapi.ModeMarkdown
-	
-	_, err := b.api.Send(msg)
-	if err != nil {
-		// If markdown fails, try without formatting
-		b.logger.Printf("Error sending formatted message: %v. Trying without markdown", err)
-		plainMsg := tgbotapi.NewMessage(parseChatID(b.chatID), stripMarkdown(sb.String()))
-		_, err = b.api.Send(plainMsg)
-		if err != nil {
-			return fmt.Errorf("failed to send portfolio analysis: %w", err)
+	// The monthly parts are computed by the monthly run just before the report
+	var monthly *MonthlyReview
+	if analysis.IsMonthlyReminder {
+		monthly = &MonthlyReview{}
+		if b.performance != nil {
+			monthly.Returns = b.performance.Latest()
+		}
+		if b.tax != nil {
+			monthly.Tax = b.tax.Latest()
 		}
+		if b.contributions != nil {
+			monthly.Deposits = b.contributions.Latest()
+		}
+	}
+	if err := b.sendRendered(BuildReport(portfolio, analysis, articles, comparison, monthly), &keyboard); err != nil {
+		return fmt.Errorf("failed to send portfolio analysis: %w", err)
 	}
 	
 	return nil
@@ -306,24 +567,25 @@ func parseChatID(chatID string) int64 {
 	return id
 }
 
-// Helper function to split a message into chunks
+// Helper function to split a message into chunks of at most maxLength UTF-16 code units
 func splitMessage(message string, maxLength int) []string {
-	if len(message) <= maxLength {
+	if render.UTF16Len(message) <= maxLength {
 		return []string{message}
 	}
 	
 	var chunks []string
 	for len(message) > 0 {
-		if len(message) <= maxLength {
+		if render.UTF16Len(message) <= maxLength {
 			chunks = append(chunks, message)
 			break
 		}
 		
 		// Try to split at newline to preserve formatting
-		cutIndex := strings.LastIndex(message[:maxLength], "\n")
-		if cutIndex == -1 || cutIndex < maxLength/2 {
-			// If no suitable newline found, split at maxLength
-			cutIndex = maxLength
+		limit := render.CutIndex(message, maxLength)
+		cutIndex := strings.LastIndex(message[:limit], "\n")
+		if cutIndex <= 0 || cutIndex < limit/2 {
+			// If no suitable newline found, split at the limit
+			cutIndex = limit
 		}
 		
 		chunks = append(chunks, message[:cutIndex])
@@ -332,10 +594,3 @@ func splitMessage(message string, maxLength int) []string {
 	
 	return chunks
 }
-
-// Helper function to strip markdown for plain text fallback
-func stripMarkdown(text string) string {
-	text = strings.ReplaceAll(text, "*", "")
-	text = strings.ReplaceAll(text, "_", "")
-	return text
-} 
\ No newline at end of file
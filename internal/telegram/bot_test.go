@@ -0,0 +1,100 @@
+package telegram
+
+import (
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/news"
+	"invest-manager/internal/stops"
+	"invest-manager/internal/telegram/render"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The fakes must stay usable in place of the production dependencies
+var (
+	_ Broker     = (*fake.Broker)(nil)
+	_ Trader     = (*fake.Broker)(nil)
+	_ StopOrders = (*fake.Broker)(nil)
+	_ NewsSource = (*fake.News)(nil)
+
+	_ Broker     = (*invest.Client)(nil)
+	_ Trader     = (*invest.Client)(nil)
+	_ StopOrders = (*invest.Client)(nil)
+	_ Analyzer   = (*analysis.Analyzer)(nil)
+	_ NewsSource = (*news.Fetcher)(nil)
+
+	_ stops.Notifier = (*Bot)(nil)
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		maxLength int
+		want      []string
+	}{
+		{
+			name:      "short message is kept whole",
+			message:   "hello",
+			maxLength: 10,
+			want:      []string{"hello"},
+		},
+		{
+			name:      "exact length is kept whole",
+			message:   "0123456789",
+			maxLength: 10,
+			want:      []string{"0123456789"},
+		},
+		{
+			name:      "splits at the last newline",
+			message:   "first line\nsecond\nthird line",
+			maxLength: 20,
+			want:      []string{"first line\nsecond", "\nthird line"},
+		},
+		{
+			name:      "newline too early is ignored",
+			message:   "a\nbcdefghijklmnop",
+			maxLength: 8,
+			want:      []string{"a\nbcdefg", "hijklmno", "p"},
+		},
+		{
+			name:      "no newline splits at the limit",
+			message:   strings.Repeat("x", 25),
+			maxLength: 10,
+			want:      []string{strings.Repeat("x", 10), strings.Repeat("x", 10), strings.Repeat("x", 5)},
+		},
+		{
+			name:      "emoji count as two UTF-16 units",
+			message:   "📊📊📊📊",
+			maxLength: 4,
+			want:      []string{"📊📊", "📊📊"},
+		},
+		{
+			name:      "cyrillic is not cut inside a rune",
+			message:   "привет мир",
+			maxLength: 4,
+			want:      []string{"прив", "ет м", "ир"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.message, tt.maxLength)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMessage() = %q, want %q", got, tt.want)
+			}
+
+			// Nothing is lost and no chunk exceeds the limit
+			if joined := strings.Join(got, ""); joined != tt.message {
+				t.Errorf("chunks join to %q, want %q", joined, tt.message)
+			}
+			for _, chunk := range got {
+				if n := render.UTF16Len(chunk); n > tt.maxLength {
+					t.Errorf("chunk %q has %d UTF-16 units, limit %d", chunk, n, tt.maxLength)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Lifetimes of inline keyboard state
+const (
+	reportButtonsTTL = 24 * time.Hour
+	pageButtonsTTL   = 30 * time.Minute
+	confirmTTL       = 5 * time.Minute
+)
+
+// Callback actions understood by the router
+const (
+	actionNoop    = "noop"
+	actionRerun   = "rerun"
+	actionDetails = "details"
+	actionPage    = "page"
+	actionAccount = "account"
+	actionConfirm = "confirm"
+	actionTrade   = "trade"
+)
+
+// callbackHandler processes a pressed inline button with its stored payload
+type callbackHandler func(ctx context.Context, query *tgbotapi.CallbackQuery, payload any) error
+
+// callbackState is the server-side state behind a single inline button
+type callbackState struct {
+	action  string
+	payload any
+	expires time.Time
+}
+
+// callbackRouter maps inline buttons to typed handlers.
+// Telegram limits callback data to 64 bytes, so buttons only carry a random
+// key and the payload itself stays in memory until it expires.
+type callbackRouter struct {
+	mu       sync.Mutex
+	handlers map[string]callbackHandler
+	states   map[string]callbackState
+	now      func() time.Time
+}
+
+// errCallbackExpired is returned for unknown or outdated buttons
+var errCallbackExpired = fmt.Errorf("callback expired")
+
+// newCallbackRouter creates an empty router
+func newCallbackRouter() *callbackRouter {
+	return &callbackRouter{
+		handlers: make(map[string]callbackHandler),
+		states:   make(map[string]callbackState),
+		now:      time.Now,
+	}
+}
+
+// registerCallback binds an action to a handler that receives a payload of type T
+func registerCallback[T any](r *callbackRouter, action string, handler func(ctx context.Context, query *tgbotapi.CallbackQuery, payload T) error) {
+	r.handlers[action] = func(ctx context.Context, query *tgbotapi.CallbackQuery, payload any) error {
+		typed, ok := payload.(T)
+		if !ok {
+			return fmt.Errorf("unexpected payload %T for callback action %q", payload, action)
+		}
+		return handler(ctx, query, typed)
+	}
+}
+
+// put stores a payload for an action and returns the callback data for a button
+func (r *callbackRouter) put(action string, payload any, ttl time.Duration) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.purgeExpired(now)
+
+	key := newCallbackKey()
+	r.states[key] = callbackState{
+		action:  action,
+		payload: payload,
+		expires: now.Add(ttl),
+	}
+	return key
+}
+
+// button creates an inline button bound to an action and its payload
+func (r *callbackRouter) button(text, action string, payload any, ttl time.Duration) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(text, r.put(action, payload, ttl))
+}
+
+// dispatch finds the state behind callback data and runs its handler
+func (r *callbackRouter) dispatch(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	r.mu.Lock()
+	state, ok := r.states[query.Data]
+	if ok && r.now().After(state.expires) {
+		delete(r.states, query.Data)
+		ok = false
+	}
+	var handler callbackHandler
+	if ok {
+		handler = r.handlers[state.action]
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return errCallbackExpired
+	}
+	if handler == nil {
+		return fmt.Errorf("no handler for callback action %q", state.action)
+	}
+	return handler(ctx, query, state.payload)
+}
+
+// purgeExpired drops outdated states; the caller must hold the lock
+func (r *callbackRouter) purgeExpired(now time.Time) {
+	for key, state := range r.states {
+		if now.After(state.expires) {
+			delete(r.states, key)
+		}
+	}
+}
+
+// newCallbackKey generates a random key for callback data
+func newCallbackKey() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand never fails on supported platforms, fall back to time just in case
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
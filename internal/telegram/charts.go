@@ -0,0 +1,333 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/charts"
+	"invest-manager/internal/invest"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Chart types available through /chart
+const (
+	chartValue      = "value"
+	chartAllocation = "allocation"
+	chartPosition   = "position"
+	chartPnL        = "pnl"
+	chartPaper      = "paper"
+)
+
+// chartHistoryDays is how far back value and price charts look
+const chartHistoryDays = 90
+
+// maxMediaGroupSize is the Telegram limit of photos in a single media group
+const maxMediaGroupSize = 10
+
+// chartImage is a rendered chart ready to be sent
+type chartImage struct {
+	name string
+	data []byte
+}
+
+// handleChartCommand renders a chart on demand
+func (b *Bot) handleChartCommand(ctx context.Context, message *tgbotapi.Message) {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		b.sendMessage("Укажите тип графика: /chart value, allocation, pnl, paper или position SBER")
+		return
+	}
+	kind := strings.ToLower(args[0])
+	if kind == chartPosition && len(args) < 2 {
+		b.sendMessage("Укажите тикер, например: /chart position SBER")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+
+		portfolio, err := b.investor.GetPortfolio(ctx)
+		if err != nil {
+			b.replyError(ctx, "Ошибка при получении портфеля", err)
+			return
+		}
+
+		var images []chartImage
+		switch kind {
+		case chartValue:
+			var img chartImage
+			img, err = b.buildValueChart(ctx, portfolio)
+			images = append(images, img)
+		case chartAllocation:
+			images, err = buildAllocationCharts(portfolio)
+		case chartPnL:
+			var img chartImage
+			img, err = buildPnLChart(portfolio)
+			images = append(images, img)
+		case chartPaper:
+			var img chartImage
+			img, err = b.buildPaperChart(portfolio)
+			images = append(images, img)
+		case chartPosition:
+			pos := portfolio.FindPosition(args[1])
+			if pos == nil {
+				b.sendMessage(fmt.Sprintf("Позиция %s не найдена в портфеле.", strings.ToUpper(args[1])))
+				return
+			}
+			var img chartImage
+			img, err = b.buildPositionChart(ctx, pos)
+			images = append(images, img)
+		default:
+			b.sendMessage("Неизвестный тип графика. Доступны: value, allocation, pnl, paper, position")
+			return
+		}
+		if err != nil {
+			b.replyError(ctx, "Ошибка при построении графика", err)
+			return
+		}
+
+		if err := b.sendCharts(images); err != nil {
+			b.replyError(ctx, "Ошибка при отправке графика", err)
+		}
+	}()
+}
+
+// SendPortfolioCharts sends the charts that accompany the daily report.
+// Charts that cannot be built are skipped.
+func (b *Bot) SendPortfolioCharts(ctx context.Context, portfolio *invest.Portfolio) error {
+	var images []chartImage
+
+	if img, err := b.buildValueChart(ctx, portfolio); err != nil {
+		b.logger.WarnContext(ctx, "Could not build value chart", "error", err)
+	} else {
+		images = append(images, img)
+	}
+
+	if allocation, err := buildAllocationCharts(portfolio); err != nil {
+		b.logger.WarnContext(ctx, "Could not build allocation charts", "error", err)
+	} else {
+		images = append(images, allocation...)
+	}
+
+	if img, err := buildPnLChart(portfolio); err != nil {
+		b.logger.WarnContext(ctx, "Could not build P&L chart", "error", err)
+	} else {
+		images = append(images, img)
+	}
+
+	if len(images) == 0 {
+		return fmt.Errorf("no charts could be built")
+	}
+	return b.sendCharts(images)
+}
+
+// sendCharts sends one chart as a photo and several as a media group
+func (b *Bot) sendCharts(images []chartImage) error {
+	chatID := parseChatID(b.currentChatID())
+
+	if len(images) == 1 {
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: images[0].name, Bytes: images[0].data})
+		if _, err := b.send(photo); err != nil {
+			return fmt.Errorf("failed to send chart: %w", err)
+		}
+		return nil
+	}
+
+	for start := 0; start < len(images); start += maxMediaGroupSize {
+		end := start + maxMediaGroupSize
+		if end > len(images) {
+			end = len(images)
+		}
+
+		media := make([]interface{}, 0, end-start)
+		for _, img := range images[start:end] {
+			media = append(media, tgbotapi.NewInputMediaPhoto(tgbotapi.FileBytes{Name: img.name, Bytes: img.data}))
+		}
+		if _, err := b.sendMediaGroup(tgbotapi.NewMediaGroup(chatID, media)); err != nil {
+			return fmt.Errorf("failed to send charts: %w", err)
+		}
+	}
+	return nil
+}
+
+// buildValueChart renders the portfolio value over the last months
+func (b *Bot) buildValueChart(ctx context.Context, portfolio *invest.Portfolio) (chartImage, error) {
+	history, err := b.investor.GetPortfolioHistory(ctx, portfolio, time.Now().AddDate(0, 0, -chartHistoryDays))
+	if err != nil {
+		return chartImage{}, err
+	}
+
+	points := make([]charts.Point, 0, len(history))
+	for _, p := range history {
+		points = append(points, charts.Point{Time: p.Time, Value: p.Value})
+	}
+
+	data, err := charts.Line(fmt.Sprintf("Стоимость портфеля, %s", portfolio.Currency),
+		[]charts.Series{{Points: points}}, nil)
+	if err != nil {
+		return chartImage{}, err
+	}
+	return chartImage{name: "value.png", data: data}, nil
+}
+
+// buildPaperChart renders the paper portfolio value next to the real one
+func (b *Bot) buildPaperChart(portfolio *invest.Portfolio) (chartImage, error) {
+	if b.paper == nil {
+		return chartImage{}, fmt.Errorf("paper portfolio is disabled")
+	}
+	equity := b.paper.Equity()
+	if len(equity) == 0 {
+		return chartImage{}, fmt.Errorf("paper portfolio has no history yet")
+	}
+
+	paperPoints := make([]charts.Point, 0, len(equity))
+	realPoints := make([]charts.Point, 0, len(equity))
+	for _, p := range equity {
+		paperPoints = append(paperPoints, charts.Point{Time: p.Time, Value: p.Paper})
+		realPoints = append(realPoints, charts.Point{Time: p.Time, Value: p.Real})
+	}
+
+	data, err := charts.Line(fmt.Sprintf("Бумажный и реальный портфель, %s", portfolio.Currency),
+		[]charts.Series{{Name: "Бумажный", Points: paperPoints}, {Name: "Реальный", Points: realPoints}}, nil)
+	if err != nil {
+		return chartImage{}, err
+	}
+	return chartImage{name: "paper.png", data: data}, nil
+}
+
+// buildAllocationCharts renders allocation pies by sector, by currency and by asset class
+func buildAllocationCharts(portfolio *invest.Portfolio) ([]chartImage, error) {
+	sectors := make(map[string]float64)
+	currencies := make(map[string]float64)
+	for _, pos := range portfolio.Positions {
+		sectors[positionSector(pos)] += pos.Value()
+		currencies[positionCurrency(pos)] += pos.Value()
+	}
+
+	sectorChart, err := charts.Pie("Распределение по секторам", toSlices(sectors))
+	if err != nil {
+		return nil, err
+	}
+	currencyChart, err := charts.Pie("Распределение по валютам", toSlices(currencies))
+	if err != nil {
+		return nil, err
+	}
+
+	images := []chartImage{
+		{name: "sectors.png", data: sectorChart},
+		{name: "currencies.png", data: currencyChart},
+	}
+
+	// Asset classes come from the classified portfolio, which a replayed old report may lack
+	if exposures := portfolio.ExposuresBy(invest.GroupAssetClass); len(exposures) > 0 {
+		classes := make(map[string]float64, len(exposures))
+		for _, e := range exposures {
+			classes[assetClassTitle(e.Name)] += e.Value
+		}
+		classChart, err := charts.Pie("Распределение по классам активов", toSlices(classes))
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, chartImage{name: "asset-classes.png", data: classChart})
+	}
+	return images, nil
+}
+
+// assetClassTitles names the asset classes in the charts
+var assetClassTitles = map[string]string{
+	invest.AssetEquity:     "Акции",
+	invest.AssetBond:       "Облигации",
+	invest.AssetFund:       "Фонды",
+	invest.AssetCash:       "Валюта",
+	invest.AssetDerivative: "Деривативы",
+	invest.AssetOther:      "Прочее",
+}
+
+// assetClassTitle returns the chart label of an asset class
+func assetClassTitle(class string) string {
+	if title, ok := assetClassTitles[class]; ok {
+		return title
+	}
+	return "Не указан"
+}
+
+// buildPositionChart renders the price of a position along with its average cost
+func (b *Bot) buildPositionChart(ctx context.Context, pos *invest.Position) (chartImage, error) {
+	now := time.Now()
+	candles, err := b.investor.GetDailyCandles(ctx, pos.FIGI, now.AddDate(0, 0, -chartHistoryDays), now)
+	if err != nil {
+		return chartImage{}, err
+	}
+
+	points := make([]charts.Point, 0, len(candles))
+	for _, candle := range candles {
+		points = append(points, charts.Point{Time: candle.Time, Value: candle.Close})
+	}
+
+	data, err := charts.Line(fmt.Sprintf("%s (%s)", pos.Ticker, pos.Name),
+		[]charts.Series{{Name: "Цена закрытия", Points: points}},
+		[]charts.Level{{Name: fmt.Sprintf("Средняя цена %.2f", pos.AveragePrice), Value: pos.AveragePrice}})
+	if err != nil {
+		return chartImage{}, err
+	}
+	return chartImage{name: strings.ToLower(pos.Ticker) + ".png", data: data}, nil
+}
+
+// buildPnLChart renders unrealized P&L of every position
+func buildPnLChart(portfolio *invest.Portfolio) (chartImage, error) {
+	var bars []charts.Bar
+	for _, pos := range portfolio.Positions {
+		if pos.InstrumentType == "currency" {
+			continue
+		}
+		bars = append(bars, charts.Bar{Label: pos.Ticker, Value: pos.ExpectedYield})
+	}
+	sort.Slice(bars, func(i, j int) bool {
+		return bars[i].Value > bars[j].Value
+	})
+
+	data, err := charts.Bars(fmt.Sprintf("P&L по позициям, %s", portfolio.Currency), bars)
+	if err != nil {
+		return chartImage{}, err
+	}
+	return chartImage{name: "pnl.png", data: data}, nil
+}
+
+// positionSector returns the sector of a position for allocation charts
+func positionSector(pos invest.Position) string {
+	switch {
+	case pos.InstrumentType == "currency":
+		return "Валюта"
+	case pos.Sector != "":
+		return pos.Sector
+	}
+	return "Не указан"
+}
+
+// positionCurrency returns the currency a position is denominated in
+func positionCurrency(pos invest.Position) string {
+	switch {
+	case pos.AssetCurrency != "":
+		return pos.AssetCurrency
+	case pos.InstrumentType == "currency" && len(pos.Ticker) >= 3:
+		// Currency tickers look like USD000UTSTOM
+		return strings.ToUpper(pos.Ticker[:3])
+	}
+	return pos.Currency
+}
+
+// toSlices converts aggregated values to pie slices
+func toSlices(values map[string]float64) []charts.Slice {
+	slices := make([]charts.Slice, 0, len(values))
+	for label, value := range values {
+		slices = append(slices, charts.Slice{Label: label, Value: value})
+	}
+	sort.Slice(slices, func(i, j int) bool {
+		return slices[i].Label < slices[j].Label
+	})
+	return slices
+}
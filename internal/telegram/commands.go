@@ -0,0 +1,458 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/news"
+	"invest-manager/internal/paper"
+	"invest-manager/internal/usage"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// commandTimeout limits how long a single data command may talk to the broker
+const commandTimeout = 30 * time.Second
+
+// handlePortfolioCommand shows current positions with their weights and P&L
+func (b *Bot) handlePortfolioCommand(ctx context.Context, message *tgbotapi.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+
+		portfolio, err := b.investor.GetPortfolio(ctx)
+		if err != nil {
+			b.replyError(ctx, "Ошибка при получении портфеля", err)
+			return
+		}
+
+		text, keyboard := b.portfolioPage(portfolio, 0)
+		if err := b.sendWithKeyboard(text, keyboard); err != nil {
+			b.logger.ErrorContext(ctx, "Error sending portfolio", "error", err)
+		}
+	}()
+}
+
+// handlePositionCommand shows details of a single position
+func (b *Bot) handlePositionCommand(ctx context.Context, message *tgbotapi.Message) {
+	ticker := strings.TrimSpace(message.CommandArguments())
+	if ticker == "" {
+		b.sendMessage("Укажите тикер, например: /position SBER")
+		return
+	}
+
+	go b.showPosition(ctx, ticker)
+}
+
+// handleAccountCommand shows the account picker
+func (b *Bot) handleAccountCommand(ctx context.Context, message *tgbotapi.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+
+		accounts, err := b.investor.GetAccounts(ctx)
+		if err != nil {
+			b.replyError(ctx, "Ошибка при получении счетов", err)
+			return
+		}
+		if len(accounts) == 0 {
+			b.sendMessage("Нет доступных счетов.")
+			return
+		}
+
+		keyboard := b.accountsKeyboard(accounts, b.investor.AccountID())
+		if err := b.sendWithKeyboard("Выберите счёт для отчётов:", &keyboard); err != nil {
+			b.logger.ErrorContext(ctx, "Error sending account picker", "error", err)
+		}
+	}()
+}
+
+// showPosition sends the detailed view of a position
+func (b *Bot) showPosition(ctx context.Context, ticker string) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	portfolio, err := b.investor.GetPortfolio(ctx)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при получении портфеля", err)
+		return
+	}
+
+	pos := portfolio.FindPosition(ticker)
+	if pos == nil {
+		b.sendMessage(fmt.Sprintf("Позиция %s не найдена в портфеле.", strings.ToUpper(ticker)))
+		return
+	}
+
+	// Price history and news are optional parts of the view
+	now := time.Now()
+	candles, err := b.investor.GetDailyCandles(ctx, pos.FIGI, now.AddDate(0, 0, -7), now)
+	if err != nil {
+		b.logger.WarnContext(ctx, "Could not get price history", "ticker", pos.Ticker, "error", err)
+	}
+
+	articles, err := b.newsFetcher.FetchNews(positionNewsQuery(pos), 3)
+	if err != nil {
+		b.logger.WarnContext(ctx, "Could not fetch news", "ticker", pos.Ticker, "error", err)
+	}
+
+	b.sendMessage(formatPosition(portfolio, pos, candles, articles, b.lastRecommendation(pos.Ticker)))
+}
+
+// handleNewsCommand shows fresh news, optionally about a specific ticker
+func (b *Bot) handleNewsCommand(ctx context.Context, message *tgbotapi.Message) {
+	if !b.newsFetcher.Enabled() {
+		b.sendMessage("Новости отключены в конфигурации.")
+		return
+	}
+	ticker := strings.TrimSpace(message.CommandArguments())
+
+	go func() {
+		var articles []news.Article
+		var err error
+		if ticker == "" {
+			articles, err = b.newsFetcher.FetchMarketNews()
+		} else {
+			query := ticker
+
+			// Prefer the company name when the ticker is held in the portfolio
+			ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+			defer cancel()
+			if portfolio, err := b.investor.GetPortfolio(ctx); err == nil {
+				if pos := portfolio.FindPosition(ticker); pos != nil {
+					query = positionNewsQuery(pos)
+				}
+			}
+			articles, err = b.newsFetcher.FetchNews(query, 5)
+		}
+		if err != nil {
+			b.replyError(ctx, "Ошибка при получении новостей", err)
+			return
+		}
+
+		b.sendMessage(formatNews(articles))
+	}()
+}
+
+// handlePnLCommand shows how the portfolio value changed over a period
+func (b *Bot) handlePnLCommand(ctx context.Context, message *tgbotapi.Message) {
+	from, label, ok := parsePeriod(message.CommandArguments(), time.Now())
+	if !ok {
+		b.sendMessage("Неизвестный период. Используйте: /pnl day, week, month или year")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+
+		pnl, err := b.investor.GetPeriodPnL(ctx, from)
+		if err != nil {
+			b.replyError(ctx, "Ошибка при расчёте доходности", err)
+			return
+		}
+
+		b.sendMessage(formatPnL(pnl, label))
+	}()
+}
+
+// handleUsageCommand shows the LLM usage of the day and month against the budget
+func (b *Bot) handleUsageCommand(message *tgbotapi.Message) {
+	report := b.analyzer.UsageReport()
+	if report == nil {
+		b.sendMessage("Учёт расхода LLM не ведётся.")
+		return
+	}
+	b.sendMessage(formatUsage(report))
+}
+
+// paperTradesShown is how many recent paper trades /paper lists
+const paperTradesShown = 10
+
+// handlePaperCommand shows the paper portfolio and how it compares with the real one
+func (b *Bot) handlePaperCommand(message *tgbotapi.Message) {
+	if b.paper == nil || !b.paper.Enabled() {
+		b.sendMessage("Бумажный портфель отключён. Включите paper.enabled в конфигурации.")
+		return
+	}
+	comparison := b.paper.Comparison()
+	if comparison == nil {
+		b.sendMessage("Бумажный портфель начнёт торговать после следующего анализа.")
+		return
+	}
+	b.sendMessage(formatPaper(b.paper.Snapshot(), comparison))
+}
+
+// replyError logs an error and reports it to the chat
+func (b *Bot) replyError(ctx context.Context, prefix string, err error) {
+	b.logger.ErrorContext(ctx, prefix, "error", err)
+	b.sendMessage(fmt.Sprintf("%s: %v", prefix, err))
+}
+
+// lastRecommendation returns the recommendation for a ticker from the latest report
+func (b *Bot) lastRecommendation(ticker string) *analysis.Recommendation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.lastAnalysis == nil {
+		return nil
+	}
+	for _, rec := range b.lastAnalysis.Recommendations {
+		if strings.EqualFold(rec.Ticker, ticker) {
+			rec := rec
+			return &rec
+		}
+	}
+	return nil
+}
+
+// parsePeriod converts a period argument into the start of the period and its label
+func parsePeriod(arg string, now time.Time) (time.Time, string, bool) {
+	switch strings.ToLower(strings.TrimSpace(arg)) {
+	case "day", "d", "1d":
+		return now.AddDate(0, 0, -1), "за день", true
+	case "", "week", "w", "7d":
+		return now.AddDate(0, 0, -7), "за неделю", true
+	case "month", "m", "30d":
+		return now.AddDate(0, -1, 0), "за месяц", true
+	case "year", "y", "1y":
+		return now.AddDate(-1, 0, 0), "за год", true
+	}
+	return time.Time{}, "", false
+}
+
+// positionNewsQuery builds a news search query for a position
+func positionNewsQuery(pos *invest.Position) string {
+	if pos.Name == "" || pos.Name == pos.InstrumentType {
+		return pos.Ticker
+	}
+	return fmt.Sprintf("\"%s\" OR %s", pos.Name, pos.Ticker)
+}
+
+// formatPortfolioPage renders a page of positions sorted by value with weights and P&L.
+// It returns the text and the total number of pages.
+func formatPortfolioPage(portfolio *invest.Portfolio, page, perPage int) (string, int) {
+	var sb strings.Builder
+
+	sb.WriteString("💼 ПОРТФЕЛЬ\n\n")
+	sb.WriteString(fmt.Sprintf("Стоимость: %.2f %s\n", portfolio.TotalAmount, portfolio.Currency))
+	sb.WriteString(fmt.Sprintf("Доходность: %+.2f %s\n\n", portfolio.ExpectedYield, portfolio.Currency))
+
+	if len(portfolio.Positions) == 0 {
+		sb.WriteString("Портфель пуст.\n")
+		return sb.String(), 1
+	}
+
+	positions := make([]invest.Position, len(portfolio.Positions))
+	copy(positions, portfolio.Positions)
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Value() > positions[j].Value()
+	})
+
+	pages := (len(positions) + perPage - 1) / perPage
+	if page < 0 || page >= pages {
+		page = 0
+	}
+	start := page * perPage
+	end := start + perPage
+	if end > len(positions) {
+		end = len(positions)
+	}
+
+	for _, pos := range positions[start:end] {
+		sb.WriteString(fmt.Sprintf("%s %s (%s)\n", yieldEmoji(pos.ExpectedYield), pos.Ticker, pos.Name))
+		sb.WriteString(fmt.Sprintf("  %g шт. × %.2f = %.2f %s\n", pos.Quantity, pos.CurrentPrice, pos.Value(), pos.Currency))
+		sb.WriteString(fmt.Sprintf("  Доля: %.1f%% | P&L: %+.2f%% (%+.2f %s)\n\n",
+			portfolio.Weight(pos), pos.YieldPercent(), pos.ExpectedYield, pos.Currency))
+	}
+
+	return sb.String(), pages
+}
+
+// formatPosition renders the detailed view of a single position
+func formatPosition(portfolio *invest.Portfolio, pos *invest.Position, candles []invest.Candle,
+	articles []news.Article, rec *analysis.Recommendation) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("%s %s (%s)\n", yieldEmoji(pos.ExpectedYield), pos.Ticker, pos.Name))
+	sb.WriteString(fmt.Sprintf("Тип: %s | FIGI: %s\n\n", pos.InstrumentType, pos.FIGI))
+	sb.WriteString(fmt.Sprintf("Количество: %g\n", pos.Quantity))
+	sb.WriteString(fmt.Sprintf("Средняя цена: %.2f %s\n", pos.AveragePrice, pos.Currency))
+	sb.WriteString(fmt.Sprintf("Текущая цена: %.2f %s\n", pos.CurrentPrice, pos.Currency))
+	sb.WriteString(fmt.Sprintf("Стоимость: %.2f %s\n", pos.Value(), pos.Currency))
+	sb.WriteString(fmt.Sprintf("Доля в портфеле: %.1f%%\n", portfolio.Weight(*pos)))
+	sb.WriteString(fmt.Sprintf("P&L: %+.2f%% (%+.2f %s)\n", pos.YieldPercent(), pos.ExpectedYield, pos.Currency))
+
+	if len(candles) > 0 {
+		sb.WriteString("\n📈 Цены закрытия:\n")
+		for _, candle := range candles {
+			sb.WriteString(fmt.Sprintf("%s: %.2f\n", candle.Time.Format("02.01"), candle.Close))
+		}
+	}
+
+	if rec != nil {
+		sb.WriteString(fmt.Sprintf("\n🤖 Последняя рекомендация: %s %s\n", actionEmoji(rec.Action), rec.Action))
+		if rec.Reason != "" {
+			sb.WriteString(rec.Reason + "\n")
+		}
+	}
+
+	if len(articles) > 0 {
+		sb.WriteString("\n📰 Новости:\n")
+		for _, article := range articles {
+			sb.WriteString(fmt.Sprintf("• %s (%s, %s)\n%s\n",
+				article.Title, article.Source.Name, article.PublishedAt.Format("2006-01-02"), article.URL))
+		}
+	}
+
+	return sb.String()
+}
+
+// formatNews renders a list of news articles
+func formatNews(articles []news.Article) string {
+	if len(articles) == 0 {
+		return "📰 Нет доступных новостей."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📰 НОВОСТИ\n\n")
+	for _, article := range articles {
+		sb.WriteString(article.Title + "\n")
+		sb.WriteString(fmt.Sprintf("%s, %s\n", article.Source.Name, article.PublishedAt.Format("2006-01-02")))
+		sb.WriteString(article.URL + "\n\n")
+	}
+	return sb.String()
+}
+
+// formatPnL renders portfolio P&L over a period
+func formatPnL(pnl *invest.PeriodPnL, label string) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("📊 P&L %s (с %s)\n\n", label, pnl.From.Format("02.01.2006")))
+	sb.WriteString(fmt.Sprintf("Итого: %+.2f %s (%+.2f%%)\n\n", pnl.Total, pnl.Currency, pnl.TotalPct))
+
+	positions := make([]invest.PositionPnL, len(pnl.Positions))
+	copy(positions, pnl.Positions)
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].Change > positions[j].Change
+	})
+
+	for _, p := range positions {
+		sb.WriteString(fmt.Sprintf("%s %s: %+.2f %s (%+.2f%%)\n",
+			yieldEmoji(p.Change), p.Position.Ticker, p.Change, p.Position.Currency, p.ChangePct))
+	}
+
+	sb.WriteString("\nРасчёт учитывает только изменение цены текущих позиций.")
+	return sb.String()
+}
+
+// formatUsage renders the LLM usage of the day and month with the budget
+func formatUsage(report *usage.Report) string {
+	var sb strings.Builder
+
+	sb.WriteString("🧮 РАСХОД LLM\n\n")
+	sb.WriteString(formatUsagePeriod("Сегодня", report.Today.Total, report.Budget.Daily))
+	sb.WriteString(formatUsagePeriod("С начала месяца", report.Month.Total, report.Budget.Monthly))
+
+	for _, group := range []struct {
+		title  string
+		totals map[string]usage.Totals
+	}{
+		{"По задачам", report.Month.ByJob},
+		{"По моделям", report.Month.ByModel},
+		{"По пользователям", report.Month.ByUser},
+	} {
+		if len(group.totals) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(group.totals))
+		for key := range group.totals {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return group.totals[keys[i]].Cost > group.totals[keys[j]].Cost
+		})
+
+		sb.WriteString("\n" + group.title + " за месяц:\n")
+		for _, key := range keys {
+			t := group.totals[key]
+			sb.WriteString(fmt.Sprintf("• %s: %d запр., %d ток., $%.2f\n", key, t.Calls, t.Tokens(), t.Cost))
+		}
+	}
+
+	if report.Budget.FallbackModel != "" && (report.Budget.Daily > 0 || report.Budget.Monthly > 0) {
+		sb.WriteString(fmt.Sprintf("\nПри превышении бюджета используется %s.", report.Budget.FallbackModel))
+	}
+	return sb.String()
+}
+
+// formatPaper renders the paper holdings, the comparison with the real account and the latest trades
+func formatPaper(snapshot paper.Snapshot, comparison *paper.Comparison) string {
+	var sb strings.Builder
+
+	sb.WriteString("📝 БУМАЖНЫЙ ПОРТФЕЛЬ\n\n")
+	sb.WriteString(fmt.Sprintf("С %s\n", comparison.Since.Format("02.01.2006")))
+	sb.WriteString(fmt.Sprintf("%s Бумажный: %.2f (%+.2f%%)\n", yieldEmoji(comparison.PaperReturn), comparison.Paper, comparison.PaperReturn))
+	sb.WriteString(fmt.Sprintf("%s Реальный: %.2f (%+.2f%%)\n", yieldEmoji(comparison.RealReturn), comparison.Real, comparison.RealReturn))
+	sb.WriteString(fmt.Sprintf("Деньги: %.2f\n", snapshot.Cash))
+
+	if len(snapshot.Holdings) > 0 {
+		sb.WriteString("\nПозиции:\n")
+		for _, h := range snapshot.Holdings {
+			yield := (h.LastPrice - h.AveragePrice) * h.Quantity
+			sb.WriteString(fmt.Sprintf("%s %s: %g шт., %.2f (%+.2f)\n", yieldEmoji(yield), h.Ticker, h.Quantity, h.Value(), yield))
+		}
+	}
+
+	trades := snapshot.Trades
+	if len(trades) > paperTradesShown {
+		trades = trades[len(trades)-paperTradesShown:]
+	}
+	if len(trades) > 0 {
+		sb.WriteString("\nПоследние сделки:\n")
+		for i := len(trades) - 1; i >= 0; i-- {
+			t := trades[i]
+			sb.WriteString(fmt.Sprintf("%s %s %s %g × %.2f\n", t.Time.Format("02.01"), actionEmoji(t.Action), t.Ticker, t.Quantity, t.Price))
+		}
+	}
+	return sb.String()
+}
+
+// formatUsagePeriod renders the totals of a period and its budget, if any
+func formatUsagePeriod(label string, t usage.Totals, budget float64) string {
+	line := fmt.Sprintf("%s: %d запр., %d ток. (%d + %d), $%.2f",
+		label, t.Calls, t.Tokens(), t.PromptTokens, t.CompletionTokens, t.Cost)
+	if budget > 0 {
+		line += fmt.Sprintf(" из $%.2f", budget)
+		if t.Cost >= budget {
+			line += " ⚠️"
+		}
+	}
+	return line + "\n"
+}
+
+// yieldEmoji picks an emoji for a positive or negative result
+func yieldEmoji(value float64) string {
+	switch {
+	case value > 0:
+		return "🟢"
+	case value < 0:
+		return "🔴"
+	}
+	return "⚪"
+}
+
+// actionEmoji picks an emoji for a recommendation action
+func actionEmoji(action string) string {
+	switch action {
+	case "BUY":
+		return "🟢"
+	case "SELL":
+		return "🔴"
+	}
+	return "🔄"
+}
@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/contributions"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handlePlanCommand checks the deposits of the month and year against the contribution plan
+func (b *Bot) handlePlanCommand(ctx context.Context, message *tgbotapi.Message) {
+	if b.contributions == nil || !b.contributions.Enabled() {
+		b.sendMessage("План пополнений не задан. Добавьте contributions.plans в конфигурацию.")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+
+		status, err := b.contributions.Refresh(ctx, b.localNow())
+		if err != nil {
+			b.replyError(ctx, "Ошибка при проверке пополнений", err)
+			return
+		}
+		b.sendMessage(formatPlan(status))
+	}()
+}
+
+// formatPlan lays out the deposits against the plan for /plan
+func formatPlan(status *contributions.Status) string {
+	var sb strings.Builder
+	sb.WriteString("💰 ПЛАН ПОПОЛНЕНИЙ\n")
+	for _, p := range status.Accounts {
+		sb.WriteString(fmt.Sprintf("\n%s\n", accountTitle(p)))
+		if p.Missing > 0 {
+			sb.WriteString(fmt.Sprintf("⚠️ В этом месяце: %.0f из %.0f, осталось %.0f\n", p.Deposited, p.Monthly, p.Missing))
+		} else {
+			sb.WriteString(fmt.Sprintf("✅ В этом месяце: %.0f из %.0f\n", p.Deposited, p.Monthly))
+		}
+		sb.WriteString(fmt.Sprintf("За год: %.0f из %.0f (%.0f%%)%s\n", p.YearTotal, p.Yearly, percentOf(p.YearTotal, p.Yearly),
+			onTrackMark(p.OnTrack)))
+	}
+	if len(status.Split) > 0 {
+		sb.WriteString(fmt.Sprintf("\nКак вложить %.0f по целевым долям:\n", status.Amount))
+		for _, s := range status.Split {
+			sb.WriteString(fmt.Sprintf("%s: %.0f (сейчас %.0f%%, цель %.0f%%)\n", s.Ticker, s.Amount, s.Current*100, s.Target*100))
+		}
+	}
+	return sb.String()
+}
+
+// accountTitle names an account of the plan, by its ID if the broker has no name for it
+func accountTitle(p contributions.Progress) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Account
+}
+
+// percentOf returns part as a percentage of whole, 0 for an empty whole
+func percentOf(part, whole float64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return part / whole * 100
+}
+
+// onTrackMark flags a yearly goal behind its schedule in /plan
+func onTrackMark(onTrack bool) string {
+	if onTrack {
+		return ""
+	}
+	return ", отстаёт от графика"
+}
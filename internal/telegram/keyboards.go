@@ -0,0 +1,231 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// positionsPerPage limits how many positions are shown in a single portfolio message
+const positionsPerPage = 10
+
+// detailsPayload identifies the position behind a "Details" button
+type detailsPayload struct {
+	Ticker string
+}
+
+// pagePayload holds a portfolio snapshot and the page to show
+type pagePayload struct {
+	Portfolio *invest.Portfolio
+	Page      int
+}
+
+// accountPayload identifies the account behind an account picker button
+type accountPayload struct {
+	Account invest.Account
+}
+
+// tradePayload identifies the recommendation behind a trade picker button
+type tradePayload struct {
+	Ticker string
+}
+
+// confirmation is a pending destructive action shared by its confirm and cancel buttons
+type confirmation struct {
+	question  string
+	once      sync.Once
+	onConfirm func() string
+}
+
+// confirmPayload is attached to the confirm and cancel buttons of a confirmation
+type confirmPayload struct {
+	Confirmation *confirmation
+	Accepted     bool
+}
+
+// registerCallbacks wires inline button actions to bot handlers
+func (b *Bot) registerCallbacks() {
+	registerCallback(b.callbacks, actionNoop, func(ctx context.Context, query *tgbotapi.CallbackQuery, _ struct{}) error {
+		return nil
+	})
+	registerCallback(b.callbacks, actionRerun, func(ctx context.Context, query *tgbotapi.CallbackQuery, _ struct{}) error {
+		b.startAnalysis(ctx)
+		return nil
+	})
+	registerCallback(b.callbacks, actionDetails, func(ctx context.Context, query *tgbotapi.CallbackQuery, p detailsPayload) error {
+		go b.showPosition(ctx, p.Ticker)
+		return nil
+	})
+	registerCallback(b.callbacks, actionPage, b.handlePageCallback)
+	registerCallback(b.callbacks, actionAccount, b.handleAccountCallback)
+	registerCallback(b.callbacks, actionConfirm, b.handleConfirmCallback)
+	registerCallback(b.callbacks, actionTrade, func(ctx context.Context, query *tgbotapi.CallbackQuery, p tradePayload) error {
+		go b.proposeTrade(ctx, p.Ticker)
+		return nil
+	})
+}
+
+// handleCallback processes a pressed inline button
+func (b *Bot) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	answer := ""
+	if err := b.callbacks.dispatch(ctx, query); err != nil {
+		if err == errCallbackExpired {
+			answer = "Кнопка устарела, повторите команду."
+		} else {
+			b.logger.ErrorContext(ctx, "Error handling callback", "error", err)
+			answer = "Ошибка при обработке запроса."
+		}
+	}
+
+	if _, err := b.api.Request(tgbotapi.NewCallback(query.ID, answer)); err != nil {
+		b.logger.WarnContext(ctx, "Failed to answer callback query", "error", err)
+	}
+}
+
+// handlePageCallback switches a portfolio message to another page
+func (b *Bot) handlePageCallback(ctx context.Context, query *tgbotapi.CallbackQuery, p pagePayload) error {
+	text, keyboard := b.portfolioPage(p.Portfolio, p.Page)
+
+	var edit tgbotapi.EditMessageTextConfig
+	if keyboard != nil {
+		edit = tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, *keyboard)
+	} else {
+		edit = tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
+	}
+	_, err := b.send(edit)
+	return err
+}
+
+// handleAccountCallback asks to confirm switching to the picked account
+func (b *Bot) handleAccountCallback(ctx context.Context, query *tgbotapi.CallbackQuery, p accountPayload) error {
+	account := p.Account
+	question := fmt.Sprintf("Переключить отчёты на счёт «%s»?", account.Name)
+	return b.askConfirmation(question, func() string {
+		b.investor.SetAccount(account.ID)
+		b.logger.InfoContext(ctx, "Switched account", logging.AccountIDKey, account.ID)
+		return fmt.Sprintf("✅ Выбран счёт «%s».", account.Name)
+	})
+}
+
+// handleConfirmCallback runs or cancels a pending action exactly once
+func (b *Bot) handleConfirmCallback(ctx context.Context, query *tgbotapi.CallbackQuery, p confirmPayload) error {
+	result := ""
+	p.Confirmation.once.Do(func() {
+		if p.Accepted {
+			result = p.Confirmation.onConfirm()
+		} else {
+			result = "❌ Отменено."
+		}
+	})
+	if result == "" {
+		// The other button has already been pressed
+		return nil
+	}
+
+	edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID,
+		p.Confirmation.question+"\n\n"+result)
+	_, err := b.send(edit)
+	return err
+}
+
+// askConfirmation sends a question with confirm and cancel buttons.
+// onConfirm runs only if the user confirms and returns the text to show afterwards.
+func (b *Bot) askConfirmation(question string, onConfirm func() string) error {
+	c := &confirmation{question: question, onConfirm: onConfirm}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		b.callbacks.button("✅ Подтвердить", actionConfirm, confirmPayload{Confirmation: c, Accepted: true}, confirmTTL),
+		b.callbacks.button("❌ Отмена", actionConfirm, confirmPayload{Confirmation: c, Accepted: false}, confirmTTL),
+	))
+	return b.sendWithKeyboard(question, &keyboard)
+}
+
+// portfolioPage renders a page of the portfolio and its navigation keyboard
+func (b *Bot) portfolioPage(portfolio *invest.Portfolio, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	text, pages := formatPortfolioPage(portfolio, page, positionsPerPage)
+	if pages <= 1 {
+		return text, nil
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, b.callbacks.button("◀️", actionPage, pagePayload{Portfolio: portfolio, Page: page - 1}, pageButtonsTTL))
+	}
+	row = append(row, b.callbacks.button(fmt.Sprintf("%d/%d", page+1, pages), actionNoop, struct{}{}, pageButtonsTTL))
+	if page < pages-1 {
+		row = append(row, b.callbacks.button("▶️", actionPage, pagePayload{Portfolio: portfolio, Page: page + 1}, pageButtonsTTL))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+	return text, &keyboard
+}
+
+// reportKeyboard builds "Details" buttons for recommendations and a re-run button
+func (b *Bot) reportKeyboard(result *analysis.PortfolioAnalysis) tgbotapi.InlineKeyboardMarkup {
+	const buttonsPerRow = 3
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, rec := range result.Recommendations {
+		if rec.Watched {
+			continue // details are shown for held positions only
+		}
+		row = append(row, b.callbacks.button("ℹ️ "+rec.Ticker, actionDetails, detailsPayload{Ticker: rec.Ticker}, reportButtonsTTL))
+		if len(row) == buttonsPerRow {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		b.callbacks.button("🔄 Повторить анализ", actionRerun, struct{}{}, reportButtonsTTL),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// tradeKeyboard builds a picker of the recommendations that can be traded
+func (b *Bot) tradeKeyboard(recs []analysis.Recommendation) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, rec := range recs {
+		label := fmt.Sprintf("%s %s %s", actionEmoji(strings.ToUpper(rec.Action)), strings.ToUpper(rec.Action), rec.Ticker)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			b.callbacks.button(label, actionTrade, tradePayload{Ticker: rec.Ticker}, pageButtonsTTL),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// accountsKeyboard builds an account picker marking the selected account
+func (b *Bot) accountsKeyboard(accounts []invest.Account, selected string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, account := range accounts {
+		label := fmt.Sprintf("%s (%s)", account.Name, account.Type)
+		if account.ID == selected || (selected == "" && i == 0) {
+			label = "✅ " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			b.callbacks.button(label, actionAccount, accountPayload{Account: account}, pageButtonsTTL),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// sendWithKeyboard sends a plain text message with an optional inline keyboard
+func (b *Bot) sendWithKeyboard(text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	msg := tgbotapi.NewMessage(parseChatID(b.currentChatID()), text)
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	if _, err := b.send(msg); err != nil {
+		return fmt.Errorf("failed to send Telegram message: %w", err)
+	}
+	return nil
+}
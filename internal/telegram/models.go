@@ -0,0 +1,82 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/models"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleModelsCommand compares the accounts with their model portfolios
+func (b *Bot) handleModelsCommand(ctx context.Context, message *tgbotapi.Message) {
+	if b.models == nil || !b.models.Enabled() {
+		b.sendMessage("Модельные портфели не заданы. Добавьте models.portfolios в конфигурацию.")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+
+		report, err := b.models.Refresh(ctx, b.localNow())
+		if err != nil {
+			b.replyError(ctx, "Ошибка при сравнении с модельными портфелями", err)
+			return
+		}
+		b.sendMessage(formatModels(report))
+	}()
+}
+
+// SendModelComparison sends the weekly comparison of the accounts with their model portfolios
+func (b *Bot) SendModelComparison(report *models.Report) error {
+	if report == nil {
+		return nil
+	}
+	return b.sendMessage(formatModels(report))
+}
+
+// formatModels lays out every model with the account that follows it
+func formatModels(report *models.Report) string {
+	var sb strings.Builder
+	sb.WriteString("📐 МОДЕЛЬНЫЕ ПОРТФЕЛИ\n")
+	for _, c := range report.Comparisons {
+		sb.WriteString(fmt.Sprintf("\n%s", c.Model))
+		if c.From.IsZero() {
+			sb.WriteString(": нет истории цен\n")
+		} else {
+			sb.WriteString(fmt.Sprintf(" с %s\n", c.From.Format("02.01.2006")))
+			sb.WriteString("Модель: " + formatModelStats(c.Simulated) + "\n")
+		}
+
+		if c.Account != "" {
+			name := c.Name
+			if name == "" {
+				name = c.Account
+			}
+			if c.Real != nil {
+				sb.WriteString(fmt.Sprintf("Счёт %s: %s\n", name, formatModelStats(*c.Real)))
+				sb.WriteString(fmt.Sprintf("Разница с моделью: %+.1f п.п.\n", c.Real.Return-c.Simulated.Return))
+			} else {
+				sb.WriteString(fmt.Sprintf("Счёт %s\n", name))
+			}
+			sb.WriteString(fmt.Sprintf("Отклонение от весов: %.1f%% стоимости счёта\n", c.Drift))
+			for _, d := range c.Deviations {
+				sb.WriteString(fmt.Sprintf("  %s: %.1f%% при цели %.1f%%\n", d.Ticker, d.Actual, d.Target))
+			}
+		}
+		if len(c.Unpriced) > 0 {
+			sb.WriteString("Без истории цен, не учтены: " + strings.Join(c.Unpriced, ", ") + "\n")
+		}
+		if len(c.PriceOnly) > 0 {
+			sb.WriteString("Без дивидендов и купонов, только цена: " + strings.Join(c.PriceOnly, ", ") + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// formatModelStats shows the return and the risk of a portfolio
+func formatModelStats(s models.Stats) string {
+	return fmt.Sprintf("доходность %+.1f%%, волатильность %.1f%%, просадка %.1f%%", s.Return, s.Volatility, s.Drawdown)
+}
@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invest-manager/internal/performance"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handlePerformanceCommand records the current value of the account and shows its returns
+func (b *Bot) handlePerformanceCommand(ctx context.Context, message *tgbotapi.Message) {
+	if b.performance == nil {
+		b.sendMessage("Расчёт доходности недоступен.")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+
+		portfolio, err := b.investor.GetPortfolio(ctx)
+		if err != nil {
+			b.replyError(ctx, "Ошибка при получении портфеля", err)
+			return
+		}
+		now := b.localNow()
+		if err := b.performance.Record(portfolio, now); err != nil {
+			b.logger.WarnContext(ctx, "Failed to record the portfolio value", "error", err)
+		}
+
+		report, err := b.performance.Refresh(ctx, now)
+		if errors.Is(err, performance.ErrNoHistory) {
+			b.sendMessage("Стоимость портфеля ещё не записана. Доходность появится после первого анализа.")
+			return
+		}
+		if err != nil {
+			b.replyError(ctx, "Ошибка при расчёте доходности", err)
+			return
+		}
+		b.sendMessage(formatPerformance(report))
+	}()
+}
+
+// localNow returns the current time in the time zone of the reports
+func (b *Bot) localNow() time.Time {
+	b.settingsMu.RLock()
+	timezone := b.timezone
+	b.settingsMu.RUnlock()
+	if timezone == nil {
+		timezone = time.Local
+	}
+	return time.Now().In(timezone)
+}
+
+// formatPerformance lays out the returns of every period for /performance
+func formatPerformance(report *performance.Report) string {
+	var sb strings.Builder
+	sb.WriteString("📈 ДОХОДНОСТЬ\n")
+	for _, r := range report.Returns {
+		sb.WriteString(fmt.Sprintf("\n%s (с %s)\n", periodTitleRu(r.Period), r.Since.Format("02.01.2006")))
+		if r.HasTWR {
+			sb.WriteString(fmt.Sprintf("%s TWR: %+.2f%%\n", yieldEmoji(r.TWR), r.TWR))
+		}
+		if r.HasMWR {
+			sb.WriteString(fmt.Sprintf("%s XIRR: %+.2f%% за период, %+.2f%% годовых\n", yieldEmoji(r.MWR), r.MWR, r.XIRR))
+		}
+		if !r.HasTWR && !r.HasMWR {
+			sb.WriteString("Недостаточно данных\n")
+		}
+		if r.NetFlows != 0 {
+			sb.WriteString(fmt.Sprintf("Пополнения за вычетом выводов: %.2f\n", r.NetFlows))
+		}
+		for _, bm := range r.Benchmarks {
+			sb.WriteString(fmt.Sprintf("%s: %+.2f%%\n", bm.Ticker, bm.Return))
+		}
+	}
+	sb.WriteString("\nTWR не зависит от пополнений, XIRR учитывает, когда вносились деньги. Индексы сравниваются с TWR.")
+	return sb.String()
+}
+
+// periodTitleRu names a period of the returns in /performance
+func periodTitleRu(period string) string {
+	switch period {
+	case performance.PeriodMonth:
+		return "С начала месяца"
+	case performance.PeriodYear:
+		return "С начала года"
+	case performance.Period12M:
+		return "За 12 месяцев"
+	case performance.PeriodAll:
+		return "За всё время"
+	}
+	return period
+}
+
+// periodTitle names a period of the returns in the report
+func periodTitle(period string) string {
+	switch period {
+	case performance.PeriodMonth:
+		return "Month to date"
+	case performance.PeriodYear:
+		return "Year to date"
+	case performance.Period12M:
+		return "Last 12 months"
+	case performance.PeriodAll:
+		return "Since inception"
+	}
+	return period
+}
+
+// formatReturn puts the returns of a period and its benchmarks on one line of the report
+func formatReturn(r performance.Return) string {
+	var parts []string
+	if r.HasTWR {
+		parts = append(parts, fmt.Sprintf("TWR %+.2f%%", r.TWR))
+	}
+	if r.HasMWR {
+		parts = append(parts, fmt.Sprintf("XIRR %+.2f%% a year", r.XIRR))
+	}
+	for _, bm := range r.Benchmarks {
+		parts = append(parts, fmt.Sprintf("%s %+.2f%%", bm.Ticker, bm.Return))
+	}
+	if len(parts) == 0 {
+		return "not enough data"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,210 @@
+// Package render builds Telegram messages with correctly escaped formatting
+// and splits them into parts that fit Telegram limits without breaking entities.
+package render
+
+import "strings"
+
+// Mode selects how formatting is encoded
+type Mode string
+
+// Supported modes; the values match Telegram parse_mode names
+const (
+	MarkdownV2 Mode = "MarkdownV2"
+	HTML       Mode = "HTML"
+	Plain      Mode = ""
+)
+
+// MaxMessageLength is the Telegram limit for a text message in UTF-16 code units
+const MaxMessageLength = 4096
+
+// spanKind is the formatting applied to a piece of text
+type spanKind int
+
+const (
+	kindText spanKind = iota
+	kindBold
+	kindItalic
+	kindCode
+	kindLink
+)
+
+// span is a piece of text with a single kind of formatting
+type span struct {
+	kind spanKind
+	text string
+	url  string
+}
+
+// section is a group of spans that should stay in one message if possible
+type section struct {
+	spans []span
+}
+
+// Message is a formatted message built from sections.
+// All text passed to it is treated as literal and escaped on rendering.
+type Message struct {
+	sections []section
+}
+
+// New creates an empty message
+func New() *Message {
+	return &Message{}
+}
+
+// Section starts a new section. Splitting prefers section boundaries.
+func (m *Message) Section() *Message {
+	m.sections = append(m.sections, section{})
+	return m
+}
+
+// Text appends plain text
+func (m *Message) Text(s string) *Message {
+	return m.add(span{kind: kindText, text: s})
+}
+
+// Line appends plain text followed by a line break
+func (m *Message) Line(s string) *Message {
+	return m.add(span{kind: kindText, text: s + "\n"})
+}
+
+// Bold appends bold text
+func (m *Message) Bold(s string) *Message {
+	return m.add(span{kind: kindBold, text: s})
+}
+
+// Italic appends italic text
+func (m *Message) Italic(s string) *Message {
+	return m.add(span{kind: kindItalic, text: s})
+}
+
+// Code appends inline code
+func (m *Message) Code(s string) *Message {
+	return m.add(span{kind: kindCode, text: s})
+}
+
+// Link appends a link
+func (m *Message) Link(text, url string) *Message {
+	return m.add(span{kind: kindLink, text: text, url: url})
+}
+
+// add appends a span to the last section
+func (m *Message) add(s span) *Message {
+	if len(m.sections) == 0 {
+		m.sections = append(m.sections, section{})
+	}
+	last := &m.sections[len(m.sections)-1]
+	last.spans = append(last.spans, s)
+	return m
+}
+
+// Render encodes the whole message in the given mode
+func (m *Message) Render(mode Mode) string {
+	var sb strings.Builder
+	for _, sec := range m.sections {
+		for _, s := range sec.spans {
+			sb.WriteString(renderSpan(s, mode))
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// renderSpan encodes a single span
+func renderSpan(s span, mode Mode) string {
+	// Empty entities are rejected by Telegram
+	if s.text == "" {
+		return ""
+	}
+
+	switch mode {
+	case MarkdownV2:
+		switch s.kind {
+		case kindBold:
+			return "*" + EscapeMarkdownV2(s.text) + "*"
+		case kindItalic:
+			return "_" + EscapeMarkdownV2(s.text) + "_"
+		case kindCode:
+			return "`" + escapeMarkdownV2Code(s.text) + "`"
+		case kindLink:
+			return "[" + EscapeMarkdownV2(s.text) + "](" + escapeMarkdownV2URL(s.url) + ")"
+		}
+		return EscapeMarkdownV2(s.text)
+	case HTML:
+		switch s.kind {
+		case kindBold:
+			return "<b>" + EscapeHTML(s.text) + "</b>"
+		case kindItalic:
+			return "<i>" + EscapeHTML(s.text) + "</i>"
+		case kindCode:
+			return "<code>" + EscapeHTML(s.text) + "</code>"
+		case kindLink:
+			return `<a href="` + EscapeHTML(s.url) + `">` + EscapeHTML(s.text) + "</a>"
+		}
+		return EscapeHTML(s.text)
+	}
+
+	if s.kind == kindLink && s.url != s.text {
+		return s.text + " (" + s.url + ")"
+	}
+	return s.text
+}
+
+// markdownV2Special lists characters that must be escaped in MarkdownV2 text
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// EscapeMarkdownV2 escapes text for use in MarkdownV2 outside of entities
+func EscapeMarkdownV2(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Special, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// escapeMarkdownV2Code escapes text inside code entities
+func escapeMarkdownV2Code(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s)
+}
+
+// escapeMarkdownV2URL escapes the URL part of a link
+func escapeMarkdownV2URL(s string) string {
+	return strings.NewReplacer("\\", "\\\\", ")", "\\)").Replace(s)
+}
+
+// EscapeHTML escapes text for use in Telegram HTML
+func EscapeHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+// UTF16Len returns the length of a string in UTF-16 code units, which is how Telegram counts limits
+func UTF16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// CutIndex returns the largest byte index i such that s[:i] ends on a rune boundary
+// and is at most limit UTF-16 code units long
+func CutIndex(s string, limit int) int {
+	n := 0
+	for i, r := range s {
+		w := 1
+		if r >= 0x10000 {
+			w = 2
+		}
+		if n+w > limit {
+			return i
+		}
+		n += w
+	}
+	return len(s)
+}
@@ -0,0 +1,162 @@
+package render
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// sampleReport mimics a daily report with LLM text full of special characters
+func sampleReport() *Message {
+	m := New()
+	m.Section().Bold("📰 NEWS:").Text("\n\n")
+	m.Section().Bold("Russia's central bank (CBR) keeps key rate at 16%!").Text("\n").
+		Text("Source: Reuters\n").
+		Link("Read more", "https://example.com/news?id=1&src=(tg)").Text("\n\n")
+	m.Section().Bold("📊 PORTFOLIO ANALYSIS 📊").Text("\n\n")
+	m.Section().Bold("SUMMARY:").Text("\n").
+		Text("Портфель *умеренно* диверсифицирован; доля SBER_P — 35.5% (выше нормы). См. [отчёт] #1 > 2 = 3 | {x} ~ `code`\n\n")
+	m.Section().Bold("SBER (Сбербанк)").Text(" - 🟢 BUY\n").
+		Italic("Дивиденды 11.4% + рост прибыли.").Text("\n\n")
+	m.Section().Bold("GAZP (Газпром)").Text(" - 🔴 SELL\n").
+		Italic("Слабые результаты_2024, C:\\path\\to").Text("\n").
+		Code("x := `y` \\ z").Text("\n\n")
+	return m
+}
+
+func TestRenderGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		mode Mode
+	}{
+		{"report_markdownv2", MarkdownV2},
+		{"report_html", HTML},
+		{"report_plain", Plain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkGolden(t, tt.name, sampleReport().Render(tt.mode))
+		})
+	}
+}
+
+func TestSplitGolden(t *testing.T) {
+	const limit = 120
+
+	tests := []struct {
+		name string
+		mode Mode
+	}{
+		{"split_markdownv2", MarkdownV2},
+		{"split_html", HTML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := sampleReport().Split(tt.mode, limit)
+
+			var sb strings.Builder
+			for i, part := range parts {
+				text := part.Render(tt.mode)
+				if n := UTF16Len(text); n > limit {
+					t.Errorf("part %d is %d UTF-16 units long, limit is %d", i+1, n, limit)
+				}
+				fmt.Fprintf(&sb, "----- part %d -----\n%s\n", i+1, text)
+			}
+			checkGolden(t, tt.name, sb.String())
+		})
+	}
+}
+
+func TestSplitKeepsShortMessageWhole(t *testing.T) {
+	parts := sampleReport().Split(MarkdownV2, MaxMessageLength)
+	if len(parts) != 1 {
+		t.Fatalf("expected 1 part, got %d", len(parts))
+	}
+	if got, want := parts[0].Render(MarkdownV2), sampleReport().Render(MarkdownV2); got != want {
+		t.Errorf("split changed the message:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSplitLongSpan(t *testing.T) {
+	// A single bold span longer than the limit must be cut into separate entities
+	m := New().Bold(strings.Repeat("ж", 25))
+	parts := m.Split(MarkdownV2, 10)
+
+	var total int
+	for _, part := range parts {
+		text := part.Render(MarkdownV2)
+		if !strings.HasPrefix(text, "*") || !strings.HasSuffix(text, "*") {
+			t.Errorf("part %q is not a complete bold entity", text)
+		}
+		total += UTF16Len(text) - 2
+	}
+	if total != 25 {
+		t.Errorf("expected 25 characters in total, got %d", total)
+	}
+}
+
+func TestUTF16Len(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"Привет", 6},
+		{"📊", 2},
+		{"🟢 BUY", 6},
+	}
+
+	for _, tt := range tests {
+		if got := UTF16Len(tt.in); got != tt.want {
+			t.Errorf("UTF16Len(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCutIndex(t *testing.T) {
+	tests := []struct {
+		in    string
+		limit int
+		want  string
+	}{
+		{"abcdef", 3, "abc"},
+		{"Привет", 2, "Пр"},
+		{"a📊b", 2, "a"},
+		{"a📊b", 3, "a📊"},
+		{"ab", 10, "ab"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in[:CutIndex(tt.in, tt.limit)]; got != tt.want {
+			t.Errorf("CutIndex(%q, %d) cuts %q, want %q", tt.in, tt.limit, got, tt.want)
+		}
+	}
+}
+
+// checkGolden compares output with testdata/<name>.golden
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output does not match %s:\n%s\nwant:\n%s", path, got, want)
+	}
+}
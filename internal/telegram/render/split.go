@@ -0,0 +1,151 @@
+package render
+
+import "strings"
+
+// Split divides the message into parts whose rendering in the given mode fits
+// into limit UTF-16 code units. Parts are cut at section boundaries first, then
+// at line breaks and, as a last resort, inside a span. Every part is a complete
+// message on its own, so formatting entities are never broken.
+func (m *Message) Split(mode Mode, limit int) []*Message {
+	var parts []*Message
+	cur, curLen := New(), 0
+
+	flush := func() {
+		if len(cur.sections) > 0 {
+			parts = append(parts, cur)
+		}
+		cur, curLen = New(), 0
+	}
+	appendSection := func(sec section, length int) {
+		if curLen+length > limit {
+			flush()
+		}
+		cur.sections = append(cur.sections, sec)
+		curLen += length
+	}
+
+	for _, sec := range m.sections {
+		if length := sectionLen(sec, mode); length <= limit {
+			appendSection(sec, length)
+			continue
+		}
+
+		// The section does not fit into a single message, go line by line
+		for _, line := range splitLines(sec) {
+			if length := sectionLen(line, mode); length <= limit {
+				appendSection(line, length)
+				continue
+			}
+
+			// A single line is too long, cut its spans into pieces
+			for _, s := range line.spans {
+				for _, piece := range splitSpan(s, mode, limit) {
+					appendSection(section{spans: []span{piece}}, spanLen(piece, mode))
+				}
+			}
+		}
+	}
+	flush()
+
+	return parts
+}
+
+// sectionLen returns the rendered length of a section
+func sectionLen(sec section, mode Mode) int {
+	n := 0
+	for _, s := range sec.spans {
+		n += spanLen(s, mode)
+	}
+	return n
+}
+
+// spanLen returns the rendered length of a span
+func spanLen(s span, mode Mode) int {
+	return UTF16Len(renderSpan(s, mode))
+}
+
+// splitLines breaks a section into sections of one line each,
+// keeping line breaks at the end of the lines
+func splitLines(sec section) []section {
+	var lines []section
+	var cur section
+
+	for _, s := range sec.spans {
+		text := s.text
+		for {
+			idx := strings.IndexByte(text, '\n')
+			if idx < 0 {
+				break
+			}
+			piece := s
+			piece.text = text[:idx+1]
+			cur.spans = append(cur.spans, piece)
+			lines = append(lines, cur)
+			cur = section{}
+			text = text[idx+1:]
+		}
+		if text != "" {
+			piece := s
+			piece.text = text
+			cur.spans = append(cur.spans, piece)
+		}
+	}
+	if len(cur.spans) > 0 {
+		lines = append(lines, cur)
+	}
+	return lines
+}
+
+// splitSpan cuts a span into pieces that each fit into limit once rendered,
+// preferring to cut at spaces
+func splitSpan(s span, mode Mode, limit int) []span {
+	var pieces []span
+	text := s.text
+
+	for text != "" {
+		// Byte offsets of rune boundaries, including the end of the text
+		var bounds []int
+		for i := range text {
+			if i > 0 {
+				bounds = append(bounds, i)
+			}
+		}
+		bounds = append(bounds, len(text))
+
+		fits := func(end int) bool {
+			piece := s
+			piece.text = text[:end]
+			return spanLen(piece, mode) <= limit
+		}
+
+		// Binary search for the longest prefix that fits
+		lo, hi := 0, len(bounds)-1
+		if !fits(bounds[lo]) {
+			// Not even a single character fits; take it anyway to make progress
+			hi = 0
+		}
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if fits(bounds[mid]) {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		cut := bounds[lo]
+
+		// Prefer a word boundary if it does not waste too much space
+		if cut < len(text) {
+			if space := strings.LastIndexByte(text[:cut], ' '); space > cut/2 {
+				cut = space + 1
+			}
+		}
+
+		piece := s
+		piece.text = text[:cut]
+		pieces = append(pieces, piece)
+		text = text[cut:]
+	}
+
+	return pieces
+}
@@ -0,0 +1,210 @@
+package telegram
+
+import (
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/contributions"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/news"
+	"invest-manager/internal/paper"
+	"invest-manager/internal/performance"
+	"invest-manager/internal/tax"
+	"invest-manager/internal/telegram/render"
+	"strings"
+)
+
+// MonthlyReview holds the parts of the monthly report computed just before it; nil ones are left out
+type MonthlyReview struct {
+	Returns  *performance.Report
+	Tax      *tax.Report
+	Deposits *contributions.Status // without it the reminder always fires
+}
+
+// BuildReport lays out the daily report. Every article and recommendation is a
+// separate section, so long reports are split between them.
+// The paper comparison and the monthly review are left out if they are nil.
+func BuildReport(portfolio *invest.Portfolio, result *analysis.PortfolioAnalysis, articles []news.Article,
+	comparison *paper.Comparison, monthly *MonthlyReview) *render.Message {
+	m := render.New()
+
+	// Fresh news section
+	m.Section().Bold("📰 NEWS:")
+	if len(articles) == 0 {
+		m.Text("\nНет доступных новостей.\n\n")
+	} else {
+		m.Text("\n\n")
+		for _, article := range articles {
+			m.Section().Bold(article.Title).Text("\n")
+			m.Line("Source: " + article.Source.Name)
+			m.Line("Date: " + article.PublishedAt.Format("2006-01-02"))
+			m.Link(article.URL, article.URL).Text("\n\n")
+		}
+	}
+
+	// Portfolio analysis header and summary
+	m.Section().Bold("📊 PORTFOLIO ANALYSIS 📊").Text("\n\n")
+	m.Section().Bold("SUMMARY:").Text("\n")
+	m.Text(result.Summary).Text("\n\n")
+	if result.OverBudget != "" {
+		m.Line("⚠️ LLM " + result.OverBudget + ": this is a shorter analysis without news and opportunities.").Text("\n")
+	}
+
+	// Portfolio overview
+	m.Section().Bold("PORTFOLIO OVERVIEW:").Text("\n")
+	m.Line(fmt.Sprintf("Total Value: %.2f %s", portfolio.TotalAmount, portfolio.Currency))
+	m.Line(fmt.Sprintf("Expected Yield: %.2f %s", portfolio.ExpectedYield, portfolio.Currency)).Text("\n")
+
+	// Allocation by asset class, sector, country and issuer
+	if len(portfolio.Exposures) > 0 {
+		m.Section().Bold("ALLOCATION:").Text("\n")
+		for _, group := range invest.ExposureGroups {
+			if exposures := portfolio.ExposuresBy(group); len(exposures) > 0 {
+				m.Line(exposureTitles[group] + ": " + formatAllocation(exposures))
+			}
+		}
+		m.Text("\n")
+	}
+
+	// Recommendations
+	m.Section().Bold("RECOMMENDATIONS:").Text("\n\n")
+	for _, rec := range result.Recommendations {
+		m.Section().Bold(fmt.Sprintf("%s (%s)", rec.Ticker, rec.Name))
+		if rec.Watched {
+			m.Text(" 👀")
+		}
+		m.Text(fmt.Sprintf(" - %s %s\n", actionEmoji(rec.Action), rec.Action))
+		m.Italic(rec.Reason).Text("\n\n")
+	}
+
+	// Opportunities, if available
+	if len(result.Opportunities) > 0 {
+		m.Section().Text("\n").Bold("OPPORTUNITIES:").Text("\n")
+		if portfolio.Screen != "" {
+			m.Line("🔎 Screen: " + portfolio.Screen).Text("\n")
+		}
+		for _, opp := range result.Opportunities {
+			action := strings.ToUpper(opp.Action)
+			emoji := "📈" // default LONG
+			if action == "SHORT" {
+				emoji = "📉"
+			}
+			m.Section().Bold(fmt.Sprintf("%s (%s)", opp.Ticker, opp.Name))
+			m.Text(fmt.Sprintf(" - %s %s\n", emoji, action))
+			if opp.Unverified {
+				m.Line("⚠️ Not verified with the broker, check that it can be traded")
+			}
+			m.Italic(opp.Reason).Text("\n\n")
+		}
+	}
+
+	// Paper portfolio that follows every recommendation
+	if comparison != nil {
+		m.Section().Text("\n").Bold("PAPER VS REAL:").Text("\n")
+		m.Line(fmt.Sprintf("Since %s", comparison.Since.Format("2006-01-02")))
+		m.Line(fmt.Sprintf("Paper: %.2f %s (%+.2f%%)", comparison.Paper, portfolio.Currency, comparison.PaperReturn))
+		m.Line(fmt.Sprintf("Real: %.2f %s (%+.2f%%)", comparison.Real, portfolio.Currency, comparison.RealReturn))
+		for _, t := range comparison.Trades {
+			m.Line(fmt.Sprintf("%s %s %g × %.2f", actionEmoji(t.Action), t.Ticker, t.Quantity, t.Price))
+		}
+		m.Text("\n")
+	}
+
+	if result.IsMonthlyReminder {
+		if monthly == nil {
+			monthly = &MonthlyReview{}
+		}
+		addMonthlyReview(m, monthly)
+	}
+
+	return m
+}
+
+// allocationLimit is the number of names listed per line of the allocation; the rest are summed up
+const allocationLimit = 5
+
+// exposureTitles names the exposure groups in the report
+var exposureTitles = map[string]string{
+	invest.GroupAssetClass: "Asset class",
+	invest.GroupSector:     "Sector",
+	invest.GroupCountry:    "Country",
+	invest.GroupIssuer:     "Issuer",
+}
+
+// formatAllocation lists the largest exposures of a group with their weights
+func formatAllocation(exposures []invest.Exposure) string {
+	parts := make([]string, 0, allocationLimit+1)
+	var rest float64
+	for i, e := range exposures {
+		if i >= allocationLimit {
+			rest += e.Weight
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %.1f%%", e.Name, e.Weight))
+	}
+	if rest > 0 {
+		parts = append(parts, fmt.Sprintf("others %.1f%%", rest))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// addMonthlyReview lays out the returns, the tax and the deposits of the monthly report
+func addMonthlyReview(m *render.Message, monthly *MonthlyReview) {
+	// Returns with deposits and withdrawals taken out
+	if returns := monthly.Returns; returns != nil {
+		m.Section().Text("\n").Bold("RETURNS:").Text("\n")
+		for _, r := range returns.Returns {
+			m.Line(periodTitle(r.Period) + ": " + formatReturn(r))
+		}
+		m.Text("\n")
+	}
+
+	// Income tax of the year, with the losses worth realizing once the year is ending
+	if taxes := monthly.Tax; taxes != nil {
+		m.Section().Text("\n").Bold(fmt.Sprintf("TAX %d:", taxes.Year)).Text("\n")
+		if taxes.IIS {
+			m.Line(fmt.Sprintf("Tax on gains at IIS closure: %.0f RUB", taxes.Tax))
+			m.Line(fmt.Sprintf("Deposits: %.0f RUB, type A deduction %.0f RUB", taxes.Contributed, taxes.Deduction))
+		} else {
+			m.Line(fmt.Sprintf("Estimated tax: %.0f RUB, withheld %.0f RUB, due %.0f RUB", taxes.Tax, taxes.Withheld, taxes.Due))
+		}
+		for _, lot := range taxes.SoonExempt {
+			m.Line(fmt.Sprintf("⏳ %s %g: tax-free from %s, saves %.0f RUB", lot.Ticker, lot.Quantity, lot.Exempt.Format("2006-01-02"), lot.Saving))
+		}
+		if taxes.InSeason && len(taxes.Harvest) > 0 {
+			m.Line(fmt.Sprintf("✂️ Selling at a loss before December saves %.0f RUB:", taxes.HarvestSave))
+			for _, h := range taxes.Harvest {
+				m.Line(fmt.Sprintf("%s %g: loss %.0f RUB", h.Ticker, h.Quantity, h.Loss))
+			}
+		}
+		m.Text("\n")
+	}
+
+	// Deposits against the plan; the reminder fires only while the money of the month is missing
+	deposits := monthly.Deposits
+	if deposits == nil {
+		m.Section().Text("\n").Bold("⚠️ REMINDER ⚠️").Text("\n")
+		m.Line("Don't forget to add funds and redistribute your portfolio this month!")
+		return
+	}
+	if deposits.Met {
+		m.Section().Text("\n").Bold("✅ DEPOSITS DONE").Text("\n")
+	} else {
+		m.Section().Text("\n").Bold("⚠️ REMINDER ⚠️").Text("\n")
+	}
+	for _, p := range deposits.Accounts {
+		if p.Missing > 0 {
+			m.Line(fmt.Sprintf("%s: deposit %.0f RUB more this month", accountTitle(p), p.Missing))
+		}
+		line := fmt.Sprintf("%s: %.0f of %.0f RUB this year (%.0f%%)", accountTitle(p), p.YearTotal, p.Yearly, percentOf(p.YearTotal, p.Yearly))
+		if !p.OnTrack {
+			line += ", behind schedule"
+		}
+		m.Line(line)
+	}
+	if len(deposits.Split) > 0 {
+		m.Line(fmt.Sprintf("Split of %.0f RUB by the target allocation:", deposits.Amount))
+		for _, s := range deposits.Split {
+			m.Line(fmt.Sprintf("%s: %.0f RUB (%.0f%% now, target %.0f%%)", s.Ticker, s.Amount, s.Current*100, s.Target*100))
+		}
+	}
+}
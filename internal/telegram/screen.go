@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invest-manager/internal/config"
+	"invest-manager/internal/screener"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// screenTimeout limits an on-demand screen; the first run of the day loads
+// candles for every instrument that passes the instrument rules
+const screenTimeout = 3 * time.Minute
+
+// screenMatchLimit is the number of matches listed in a message
+const screenMatchLimit = 15
+
+// handleScreenCommand lists the configured screens or runs one of them
+func (b *Bot) handleScreenCommand(ctx context.Context, message *tgbotapi.Message) {
+	if b.screener == nil {
+		b.sendMessage("Скринер недоступен.")
+		return
+	}
+
+	name := strings.TrimSpace(message.CommandArguments())
+	if name == "" {
+		b.sendMessage(formatScreens(b.screener))
+		return
+	}
+	if b.screener.Rules(name) == nil {
+		b.sendMessage(fmt.Sprintf("Скрин %q не настроен.\n\n%s", name, formatScreens(b.screener)))
+		return
+	}
+
+	b.sendMessage(fmt.Sprintf("🔎 Запускаю скрин %s, это может занять пару минут...", name))
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, screenTimeout)
+		defer cancel()
+
+		result, err := b.screener.Run(ctx, name)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				b.sendMessage("Скрин не успел завершиться. Данные уже загруженных инструментов сохранены, повторите команду.")
+				return
+			}
+			b.replyError(ctx, "Ошибка при запуске скрина", err)
+			return
+		}
+		b.sendMessage(formatScreenResult(result, b.screener.Default() == name))
+	}()
+}
+
+// formatScreens lists the configured screens with their rules
+func formatScreens(s *screener.Screener) string {
+	names := s.Names()
+	if len(names) == 0 {
+		return "Скрины не настроены, добавьте их в раздел screener.screens конфигурации."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔎 СКРИНЫ\n\n")
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("• %s", name))
+		if name == s.Default() {
+			sb.WriteString(" (идеи для анализа)")
+		}
+		sb.WriteString("\n")
+		for _, rule := range s.Rules(name) {
+			sb.WriteString(fmt.Sprintf("   %s\n", rule))
+		}
+	}
+	sb.WriteString("\nЗапуск: /screen " + names[0])
+	return sb.String()
+}
+
+// formatScreenResult renders the most traded matches of a screen with their metrics
+func formatScreenResult(result *screener.Result, isDefault bool) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔎 СКРИН %s: %d из %d\n", result.Screen, len(result.Matches), result.Scanned))
+	for _, rule := range result.Rules {
+		sb.WriteString(fmt.Sprintf("   %s\n", rule))
+	}
+	sb.WriteString("\n")
+
+	if len(result.Matches) == 0 {
+		sb.WriteString("Ни один инструмент не прошёл отбор.")
+		return sb.String()
+	}
+	for i, m := range result.Matches {
+		if i == screenMatchLimit {
+			sb.WriteString(fmt.Sprintf("…и ещё %d\n", len(result.Matches)-screenMatchLimit))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("• %s (%s): %.2f %s\n", m.Instrument.Ticker, m.Instrument.Name,
+			m.Metrics[config.MetricPrice], m.Instrument.Currency))
+		sb.WriteString("   " + formatScreenMetrics(m.Metrics) + "\n")
+	}
+	if isDefault {
+		sb.WriteString("\nЭти инструменты — единственные кандидаты в идеи при анализе.")
+	}
+	return sb.String()
+}
+
+// formatScreenMetrics renders the metrics of a match other than the price
+func formatScreenMetrics(metrics screener.Metrics) string {
+	var parts []string
+	if v, ok := metrics[config.MetricTurnover]; ok {
+		parts = append(parts, fmt.Sprintf("оборот %.1f млн", v/1e6))
+	}
+	if v, ok := metrics[config.MetricChange]; ok {
+		parts = append(parts, fmt.Sprintf("30 дн. %+.1f%%", v))
+	}
+	if v, ok := metrics[config.MetricRSI]; ok {
+		parts = append(parts, fmt.Sprintf("RSI %.0f", v))
+	}
+	if v, ok := metrics[config.MetricDividendYield]; ok {
+		parts = append(parts, fmt.Sprintf("див. %.1f%%", v))
+	}
+	return strings.Join(parts, ", ")
+}
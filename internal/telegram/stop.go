@@ -0,0 +1,386 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/stops"
+	"invest-manager/internal/trading"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// stopUsage explains the /stop subcommands
+const stopUsage = `Используйте:
+/stop list - уровни и активные стоп-заявки
+/stop SBER loss 280 или 5% - стоп-лосс по цене или от средней цены
+/stop SBER trail 7% - скользящий стоп-лосс от максимума
+/stop SBER take 350 или 15% - тейк-профит
+/stop SBER clear - удалить уровни
+/stop SBER order - выставить стоп-заявки брокеру
+/stop cancel ID - отменить стоп-заявку`
+
+// stopOrdersPeriod is how far back /stop list looks for triggered stop orders
+const stopOrdersPeriod = 30 * 24 * time.Hour
+
+// handleStopCommand manages the stop-loss and take-profit levels of the positions
+func (b *Bot) handleStopCommand(ctx context.Context, message *tgbotapi.Message) {
+	if b.stops == nil {
+		b.sendMessage("Стоп-уровни недоступны.")
+		return
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 || strings.EqualFold(args[0], "list") {
+		go b.showStops(ctx)
+		return
+	}
+	if len(args) < 2 {
+		b.sendMessage(stopUsage)
+		return
+	}
+	if strings.EqualFold(args[0], "cancel") {
+		b.proposeStopCancel(ctx, args[1])
+		return
+	}
+
+	ticker := strings.ToUpper(args[0])
+	switch sub := strings.ToLower(args[1]); sub {
+	case "loss", "trail", "take":
+		if len(args) < 3 {
+			b.sendMessage(stopUsage)
+			return
+		}
+		side := stops.SideLoss
+		if sub == "take" {
+			side = stops.SideTake
+		}
+		level, err := stops.ParseLevel(args[2], sub == "trail")
+		if err != nil {
+			b.sendMessage(fmt.Sprintf("Неверный уровень: %v", err))
+			return
+		}
+		go b.setStop(ctx, ticker, side, level)
+	case "clear":
+		if err := b.stops.Remove(ticker); err != nil {
+			if errors.Is(err, stops.ErrNoLevels) {
+				b.sendMessage(fmt.Sprintf("Для %s уровни не заданы.", ticker))
+				return
+			}
+			b.replyError(ctx, "Ошибка при удалении уровней", err)
+			return
+		}
+		b.sendMessage(fmt.Sprintf("Уровни %s удалены. Выставленные стоп-заявки остаются активными: /stop list", ticker))
+	case "order":
+		if !b.tradingSettings().Enabled {
+			b.sendMessage("Торговля отключена. Включите trading.enabled в конфигурации.")
+			return
+		}
+		go b.proposeStopOrders(ctx, ticker)
+	default:
+		b.sendMessage(stopUsage)
+	}
+}
+
+// setStop sets a level of a held position after checking it against the current price
+func (b *Bot) setStop(ctx context.Context, ticker, side string, level stops.Level) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	portfolio, err := b.investor.GetPortfolio(ctx)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при получении портфеля", err)
+		return
+	}
+	pos := portfolio.FindPosition(ticker)
+	if pos == nil {
+		b.sendMessage(fmt.Sprintf("%s нет в портфеле.", ticker))
+		return
+	}
+
+	stop, err := b.stops.Set(*pos, side, level)
+	if err != nil {
+		b.sendMessage(fmt.Sprintf("⚠️ Уровень не задан: %v", err))
+		return
+	}
+	b.sendMessage(fmt.Sprintf("✅ Уровни %s обновлены.\n%s\nПроверяю цену каждые несколько минут и пришлю уведомление при срабатывании.",
+		stop.Ticker, formatStopLevels(*stop, pos)))
+}
+
+// showStops lists the levels with their prices, the active stop orders and the recently triggered ones
+func (b *Bot) showStops(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	portfolio, err := b.investor.GetPortfolio(ctx)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при получении портфеля", err)
+		return
+	}
+	orders, err := b.stopOrders.GetStopOrders(ctx, time.Now().Add(-stopOrdersPeriod))
+	if err != nil {
+		// The levels are still worth showing without the broker orders
+		b.logger.WarnContext(ctx, "Could not get stop orders", "error", err)
+	}
+	b.sendMessage(formatStops(b.stops.Items(), portfolio, orders, err))
+}
+
+// proposeStopOrders asks to place broker stop orders for the levels of a position
+func (b *Bot) proposeStopOrders(ctx context.Context, ticker string) {
+	reqCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	stop := b.stops.Find(ticker)
+	if stop == nil {
+		b.sendMessage(fmt.Sprintf("Для %s уровни не заданы: /stop %s loss 5%%", ticker, ticker))
+		return
+	}
+	portfolio, err := b.investor.GetPortfolio(reqCtx)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при получении портфеля", err)
+		return
+	}
+	pos := portfolio.FindPosition(ticker)
+	if pos == nil {
+		b.sendMessage(fmt.Sprintf("%s нет в портфеле.", ticker))
+		return
+	}
+	instr, err := b.investor.GetInstrument(reqCtx, pos.FIGI)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при получении инструмента", err)
+		return
+	}
+	lots := int64(0)
+	if instr.Lot > 0 {
+		lots = int64(pos.Quantity) / instr.Lot
+	}
+	if lots < 1 {
+		b.sendMessage(fmt.Sprintf("В позиции %s меньше одного лота, стоп-заявку выставить нельзя.", ticker))
+		return
+	}
+
+	// A trailing level moves with the price, which a broker stop order cannot follow.
+	// The levels are in money per unit; the orders take a quote on the price step,
+	// rounded so the stop-loss fires no earlier and the take-profit no later than the level.
+	var requests []invest.StopOrderRequest
+	if stop.Loss != nil && stop.Loss.Kind != stops.KindTrailing {
+		requests = append(requests, invest.StopOrderRequest{InstrumentID: instr.UID, Type: invest.StopLoss,
+			Lots: lots, StopPrice: instr.FloorPrice(instr.QuoteOf(stop.LossPrice(pos.AveragePrice)))})
+	}
+	if stop.Take != nil {
+		requests = append(requests, invest.StopOrderRequest{InstrumentID: instr.UID, Type: invest.TakeProfit,
+			Lots: lots, StopPrice: instr.CeilPrice(instr.QuoteOf(stop.TakePrice(pos.AveragePrice)))})
+	}
+	if len(requests) == 0 {
+		b.sendMessage("Скользящий стоп-лосс отслеживается только ботом, брокеру выставить нечего.")
+		return
+	}
+
+	question := formatStopOrderProposal(pos, instr, requests)
+	if err := b.askConfirmation(question, func() string { return b.placeStopOrders(ctx, stop.Ticker, instr, requests) }); err != nil {
+		b.logger.ErrorContext(ctx, "Error sending stop order proposal", "error", err)
+	}
+}
+
+// placeStopOrders places confirmed stop orders within the per-order limit and follows them until they are final
+func (b *Bot) placeStopOrders(ctx context.Context, ticker string, instr *invest.Instrument, requests []invest.StopOrderRequest) string {
+	limits := b.tradingSettings()
+	if !limits.Enabled {
+		return "⛔ Торговля отключена в конфигурации."
+	}
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	var placed []string
+	var lines []string
+	for _, req := range requests {
+		if err := trading.CheckOrderLimit(stopOrderAmount(instr, req), limits); err != nil {
+			lines = append(lines, fmt.Sprintf("⛔ %s превышает лимит: %v", stopOrderTypeName(req.Type), err))
+			continue
+		}
+		id, err := b.stopOrders.PostStopOrder(ctx, req)
+		if err != nil {
+			b.logger.ErrorContext(ctx, "Failed to place stop order", "ticker", ticker, "type", req.Type, "error", err)
+			lines = append(lines, fmt.Sprintf("⚠️ %s не выставлен: %v", stopOrderTypeName(req.Type), err))
+			continue
+		}
+		b.logger.InfoContext(ctx, "Placed stop order", "stop_order_id", id, "ticker", ticker, "type", req.Type,
+			"lots", req.Lots, "stop_price", req.StopPrice)
+		placed = append(placed, id)
+		lines = append(lines, fmt.Sprintf("✅ %s выставлен по %.2f, ID %s", stopOrderTypeName(req.Type), req.StopPrice, id))
+	}
+	if len(placed) > 0 {
+		if err := b.stops.AddOrders(ticker, placed...); err != nil {
+			b.logger.WarnContext(ctx, "Could not follow stop orders", "ticker", ticker, "error", err)
+			lines = append(lines, "⚠️ Не удалось сохранить заявки для отслеживания, проверяйте их статус в /stop list.")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stopOrderAmount returns the money a stop order trades at its stop price,
+// with the accrued interest of bonds like the orders of trading.Propose
+func stopOrderAmount(instr *invest.Instrument, req invest.StopOrderRequest) float64 {
+	unit := instr.QuoteValue(req.StopPrice)
+	if instr.Type == "bond" {
+		unit += instr.AccruedInterest
+	}
+	return unit * float64(req.Lots*instr.Lot)
+}
+
+// proposeStopCancel asks to cancel a stop order
+func (b *Bot) proposeStopCancel(ctx context.Context, orderID string) {
+	question := fmt.Sprintf("Отменить стоп-заявку %s?", orderID)
+	err := b.askConfirmation(question, func() string {
+		reqCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+		if err := b.stopOrders.CancelStopOrder(reqCtx, orderID); err != nil {
+			b.logger.ErrorContext(ctx, "Failed to cancel stop order", "stop_order_id", orderID, "error", err)
+			return fmt.Sprintf("⚠️ Ошибка при отмене: %v", err)
+		}
+		b.logger.InfoContext(ctx, "Cancelled stop order", "stop_order_id", orderID)
+		return "❌ Стоп-заявка отменена."
+	})
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Error sending stop order cancellation", "error", err)
+	}
+}
+
+// SendStopAlert reports a stop-loss or take-profit level reached by the price
+func (b *Bot) SendStopAlert(alert stops.Alert) error {
+	return b.sendMessage(formatStopAlert(alert))
+}
+
+// SendStopOrderUpdate reports a followed stop order that was executed, cancelled or expired
+func (b *Bot) SendStopOrderUpdate(stop stops.Stop, order invest.StopOrder) error {
+	return b.sendMessage(formatStopOrderUpdate(stop.Ticker, order))
+}
+
+// formatStops renders the levels of the positions and the stop orders of the account
+func formatStops(items []stops.Stop, portfolio *invest.Portfolio, orders []invest.StopOrder, ordersErr error) string {
+	var sb strings.Builder
+	sb.WriteString("🛡 СТОП-УРОВНИ\n\n")
+	if len(items) == 0 {
+		sb.WriteString("Уровни не заданы. Например: /stop SBER loss 5%\n")
+	}
+	for _, s := range items {
+		pos := portfolio.FindPosition(s.Ticker)
+		sb.WriteString(fmt.Sprintf("• %s (%s)", s.Ticker, s.Name))
+		if pos == nil {
+			sb.WriteString(" — позиции нет, уровни не проверяются\n")
+			continue
+		}
+		sb.WriteString(fmt.Sprintf(": %.2f %s\n", pos.CurrentPrice, pos.Currency))
+		sb.WriteString(formatStopLevels(s, pos) + "\n")
+	}
+
+	if ordersErr != nil {
+		sb.WriteString(fmt.Sprintf("\n⚠️ Стоп-заявки брокера недоступны: %v\n", ordersErr))
+		return sb.String()
+	}
+	var active, triggered []invest.StopOrder
+	for _, o := range orders {
+		switch o.Status {
+		case invest.StopOrderActive:
+			active = append(active, o)
+		case invest.StopOrderExecuted:
+			triggered = append(triggered, o)
+		}
+	}
+	if len(active) > 0 {
+		sb.WriteString("\nАктивные стоп-заявки:\n")
+		for _, o := range active {
+			sb.WriteString(fmt.Sprintf("• %s %s: %d лот. по %.2f %s, ID %s\n", stopOrderTypeName(o.Type),
+				tickerOf(portfolio, o.FIGI), o.Lots, o.StopPrice, o.Currency, o.ID))
+		}
+	}
+	if len(triggered) > 0 {
+		sb.WriteString("\nСработали за 30 дней:\n")
+		for _, o := range triggered {
+			sb.WriteString(fmt.Sprintf("• %s %s: %d лот. по %.2f %s, %s\n", stopOrderTypeName(o.Type),
+				tickerOf(portfolio, o.FIGI), o.Lots, o.StopPrice, o.Currency, o.Activated.Format("02.01 15:04")))
+		}
+	}
+	return sb.String()
+}
+
+// formatStopLevels renders the levels of a position with the prices they stand for
+func formatStopLevels(s stops.Stop, pos *invest.Position) string {
+	var parts []string
+	if s.Loss != nil {
+		parts = append(parts, fmt.Sprintf("   🛑 стоп-лосс %s → %.2f", s.Loss, s.LossPrice(pos.AveragePrice)))
+	}
+	if s.Take != nil {
+		parts = append(parts, fmt.Sprintf("   🎯 тейк-профит %s → %.2f", s.Take, s.TakePrice(pos.AveragePrice)))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// formatStopOrderProposal renders the stop orders to confirm
+func formatStopOrderProposal(pos *invest.Position, instr *invest.Instrument, requests []invest.StopOrderRequest) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🛡 СТОП-ЗАЯВКИ %s (%s)\n\n", pos.Ticker, pos.Name))
+	unit := pos.Currency
+	if instr.Type == "bond" {
+		unit = "% номинала"
+	}
+	for _, req := range requests {
+		sb.WriteString(fmt.Sprintf("%s: продажа %d лот. × %d шт. по рынку при цене %.2f %s\n",
+			stopOrderTypeName(req.Type), req.Lots, instr.Lot, req.StopPrice, unit))
+	}
+	sb.WriteString(fmt.Sprintf("\nТекущая цена: %.2f %s. Заявки действуют до отмены.\n", pos.CurrentPrice, pos.Currency))
+	sb.WriteString("\nВыставить стоп-заявки?")
+	return sb.String()
+}
+
+// formatStopAlert renders a reached level
+func formatStopAlert(alert stops.Alert) string {
+	pos := alert.Position
+	text := fmt.Sprintf("🛑 %s: цена %.2f %s дошла до стоп-лосса %.2f (%s)",
+		alert.Stop.Ticker, alert.Price, pos.Currency, alert.Level, alert.Stop.Loss)
+	if alert.Side == stops.SideTake {
+		text = fmt.Sprintf("🎯 %s: цена %.2f %s дошла до тейк-профита %.2f (%s)",
+			alert.Stop.Ticker, alert.Price, pos.Currency, alert.Level, alert.Stop.Take)
+	}
+	if pos.AveragePrice > 0 {
+		text += fmt.Sprintf("\nСредняя цена %.2f, результат %+.1f%% на %g шт.",
+			pos.AveragePrice, (alert.Price/pos.AveragePrice-1)*100, pos.Quantity)
+	}
+	return text + "\nПродать: /trade " + alert.Stop.Ticker + ", изменить уровни: /stop list"
+}
+
+// formatStopOrderUpdate renders the final status of a followed stop order
+func formatStopOrderUpdate(ticker string, order invest.StopOrder) string {
+	var status string
+	switch order.Status {
+	case invest.StopOrderExecuted:
+		status = "✅ Стоп-заявка сработала"
+	case invest.StopOrderExpired:
+		status = "⌛ Стоп-заявка истекла"
+	default:
+		status = "❌ Стоп-заявка отменена"
+	}
+	return fmt.Sprintf("%s: %s %s, %d лот. по %.2f %s", status, stopOrderTypeName(order.Type), ticker,
+		order.Lots, order.StopPrice, order.Currency)
+}
+
+// stopOrderTypeName names a stop order type
+func stopOrderTypeName(t string) string {
+	if t == invest.TakeProfit {
+		return "Тейк-профит"
+	}
+	return "Стоп-лосс"
+}
+
+// tickerOf finds the ticker of a portfolio position by FIGI, falling back to the FIGI
+func tickerOf(portfolio *invest.Portfolio, figi string) string {
+	for _, pos := range portfolio.Positions {
+		if pos.FIGI == figi {
+			return pos.Ticker
+		}
+	}
+	return figi
+}
@@ -0,0 +1,88 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"invest-manager/internal/tax"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleTaxCommand estimates the income tax of the selected account for the year so far
+func (b *Bot) handleTaxCommand(ctx context.Context, message *tgbotapi.Message) {
+	if b.tax == nil {
+		b.sendMessage("Оценка налога недоступна.")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+
+		report, err := b.tax.Refresh(ctx, b.localNow())
+		if err != nil {
+			b.replyError(ctx, "Ошибка при оценке налога", err)
+			return
+		}
+		b.sendMessage(formatTax(report))
+	}()
+}
+
+// formatTax lays out the tax estimate for /tax
+func formatTax(report *tax.Report) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧾 НДФЛ ЗА %d\n\n", report.Year))
+	sb.WriteString(fmt.Sprintf("Прибыль по сделкам: %.2f\n", report.Gain))
+	if report.ExemptGain > 0 {
+		sb.WriteString(fmt.Sprintf("Освобождено льготой трёх лет: %.2f\n", report.ExemptGain))
+	}
+	if report.Fees > 0 {
+		sb.WriteString(fmt.Sprintf("Комиссии: %.2f\n", report.Fees))
+	}
+	sb.WriteString(fmt.Sprintf("Налоговая база: %.2f\n", report.Base))
+
+	if report.IIS {
+		sb.WriteString(fmt.Sprintf("Налог при закрытии ИИС: %.0f\n", report.Tax))
+		sb.WriteString(fmt.Sprintf("\nПополнения ИИС: %.0f", report.Contributed))
+		if report.ContributionLimit > 0 {
+			sb.WriteString(fmt.Sprintf(" из %.0f (%.0f%%)", report.ContributionLimit, report.Contributed/report.ContributionLimit*100))
+		}
+		sb.WriteString(fmt.Sprintf("\nВычет типа А: %.0f", report.Deduction))
+		if left := report.DeductionBase - report.Contributed; left > 0 {
+			sb.WriteString(fmt.Sprintf(", пополните ещё на %.0f для полного вычета", left))
+		}
+		sb.WriteString("\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Налог: %.0f, удержано брокером: %.0f\n", report.Tax, report.Withheld))
+		if report.Due >= 0 {
+			sb.WriteString(fmt.Sprintf("Брокер удержит в конце года или при выводе: %.0f\n", report.Due))
+		} else {
+			sb.WriteString(fmt.Sprintf("Брокер вернёт переплату: %.0f\n", -report.Due))
+		}
+	}
+
+	if len(report.SoonExempt) > 0 {
+		sb.WriteString("\n⏳ Скоро без налога:\n")
+		for _, lot := range report.SoonExempt {
+			sb.WriteString(fmt.Sprintf("%s %g шт.: с %s, экономия %.0f на прибыли %.0f\n",
+				lot.Ticker, lot.Quantity, lot.Exempt.Format("02.01.2006"), lot.Saving, lot.Gain))
+		}
+	}
+	if len(report.Harvest) > 0 {
+		sb.WriteString(fmt.Sprintf("\n✂️ Продажа в убыток до конца года сэкономит %.0f:\n", report.HarvestSave))
+		for _, h := range report.Harvest {
+			sb.WriteString(fmt.Sprintf("%s %g шт.: убыток %.0f\n", h.Ticker, h.Quantity, h.Loss))
+		}
+		sb.WriteString("Бумаги можно сразу купить обратно.\n")
+	}
+
+	if report.Unmatched > 0 {
+		sb.WriteString(fmt.Sprintf("\n⚠️ Продаж без покупок в истории: %d, они не учтены.", report.Unmatched))
+	}
+	if report.Skipped > 0 {
+		sb.WriteString(fmt.Sprintf("\n⚠️ Операций в валюте: %d, они не учтены.", report.Skipped))
+	}
+	sb.WriteString("\nЭто оценка: точную сумму рассчитывает брокер.")
+	return sb.String()
+}
@@ -0,0 +1,303 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/monitoring"
+	"invest-manager/internal/trading"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Order tracking settings
+const (
+	orderBookDepth     = 1
+	orderPollInterval  = 15 * time.Second
+	orderTrackTimeout  = 8 * time.Hour // roughly a trading session; later the app has the status
+	orderStatusTimeout = 10 * time.Second
+)
+
+// handleTradeCommand proposes an order for a recommendation of the latest report.
+// Without a ticker it lists the BUY and SELL recommendations to pick from.
+func (b *Bot) handleTradeCommand(ctx context.Context, message *tgbotapi.Message) {
+	if !b.tradingSettings().Enabled {
+		b.sendMessage("Торговля отключена. Включите trading.enabled в конфигурации.")
+		return
+	}
+
+	ticker := strings.TrimSpace(message.CommandArguments())
+	if ticker != "" {
+		go b.proposeTrade(ctx, ticker)
+		return
+	}
+
+	recs := b.tradableRecommendations()
+	if len(recs) == 0 {
+		b.sendMessage("В последнем отчёте нет рекомендаций на покупку или продажу. Запустите /analyze.")
+		return
+	}
+	keyboard := b.tradeKeyboard(recs)
+	if err := b.sendWithKeyboard("Выберите рекомендацию для заявки:", &keyboard); err != nil {
+		b.logger.ErrorContext(ctx, "Error sending trade picker", "error", err)
+	}
+}
+
+// tradingSettings returns the current trading configuration
+func (b *Bot) tradingSettings() config.TradingConfig {
+	b.settingsMu.RLock()
+	defer b.settingsMu.RUnlock()
+	return b.trading
+}
+
+// tradableRecommendations returns the BUY and SELL recommendations of the latest report
+func (b *Bot) tradableRecommendations() []analysis.Recommendation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.lastAnalysis == nil {
+		return nil
+	}
+	var recs []analysis.Recommendation
+	for _, rec := range b.lastAnalysis.Recommendations {
+		switch strings.ToUpper(rec.Action) {
+		case invest.OrderBuy, invest.OrderSell:
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+// proposeTrade turns the recommendation for a ticker into an order proposal and asks to confirm it
+func (b *Bot) proposeTrade(ctx context.Context, ticker string) {
+	reqCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	limits := b.tradingSettings()
+	rec := b.lastRecommendation(ticker)
+	if rec == nil {
+		b.sendMessage(fmt.Sprintf("Нет рекомендации по %s в последнем отчёте. Запустите /analyze.", strings.ToUpper(ticker)))
+		return
+	}
+
+	portfolio, err := b.investor.GetPortfolio(reqCtx)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при получении портфеля", err)
+		return
+	}
+	// Watched instruments can be bought without a position
+	pos := portfolio.FindPosition(ticker)
+	figi := ""
+	if pos != nil {
+		figi = pos.FIGI
+	} else if b.watchlist != nil {
+		if watched := b.watchlist.Find(ticker); watched != nil {
+			figi = watched.FIGI
+		}
+	}
+	if figi == "" {
+		b.sendMessage(fmt.Sprintf("%s нет ни в портфеле, ни в списке наблюдения.", strings.ToUpper(ticker)))
+		return
+	}
+	instr, err := b.investor.GetInstrument(reqCtx, figi)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при получении инструмента", err)
+		return
+	}
+	book, err := b.trader.GetOrderBook(reqCtx, instr.UID, orderBookDepth)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при получении стакана", err)
+		return
+	}
+
+	proposal, err := trading.Propose(*rec, pos, instr, book, limits)
+	if err != nil {
+		b.sendMessage(fmt.Sprintf("⚠️ Заявку составить нельзя: %v", err))
+		return
+	}
+	traded, err := b.tradedToday(reqCtx)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при проверке дневного лимита", err)
+		return
+	}
+	if err := trading.CheckLimits(proposal, traded, limits); err != nil {
+		b.sendMessage(fmt.Sprintf("⛔ Заявка превышает лимит: %v", err))
+		return
+	}
+
+	proposal.ID = newOrderID()
+	question := formatProposal(proposal, limits, traded)
+	if err := b.askConfirmation(question, func() string { return b.placeOrder(ctx, proposal) }); err != nil {
+		b.logger.ErrorContext(ctx, "Error sending order proposal", "error", err)
+	}
+}
+
+// placeOrder places a confirmed proposal after checking the limits again,
+// since the settings and the trades of the day may have changed meanwhile
+func (b *Bot) placeOrder(ctx context.Context, p *trading.Proposal) string {
+	limits := b.tradingSettings()
+	if !limits.Enabled {
+		return "⛔ Торговля отключена в конфигурации."
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	// two confirmations at once must not both pass the daily limit
+	b.orderMu.Lock()
+	defer b.orderMu.Unlock()
+
+	traded, err := b.tradedToday(reqCtx)
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Could not check the daily trading limit", "error", err)
+		return fmt.Sprintf("⚠️ Не удалось проверить дневной лимит: %v", err)
+	}
+	if err := trading.CheckLimits(p, traded, limits); err != nil {
+		return fmt.Sprintf("⛔ Заявка превышает лимит: %v", err)
+	}
+
+	state, err := b.trader.PostOrder(reqCtx, p.Request())
+	if err != nil {
+		b.logger.ErrorContext(ctx, "Failed to place order", "ticker", p.Ticker, "direction", p.Direction, "error", err)
+		monitoring.ObserveOrder(p.Direction, "error")
+		return fmt.Sprintf("⚠️ Ошибка при выставлении заявки: %v", err)
+	}
+	b.logger.InfoContext(ctx, "Placed order", "order_id", state.ID, "ticker", p.Ticker,
+		"direction", p.Direction, "lots", p.Lots, "price", p.Price, "status", state.Status)
+
+	if state.Status.Final() {
+		monitoring.ObserveOrder(p.Direction, string(state.Status))
+		return formatOrderState(p, state)
+	}
+	b.pendingOrders.Add(state.ID, p.Amount)
+	b.wg.Add(1)
+	go b.trackOrder(ctx, p, state)
+	return formatOrderState(p, state) + "\nСледующие изменения статуса пришлю сообщением."
+}
+
+// trackOrder polls an open order and reports every change until it is final
+func (b *Bot) trackOrder(ctx context.Context, p *trading.Proposal, last *invest.OrderState) {
+	defer b.wg.Done()
+	defer b.pendingOrders.Remove(last.ID)
+
+	ticker := time.NewTicker(orderPollInterval)
+	defer ticker.Stop()
+	deadline := time.After(orderTrackTimeout)
+
+	for {
+		select {
+		case <-b.stopChan:
+			b.logger.InfoContext(ctx, "Stopped tracking order", "order_id", last.ID)
+			return
+		case <-deadline:
+			b.sendMessage(fmt.Sprintf("⏳ Заявка по %s всё ещё активна, дальнейший статус смотрите в приложении брокера.", p.Ticker))
+			return
+		case <-ticker.C:
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, orderStatusTimeout)
+		state, err := b.trader.GetOrderState(reqCtx, last.ID)
+		cancel()
+		if err != nil {
+			b.logger.WarnContext(ctx, "Could not get order state", "order_id", last.ID, "error", err)
+			continue
+		}
+		if state.Status == last.Status && state.LotsExecuted == last.LotsExecuted {
+			continue
+		}
+
+		last = state
+		b.sendMessage(formatOrderState(p, state))
+		if state.Status.Final() {
+			b.logger.InfoContext(ctx, "Order finished", "order_id", state.ID, "status", state.Status,
+				"lots_executed", state.LotsExecuted)
+			monitoring.ObserveOrder(p.Direction, string(state.Status))
+			return
+		}
+	}
+}
+
+// tradedToday returns the amount traded since midnight, including orders still open
+func (b *Bot) tradedToday(ctx context.Context) (float64, error) {
+	now := b.localNow()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	traded, err := b.trader.GetTradedAmount(ctx, midnight)
+	if err != nil {
+		return 0, err
+	}
+	return traded + b.pendingOrders.Total(), nil
+}
+
+// newOrderID generates a random UUID used as the idempotency key of an order
+func newOrderID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand never fails on supported platforms, fall back to time just in case
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	buf[6] = buf[6]&0x0f | 0x40 // version 4
+	buf[8] = buf[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16])
+}
+
+// formatProposal renders an order proposal with the limits it is checked against
+func formatProposal(p *trading.Proposal, limits config.TradingConfig, traded float64) string {
+	var sb strings.Builder
+
+	side, priceSource := "ПОКУПКА", "лучшая цена продажи в стакане"
+	if p.Direction == invest.OrderSell {
+		side, priceSource = "ПРОДАЖА", "лучшая цена покупки в стакане"
+	}
+	sb.WriteString(fmt.Sprintf("%s %s %s (%s)\n\n", actionEmoji(p.Direction), side, p.Ticker, p.Name))
+	sb.WriteString(fmt.Sprintf("Количество: %d лот. × %d шт. = %d шт.\n", p.Lots, p.LotSize, p.Quantity()))
+	sb.WriteString(fmt.Sprintf("Лимитная цена: %.2f RUB (%s)\n", p.Price, priceSource))
+	sb.WriteString(fmt.Sprintf("Сумма: %.2f RUB\n", p.Amount))
+	sb.WriteString(fmt.Sprintf("Комиссия (оценка): %.2f RUB\n", p.Commission))
+	if p.Direction == invest.OrderSell {
+		sb.WriteString(fmt.Sprintf("К получению: %.2f RUB\n", p.Amount-p.Commission))
+	} else {
+		sb.WriteString(fmt.Sprintf("Итого к списанию: %.2f RUB\n", p.Amount+p.Commission))
+	}
+	if p.Reason != "" {
+		sb.WriteString("\n🤖 " + p.Reason + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("\nЛимиты: заявка до %.2f RUB, за день до %.2f RUB (использовано %.2f RUB)\n",
+		limits.MaxOrderAmount, limits.MaxDailyAmount, traded))
+	sb.WriteString("\nВыставить лимитную заявку?")
+	return sb.String()
+}
+
+// formatOrderState renders the status of a placed order
+func formatOrderState(p *trading.Proposal, state *invest.OrderState) string {
+	var status string
+	switch state.Status {
+	case invest.OrderFilled:
+		status = "✅ Заявка исполнена"
+	case invest.OrderPartiallyFilled:
+		status = "⏳ Заявка исполнена частично"
+	case invest.OrderRejected:
+		status = "⛔ Заявка отклонена"
+	case invest.OrderCancelled:
+		status = "❌ Заявка отменена"
+	default:
+		status = "📝 Заявка выставлена"
+	}
+
+	side := "покупка"
+	if p.Direction == invest.OrderSell {
+		side = "продажа"
+	}
+	text := fmt.Sprintf("%s: %s %s, исполнено %d из %d лот.", status, side, p.Ticker, state.LotsExecuted, state.LotsRequested)
+	if amount := state.ExecutedAmount(p.LotSize); amount > 0 {
+		text += fmt.Sprintf("\nСумма сделки: %.2f RUB, комиссия: %.2f RUB", amount, state.Commission)
+	}
+	if state.Message != "" {
+		text += "\n" + state.Message
+	}
+	return text
+}
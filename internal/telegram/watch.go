@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/watchlist"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// watchUsage explains the /watch subcommands
+const watchUsage = "Используйте: /watch add SBER, /watch remove SBER или /watch list"
+
+// handleWatchCommand manages the instruments analyzed alongside the portfolio
+func (b *Bot) handleWatchCommand(ctx context.Context, message *tgbotapi.Message) {
+	if b.watchlist == nil {
+		b.sendMessage("Список наблюдения недоступен.")
+		return
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	if sub != "list" && len(args) < 2 {
+		b.sendMessage(watchUsage)
+		return
+	}
+
+	switch sub {
+	case "add":
+		go b.addWatched(ctx, args[1])
+	case "remove", "rm":
+		ticker := strings.ToUpper(args[1])
+		if err := b.watchlist.Remove(ticker); err != nil {
+			if errors.Is(err, watchlist.ErrNotWatched) {
+				b.sendMessage(fmt.Sprintf("%s нет в списке наблюдения.", ticker))
+				return
+			}
+			b.replyError(ctx, "Ошибка при изменении списка наблюдения", err)
+			return
+		}
+		b.sendMessage(fmt.Sprintf("%s удалён из списка наблюдения.", ticker))
+	case "list":
+		go b.showWatchlist(ctx)
+	default:
+		b.sendMessage(watchUsage)
+	}
+}
+
+// addWatched resolves a ticker and adds the instrument to the watchlist
+func (b *Bot) addWatched(ctx context.Context, ticker string) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	instr, err := b.watchlist.Add(ctx, ticker)
+	switch {
+	case errors.Is(err, invest.ErrInstrumentNotFound):
+		b.sendMessage(fmt.Sprintf("Инструмент %s не найден.", strings.ToUpper(ticker)))
+	case errors.Is(err, watchlist.ErrWatched):
+		b.sendMessage(fmt.Sprintf("%s уже в списке наблюдения.", strings.ToUpper(ticker)))
+	case errors.Is(err, watchlist.ErrFull):
+		b.sendMessage("Список наблюдения заполнен, удалите что-нибудь: /watch remove TICKER")
+	case err != nil:
+		b.replyError(ctx, "Ошибка при добавлении в список наблюдения", err)
+	default:
+		text := fmt.Sprintf("👀 %s (%s) добавлен в список наблюдения и попадёт в следующий анализ.", instr.Ticker, instr.Name)
+		if !instr.Tradable {
+			text += "\n⚠️ Инструмент недоступен для торговли через API."
+		}
+		b.sendMessage(text)
+	}
+}
+
+// showWatchlist lists the watched instruments with their last prices and recommendations
+func (b *Bot) showWatchlist(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	quotes, err := b.watchlist.Quotes(ctx)
+	if err != nil {
+		b.replyError(ctx, "Ошибка при получении цен", err)
+		return
+	}
+
+	recs := make(map[string]*analysis.Recommendation, len(quotes))
+	for _, q := range quotes {
+		recs[q.Ticker] = b.lastRecommendation(q.Ticker)
+	}
+	b.sendMessage(formatWatchlist(quotes, recs))
+}
+
+// formatWatchlist renders the watched instruments with the recommendations of the latest report
+func formatWatchlist(quotes []invest.Quote, recs map[string]*analysis.Recommendation) string {
+	if len(quotes) == 0 {
+		return "Список наблюдения пуст. Добавьте инструмент: /watch add SBER"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("👀 СПИСОК НАБЛЮДЕНИЯ\n\n")
+	for _, q := range quotes {
+		sb.WriteString(fmt.Sprintf("• %s (%s)", q.Ticker, q.Name))
+		if q.Price > 0 {
+			sb.WriteString(fmt.Sprintf(": %.2f %s", q.Price, q.Currency))
+		}
+		if rec := recs[q.Ticker]; rec != nil {
+			sb.WriteString(fmt.Sprintf(" %s %s", actionEmoji(rec.Action), rec.Action))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,185 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invest-manager/internal/config"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// secretTokenHeader carries the webhook secret in every request from Telegram
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookShutdownTimeout limits how long in-flight webhook requests may take on shutdown
+const webhookShutdownTimeout = 5 * time.Second
+
+// webhookHandler receives updates posted by Telegram
+type webhookHandler struct {
+	secret  string
+	updates chan<- tgbotapi.Update
+	logger  *slog.Logger
+}
+
+// newWebhookHandler creates a handler that verifies the secret token and forwards updates
+func newWebhookHandler(secret string, updates chan<- tgbotapi.Update, logger *slog.Logger) *webhookHandler {
+	return &webhookHandler{
+		secret:  secret,
+		updates: updates,
+		logger:  logger,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) != 1 {
+		h.logger.Warn("Rejected webhook request: invalid secret token", "remote_addr", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update); err != nil {
+		h.logger.Warn("Rejected webhook request: invalid update", "error", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case h.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-r.Context().Done():
+		// Telegram will retry the update later
+		http.Error(w, "timeout", http.StatusServiceUnavailable)
+	}
+}
+
+// webhookServer serves the webhook endpoint and manages webhook registration
+type webhookServer struct {
+	api     *tgbotapi.BotAPI
+	cfg     config.WebhookConfig
+	logger  *slog.Logger
+	server  *http.Server
+	updates chan tgbotapi.Update
+}
+
+// newWebhookServer prepares the listener for the configured public URL
+func newWebhookServer(api *tgbotapi.BotAPI, cfg config.WebhookConfig, logger *slog.Logger) (*webhookServer, error) {
+	publicURL, err := url.Parse(cfg.PublicURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	path := publicURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	updates := make(chan tgbotapi.Update, api.Buffer)
+	mux := http.NewServeMux()
+	mux.Handle(path, newWebhookHandler(cfg.Secret.Value(), updates, logger))
+
+	return &webhookServer{
+		api:    api,
+		cfg:    cfg,
+		logger: logger,
+		server: &http.Server{
+			Addr:              cfg.ListenAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		updates: updates,
+	}, nil
+}
+
+// Start begins listening and registers the webhook with Telegram. The port is bound and
+// the certificate loaded first, so the webhook is not registered if either fails.
+func (s *webhookServer) Start() (tgbotapi.UpdatesChannel, error) {
+	if s.cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.cfg.CertFile, s.cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load webhook certificate: %w", err)
+		}
+		s.server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	listener, err := net.Listen("tcp", s.cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for webhook: %w", err)
+	}
+
+	go func() {
+		var err error
+		if s.server.TLSConfig != nil {
+			err = s.server.ServeTLS(listener, "", "")
+		} else {
+			err = s.server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Webhook listener stopped", "error", err)
+		}
+	}()
+
+	if err := s.register(); err != nil {
+		s.shutdown()
+		return nil, err
+	}
+
+	s.logger.Info("Webhook listener started", "addr", s.cfg.ListenAddr)
+	return s.updates, nil
+}
+
+// Stop shuts the listener down and removes the webhook from Telegram
+func (s *webhookServer) Stop() {
+	s.shutdown()
+	if _, err := s.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		s.logger.Warn("Failed to delete webhook", "error", err)
+	}
+}
+
+// shutdown stops the HTTP listener waiting for in-flight requests
+func (s *webhookServer) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.Error("Failed to shut down webhook listener", "error", err)
+	}
+}
+
+// register calls setWebhook. The library does not support secret tokens,
+// so the request is built by hand.
+func (s *webhookServer) register() error {
+	params := tgbotapi.Params{
+		"url":          s.cfg.PublicURL,
+		"secret_token": s.cfg.Secret.Value(),
+	}
+	if err := params.AddInterface("allowed_updates", []string{"message", "callback_query"}); err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+
+	var err error
+	if s.cfg.CertFile != "" {
+		// Upload the certificate so self-signed ones are accepted by Telegram
+		files := []tgbotapi.RequestFile{{Name: "certificate", Data: tgbotapi.FilePath(s.cfg.CertFile)}}
+		_, err = s.api.UploadFiles("setWebhook", params, files)
+	} else {
+		_, err = s.api.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set webhook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package telegram
+
+import (
+	"invest-manager/internal/config"
+	"invest-manager/internal/logging"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const testSecret = "s3cr3t"
+
+func TestWebhookHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		secret     string
+		body       string
+		wantStatus int
+		wantUpdate bool
+	}{
+		{
+			name:       "valid update",
+			method:     http.MethodPost,
+			secret:     testSecret,
+			body:       `{"update_id": 42, "message": {"message_id": 1, "chat": {"id": 100}, "text": "/portfolio", "entities": [{"type": "bot_command", "offset": 0, "length": 10}]}}`,
+			wantStatus: http.StatusOK,
+			wantUpdate: true,
+		},
+		{
+			name:       "missing secret",
+			method:     http.MethodPost,
+			body:       `{"update_id": 42}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong secret",
+			method:     http.MethodPost,
+			secret:     "guess",
+			body:       `{"update_id": 42}`,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid json",
+			method:     http.MethodPost,
+			secret:     testSecret,
+			body:       `{"update_id":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			secret:     testSecret,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates := make(chan tgbotapi.Update, 1)
+			server := httptest.NewServer(newWebhookHandler(testSecret, updates, logging.Discard()))
+			defer server.Close()
+
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.secret != "" {
+				req.Header.Set(secretTokenHeader, tt.secret)
+			}
+
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			select {
+			case update := <-updates:
+				if !tt.wantUpdate {
+					t.Fatalf("unexpected update %+v", update)
+				}
+				if update.UpdateID != 42 || update.Message == nil || update.Message.Command() != "portfolio" {
+					t.Errorf("unexpected update content: %+v", update)
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.wantUpdate {
+					t.Fatal("update was not delivered")
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookStartFailsBeforeRegistering(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	tests := []struct {
+		name string
+		cfg  config.WebhookConfig
+	}{
+		{"port taken", config.WebhookConfig{ListenAddr: taken.Addr().String()}},
+		{"missing certificate", config.WebhookConfig{ListenAddr: "127.0.0.1:0", CertFile: "testdata/missing.pem", KeyFile: "testdata/missing.key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No Bot API: reaching setWebhook would panic
+			s := &webhookServer{cfg: tt.cfg, logger: logging.Discard(), server: &http.Server{}}
+			if _, err := s.Start(); err == nil {
+				t.Fatal("Start() succeeded")
+			}
+		})
+	}
+}
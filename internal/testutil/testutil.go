@@ -0,0 +1,40 @@
+// Package testutil builds the dates, candles and operations the tests of the components
+// that compute from the broker history share.
+package testutil
+
+import (
+	"invest-manager/internal/invest"
+	"math"
+	"time"
+)
+
+// Date returns the hour of a day in UTC
+func Date(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+// AlmostEqual reports whether two amounts differ by no more than float errors
+func AlmostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// Candles returns one daily candle per close, the first at from
+func Candles(from time.Time, closes ...float64) []invest.Candle {
+	result := make([]invest.Candle, len(closes))
+	for i, c := range closes {
+		result[i] = invest.Candle{Time: from.AddDate(0, 0, i), Close: c}
+	}
+	return result
+}
+
+// Buy returns a purchase of shares paid in rubles
+func Buy(at time.Time, figi string, quantity, price float64) invest.Operation {
+	return invest.Operation{Time: at, Type: invest.OperationBuy, FIGI: figi, InstrumentType: "share",
+		Quantity: quantity, Price: price, Payment: -quantity * price, Currency: "rub"}
+}
+
+// Sell returns a sale of shares paid in rubles
+func Sell(at time.Time, figi string, quantity, price float64) invest.Operation {
+	return invest.Operation{Time: at, Type: invest.OperationSell, FIGI: figi, InstrumentType: "share",
+		Quantity: quantity, Price: price, Payment: quantity * price, Currency: "rub"}
+}
@@ -0,0 +1,176 @@
+// Package trading turns recommendations into concrete order proposals and
+// enforces the per-order and per-day limits before orders are placed.
+package trading
+
+import (
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"math"
+	"strings"
+	"sync"
+)
+
+// Proposal is a limit order derived from a recommendation, waiting for confirmation
+type Proposal struct {
+	ID           string // idempotency key of the order, so a repeated confirmation places it once
+	Ticker       string
+	Name         string
+	InstrumentID string
+	Direction    string // invest.OrderBuy or invest.OrderSell
+	Lots         int64
+	LotSize      int64   // units per lot
+	Price        float64 // limit price per unit as quoted, the best opposite quote of the order book
+	Amount       float64 // money paid or received for all units without commission, with the accrued interest of bonds
+	Commission   float64 // estimated from the configured rate
+	Reason       string
+}
+
+// Quantity returns the number of units in the order
+func (p *Proposal) Quantity() int64 {
+	return p.Lots * p.LotSize
+}
+
+// Request returns the order to place for the proposal
+func (p *Proposal) Request() invest.OrderRequest {
+	return invest.OrderRequest{
+		ID:           p.ID,
+		InstrumentID: p.InstrumentID,
+		Direction:    p.Direction,
+		Lots:         p.Lots,
+		Price:        p.Price,
+	}
+}
+
+// Propose sizes an order for a BUY or SELL recommendation on a held position.
+// Buys aim for the configured order amount, sells offer the configured share
+// of the position; both are cut down to the per-order limit. Bonds are quoted
+// in percent of their nominal, so they are sized by the nominal plus the
+// accrued interest; other instruments than shares, ETFs and bonds are refused.
+func Propose(rec analysis.Recommendation, pos *invest.Position, instr *invest.Instrument,
+	book *invest.OrderBook, cfg config.TradingConfig) (*Proposal, error) {
+	direction := strings.ToUpper(rec.Action)
+	if direction != invest.OrderBuy && direction != invest.OrderSell {
+		return nil, fmt.Errorf("only BUY and SELL recommendations can be traded, %s is %s", rec.Ticker, rec.Action)
+	}
+	if instr.Type != "share" && instr.Type != "etf" && instr.Type != "bond" {
+		return nil, fmt.Errorf("%s is a %s, only shares, ETFs and bonds can be traded", instr.Ticker, instr.Type)
+	}
+	if instr.Type == "bond" && instr.Nominal <= 0 {
+		return nil, fmt.Errorf("the nominal of %s is unknown, so its price cannot be valued", instr.Ticker)
+	}
+	if instr.Currency != "RUB" {
+		return nil, fmt.Errorf("%s is traded in %s, only RUB instruments are supported", instr.Ticker, instr.Currency)
+	}
+	if !instr.Tradable || (direction == invest.OrderBuy && !instr.Buyable) || (direction == invest.OrderSell && !instr.Sellable) {
+		return nil, fmt.Errorf("%s is not available for %s orders through the API", instr.Ticker, strings.ToLower(direction))
+	}
+	if instr.Lot <= 0 {
+		return nil, fmt.Errorf("%s has no lot size", instr.Ticker)
+	}
+
+	// Buy at the best ask and sell at the best bid, so the order can fill right away
+	quotes := book.Asks
+	if direction == invest.OrderSell {
+		quotes = book.Bids
+	}
+	if len(quotes) == 0 || quotes[0].Price <= 0 {
+		return nil, fmt.Errorf("the order book of %s is empty, the market may be closed", instr.Ticker)
+	}
+	price := quotes[0].Price
+	unitAmount := instr.QuoteValue(price)
+	if instr.Type == "bond" {
+		unitAmount += instr.AccruedInterest
+	}
+	lotAmount := unitAmount * float64(instr.Lot)
+
+	var lots int64
+	if direction == invest.OrderBuy {
+		lots = max(int64(cfg.OrderAmount/lotAmount), 1)
+	} else {
+		var held int64
+		if pos != nil {
+			held = int64(pos.Quantity) / instr.Lot
+		}
+		if held == 0 {
+			return nil, fmt.Errorf("the position in %s is smaller than one lot of %d", instr.Ticker, instr.Lot)
+		}
+		lots = max(int64(math.Floor(float64(held)*cfg.SellShare)), 1)
+	}
+	lots = min(lots, int64(cfg.MaxOrderAmount/lotAmount))
+	if lots == 0 {
+		return nil, fmt.Errorf("one lot of %s costs %.2f, more than the order limit of %.2f", instr.Ticker, lotAmount, cfg.MaxOrderAmount)
+	}
+
+	amount := float64(lots) * lotAmount
+	return &Proposal{
+		Ticker:       instr.Ticker,
+		Name:         instr.Name,
+		InstrumentID: instr.UID,
+		Direction:    direction,
+		Lots:         lots,
+		LotSize:      instr.Lot,
+		Price:        price,
+		Amount:       amount,
+		Commission:   amount * cfg.CommissionRate,
+		Reason:       rec.Reason,
+	}, nil
+}
+
+// CheckLimits rejects a proposal that exceeds the per-order limit, or the daily
+// limit together with the amount already traded today
+func CheckLimits(p *Proposal, tradedToday float64, cfg config.TradingConfig) error {
+	if err := CheckOrderLimit(p.Amount, cfg); err != nil {
+		return err
+	}
+	if tradedToday+p.Amount > cfg.MaxDailyAmount {
+		return fmt.Errorf("the order of %.2f exceeds the daily limit of %.2f, %.2f is already traded today",
+			p.Amount, cfg.MaxDailyAmount, tradedToday)
+	}
+	return nil
+}
+
+// CheckOrderLimit rejects an order amount over the per-order limit. Stop orders are
+// checked only against it, since the day they are executed on is not known.
+func CheckOrderLimit(amount float64, cfg config.TradingConfig) error {
+	if amount > cfg.MaxOrderAmount {
+		return fmt.Errorf("the order of %.2f exceeds the order limit of %.2f", amount, cfg.MaxOrderAmount)
+	}
+	return nil
+}
+
+// Pending keeps the amounts of placed orders that have not reached a final status.
+// They count against the daily limit until the broker reports them as executed trades.
+type Pending struct {
+	mu      sync.Mutex
+	amounts map[string]float64 // by order ID
+}
+
+// Add counts an open order
+func (p *Pending) Add(orderID string, amount float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.amounts == nil {
+		p.amounts = make(map[string]float64)
+	}
+	p.amounts[orderID] = amount
+}
+
+// Remove stops counting an order once it is final
+func (p *Pending) Remove(orderID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.amounts, orderID)
+}
+
+// Total returns the amount of all open orders
+func (p *Pending) Total() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var total float64
+	for _, amount := range p.amounts {
+		total += amount
+	}
+	return total
+}
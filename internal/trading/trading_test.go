@@ -0,0 +1,172 @@
+package trading
+
+import (
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"math"
+	"testing"
+)
+
+var testLimits = config.TradingConfig{
+	Enabled:        true,
+	OrderAmount:    10000,
+	SellShare:      0.5,
+	MaxOrderAmount: 20000,
+	MaxDailyAmount: 30000,
+	CommissionRate: 0.003,
+}
+
+func TestPropose(t *testing.T) {
+	sber := &invest.Instrument{FIGI: "BBG004730N88", UID: "sber-uid", Ticker: "SBER", Name: "Сбербанк",
+		Type: "share", Currency: "RUB", Lot: 10, Tradable: true, Buyable: true, Sellable: true}
+	book := &invest.OrderBook{
+		Bids: []invest.OrderBookLevel{{Price: 299.5, Lots: 100}},
+		Asks: []invest.OrderBookLevel{{Price: 300, Lots: 50}},
+	}
+
+	tests := []struct {
+		name      string
+		action    string
+		quantity  float64
+		instr     func(i *invest.Instrument)
+		book      *invest.OrderBook
+		limits    func(c *config.TradingConfig)
+		wantLots  int64
+		wantPrice float64
+		wantErr   bool
+	}{
+		{name: "buy aims for the order amount at the ask", action: "BUY", wantLots: 3, wantPrice: 300},
+		{name: "sell offers a share of the position at the bid", action: "SELL", quantity: 100, wantLots: 5, wantPrice: 299.5},
+		{name: "sell of a single lot", action: "SELL", quantity: 10, wantLots: 1, wantPrice: 299.5},
+		{name: "lowercase action", action: "buy", wantLots: 3, wantPrice: 300},
+		{
+			name: "buy is cut to the order limit", action: "BUY",
+			limits:   func(c *config.TradingConfig) { c.OrderAmount = 100000 },
+			wantLots: 6, wantPrice: 300,
+		},
+		{
+			name: "lot above the order amount buys one lot", action: "BUY",
+			limits:   func(c *config.TradingConfig) { c.OrderAmount = 1000 },
+			wantLots: 1, wantPrice: 300,
+		},
+		{
+			name: "sell is cut to the order limit", action: "SELL", quantity: 2000,
+			wantLots: 6, wantPrice: 299.5,
+		},
+		{
+			name: "lot above the order limit", action: "BUY",
+			limits:  func(c *config.TradingConfig) { c.MaxOrderAmount = 2000 },
+			wantErr: true,
+		},
+		{name: "hold cannot be traded", action: "HOLD", wantErr: true},
+		{name: "position below one lot", action: "SELL", quantity: 5, wantErr: true},
+		{name: "empty order book", action: "BUY", book: &invest.OrderBook{}, wantErr: true},
+		{name: "foreign currency", action: "BUY", instr: func(i *invest.Instrument) { i.Currency = "USD" }, wantErr: true},
+		{name: "buying not available", action: "BUY", instr: func(i *invest.Instrument) { i.Buyable = false }, wantErr: true},
+		{name: "no API trading", action: "SELL", quantity: 100, instr: func(i *invest.Instrument) { i.Tradable = false }, wantErr: true},
+		{name: "futures are refused", action: "BUY", instr: func(i *invest.Instrument) { i.Type = "futures" }, wantErr: true},
+		{name: "bond without a nominal", action: "BUY", instr: func(i *invest.Instrument) { i.Type = "bond" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instr := *sber
+			if tt.instr != nil {
+				tt.instr(&instr)
+			}
+			limits := testLimits
+			if tt.limits != nil {
+				tt.limits(&limits)
+			}
+			orderBook := book
+			if tt.book != nil {
+				orderBook = tt.book
+			}
+			pos := &invest.Position{FIGI: sber.FIGI, Ticker: "SBER", Quantity: tt.quantity}
+			rec := analysis.Recommendation{Ticker: "SBER", Action: tt.action, Reason: "because"}
+
+			p, err := Propose(rec, pos, &instr, orderBook, limits)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Propose: %v", err)
+			}
+			if p.Lots != tt.wantLots || p.Price != tt.wantPrice {
+				t.Errorf("proposal = %d lots at %g, want %d lots at %g", p.Lots, p.Price, tt.wantLots, tt.wantPrice)
+			}
+			wantAmount := float64(tt.wantLots*sber.Lot) * tt.wantPrice
+			if math.Abs(p.Amount-wantAmount) > 1e-9 || math.Abs(p.Commission-wantAmount*0.003) > 1e-9 {
+				t.Errorf("amount = %g, commission = %g, want %g", p.Amount, p.Commission, wantAmount)
+			}
+			if p.Amount > limits.MaxOrderAmount {
+				t.Errorf("amount %g exceeds the order limit", p.Amount)
+			}
+			if req := p.Request(); req.InstrumentID != "sber-uid" || req.Lots != p.Lots {
+				t.Errorf("request = %+v", req)
+			}
+		})
+	}
+}
+
+func TestProposeBond(t *testing.T) {
+	ofz := &invest.Instrument{UID: "ofz-uid", Ticker: "SU26238RMFS4", Type: "bond", Currency: "RUB", Lot: 1,
+		Nominal: 1000, AccruedInterest: 15, Tradable: true, Buyable: true, Sellable: true}
+	book := &invest.OrderBook{Asks: []invest.OrderBookLevel{{Price: 98.5, Lots: 100}}}
+
+	// 985 for the price and 15 of accrued interest: 10 bonds for the order amount
+	p, err := Propose(analysis.Recommendation{Ticker: "SU26238RMFS4", Action: "BUY"}, nil, ofz, book, testLimits)
+	if err != nil {
+		t.Fatalf("Propose: %v", err)
+	}
+	if p.Lots != 10 || p.Price != 98.5 || math.Abs(p.Amount-10000) > 1e-9 {
+		t.Errorf("proposal = %d lots at %g for %g, want 10 lots at 98.5 for 10000", p.Lots, p.Price, p.Amount)
+	}
+
+	limits := testLimits
+	limits.MaxOrderAmount = 5000
+	limits.OrderAmount = 100000
+	if p, err := Propose(analysis.Recommendation{Ticker: "SU26238RMFS4", Action: "BUY"}, nil, ofz, book, limits); err != nil || p.Lots != 5 {
+		t.Errorf("bond order under a limit of 5000 = %+v, %v; want 5 lots", p, err)
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  float64
+		traded  float64
+		wantErr bool
+	}{
+		{name: "within limits", amount: 9000, traded: 10000},
+		{name: "exactly the daily limit", amount: 10000, traded: 20000},
+		{name: "over the order limit", amount: 25000, wantErr: true},
+		{name: "over the daily limit", amount: 9000, traded: 25000, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckLimits(&Proposal{Amount: tt.amount}, tt.traded, testLimits)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckLimits = %v, want error = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPending(t *testing.T) {
+	var p Pending
+	p.Add("a", 1000)
+	p.Add("b", 500)
+	p.Add("a", 1000) // the same order counts once
+	if got := p.Total(); got != 1500 {
+		t.Errorf("Total = %g, want 1500", got)
+	}
+	p.Remove("a")
+	if got := p.Total(); got != 500 {
+		t.Errorf("Total after Remove = %g, want 500", got)
+	}
+}
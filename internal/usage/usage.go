@@ -0,0 +1,285 @@
+// Package usage accounts for the tokens spent on LLM requests and their cost.
+// Every request is recorded with the job and user that caused it, and the
+// totals of the current day and month are checked against the budget.
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invest-manager/internal/atomicfile"
+	"invest-manager/internal/config"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record is the usage of a single LLM request
+type Record struct {
+	Time             time.Time `json:"time"`
+	Job              string    `json:"job"`
+	User             string    `json:"user,omitempty"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"` // USD, 0 if the model has no price
+}
+
+// Cost computes the cost of a request in USD from the price table.
+// It reports false if the model has no price.
+func Cost(prices map[string]config.ModelPrice, model string, promptTokens, completionTokens int) (float64, bool) {
+	price, ok := prices[model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6, true
+}
+
+// Totals sums up a set of records
+type Totals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Tokens returns the prompt and completion tokens together
+func (t Totals) Tokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+// add counts a record in the totals
+func (t *Totals) add(r Record) {
+	t.Calls++
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.Cost += r.Cost
+}
+
+// Summary is the usage since a point in time, overall and broken down by job, model and user
+type Summary struct {
+	From    time.Time         `json:"from"`
+	Total   Totals            `json:"total"`
+	ByJob   map[string]Totals `json:"by_job"`
+	ByModel map[string]Totals `json:"by_model"`
+	ByUser  map[string]Totals `json:"by_user"`
+}
+
+// Report is the usage of the current day and month with the budget they are limited by
+type Report struct {
+	Today  Summary             `json:"today"`
+	Month  Summary             `json:"month"`
+	Budget config.BudgetConfig `json:"budget"`
+}
+
+// Ledger keeps the usage records in memory and appends them to a file,
+// so the totals of the month survive restarts. Only the records of the
+// current month are kept, as the budget does not look further back.
+type Ledger struct {
+	path   string
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	records []Record
+}
+
+// Open reads the records of the usage file, which is created on the first request.
+// Records of past months are dropped and the file is rewritten without them.
+// With an empty path the records are only kept in memory.
+func Open(path string, logger *slog.Logger) (*Ledger, error) {
+	l := &Ledger{path: path, logger: logger}
+	if path == "" {
+		return l, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// A line cut short by a crash must not lose the rest of the month
+			logger.Warn("Skipping invalid usage record", "path", path, "line", line, "error", err)
+			continue
+		}
+		l.records = append(l.records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage file: %w", err)
+	}
+	file.Close()
+
+	if len(l.records) > 0 && l.prune(l.records[len(l.records)-1].Time) {
+		if err := rewriteRecords(path, l.records); err != nil {
+			return nil, fmt.Errorf("failed to rotate usage file: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// prune drops the records made before the month of now, reporting whether there were any
+func (l *Ledger) prune(now time.Time) bool {
+	cutoff := retainedFrom(now)
+	kept := l.records[:0]
+	for _, r := range l.records {
+		if !r.Time.Before(cutoff) {
+			kept = append(kept, r)
+		}
+	}
+	pruned := len(kept) < len(l.records)
+	l.records = kept
+	return pruned
+}
+
+// retainedFrom returns the time records are kept from: the start of the month of now in UTC
+// less a day, so the month is complete in whatever time zone the reports are made in
+func retainedFrom(now time.Time) time.Time {
+	return startOfMonth(now.UTC()).AddDate(0, 0, -1)
+}
+
+// Add records a request. A failure to write the file is logged,
+// the record is still counted for the running process.
+func (l *Ledger) Add(r Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(r.Time)
+	l.records = append(l.records, r)
+	if l.path == "" {
+		return
+	}
+	if err := appendRecord(l.path, r); err != nil {
+		l.logger.Error("Failed to save usage record", "path", l.path, "error", err)
+	}
+}
+
+// appendRecord writes a record as a line of JSON
+func appendRecord(path string, r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// rewriteRecords replaces the file with the given records
+func rewriteRecords(path string, records []Record) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	return atomicfile.Write(path, buf.Bytes())
+}
+
+// Summary sums up the records from the given time on
+func (l *Ledger) Summary(from time.Time) Summary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s := Summary{
+		From:    from,
+		ByJob:   make(map[string]Totals),
+		ByModel: make(map[string]Totals),
+		ByUser:  make(map[string]Totals),
+	}
+	for _, r := range l.records {
+		if r.Time.Before(from) {
+			continue
+		}
+		s.Total.add(r)
+		addTo(s.ByJob, r.Job, r)
+		addTo(s.ByModel, r.Model, r)
+		if r.User != "" {
+			addTo(s.ByUser, r.User, r)
+		}
+	}
+	return s
+}
+
+// addTo counts a record in the totals of a key
+func addTo(m map[string]Totals, key string, r Record) {
+	t := m[key]
+	t.add(r)
+	m[key] = t
+}
+
+// Report returns the usage of the day and month of now, in the time zone of now
+func (l *Ledger) Report(budget config.BudgetConfig, now time.Time) Report {
+	return Report{
+		Today:  l.Summary(startOfDay(now)),
+		Month:  l.Summary(startOfMonth(now)),
+		Budget: budget,
+	}
+}
+
+// OverBudget returns why the budget is exhausted, or an empty string if it is not
+func (l *Ledger) OverBudget(budget config.BudgetConfig, now time.Time) string {
+	if budget.Daily > 0 {
+		if spent := l.Summary(startOfDay(now)).Total.Cost; spent >= budget.Daily {
+			return fmt.Sprintf("daily budget of $%.2f reached ($%.2f spent)", budget.Daily, spent)
+		}
+	}
+	if budget.Monthly > 0 {
+		if spent := l.Summary(startOfMonth(now)).Total.Cost; spent >= budget.Monthly {
+			return fmt.Sprintf("monthly budget of $%.2f reached ($%.2f spent)", budget.Monthly, spent)
+		}
+	}
+	return ""
+}
+
+// startOfDay returns midnight of the day of t
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfMonth returns midnight of the first day of the month of t
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// Tags tell what caused an LLM request
+type Tags struct {
+	Job  string // e.g. scheduled, manual or command
+	User string // Telegram user for commands, empty for jobs
+}
+
+// tagsKey is the context key of the tags
+type tagsKey struct{}
+
+// WithTags returns a context whose LLM requests are recorded with the job and user
+func WithTags(ctx context.Context, job, user string) context.Context {
+	return context.WithValue(ctx, tagsKey{}, Tags{Job: job, User: user})
+}
+
+// TagsFrom returns the tags of a context; the job is "unknown" if none were set
+func TagsFrom(ctx context.Context) Tags {
+	tags, ok := ctx.Value(tagsKey{}).(Tags)
+	if !ok {
+		return Tags{Job: "unknown"}
+	}
+	return tags
+}
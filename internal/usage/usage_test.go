@@ -0,0 +1,164 @@
+package usage
+
+import (
+	"context"
+	"invest-manager/internal/config"
+	"invest-manager/internal/logging"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testPrices = map[string]config.ModelPrice{
+	"gpt-4o":      {Prompt: 2.5, Completion: 10},
+	"gpt-4o-mini": {Prompt: 0.15, Completion: 0.6},
+}
+
+func TestCost(t *testing.T) {
+	tests := []struct {
+		model            string
+		prompt, complete int
+		want             float64
+		wantPriced       bool
+	}{
+		{"gpt-4o", 1_000_000, 0, 2.5, true},
+		{"gpt-4o", 2000, 500, 0.01, true},
+		{"gpt-4o-mini", 1_000_000, 1_000_000, 0.75, true},
+		{"o3", 1000, 1000, 0, false},
+	}
+	for _, tt := range tests {
+		got, priced := Cost(testPrices, tt.model, tt.prompt, tt.complete)
+		if priced != tt.wantPriced || got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("Cost(%s, %d, %d) = %g, %v, want %g, %v", tt.model, tt.prompt, tt.complete, got, priced, tt.want, tt.wantPriced)
+		}
+	}
+}
+
+func TestLedgerPersistsRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	ledger, err := Open(path, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger.Add(Record{Time: now, Job: "scheduled", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 200, Cost: 0.0045})
+	ledger.Add(Record{Time: now, Job: "command", User: "alice", Model: "gpt-4o-mini", PromptTokens: 500, CompletionTokens: 100, Cost: 0.0001})
+
+	// A line cut short by a crash is skipped
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"time":"2026-10-18T`)
+	file.Close()
+
+	reopened, err := Open(path, logging.Discard())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	s := reopened.Summary(now.Add(-time.Hour))
+	if s.Total.Calls != 2 || s.Total.Tokens() != 1800 {
+		t.Errorf("total = %+v, want 2 calls of 1800 tokens", s.Total)
+	}
+	if s.ByJob["command"].Calls != 1 || s.ByModel["gpt-4o"].PromptTokens != 1000 || s.ByUser["alice"].Calls != 1 {
+		t.Errorf("breakdown = %+v %+v %+v", s.ByJob, s.ByModel, s.ByUser)
+	}
+	if _, ok := s.ByUser[""]; ok {
+		t.Error("jobs without a user are counted as a user")
+	}
+}
+
+func TestLedgerDropsPastMonths(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	ledger, err := Open(path, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger.Add(Record{Time: now.AddDate(0, -2, 0), Job: "scheduled", Cost: 1})
+	// The last evening of September is already October in Moscow
+	ledger.Add(Record{Time: time.Date(2026, 9, 30, 22, 0, 0, 0, time.UTC), Job: "scheduled", Cost: 2})
+	ledger.Add(Record{Time: now, Job: "scheduled", Cost: 3})
+	if s := ledger.Summary(time.Time{}); s.Total.Calls != 2 {
+		t.Errorf("%d records kept in memory, want 2", s.Total.Calls)
+	}
+
+	reopened, err := Open(path, logging.Discard())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if s := reopened.Summary(time.Time{}); s.Total.Calls != 2 || s.Total.Cost != 5 {
+		t.Errorf("reopened = %+v, want the records of October", s.Total)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("file has %d records after rotation, want 2", lines)
+	}
+}
+
+func TestOverBudget(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		records []Record
+		budget  config.BudgetConfig
+		want    bool
+	}{
+		{
+			name:    "no limits",
+			records: []Record{{Time: now, Cost: 100}},
+		},
+		{
+			name:    "daily limit reached",
+			records: []Record{{Time: now.Add(-time.Hour), Cost: 0.6}, {Time: now, Cost: 0.4}},
+			budget:  config.BudgetConfig{Daily: 1},
+			want:    true,
+		},
+		{
+			name:    "spending of yesterday does not count for the day",
+			records: []Record{{Time: now.AddDate(0, 0, -1), Cost: 5}, {Time: now, Cost: 0.5}},
+			budget:  config.BudgetConfig{Daily: 1},
+		},
+		{
+			name:    "monthly limit reached",
+			records: []Record{{Time: now.AddDate(0, 0, -10), Cost: 15}, {Time: now.AddDate(0, 0, -1), Cost: 5}},
+			budget:  config.BudgetConfig{Daily: 1, Monthly: 20},
+			want:    true,
+		},
+		{
+			name:    "spending of last month does not count",
+			records: []Record{{Time: now.AddDate(0, -1, 0), Cost: 50}},
+			budget:  config.BudgetConfig{Monthly: 20},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger, _ := Open("", logging.Discard())
+			for _, r := range tt.records {
+				ledger.Add(r)
+			}
+			if got := ledger.OverBudget(tt.budget, now); (got != "") != tt.want {
+				t.Errorf("OverBudget = %q, want exceeded = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	if got := TagsFrom(context.Background()); got.Job != "unknown" {
+		t.Errorf("tags without WithTags = %+v", got)
+	}
+	ctx := WithTags(context.Background(), "command", "alice")
+	if got := TagsFrom(ctx); got != (Tags{Job: "command", User: "alice"}) {
+		t.Errorf("TagsFrom = %+v", got)
+	}
+}
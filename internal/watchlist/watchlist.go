@@ -0,0 +1,301 @@
+// Package watchlist keeps the instruments analyzed alongside the portfolio
+// without being held, and checks that suggested opportunities can be traded.
+package watchlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/atomicfile"
+	"invest-manager/internal/config"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/news"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Errors of watchlist changes
+var (
+	ErrWatched    = errors.New("already in the watchlist")
+	ErrNotWatched = errors.New("not in the watchlist")
+	ErrFull       = errors.New("the watchlist is full")
+)
+
+// Instruments resolves tickers and prices instruments; *invest.Client is the production one
+type Instruments interface {
+	FindInstrument(ctx context.Context, ticker string) (*invest.Instrument, error)
+	GetLastPrices(ctx context.Context, figis []string) (map[string]float64, error)
+}
+
+// NewsSource searches news about an instrument; *news.Fetcher is the production one
+type NewsSource interface {
+	FetchNews(query string, limit int) ([]news.Article, error)
+}
+
+// List is the watchlist. It is safe for concurrent use.
+type List struct {
+	path        string
+	instruments Instruments
+	logger      *slog.Logger
+
+	mu       sync.Mutex
+	settings config.WatchlistConfig
+	items    []invest.Instrument
+}
+
+// Open reads the watchlist from its file, which is created by the first change.
+// With an empty path the watchlist is only kept in memory.
+func Open(path string, settings config.WatchlistConfig, instruments Instruments, logger *slog.Logger) (*List, error) {
+	l := &List{path: path, instruments: instruments, logger: logger, settings: settings}
+	if path == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watchlist: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.items); err != nil {
+		return nil, fmt.Errorf("failed to parse watchlist %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Reload applies the limits of a new configuration; instruments above a lowered limit are kept
+func (l *List) Reload(settings config.WatchlistConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.settings = settings
+}
+
+// Items returns the watched instruments in the order they were added
+func (l *List) Items() []invest.Instrument {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]invest.Instrument(nil), l.items...)
+}
+
+// Find returns a watched instrument by ticker (case-insensitive), nil if it is not watched
+func (l *List) Find(ticker string) *invest.Instrument {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i := l.index(ticker); i >= 0 {
+		instr := l.items[i]
+		return &instr
+	}
+	return nil
+}
+
+// Add resolves a ticker through the instruments service and watches the instrument
+func (l *List) Add(ctx context.Context, ticker string) (*invest.Instrument, error) {
+	ticker = strings.TrimSpace(ticker)
+	l.mu.Lock()
+	watched, full := l.index(ticker) >= 0, len(l.items) >= l.settings.MaxItems
+	l.mu.Unlock()
+	if watched {
+		return nil, fmt.Errorf("%s is %w", strings.ToUpper(ticker), ErrWatched)
+	}
+	if full {
+		return nil, ErrFull
+	}
+
+	// The lookup talks to the broker, so it runs without the lock and the checks are repeated afterwards
+	instr, err := l.instruments.FindInstrument(ctx, ticker)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.index(instr.Ticker) >= 0 {
+		return nil, fmt.Errorf("%s is %w", instr.Ticker, ErrWatched)
+	}
+	if len(l.items) >= l.settings.MaxItems {
+		return nil, ErrFull
+	}
+	l.items = append(l.items, *instr)
+	if err := l.save(); err != nil {
+		l.items = l.items[:len(l.items)-1]
+		return nil, err
+	}
+	return instr, nil
+}
+
+// Remove stops watching an instrument
+func (l *List) Remove(ticker string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := l.index(strings.TrimSpace(ticker))
+	if i < 0 {
+		return fmt.Errorf("%s is %w", strings.ToUpper(ticker), ErrNotWatched)
+	}
+	items := append(append([]invest.Instrument(nil), l.items[:i]...), l.items[i+1:]...)
+	previous := l.items
+	l.items = items
+	if err := l.save(); err != nil {
+		l.items = previous
+		return err
+	}
+	return nil
+}
+
+// Quotes returns the last prices of the watched instruments per unit, in the order they were
+// added; bonds are quoted in percent of the nominal, which is converted. Instruments without a
+// price are included with a zero price.
+func (l *List) Quotes(ctx context.Context) ([]invest.Quote, error) {
+	items := l.Items()
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	figis := make([]string, 0, len(items))
+	for _, instr := range items {
+		figis = append(figis, instr.FIGI)
+	}
+	prices, err := l.instruments.GetLastPrices(ctx, figis)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]invest.Quote, 0, len(items))
+	for _, instr := range items {
+		quotes = append(quotes, invest.Quote{
+			FIGI:     instr.FIGI,
+			Ticker:   instr.Ticker,
+			Name:     instr.Name,
+			Type:     instr.Type,
+			Currency: instr.Currency,
+			Price:    instr.QuoteValue(prices[instr.FIGI]),
+		})
+	}
+	return quotes, nil
+}
+
+// Include adds the watched instruments that are not held to the portfolio, so they are
+// analyzed alongside the positions, and returns the articles extended with their news.
+// Failures are logged: the analysis goes on without the missing data.
+func (l *List) Include(ctx context.Context, portfolio *invest.Portfolio, articles []news.Article, source NewsSource) []news.Article {
+	quotes, err := l.Quotes(ctx)
+	if err != nil {
+		l.logger.WarnContext(ctx, "Failed to get watchlist prices, continuing without the watchlist", "error", err)
+		return articles
+	}
+
+	l.mu.Lock()
+	newsLimit := l.settings.NewsLimit
+	l.mu.Unlock()
+
+	seen := make(map[string]bool, len(articles))
+	for _, article := range articles {
+		seen[article.URL] = true
+	}
+	for _, q := range quotes {
+		if portfolio.FindPosition(q.Ticker) != nil {
+			continue
+		}
+		portfolio.Watched = append(portfolio.Watched, q)
+
+		if newsLimit == 0 || source == nil {
+			continue
+		}
+		found, err := source.FetchNews(newsQuery(q), newsLimit)
+		if err != nil {
+			l.logger.WarnContext(ctx, "Failed to fetch news about a watched instrument", "ticker", q.Ticker, "error", err)
+			continue
+		}
+		for _, article := range found {
+			if !seen[article.URL] {
+				seen[article.URL] = true
+				articles = append(articles, article)
+			}
+		}
+	}
+	return articles
+}
+
+// ValidateOpportunities keeps the opportunities that can be acted on: the ticker must
+// resolve to an instrument available for API trading, LONG ideas must be buyable and
+// SHORT ideas must allow short selling. Names are replaced with the official ones.
+// Only tickers the broker does not know are dropped; if the broker cannot be asked,
+// the opportunity is kept and flagged as unverified.
+func (l *List) ValidateOpportunities(ctx context.Context, result *analysis.PortfolioAnalysis) {
+	valid := make([]analysis.Recommendation, 0, len(result.Opportunities))
+	for _, opp := range result.Opportunities {
+		instr, err := l.instruments.FindInstrument(ctx, opp.Ticker)
+		if errors.Is(err, invest.ErrInstrumentNotFound) {
+			l.logger.InfoContext(ctx, "Dropped an opportunity", "ticker", opp.Ticker, "reason", err)
+			continue
+		}
+		if err != nil {
+			l.logger.WarnContext(ctx, "Could not verify an opportunity, keeping it", "ticker", opp.Ticker, "error", err)
+			opp.Unverified = true
+			valid = append(valid, opp)
+			continue
+		}
+		if reason := untradable(instr, opp.Action); reason != "" {
+			l.logger.InfoContext(ctx, "Dropped an opportunity", "ticker", opp.Ticker, "reason", reason)
+			continue
+		}
+		opp.Ticker, opp.Name = instr.Ticker, instr.Name
+		valid = append(valid, opp)
+	}
+	result.Opportunities = valid
+}
+
+// untradable explains why an opportunity cannot be acted on, empty if it can
+func untradable(instr *invest.Instrument, action string) string {
+	switch {
+	case !instr.Tradable:
+		return "not available for API trading"
+	case strings.EqualFold(strings.TrimSpace(action), "SHORT"):
+		if !instr.Shortable || !instr.Sellable {
+			return "short selling is not available"
+		}
+	case !instr.Buyable:
+		return "buying is not available"
+	}
+	return ""
+}
+
+// newsQuery builds a news search query for an instrument
+func newsQuery(q invest.Quote) string {
+	if q.Name == "" {
+		return q.Ticker
+	}
+	return fmt.Sprintf("\"%s\" OR %s", q.Name, q.Ticker)
+}
+
+// index finds a watched instrument by ticker; the caller must hold the lock
+func (l *List) index(ticker string) int {
+	for i := range l.items {
+		if strings.EqualFold(l.items[i].Ticker, ticker) {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the watchlist to its file.
+// The caller must hold the lock.
+func (l *List) save() error {
+	if l.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(l.items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watchlist: %w", err)
+	}
+	if err := atomicfile.Write(l.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to save watchlist: %w", err)
+	}
+	return nil
+}
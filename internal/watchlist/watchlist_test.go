@@ -0,0 +1,161 @@
+package watchlist
+
+import (
+	"context"
+	"errors"
+	"invest-manager/internal/analysis"
+	"invest-manager/internal/config"
+	"invest-manager/internal/fake"
+	"invest-manager/internal/invest"
+	"invest-manager/internal/logging"
+	"invest-manager/internal/news"
+	"path/filepath"
+	"testing"
+)
+
+var testSettings = config.WatchlistConfig{MaxItems: 2, NewsLimit: 1}
+
+// testBroker knows SBER, held, and LKOH and YDEX, which are not
+func testBroker() *fake.Broker {
+	return &fake.Broker{
+		Portfolio: &invest.Portfolio{Positions: []invest.Position{{FIGI: "sber", Ticker: "SBER", Name: "Сбербанк"}}},
+		Instruments: map[string]*invest.Instrument{
+			"sber": {FIGI: "sber", Ticker: "SBER", Name: "Сбербанк", Tradable: true, Buyable: true, Sellable: true, Shortable: true},
+			"lkoh": {FIGI: "lkoh", Ticker: "LKOH", Name: "Лукойл", Type: "share", Currency: "RUB", Tradable: true, Buyable: true, Sellable: true},
+			"ydex": {FIGI: "ydex", Ticker: "YDEX", Name: "Яндекс", Tradable: false},
+		},
+		LastPrices: map[string]float64{"sber": 300, "lkoh": 7000},
+	}
+}
+
+func TestAddRemoveAndPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watchlist.json")
+	ctx := context.Background()
+	l, err := Open(path, testSettings, testBroker(), logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instr, err := l.Add(ctx, "lkoh")
+	if err != nil || instr.FIGI != "lkoh" {
+		t.Fatalf("Add(lkoh) = %+v, %v", instr, err)
+	}
+	if _, err := l.Add(ctx, "LKOH"); !errors.Is(err, ErrWatched) {
+		t.Errorf("second Add error = %v, want ErrWatched", err)
+	}
+	if _, err := l.Add(ctx, "ABCD"); !errors.Is(err, invest.ErrInstrumentNotFound) {
+		t.Errorf("Add of an unknown ticker error = %v, want ErrInstrumentNotFound", err)
+	}
+	if _, err := l.Add(ctx, "SBER"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Add(ctx, "YDEX"); !errors.Is(err, ErrFull) {
+		t.Errorf("Add above the limit error = %v, want ErrFull", err)
+	}
+
+	reopened, err := Open(path, testSettings, testBroker(), logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := reopened.Items(); len(items) != 2 || items[0].Ticker != "LKOH" || items[1].Ticker != "SBER" {
+		t.Fatalf("reopened items = %+v", items)
+	}
+	if err := reopened.Remove("sber"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Remove("SBER"); !errors.Is(err, ErrNotWatched) {
+		t.Errorf("second Remove error = %v, want ErrNotWatched", err)
+	}
+	if reopened.Find("lkoh") == nil || reopened.Find("sber") != nil {
+		t.Errorf("items after Remove = %+v", reopened.Items())
+	}
+}
+
+func TestQuotesValueBondsByNominal(t *testing.T) {
+	broker := testBroker()
+	broker.Instruments["ofz"] = &invest.Instrument{FIGI: "ofz", Ticker: "SU26238", Type: "bond", Currency: "RUB", Nominal: 1000, Tradable: true}
+	broker.LastPrices["ofz"] = 61.5
+	l, _ := Open("", testSettings, broker, logging.Discard())
+	ctx := context.Background()
+	if _, err := l.Add(ctx, "SU26238"); err != nil {
+		t.Fatal(err)
+	}
+	l.Add(ctx, "LKOH")
+
+	quotes, err := l.Quotes(ctx)
+	if err != nil || len(quotes) != 2 {
+		t.Fatalf("quotes = %+v, %v", quotes, err)
+	}
+	if quotes[0].Price != 615 || quotes[1].Price != 7000 {
+		t.Errorf("prices = %v and %v, want 615 per bond and 7000 per share", quotes[0].Price, quotes[1].Price)
+	}
+}
+
+func TestIncludeAddsUnheldInstrumentsAndNews(t *testing.T) {
+	broker := testBroker()
+	l, _ := Open("", testSettings, broker, logging.Discard())
+	ctx := context.Background()
+	l.Add(ctx, "LKOH")
+	l.Add(ctx, "SBER")
+
+	source := &fake.News{Articles: []news.Article{{Title: "Лукойл", URL: "https://example.com/lkoh"}}}
+	portfolio, _ := broker.GetPortfolio(ctx)
+	articles := l.Include(ctx, portfolio, []news.Article{{URL: "https://example.com/market"}}, source)
+
+	if len(portfolio.Watched) != 1 || portfolio.Watched[0].Ticker != "LKOH" || portfolio.Watched[0].Price != 7000 {
+		t.Errorf("watched = %+v, want LKOH at 7000; SBER is held", portfolio.Watched)
+	}
+	if len(articles) != 2 {
+		t.Errorf("articles = %+v, want the market news and one about LKOH", articles)
+	}
+	if queries := source.Queries(); len(queries) != 1 || queries[0] != `"Лукойл" OR LKOH` {
+		t.Errorf("news queries = %q", queries)
+	}
+
+	// Without prices the analysis goes on as before
+	broker.Err = errors.New("unavailable")
+	portfolio = &invest.Portfolio{}
+	if got := l.Include(ctx, portfolio, nil, source); len(got) != 0 || len(portfolio.Watched) != 0 {
+		t.Errorf("Include with a failing broker changed the input: %+v, %+v", got, portfolio.Watched)
+	}
+}
+
+func TestValidateOpportunities(t *testing.T) {
+	l, _ := Open("", testSettings, testBroker(), logging.Discard())
+	result := &analysis.PortfolioAnalysis{Opportunities: []analysis.Recommendation{
+		{Ticker: "LKOH", Name: "Lukoil", Action: "LONG"},
+		{Ticker: "LKOH", Action: "SHORT"},   // no short selling
+		{Ticker: "YDEX", Action: "LONG"},    // not tradable through the API
+		{Ticker: "GOLD", Action: "LONG"},    // not a ticker at all
+		{Ticker: "sber", Action: " short "}, // shortable
+	}}
+
+	l.ValidateOpportunities(context.Background(), result)
+
+	want := []analysis.Recommendation{
+		{Ticker: "LKOH", Name: "Лукойл", Action: "LONG"},
+		{Ticker: "SBER", Name: "Сбербанк", Action: " short "},
+	}
+	if len(result.Opportunities) != len(want) {
+		t.Fatalf("opportunities = %+v, want %+v", result.Opportunities, want)
+	}
+	for i := range want {
+		if result.Opportunities[i] != want[i] {
+			t.Errorf("opportunity %d = %+v, want %+v", i, result.Opportunities[i], want[i])
+		}
+	}
+}
+
+func TestValidateOpportunitiesKeepsUnverified(t *testing.T) {
+	broker := testBroker()
+	broker.Err = errors.New("unavailable")
+	l, _ := Open("", testSettings, broker, logging.Discard())
+	result := &analysis.PortfolioAnalysis{Opportunities: []analysis.Recommendation{{Ticker: "LKOH", Name: "Lukoil", Action: "LONG"}}}
+
+	l.ValidateOpportunities(context.Background(), result)
+
+	want := analysis.Recommendation{Ticker: "LKOH", Name: "Lukoil", Action: "LONG", Unverified: true}
+	if len(result.Opportunities) != 1 || result.Opportunities[0] != want {
+		t.Errorf("opportunities = %+v, want %+v", result.Opportunities, want)
+	}
+}